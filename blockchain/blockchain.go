@@ -31,6 +31,8 @@ type Blockchain struct {
 	tokens       []common.Token
 	tokenIndices map[string]tbindex
 	nonce        NonceCorpus
+	// events watched on the reserve and pricing contracts
+	events []contractEvents
 }
 
 func (self *Blockchain) AddToken(t common.Token) {
@@ -62,9 +64,9 @@ func (self *Blockchain) LoadAndSetTokenIndices() error {
 	return nil
 }
 
-func (self *Blockchain) CurrentBlock() (uint64, error) {
+func (self *Blockchain) CurrentBlock(ctx context.Context) (uint64, error) {
 	var blockno string
-	err := self.rpcClient.Call(&blockno, "eth_blockNumber")
+	err := self.rpcClient.CallContext(ctx, &blockno, "eth_blockNumber")
 	if err != nil {
 		return 0, err
 	}
@@ -72,9 +74,8 @@ func (self *Blockchain) CurrentBlock() (uint64, error) {
 	return result, err
 }
 
-func (self *Blockchain) IsMined(tx ethereum.Hash) (bool, error) {
-	option := context.Background()
-	receipt, err := self.client.TransactionReceipt(option, tx)
+func (self *Blockchain) IsMined(ctx context.Context, tx ethereum.Hash) (bool, error) {
+	receipt, err := self.client.TransactionReceipt(ctx, tx)
 	if receipt != nil {
 		err = nil
 	}
@@ -100,14 +101,14 @@ func (self *Blockchain) getTransactOpts() (*bind.TransactOpts, error) {
 	}
 }
 
-func (self *Blockchain) FetchBalanceData(reserve ethereum.Address, timepoint uint64) (map[string]common.BalanceEntry, error) {
+func (self *Blockchain) FetchBalanceData(ctx context.Context, reserve ethereum.Address, timepoint uint64) (map[string]common.BalanceEntry, error) {
 	result := map[string]common.BalanceEntry{}
 	tokens := []ethereum.Address{}
 	for _, tok := range self.tokens {
 		tokens = append(tokens, ethereum.HexToAddress(tok.Address))
 	}
 	timestamp := common.GetTimestamp()
-	balances, err := self.wrapper.GetBalances(nil, reserve, tokens)
+	balances, err := self.wrapper.GetBalances(&bind.CallOpts{Context: ctx}, reserve, tokens)
 	returnTime := common.GetTimestamp()
 	log.Printf("Fetcher ------> balances: %v, err: %s", balances, err)
 	if err != nil {
@@ -132,7 +133,7 @@ func (self *Blockchain) FetchBalanceData(reserve ethereum.Address, timepoint uin
 	return result, nil
 }
 
-func (self *Blockchain) FetchRates(timepoint uint64) (common.AllRateEntry, error) {
+func (self *Blockchain) FetchRates(ctx context.Context, timepoint uint64) (common.AllRateEntry, error) {
 	result := common.AllRateEntry{}
 	tokenAddrs := []ethereum.Address{}
 	for _, s := range self.tokens {
@@ -140,7 +141,7 @@ func (self *Blockchain) FetchRates(timepoint uint64) (common.AllRateEntry, error
 	}
 	timestamp := common.GetTimestamp()
 	baseBuys, baseSells, compactBuys, compactSells, blocks, err := self.wrapper.GetTokenRates(
-		nil, self.pricingAddr, tokenAddrs,
+		&bind.CallOpts{Context: ctx}, self.pricingAddr, tokenAddrs,
 	)
 	returnTime := common.GetTimestamp()
 	result.Timestamp = timestamp
@@ -328,6 +329,14 @@ func NewBlockchain(
 	if err != nil {
 		return nil, err
 	}
+	reserveEvents, err := newContractEvents("reserve", ReserveContractABI, reserveAddr)
+	if err != nil {
+		return nil, err
+	}
+	pricingEvents, err := newContractEvents("pricing", PricingABI, pricingAddr)
+	if err != nil {
+		return nil, err
+	}
 	return &Blockchain{
 		rpcClient:   client,
 		client:      ethereum,
@@ -339,5 +348,6 @@ func NewBlockchain(
 		signer:      signer,
 		tokens:      []common.Token{},
 		nonce:       nonceCorpus,
+		events:      []contractEvents{reserveEvents, pricingEvents},
 	}, nil
 }
@@ -0,0 +1,157 @@
+package blockchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	goethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// kinds of the events watched on the reserve and pricing contracts. The
+// pricing contract logs no event when rates are set, rate updates are
+// the SetRate events of the reserve.
+var watchedEvents = map[string]string{
+	"DoTrade":       "trade",
+	"SetRate":       "set_rate",
+	"DepositToken":  "deposit",
+	"Withdraw":      "withdraw",
+	"WithdrawToken": "withdraw",
+	"WithdrawEther": "withdraw",
+}
+
+type contractEvents struct {
+	name    string
+	address ethereum.Address
+	events  map[ethereum.Hash]abi.Event
+}
+
+func newContractEvents(name, definition string, address ethereum.Address) (contractEvents, error) {
+	parsed, err := abi.JSON(strings.NewReader(definition))
+	if err != nil {
+		return contractEvents{}, err
+	}
+	result := contractEvents{name, address, map[ethereum.Hash]abi.Event{}}
+	for name, event := range parsed.Events {
+		if _, watched := watchedEvents[name]; watched {
+			result.events[event.Id()] = event
+		}
+	}
+	return result, nil
+}
+
+// decodeWord turns a 32 bytes abi word into a string
+func decodeWord(t abi.Type, word []byte) string {
+	switch t.T {
+	case abi.AddressTy:
+		return ethereum.BytesToAddress(word).Hex()
+	case abi.UintTy:
+		return new(big.Int).SetBytes(word).Text(10)
+	case abi.IntTy:
+		value := new(big.Int).SetBytes(word)
+		if word[0]&0x80 != 0 {
+			value.Sub(value, new(big.Int).Lsh(big.NewInt(1), 256))
+		}
+		return value.Text(10)
+	case abi.BoolTy:
+		return fmt.Sprintf("%t", word[31] == 1)
+	default:
+		return ethereum.ToHex(word)
+	}
+}
+
+// decodeEvent reads arguments of event from l. Indexed ones are in the
+// topics after the event id, the others are words of the data, watched
+// events have no dynamic arguments.
+func decodeEvent(event abi.Event, l types.Log) (map[string]string, error) {
+	params := map[string]string{}
+	topic := 1
+	offset := 0
+	for _, input := range event.Inputs {
+		if input.Indexed {
+			if topic >= len(l.Topics) {
+				return nil, errors.New(fmt.Sprintf("%s log has no topic for %s", event.Name, input.Name))
+			}
+			params[input.Name] = decodeWord(input.Type, l.Topics[topic].Bytes())
+			topic++
+		} else {
+			if offset+32 > len(l.Data) {
+				return nil, errors.New(fmt.Sprintf("%s log is too short for %s", event.Name, input.Name))
+			}
+			params[input.Name] = decodeWord(input.Type, l.Data[offset:offset+32])
+			offset += 32
+		}
+	}
+	return params, nil
+}
+
+// FetchEvents returns the watched events logged by the reserve and
+// pricing contracts from fromBlock to toBlock, both included, in the
+// order they were logged
+func (self *Blockchain) FetchEvents(ctx context.Context, fromBlock, toBlock uint64) ([]common.ReserveEvent, error) {
+	contracts := map[ethereum.Address]contractEvents{}
+	addresses := []ethereum.Address{}
+	ids := []ethereum.Hash{}
+	for _, contract := range self.events {
+		contracts[contract.address] = contract
+		addresses = append(addresses, contract.address)
+		for id := range contract.events {
+			ids = append(ids, id)
+		}
+	}
+	logs, err := self.client.FilterLogs(ctx, goethereum.FilterQuery{
+		FromBlock: new(big.Int).SetUint64(fromBlock),
+		ToBlock:   new(big.Int).SetUint64(toBlock),
+		Addresses: addresses,
+		Topics:    [][]ethereum.Hash{ids},
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := []common.ReserveEvent{}
+	timestamps := map[uint64]uint64{}
+	for _, l := range logs {
+		if l.Removed || len(l.Topics) == 0 {
+			continue
+		}
+		contract, found := contracts[l.Address]
+		if !found {
+			continue
+		}
+		event, found := contract.events[l.Topics[0]]
+		if !found {
+			continue
+		}
+		params, err := decodeEvent(event, l)
+		if err != nil {
+			return nil, err
+		}
+		timestamp, found := timestamps[l.BlockNumber]
+		if !found {
+			header, err := self.client.HeaderByNumber(ctx, new(big.Int).SetUint64(l.BlockNumber))
+			if err != nil {
+				return nil, err
+			}
+			timestamp = header.Time.Uint64() * 1000
+			timestamps[l.BlockNumber] = timestamp
+		}
+		result = append(result, common.ReserveEvent{
+			Kind:        watchedEvents[event.Name],
+			Name:        event.Name,
+			Contract:    contract.name,
+			BlockNumber: l.BlockNumber,
+			BlockHash:   l.BlockHash.Hex(),
+			TxHash:      l.TxHash.Hex(),
+			LogIndex:    l.Index,
+			Timestamp:   timestamp,
+			Params:      params,
+		})
+	}
+	return result, nil
+}
@@ -1,11 +1,19 @@
 package main
 
 import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+
 	"github.com/KyberNetwork/reserve-data/blockchain"
 	"github.com/KyberNetwork/reserve-data/common"
 	"github.com/KyberNetwork/reserve-data/core"
 	"github.com/KyberNetwork/reserve-data/data"
 	"github.com/KyberNetwork/reserve-data/data/fetcher"
+	"github.com/KyberNetwork/reserve-data/data/fetcher/replay"
+	"github.com/KyberNetwork/reserve-data/data/storage"
+	"github.com/KyberNetwork/reserve-data/exchange"
 	"github.com/KyberNetwork/reserve-data/http"
 	"github.com/KyberNetwork/reserve-data/metric"
 	ethereum "github.com/ethereum/go-ethereum/common"
@@ -20,6 +28,7 @@ type Config struct {
 	FetcherRunner    fetcher.FetcherRunner
 	FetcherExchanges []fetcher.Exchange
 	Exchanges        []common.Exchange
+	ExchangePool     *ExchangePool
 	BlockchainSigner blockchain.Signer
 
 	EnableAuthentication bool
@@ -41,3 +50,161 @@ func (self *Config) MapTokens() map[string]common.Token {
 	}
 	return result
 }
+
+// Storage is what keeps the data of the fetcher, core, metrics and the
+// bittrex deposit registry
+type Storage interface {
+	data.Storage
+	fetcher.Storage
+	core.ActivityStorage
+	metric.MetricStorage
+	exchange.BittrexStorage
+}
+
+// loadStorage connects to the postgres database in KYBER_POSTGRES, or
+// opens the bolt file at boltPath without it
+func loadStorage(boltPath string) Storage {
+	dsn := os.Getenv("KYBER_POSTGRES")
+	if dsn == "" {
+		result, err := storage.NewBoltStorage(boltPath)
+		if err != nil {
+			panic(err)
+		}
+		return result
+	}
+	result, err := storage.NewPostgresStorage(dsn)
+	if err != nil {
+		log.Fatalf("Postgres storage is not usable. Error: %s", err)
+	}
+	return result
+}
+
+// loadFreshnessRules reads freshness rules from the file in
+// KYBER_FRESHNESS_RULES, or uses the default ones
+func loadFreshnessRules() *common.FreshnessRules {
+	path := os.Getenv("KYBER_FRESHNESS_RULES")
+	if path == "" {
+		return common.DefaultFreshnessRules()
+	}
+	rules, err := common.GetFreshnessRulesFromFile(path)
+	if err != nil {
+		log.Fatalf("Freshness rules file %s is not usable. Error: %s", path, err)
+	}
+	return rules
+}
+
+// loadSchedules reads fetcher schedules from the file in
+// KYBER_SCHEDULES, or uses the default ones
+func loadSchedules() *common.Schedules {
+	path := os.Getenv("KYBER_SCHEDULES")
+	if path == "" {
+		return common.DefaultSchedules()
+	}
+	schedules, err := common.GetSchedulesFromFile(path)
+	if err != nil {
+		log.Fatalf("Schedules file %s is not usable. Error: %s", path, err)
+	}
+	return schedules
+}
+
+// loadRetentionPolicies reads retention policies from the file in
+// KYBER_RETENTION, or uses the default ones
+func loadRetentionPolicies() *common.RetentionPolicies {
+	path := os.Getenv("KYBER_RETENTION")
+	if path == "" {
+		return common.DefaultRetentionPolicies()
+	}
+	policies, err := common.GetRetentionPoliciesFromFile(path)
+	if err != nil {
+		log.Fatalf("Retention file %s is not usable. Error: %s", path, err)
+	}
+	return policies
+}
+
+// startCompactor prunes data storage in the background, it returns nil
+// for storage that can't be pruned
+func startCompactor(config *Config) *storage.Compactor {
+	pruning, ok := config.DataStorage.(storage.PruningStorage)
+	if !ok {
+		return nil
+	}
+	compactor := storage.NewCompactor(pruning, loadRetentionPolicies())
+	compactor.Run()
+	return compactor
+}
+
+// loadBookCarry reads in KYBER_BOOK_CARRY_MAX_AGE, in milliseconds, for
+// how long books of a failing exchange are carried forward. Books are
+// not carried without it.
+func loadBookCarry() time.Duration {
+	value := os.Getenv("KYBER_BOOK_CARRY_MAX_AGE")
+	if value == "" {
+		return 0
+	}
+	maxAge, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		log.Fatalf("KYBER_BOOK_CARRY_MAX_AGE %s is not a number of milliseconds", value)
+	}
+	return time.Duration(maxAge) * time.Millisecond
+}
+
+// loadEventStartBlock reads in KYBER_EVENTS_START_BLOCK the block
+// reserve events are first watched from, the current block without it
+func loadEventStartBlock() uint64 {
+	value := os.Getenv("KYBER_EVENTS_START_BLOCK")
+	if value == "" {
+		return 0
+	}
+	block, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		log.Fatalf("KYBER_EVENTS_START_BLOCK %s is not a block number", value)
+	}
+	return block
+}
+
+// loadRecorder opens the file in KYBER_RECORD to record fetcher
+// responses into, nothing is recorded without it
+func loadRecorder() *replay.Recorder {
+	path := os.Getenv("KYBER_RECORD")
+	if path == "" {
+		return nil
+	}
+	recorder, err := replay.NewRecorder(path)
+	if err != nil {
+		log.Fatalf("Recording file %s is not usable. Error: %s", path, err)
+	}
+	log.Printf("Recording fetcher responses to %s", path)
+	return recorder
+}
+
+// loadTape reads the recording in KYBER_REPLAY to replay instead of
+// fetching live data, nil without it. Replay is refused in production,
+// replayed data would be stored and served as live to core.
+func loadTape() *replay.Tape {
+	path := os.Getenv("KYBER_REPLAY")
+	if path == "" {
+		return nil
+	}
+	switch env := os.Getenv("KYBER_ENV"); env {
+	case "mainnet", "production":
+		log.Fatalf("Replaying %s is refused in %s mode, replay in a dev or simulation environment", path, env)
+	}
+	tape, err := replay.LoadTape(path)
+	if err != nil {
+		log.Fatalf("Recording file %s is not usable. Error: %s", path, err)
+	}
+	log.Printf("Replaying fetcher responses from %s", path)
+	return tape
+}
+
+// fetcherBlockchain returns what the fetcher reads the blockchain from,
+// bc itself, bc recorded by recorder or a replay of tape
+func fetcherBlockchain(bc fetcher.Blockchain, recorder *replay.Recorder, tape *replay.Tape) fetcher.Blockchain {
+	if tape != nil {
+		return replay.NewReplayBlockchain(tape)
+	}
+	if recorder != nil {
+		return replay.NewRecordingBlockchain(recorder, bc)
+	}
+	return bc
+}
@@ -1,123 +1,64 @@
 package main
 
 import (
+	"context"
+	"io"
+	"log"
 	"os"
 	"strings"
 
 	"github.com/KyberNetwork/reserve-data/common"
 	"github.com/KyberNetwork/reserve-data/data/fetcher"
 	"github.com/KyberNetwork/reserve-data/exchange"
-	"github.com/KyberNetwork/reserve-data/exchange/binance"
-	"github.com/KyberNetwork/reserve-data/exchange/bittrex"
-	"github.com/KyberNetwork/reserve-data/signer"
+	_ "github.com/KyberNetwork/reserve-data/exchange/all"
 )
 
 type ExchangePool struct {
 	Exchanges map[common.ExchangeID]interface{}
+	// cancel stops background refreshes of the exchanges
+	cancel context.CancelFunc
 }
 
-func NewSimulationExchangePool(
+// NewExchangePool builds exchanges listed in KYBER_EXCHANGES for env,
+// an exchange that can't be built is an error and stops the ones built
+// before it
+func NewExchangePool(
+	env string,
 	addressConfig common.AddressConfig,
-	signer *signer.FileSigner,
-	bittrexStorage exchange.BittrexStorage) *ExchangePool {
+	signer interface{},
+	storage interface{}) (*ExchangePool, error) {
 
-	exchanges := map[common.ExchangeID]interface{}{}
+	ctx, cancel := context.WithCancel(context.Background())
+	pool := &ExchangePool{map[common.ExchangeID]interface{}{}, cancel}
 	params := os.Getenv("KYBER_EXCHANGES")
 	exparams := strings.Split(params, ",")
 	for _, exparam := range exparams {
-		switch exparam {
-		case "bittrex":
-			bit := exchange.NewBittrex(bittrex.NewSimulatedBittrexEndpoint(signer), bittrexStorage)
-			for tokenID, addr := range addressConfig.Exchanges["bittrex"] {
-				bit.UpdateDepositAddress(common.MustGetToken(tokenID), addr)
-			}
-			bit.UpdatePairsPrecision()
-			exchanges[bit.ID()] = bit
-		case "binance":
-			bin := exchange.NewBinance(binance.NewSimulatedBinanceEndpoint(signer))
-			for tokenID, addr := range addressConfig.Exchanges["binance"] {
-				bin.UpdateDepositAddress(common.MustGetToken(tokenID), addr)
-			}
-			bin.UpdatePairsPrecision()
-			exchanges[bin.ID()] = bin
+		name := strings.TrimSpace(exparam)
+		if name == "" {
+			continue
 		}
-	}
-	return &ExchangePool{exchanges}
-}
-
-func NewDevExchangePool(addressConfig common.AddressConfig, signer *signer.FileSigner, bittrexStorage exchange.BittrexStorage) *ExchangePool {
-	exchanges := map[common.ExchangeID]interface{}{}
-	params := os.Getenv("KYBER_EXCHANGES")
-	exparams := strings.Split(params, ",")
-	for _, exparam := range exparams {
-		switch exparam {
-		case "bittrex":
-			bit := exchange.NewBittrex(bittrex.NewDevBittrexEndpoint(signer), bittrexStorage)
-			for tokenID, addr := range addressConfig.Exchanges["bittrex"] {
-				bit.UpdateDepositAddress(common.MustGetToken(tokenID), addr)
-			}
-			bit.UpdatePairsPrecision()
-			exchanges[bit.ID()] = bit
-		case "binance":
-			bin := exchange.NewBinance(binance.NewDevBinanceEndpoint(signer))
-			for tokenID, addr := range addressConfig.Exchanges["binance"] {
-				bin.UpdateDepositAddress(common.MustGetToken(tokenID), addr)
-			}
-			bin.UpdatePairsPrecision()
-			exchanges[bin.ID()] = bin
+		ex, err := exchange.NewExchange(
+			ctx, name, env, signer, storage, addressConfig.Exchanges[name])
+		if err != nil {
+			pool.Stop()
+			return nil, err
 		}
+		pool.Exchanges[ex.ID()] = ex
 	}
-	return &ExchangePool{exchanges}
+	return pool, nil
 }
 
-func NewRopstenExchangePool(addressConfig common.AddressConfig, signer *signer.FileSigner, bittrexStorage exchange.BittrexStorage) *ExchangePool {
-	exchanges := map[common.ExchangeID]interface{}{}
-	params := os.Getenv("KYBER_EXCHANGES")
-	exparams := strings.Split(params, ",")
-	for _, exparam := range exparams {
-		switch exparam {
-		case "bittrex":
-			bit := exchange.NewBittrex(bittrex.NewRopstenBittrexEndpoint(signer), bittrexStorage)
-			for tokenID, addr := range addressConfig.Exchanges["bittrex"] {
-				bit.UpdateDepositAddress(common.MustGetToken(tokenID), addr)
-			}
-			bit.UpdatePairsPrecision()
-			exchanges[bit.ID()] = bit
-		case "binance":
-			bin := exchange.NewBinance(binance.NewRopstenBinanceEndpoint(signer))
-			for tokenID, addr := range addressConfig.Exchanges["binance"] {
-				bin.UpdateDepositAddress(common.MustGetToken(tokenID), addr)
-			}
-			bin.UpdatePairsPrecision()
-			exchanges[bin.ID()] = bin
-		}
-	}
-	return &ExchangePool{exchanges}
-}
-
-func NewMainnetExchangePool(addressConfig common.AddressConfig, signer *signer.FileSigner, bittrexStorage exchange.BittrexStorage) *ExchangePool {
-	exchanges := map[common.ExchangeID]interface{}{}
-	params := os.Getenv("KYBER_EXCHANGES")
-	exparams := strings.Split(params, ",")
-	for _, exparam := range exparams {
-		switch exparam {
-		case "bittrex":
-			bit := exchange.NewBittrex(bittrex.NewRealBittrexEndpoint(signer), bittrexStorage)
-			for tokenID, addr := range addressConfig.Exchanges["bittrex"] {
-				bit.UpdateDepositAddress(common.MustGetToken(tokenID), addr)
-			}
-			bit.UpdatePairsPrecision()
-			exchanges[bit.ID()] = bit
-		case "binance":
-			bin := exchange.NewBinance(binance.NewRealBinanceEndpoint(signer))
-			for tokenID, addr := range addressConfig.Exchanges["binance"] {
-				bin.UpdateDepositAddress(common.MustGetToken(tokenID), addr)
+// Stop ends background refreshes of the exchanges and closes the ones
+// holding connections, like streamed order books
+func (self *ExchangePool) Stop() {
+	self.cancel()
+	for id, ex := range self.Exchanges {
+		if closer, ok := ex.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				log.Printf("Closing exchange %s failed: %s", id, err)
 			}
-			bin.UpdatePairsPrecision()
-			exchanges[bin.ID()] = bin
 		}
 	}
-	return &ExchangePool{exchanges}
 }
 
 func (self *ExchangePool) FetcherExchanges() []fetcher.Exchange {
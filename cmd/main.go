@@ -1,13 +1,17 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
 	"os"
+	"os/signal"
 	"runtime"
+	"syscall"
+	"time"
 
 	"github.com/KyberNetwork/reserve-data/blockchain"
 	"github.com/KyberNetwork/reserve-data/blockchain/nonce"
@@ -15,6 +19,8 @@ import (
 	"github.com/KyberNetwork/reserve-data/core"
 	"github.com/KyberNetwork/reserve-data/data"
 	"github.com/KyberNetwork/reserve-data/data/fetcher"
+	"github.com/KyberNetwork/reserve-data/data/fetcher/replay"
+	"github.com/KyberNetwork/reserve-data/data/storage"
 	"github.com/KyberNetwork/reserve-data/http"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/rpc"
@@ -69,6 +75,13 @@ func main() {
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 	log.SetOutput(mw)
 
+	recorder := loadRecorder()
+	tape := loadTape()
+	if tape != nil {
+		config.FetcherRunner = replay.NewReplayRunner(tape)
+		config.FetcherExchanges = tape.Exchanges()
+	}
+
 	fetcher := fetcher.NewFetcher(
 		config.FetcherStorage,
 		config.FetcherRunner,
@@ -77,7 +90,11 @@ func main() {
 	for _, ex := range config.Exchanges {
 		common.SupportedExchanges[ex.ID()] = ex
 	}
+	fetcher.SetCarryForward(loadBookCarry())
 	for _, ex := range config.FetcherExchanges {
+		if recorder != nil {
+			ex = replay.NewRecordingExchange(recorder, ex)
+		}
 		fetcher.AddExchange(ex)
 	}
 	client, err := rpc.Dial(config.EthereumEndpoint)
@@ -102,16 +119,24 @@ func main() {
 		bc.AddToken(token)
 	}
 	err = bc.LoadAndSetTokenIndices()
-	if err != nil {
+	if err != nil && tape == nil {
 		fmt.Printf("Can't load and set token indices: %s\n", err)
 	} else {
-		fetcher.SetBlockchain(bc)
+		fetcher.SetBlockchain(fetcherBlockchain(bc, recorder, tape))
+		fetcher.SetEventStartBlock(loadEventStartBlock())
+		// activities core submits are refreshed right away instead of
+		// on the next auth data tick
+		bus := common.NewEventBus()
+		fetcher.SubscribeActivities(bus, 500*time.Millisecond)
 		app := data.NewReserveData(
 			config.DataStorage,
 			fetcher,
+			loadFreshnessRules(),
 		)
 		app.Run()
+		compactor := startCompactor(config)
 		core := core.NewReserveCore(bc, config.ActivityStorage, config.ReserveAddress)
+		core.SetEventBus(bus)
 		server := http.NewHTTPServer(
 			app, core,
 			config.MetricStorage,
@@ -119,6 +144,41 @@ func main() {
 			config.EnableAuthentication,
 			config.AuthEngine,
 		)
-		server.Run()
+		go func() {
+			if err := server.Run(); err != nil {
+				log.Fatalf("HTTP server failed: %s", err)
+			}
+		}()
+		waitForShutdown(server, app, compactor, config)
+	}
+}
+
+// waitForShutdown blocks until SIGTERM or SIGINT, then stops taking
+// requests, drains the fetcher, stops exchange refreshes, streams and
+// compaction and closes storage
+func waitForShutdown(server *http.HTTPServer, app *data.ReserveData, compactor *storage.Compactor, config *Config) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	sig := <-signals
+	log.Printf("Got %s, shutting down...", sig)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Shutting down HTTP server failed: %s", err)
+	}
+	if err := app.Stop(); err != nil {
+		log.Printf("Stopping fetcher failed: %s", err)
+	}
+	if config.ExchangePool != nil {
+		config.ExchangePool.Stop()
+	}
+	if compactor != nil {
+		compactor.Stop()
+	}
+	if closer, ok := config.DataStorage.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Printf("Closing storage failed: %s", err)
+		}
 	}
+	log.Printf("Shut down")
 }
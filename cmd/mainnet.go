@@ -2,11 +2,10 @@ package main
 
 import (
 	"log"
-	"time"
 
 	"github.com/KyberNetwork/reserve-data/common"
 	"github.com/KyberNetwork/reserve-data/data/fetcher"
-	"github.com/KyberNetwork/reserve-data/data/storage"
+	"github.com/KyberNetwork/reserve-data/exchange"
 	"github.com/KyberNetwork/reserve-data/signer"
 	ethereum "github.com/ethereum/go-ethereum/common"
 )
@@ -31,18 +30,18 @@ func GetConfigForMainnet() *Config {
 		tokens = append(tokens, tok)
 	}
 
-	storage, err := storage.NewBoltStorage("/go/src/github.com/KyberNetwork/reserve-data/cmd/core.db")
-	if err != nil {
-		panic(err)
-	}
+	storage := loadStorage("/go/src/github.com/KyberNetwork/reserve-data/cmd/core.db")
 
-	fetcherRunner := fetcher.NewTickerRunner(3*time.Second, 2*time.Second, 3*time.Second, 5*time.Second)
+	fetcherRunner := fetcher.NewScheduleRunner(loadSchedules())
 
 	fileSigner := signer.NewFileSigner("/go/src/github.com/KyberNetwork/reserve-data/cmd/config.json")
 
-	exchangePool := NewMainnetExchangePool(
-		addressConfig, fileSigner, storage,
+	exchangePool, err := NewExchangePool(
+		exchange.ENV_MAINNET, addressConfig, fileSigner, storage,
 	)
+	if err != nil {
+		log.Fatalf("Couldn't create exchanges: %s", err)
+	}
 
 	hmac512auth := fileSigner
 
@@ -56,6 +55,7 @@ func GetConfigForMainnet() *Config {
 		FetcherRunner:        fetcherRunner,
 		FetcherExchanges:     exchangePool.FetcherExchanges(),
 		Exchanges:            exchangePool.CoreExchanges(),
+		ExchangePool:         exchangePool,
 		BlockchainSigner:     fileSigner,
 		EnableAuthentication: true,
 		AuthEngine:           hmac512auth,
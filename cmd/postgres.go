@@ -0,0 +1,4 @@
+package main
+
+// registers the driver PostgresStorage connects with
+import _ "github.com/lib/pq"
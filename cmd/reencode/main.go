@@ -0,0 +1,31 @@
+// Command reencode encodes snapshots of a bolt file stored in an older
+// format in the latest one. The reserve must not have the file open.
+//
+//	reencode /go/src/github.com/KyberNetwork/reserve-data/cmd/core.db
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/KyberNetwork/reserve-data/data/storage"
+)
+
+func main() {
+	if len(os.Args) != 2 {
+		log.Fatalf("Usage: %s <bolt file>", os.Args[0])
+	}
+	if _, err := os.Stat(os.Args[1]); err != nil {
+		log.Fatalf("Bolt file %s is not usable. Error: %s", os.Args[1], err)
+	}
+	bolt, err := storage.NewBoltStorage(os.Args[1])
+	if err != nil {
+		log.Fatalf("Couldn't open bolt file %s. Error: %s", os.Args[1], err)
+	}
+	defer bolt.Close()
+	count, err := bolt.Reencode()
+	if err != nil {
+		log.Fatalf("Re-encoded %d snapshots before failing: %s", count, err)
+	}
+	log.Printf("Re-encoded %d snapshots", count)
+}
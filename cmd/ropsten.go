@@ -2,11 +2,11 @@ package main
 
 import (
 	"log"
-	"time"
 
 	"github.com/KyberNetwork/reserve-data/common"
 	"github.com/KyberNetwork/reserve-data/data/fetcher"
 	"github.com/KyberNetwork/reserve-data/data/storage"
+	"github.com/KyberNetwork/reserve-data/exchange"
 	"github.com/KyberNetwork/reserve-data/metric"
 	"github.com/KyberNetwork/reserve-data/signer"
 	ethereum "github.com/ethereum/go-ethereum/common"
@@ -35,13 +35,16 @@ func GetConfigForRopsten() *Config {
 	storage := storage.NewRamStorage()
 	metricStorage := metric.NewRamMetricStorage()
 
-	fetcherRunner := fetcher.NewTickerRunner(3*time.Second, 2*time.Second, 3*time.Second, 5*time.Second)
+	fetcherRunner := fetcher.NewScheduleRunner(loadSchedules())
 
 	fileSigner := signer.NewFileSigner("/go/src/github.com/KyberNetwork/reserve-data/cmd/config.json")
 
-	exchangePool := NewRopstenExchangePool(
-		addressConfig, fileSigner, storage,
+	exchangePool, err := NewExchangePool(
+		exchange.ENV_ROPSTEN, addressConfig, fileSigner, storage,
 	)
+	if err != nil {
+		log.Fatalf("Couldn't create exchanges: %s", err)
+	}
 
 	// endpoint := "http://localhost:8545"
 	// endpoint := "https://ropsten.kyber.network"
@@ -55,6 +58,7 @@ func GetConfigForRopsten() *Config {
 		FetcherRunner:    fetcherRunner,
 		FetcherExchanges: exchangePool.FetcherExchanges(),
 		Exchanges:        exchangePool.CoreExchanges(),
+		ExchangePool:     exchangePool,
 		BlockchainSigner: fileSigner,
 		EthereumEndpoint: endpoint,
 		SupportedTokens:  tokens,
@@ -2,11 +2,13 @@ package main
 
 import (
 	"log"
+	"os"
 
 	"github.com/KyberNetwork/reserve-data/common"
 	"github.com/KyberNetwork/reserve-data/data/fetcher/http_runner"
-	"github.com/KyberNetwork/reserve-data/data/storage"
+	"github.com/KyberNetwork/reserve-data/exchange"
 	"github.com/KyberNetwork/reserve-data/signer"
+	"github.com/KyberNetwork/reserve-data/simulator"
 	ethereum "github.com/ethereum/go-ethereum/common"
 )
 
@@ -30,17 +32,23 @@ func GetConfigForSimulation() *Config {
 		tokens = append(tokens, tok)
 	}
 
-	storage, err := storage.NewBoltStorage("/go/src/github.com/KyberNetwork/reserve-data/cmd/core.db")
-	if err != nil {
-		panic(err)
+	// exchanges are simulated in process unless a simulator host is
+	// given as the first argument
+	if len(os.Args) <= 1 {
+		runSimulator(addressConfig)
 	}
+
+	storage := loadStorage("/go/src/github.com/KyberNetwork/reserve-data/cmd/core.db")
 	fetcherRunner := http_runner.NewHttpRunner(8001)
 
 	fileSigner := signer.NewFileSigner("/go/src/github.com/KyberNetwork/reserve-data/cmd/config.json")
 
-	exchangePool := NewSimulationExchangePool(
-		addressConfig, fileSigner, storage,
+	exchangePool, err := NewExchangePool(
+		exchange.ENV_SIMULATION, addressConfig, fileSigner, storage,
 	)
+	if err != nil {
+		log.Fatalf("Couldn't create exchanges: %s", err)
+	}
 
 	// endpoint := "http://localhost:8545"
 	// endpoint := "https://kovan.kyber.network"
@@ -54,6 +62,7 @@ func GetConfigForSimulation() *Config {
 		FetcherRunner:    fetcherRunner,
 		FetcherExchanges: exchangePool.FetcherExchanges(),
 		Exchanges:        exchangePool.CoreExchanges(),
+		ExchangePool:     exchangePool,
 		BlockchainSigner: fileSigner,
 		EthereumEndpoint: endpoint,
 		SupportedTokens:  tokens,
@@ -62,3 +71,37 @@ func GetConfigForSimulation() *Config {
 		ReserveAddress:   reserveAddr,
 	}
 }
+
+// runSimulator serves the simulated exchanges from the config file in
+// KYBER_SIMULATOR_CONFIG, or from a default config covering all tokens.
+// Deposit addresses are the configured ones so they can be verified.
+func runSimulator(addressConfig common.AddressConfig) {
+	var config simulator.Config
+	if path := os.Getenv("KYBER_SIMULATOR_CONFIG"); path != "" {
+		var err error
+		config, err = simulator.GetConfigFromFile(path)
+		if err != nil {
+			log.Fatalf("Simulator config file %s is not usable. Error: %s", path, err)
+		}
+	} else {
+		tokenIDs := []string{}
+		for id := range addressConfig.Tokens {
+			tokenIDs = append(tokenIDs, id)
+		}
+		config = simulator.DefaultConfig(tokenIDs)
+	}
+	for name, exconfig := range config.Exchanges {
+		if exconfig.Addresses == nil {
+			exconfig.Addresses = map[string]string{}
+		}
+		for tokenID, addr := range addressConfig.Exchanges[name] {
+			if _, found := exconfig.Addresses[tokenID]; !found {
+				exconfig.Addresses[tokenID] = addr
+			}
+		}
+		config.Exchanges[name] = exconfig
+	}
+	if err := simulator.NewSimulator(config).Run(); err != nil {
+		log.Fatalf("Couldn't start simulator: %s", err)
+	}
+}
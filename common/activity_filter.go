@@ -0,0 +1,102 @@
+package common
+
+import (
+	"math"
+)
+
+const (
+	DEFAULT_ACTIVITY_LIMIT int = 100
+	MAX_ACTIVITY_LIMIT     int = 1000
+)
+
+// ActivityFilter selects activities, empty fields don't filter. FromTime
+// and ToTime bound when activities were made in milliseconds, both
+// included, 0 ToTime doesn't bound.
+type ActivityFilter struct {
+	Destination    string
+	Token          string
+	Action         string
+	ExchangeStatus string
+	MiningStatus   string
+	FromTime       uint64
+	ToTime         uint64
+}
+
+// ActivityLimit returns how many activities a page has when limit of them
+// are asked for
+func ActivityLimit(limit int) int {
+	if limit <= 0 {
+		return DEFAULT_ACTIVITY_LIMIT
+	}
+	if limit > MAX_ACTIVITY_LIMIT {
+		return MAX_ACTIVITY_LIMIT
+	}
+	return limit
+}
+
+// IDRange returns the range of timepoints of activity ids, which are in
+// nanoseconds, the filter selects
+func (self ActivityFilter) IDRange() (uint64, uint64) {
+	from := self.FromTime * 1000000
+	if self.FromTime > math.MaxUint64/1000000 {
+		from = math.MaxUint64
+	}
+	to := self.ToTime*1000000 + 999999
+	if self.ToTime == 0 || self.ToTime > math.MaxUint64/1000000-1 {
+		to = math.MaxUint64
+	}
+	return from, to
+}
+
+func (self ActivityFilter) Match(record ActivityRecord) bool {
+	if self.Destination != "" && record.Destination != self.Destination {
+		return false
+	}
+	if self.Action != "" && record.Action != self.Action {
+		return false
+	}
+	if self.ExchangeStatus != "" && record.ExchangeStatus != self.ExchangeStatus {
+		return false
+	}
+	if self.MiningStatus != "" && record.MiningStatus != self.MiningStatus {
+		return false
+	}
+	from, to := self.IDRange()
+	if record.ID.Timepoint < from || record.ID.Timepoint > to {
+		return false
+	}
+	if self.Token != "" {
+		for _, token := range record.Tokens() {
+			if token == self.Token {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// Tokens returns ids of the tokens an activity moves or sets rates of.
+// Params hold tokens until they are stored, their ids after.
+func (self ActivityRecord) Tokens() []string {
+	result := []string{}
+	for _, name := range []string{"token", "base", "quote", "tokens"} {
+		switch value := self.Params[name].(type) {
+		case string:
+			result = append(result, value)
+		case Token:
+			result = append(result, value.ID)
+		case []Token:
+			for _, token := range value {
+				result = append(result, token.ID)
+			}
+		case []interface{}:
+			for _, token := range value {
+				if id, ok := token.(string); ok {
+					result = append(result, id)
+				}
+			}
+		}
+	}
+	return result
+}
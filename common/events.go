@@ -0,0 +1,48 @@
+package common
+
+import (
+	"log"
+	"sync"
+)
+
+// ActivityEvent tells that core recorded a new activity on an exchange
+type ActivityEvent struct {
+	ID        ActivityID
+	Action    string
+	Exchange  ExchangeID
+	Timepoint uint64
+}
+
+// EventBus delivers activity events published by core to every
+// subscriber. Publishing never blocks, events are dropped for
+// subscribers that are too far behind.
+type EventBus struct {
+	mu          sync.RWMutex
+	subscribers []chan ActivityEvent
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{subscribers: []chan ActivityEvent{}}
+}
+
+// Subscribe returns a channel getting every event published from now
+// on, buffering up to buffer of them
+func (self *EventBus) Subscribe(buffer int) <-chan ActivityEvent {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	subscriber := make(chan ActivityEvent, buffer)
+	self.subscribers = append(self.subscribers, subscriber)
+	return subscriber
+}
+
+func (self *EventBus) Publish(event ActivityEvent) {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	for _, subscriber := range self.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+			log.Printf("Dropped %s event of %s, subscriber is busy\n", event.Action, event.ID)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package common
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math/big"
@@ -11,9 +12,12 @@ import (
 type Exchange interface {
 	ID() ExchangeID
 	Address(token Token) (address ethereum.Address, supported bool)
-	Withdraw(token Token, amount *big.Int, address ethereum.Address, timepoint uint64) (string, error)
-	Trade(tradeType string, base Token, quote Token, rate float64, amount float64, timepoint uint64) (id string, done float64, remaining float64, finished bool, err error)
-	CancelOrder(id ActivityID) error
+	// VerifiedAddress returns deposit address of token only if the
+	// exchange confirmed it
+	VerifiedAddress(token Token) (address ethereum.Address, err error)
+	Withdraw(ctx context.Context, token Token, amount *big.Int, address ethereum.Address, timepoint uint64) (string, error)
+	Trade(ctx context.Context, tradeType string, base Token, quote Token, rate float64, amount float64, timepoint uint64) (id string, done float64, remaining float64, finished bool, err error)
+	CancelOrder(ctx context.Context, id ActivityID) error
 	MarshalText() (text []byte, err error)
 	GetInfo() (ExchangeInfo, error)
 	GetExchangeInfo(TokenPairID) (ExchangePrecisionLimit, error)
@@ -0,0 +1,108 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+// ExchangeAddresses holds deposit addresses of an exchange per token.
+// An address comes from config or discovery and is only usable for
+// deposits once the exchange reports the same address for the token.
+// It is written by the discovery loop and read concurrently by core.
+type ExchangeAddresses struct {
+	mu        sync.RWMutex
+	addresses map[string]ethereum.Address
+	verified  map[string]bool
+	mismatch  map[string]ethereum.Address
+}
+
+func NewExchangeAddresses() *ExchangeAddresses {
+	return &ExchangeAddresses{
+		mu:        sync.RWMutex{},
+		addresses: map[string]ethereum.Address{},
+		verified:  map[string]bool{},
+		mismatch:  map[string]ethereum.Address{},
+	}
+}
+
+// Update sets the configured address of a token, it needs to be
+// verified again if it changed
+func (self *ExchangeAddresses) Update(tokenID string, address ethereum.Address) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if current, exist := self.addresses[tokenID]; exist && current == address {
+		return
+	}
+	self.addresses[tokenID] = address
+	self.verified[tokenID] = false
+	delete(self.mismatch, tokenID)
+}
+
+func (self *ExchangeAddresses) UpdateAll(address ethereum.Address) {
+	for _, tokenID := range self.TokenIDs() {
+		self.Update(tokenID, address)
+	}
+}
+
+// TokenIDs returns tokens that have an address
+func (self *ExchangeAddresses) TokenIDs() []string {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	result := []string{}
+	for tokenID, _ := range self.addresses {
+		result = append(result, tokenID)
+	}
+	return result
+}
+
+func (self *ExchangeAddresses) Get(tokenID string) (ethereum.Address, bool) {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	address, exist := self.addresses[tokenID]
+	return address, exist
+}
+
+// Verify compares the address the exchange reports for a token with the
+// configured one. It returns an error when the token has no configured
+// address, which stays so, or when the two differ, the token then stays
+// unverified until config is fixed.
+func (self *ExchangeAddresses) Verify(tokenID string, reported ethereum.Address) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	current, exist := self.addresses[tokenID]
+	if !exist {
+		return errors.New(fmt.Sprintf("No deposit address configured for %s", tokenID))
+	}
+	if current != reported {
+		self.verified[tokenID] = false
+		self.mismatch[tokenID] = reported
+		return errors.New(fmt.Sprintf(
+			"Deposit address mismatch for %s: configured %s, exchange reports %s",
+			tokenID, current.Hex(), reported.Hex()))
+	}
+	self.verified[tokenID] = true
+	delete(self.mismatch, tokenID)
+	return nil
+}
+
+// Verified returns the address of a token only if it is verified
+func (self *ExchangeAddresses) Verified(tokenID string) (ethereum.Address, error) {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	address, exist := self.addresses[tokenID]
+	if !exist {
+		return address, errors.New(fmt.Sprintf("No deposit address for %s", tokenID))
+	}
+	if reported, mismatched := self.mismatch[tokenID]; mismatched {
+		return address, errors.New(fmt.Sprintf(
+			"Deposit address of %s is %s but exchange reports %s",
+			tokenID, address.Hex(), reported.Hex()))
+	}
+	if !self.verified[tokenID] {
+		return address, errors.New(fmt.Sprintf("Deposit address of %s is not verified yet", tokenID))
+	}
+	return address, nil
+}
@@ -0,0 +1,88 @@
+package common
+
+import (
+	"testing"
+
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	configuredAddress = ethereum.HexToAddress("0x9db6e8d2d133448dbcf755f19d540253da4ba043")
+	otherAddress      = ethereum.HexToAddress("0x1795b4560491c941c0635451f07332effe3ee7b3")
+)
+
+func TestExchangeAddressIsVerifiedWhenExchangeReportsIt(t *testing.T) {
+	addresses := NewExchangeAddresses()
+	addresses.Update("OMG", configuredAddress)
+	if _, err := addresses.Verified("OMG"); err == nil {
+		t.Fatalf("Expected configured address not to be usable before verification")
+	}
+	if err := addresses.Verify("OMG", configuredAddress); err != nil {
+		t.Fatalf("Expected address to be verified, got %v", err)
+	}
+	if address, err := addresses.Verified("OMG"); err != nil || address != configuredAddress {
+		t.Fatalf("Expected verified address %s, got %s, %v", configuredAddress.Hex(), address.Hex(), err)
+	}
+}
+
+func TestExchangeAddressMismatchIsRefused(t *testing.T) {
+	addresses := NewExchangeAddresses()
+	addresses.Update("OMG", configuredAddress)
+	addresses.Verify("OMG", configuredAddress)
+	if err := addresses.Verify("OMG", otherAddress); err == nil {
+		t.Fatalf("Expected mismatching address to be reported")
+	}
+	if address, _ := addresses.Get("OMG"); address != configuredAddress {
+		t.Fatalf("Expected configured address to be kept, got %s", address.Hex())
+	}
+	if _, err := addresses.Verified("OMG"); err == nil {
+		t.Fatalf("Expected mismatching address not to be usable")
+	}
+	// fixing config needs the address to be verified again
+	addresses.Update("OMG", otherAddress)
+	if _, err := addresses.Verified("OMG"); err == nil {
+		t.Fatalf("Expected updated address not to be usable before verification")
+	}
+	if err := addresses.Verify("OMG", otherAddress); err != nil {
+		t.Fatalf("Expected updated address to be verified, got %v", err)
+	}
+	if _, err := addresses.Verified("OMG"); err != nil {
+		t.Fatalf("Expected updated address to be usable, got %v", err)
+	}
+}
+
+func TestExchangeAddressOfUnconfiguredTokenIsNotVerified(t *testing.T) {
+	addresses := NewExchangeAddresses()
+	if err := addresses.Verify("OMG", otherAddress); err == nil {
+		t.Fatalf("Expected token without configured address not to be verified")
+	}
+	if _, exist := addresses.Get("OMG"); exist {
+		t.Fatalf("Expected reported address not to be taken")
+	}
+	if _, err := addresses.Verified("OMG"); err == nil {
+		t.Fatalf("Expected token without configured address not to be usable")
+	}
+	if len(addresses.TokenIDs()) != 0 {
+		t.Fatalf("Expected no token, got %v", addresses.TokenIDs())
+	}
+}
+
+func TestExchangeAddressUpdateAllKeepsTokens(t *testing.T) {
+	addresses := NewExchangeAddresses()
+	addresses.Update("OMG", configuredAddress)
+	addresses.Update("KNC", configuredAddress)
+	addresses.Verify("OMG", configuredAddress)
+	addresses.UpdateAll(configuredAddress)
+	if _, err := addresses.Verified("OMG"); err != nil {
+		t.Fatalf("Expected unchanged address to stay verified, got %v", err)
+	}
+	addresses.UpdateAll(otherAddress)
+	for _, tokenID := range []string{"OMG", "KNC"} {
+		if address, _ := addresses.Get(tokenID); address != otherAddress {
+			t.Fatalf("Expected %s to take the new address, got %s", tokenID, address.Hex())
+		}
+		if _, err := addresses.Verified(tokenID); err == nil {
+			t.Fatalf("Expected new address of %s not to be usable before verification", tokenID)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+)
+
+// Data types freshness rules apply to
+const (
+	DATA_PRICE            string = "price"
+	DATA_EXCHANGE_BALANCE string = "exchange_balance"
+	DATA_RESERVE_BALANCE  string = "reserve_balance"
+)
+
+// FreshnessRule limits, in milliseconds, how old data can get before it
+// is considered stale. A limit of 0 is not checked.
+type FreshnessRule struct {
+	// since the data was requested (Timestamp)
+	MaxAge uint64 `json:"max_age"`
+	// between the request and the response (ReturnTime - Timestamp)
+	MaxLatency uint64 `json:"max_latency"`
+	// since the data last changed, only order books track this
+	MaxUnchanged uint64 `json:"max_unchanged"`
+}
+
+// Staleness tells how old a piece of data is and why it is considered
+// stale if it is
+type Staleness struct {
+	Stale bool
+	// milliseconds since the data was requested
+	Age    uint64
+	Reason string
+}
+
+func parseTimestamp(timestamp Timestamp) (uint64, bool) {
+	result, err := strconv.ParseUint(string(timestamp), 10, 64)
+	return result, err == nil && result != 0
+}
+
+// Check returns staleness at timepoint of data requested at timestamp,
+// returned at returnTime and last changed at lastChanged. Timestamps
+// that are empty are not checked.
+func (self FreshnessRule) Check(timestamp, returnTime, lastChanged Timestamp, timepoint uint64) Staleness {
+	result := Staleness{}
+	requested, ok := parseTimestamp(timestamp)
+	if !ok {
+		return result
+	}
+	if timepoint > requested {
+		result.Age = timepoint - requested
+	}
+	if self.MaxAge != 0 && result.Age > self.MaxAge {
+		result.Stale = true
+		result.Reason = fmt.Sprintf("data is %dms old, max %dms", result.Age, self.MaxAge)
+		return result
+	}
+	if returned, ok := parseTimestamp(returnTime); ok && self.MaxLatency != 0 && returned > requested {
+		if latency := returned - requested; latency > self.MaxLatency {
+			result.Stale = true
+			result.Reason = fmt.Sprintf("data took %dms to return, max %dms", latency, self.MaxLatency)
+			return result
+		}
+	}
+	if changed, ok := parseTimestamp(lastChanged); ok && self.MaxUnchanged != 0 && timepoint > changed {
+		if unchanged := timepoint - changed; unchanged > self.MaxUnchanged {
+			result.Stale = true
+			result.Reason = fmt.Sprintf("data hasn't changed for %dms, max %dms", unchanged, self.MaxUnchanged)
+		}
+	}
+	return result
+}
+
+// FreshnessRules holds a rule per data type, exchanges can override the
+// rule of a data type
+type FreshnessRules struct {
+	Default   map[string]FreshnessRule                `json:"default"`
+	Exchanges map[ExchangeID]map[string]FreshnessRule `json:"exchanges"`
+}
+
+func NewFreshnessRules(defaults map[string]FreshnessRule) *FreshnessRules {
+	return &FreshnessRules{
+		Default:   defaults,
+		Exchanges: map[ExchangeID]map[string]FreshnessRule{},
+	}
+}
+
+// DefaultFreshnessRules flags books and balances a couple of fetch
+// intervals old and books frozen for 10 minutes
+func DefaultFreshnessRules() *FreshnessRules {
+	return NewFreshnessRules(map[string]FreshnessRule{
+		DATA_PRICE:            FreshnessRule{60000, 10000, 600000},
+		DATA_EXCHANGE_BALANCE: FreshnessRule{120000, 20000, 0},
+		DATA_RESERVE_BALANCE:  FreshnessRule{120000, 20000, 0},
+	})
+}
+
+func GetFreshnessRulesFromFile(path string) (*FreshnessRules, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	result := NewFreshnessRules(map[string]FreshnessRule{})
+	if err = json.Unmarshal(raw, result); err != nil {
+		return nil, err
+	}
+	if result.Exchanges == nil {
+		result.Exchanges = map[ExchangeID]map[string]FreshnessRule{}
+	}
+	return result, nil
+}
+
+// Rule returns the rule of dataType on exchange, use an empty exchange
+// for data that doesn't come from exchanges
+func (self *FreshnessRules) Rule(exchange ExchangeID, dataType string) FreshnessRule {
+	if rules, found := self.Exchanges[exchange]; found {
+		if rule, found := rules[dataType]; found {
+			return rule
+		}
+	}
+	return self.Default[dataType]
+}
@@ -0,0 +1,46 @@
+package common
+
+import (
+	"testing"
+)
+
+func TestFreshnessRuleCheck(t *testing.T) {
+	rule := FreshnessRule{MaxAge: 1000, MaxLatency: 100, MaxUnchanged: 5000}
+	if s := rule.Check("10000", "10050", "9000", 10500); s.Stale || s.Age != 500 {
+		t.Fatalf("Expected fresh data 500ms old, got %+v", s)
+	}
+	if s := rule.Check("10000", "10050", "9000", 11001); !s.Stale || s.Reason == "" {
+		t.Fatalf("Expected data over max age to be stale, got %+v", s)
+	}
+	if s := rule.Check("10000", "10200", "9000", 10500); !s.Stale {
+		t.Fatalf("Expected slow data to be stale, got %+v", s)
+	}
+	if s := rule.Check("10000", "10050", "4000", 10500); !s.Stale {
+		t.Fatalf("Expected data unchanged for too long to be stale, got %+v", s)
+	}
+	if s := rule.Check("", "", "", 10500); s.Stale {
+		t.Fatalf("Expected data without timestamp not to be checked, got %+v", s)
+	}
+	if s := (FreshnessRule{}).Check("10000", "20000", "1", 99999); s.Stale {
+		t.Fatalf("Expected empty rule not to flag anything, got %+v", s)
+	}
+}
+
+func TestFreshnessRulesExchangeOverride(t *testing.T) {
+	rules := NewFreshnessRules(map[string]FreshnessRule{
+		DATA_PRICE:            FreshnessRule{MaxAge: 1000},
+		DATA_EXCHANGE_BALANCE: FreshnessRule{MaxAge: 2000},
+	})
+	rules.Exchanges["bittrex"] = map[string]FreshnessRule{
+		DATA_PRICE: FreshnessRule{MaxAge: 5000},
+	}
+	if rule := rules.Rule("bittrex", DATA_PRICE); rule.MaxAge != 5000 {
+		t.Fatalf("Expected bittrex price rule to be overridden, got %+v", rule)
+	}
+	if rule := rules.Rule("bittrex", DATA_EXCHANGE_BALANCE); rule.MaxAge != 2000 {
+		t.Fatalf("Expected bittrex balance rule to be the default, got %+v", rule)
+	}
+	if rule := rules.Rule("binance", DATA_PRICE); rule.MaxAge != 1000 {
+		t.Fatalf("Expected binance price rule to be the default, got %+v", rule)
+	}
+}
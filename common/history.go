@@ -0,0 +1,83 @@
+package common
+
+const (
+	DEFAULT_HISTORY_LIMIT int = 100
+	MAX_HISTORY_LIMIT     int = 1000
+)
+
+// HistoryQuery selects versions stored from FromTime to ToTime, both in
+// milliseconds and included. With an Interval, in milliseconds, only the
+// first version of every interval is kept. At most Limit versions are
+// returned in a page.
+type HistoryQuery struct {
+	FromTime uint64
+	ToTime   uint64
+	Interval uint64
+	Limit    int
+}
+
+// Select returns the versions of a page out of every version stored in
+// the range, in order, and where the next page starts, 0 if there is
+// none. Intervals are aligned on multiples of Interval, not on FromTime,
+// so pages going on from where the last one stopped are downsampled the
+// same way.
+func (self HistoryQuery) Select(versions []Version) ([]Version, uint64) {
+	limit := self.Limit
+	if limit <= 0 {
+		limit = DEFAULT_HISTORY_LIMIT
+	}
+	if limit > MAX_HISTORY_LIMIT {
+		limit = MAX_HISTORY_LIMIT
+	}
+	result := []Version{}
+	var next uint64
+	for _, version := range versions {
+		timepoint := uint64(version)
+		if timepoint < self.FromTime || timepoint > self.ToTime || timepoint < next {
+			continue
+		}
+		if len(result) == limit {
+			return result, next
+		}
+		result = append(result, version)
+		next = timepoint + 1
+		if self.Interval > 0 {
+			next = (timepoint/self.Interval + 1) * self.Interval
+		}
+	}
+	return result, 0
+}
+
+// PageReserveEvents returns the events of kind, or of every kind if it
+// is empty, in a page of at most limit events and the block the next
+// page starts at, 0 if there is none. Pages end on whole blocks so that
+// asking again from the next block repeats or misses no event, a block
+// with more events than limit is returned whole.
+func PageReserveEvents(events []ReserveEvent, kind string, limit int) ([]ReserveEvent, uint64) {
+	if limit <= 0 {
+		limit = DEFAULT_HISTORY_LIMIT
+	}
+	if limit > MAX_HISTORY_LIMIT {
+		limit = MAX_HISTORY_LIMIT
+	}
+	result := []ReserveEvent{}
+	// number of events in result before the block of the last one
+	blockStart := 0
+	for _, event := range events {
+		if kind != "" && event.Kind != kind {
+			continue
+		}
+		last := len(result) - 1
+		if last >= 0 && event.BlockNumber != result[last].BlockNumber {
+			if len(result) >= limit {
+				return result, event.BlockNumber
+			}
+			blockStart = len(result)
+		}
+		if len(result) >= limit && blockStart > 0 {
+			return result[:blockStart], result[blockStart].BlockNumber
+		}
+		result = append(result, event)
+	}
+	return result, 0
+}
@@ -0,0 +1,78 @@
+package common
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestHistoryQueryDownsamples(t *testing.T) {
+	versions := []Version{900, 1000, 1400, 1999, 2000, 2500, 3100, 5000}
+	query := HistoryQuery{FromTime: 1000, ToTime: 4000, Interval: 1000}
+	selected, next := query.Select(versions)
+	if fmt.Sprint(selected) != "[1000 2000 3100]" || next != 0 {
+		t.Fatalf("Expected one version per second and no next page, got %v, %d", selected, next)
+	}
+}
+
+func TestHistoryQueryPages(t *testing.T) {
+	versions := []Version{1000, 1400, 2000, 2500, 3100}
+	query := HistoryQuery{FromTime: 0, ToTime: 4000, Interval: 1000, Limit: 2}
+	selected, next := query.Select(versions)
+	if fmt.Sprint(selected) != "[1000 2000]" || next != 3000 {
+		t.Fatalf("Expected the first page to stop before 3000, got %v, %d", selected, next)
+	}
+	query.FromTime = next
+	selected, next = query.Select(versions)
+	if fmt.Sprint(selected) != "[3100]" || next != 0 {
+		t.Fatalf("Expected the last page, got %v, %d", selected, next)
+	}
+	// a full page with nothing after it has no next page
+	query = HistoryQuery{FromTime: 0, ToTime: 4000, Limit: 5}
+	if selected, next = query.Select(versions); len(selected) != 5 || next != 0 {
+		t.Fatalf("Expected every version and no next page, got %v, %d", selected, next)
+	}
+}
+
+func eventBlocks(events []ReserveEvent) string {
+	blocks := []uint64{}
+	for _, event := range events {
+		blocks = append(blocks, event.BlockNumber)
+	}
+	return fmt.Sprint(blocks)
+}
+
+func TestReserveEventPagesEndOnWholeBlocks(t *testing.T) {
+	events := []ReserveEvent{
+		ReserveEvent{Kind: "trade", BlockNumber: 10},
+		ReserveEvent{Kind: "set_rate", BlockNumber: 11},
+		ReserveEvent{Kind: "trade", BlockNumber: 11},
+		ReserveEvent{Kind: "trade", BlockNumber: 12},
+		ReserveEvent{Kind: "trade", BlockNumber: 13},
+		ReserveEvent{Kind: "trade", BlockNumber: 13},
+		ReserveEvent{Kind: "trade", BlockNumber: 13},
+	}
+	tests := []struct {
+		kind   string
+		limit  int
+		blocks string
+		next   uint64
+	}{
+		{"", 2, "[10]", 11},
+		{"", 3, "[10 11 11]", 12},
+		{"trade", 2, "[10 11]", 12},
+		{"trade", 10, "[10 11 12 13 13 13]", 0},
+		{"set_rate", 1, "[11]", 0},
+	}
+	for _, test := range tests {
+		page, next := PageReserveEvents(events, test.kind, test.limit)
+		if eventBlocks(page) != test.blocks || next != test.next {
+			t.Errorf("Expected %s %d events to be %s then %d, got %s then %d",
+				test.kind, test.limit, test.blocks, test.next, eventBlocks(page), next)
+		}
+	}
+	// a block with more events than limit is returned whole
+	page, next := PageReserveEvents(events[4:], "", 2)
+	if eventBlocks(page) != "[13 13 13]" || next != 0 {
+		t.Fatalf("Expected the whole block 13, got %s then %d", eventBlocks(page), next)
+	}
+}
@@ -0,0 +1,192 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// every request to an exchange counts toward its request limits
+	RATE_KIND_REQUEST string = "request"
+	// only requests placing orders count toward order limits
+	RATE_KIND_ORDER string = "order"
+
+	// requests wait at most this long for capacity before being rejected
+	RATE_LIMIT_MAX_WAIT time.Duration = 10 * time.Second
+)
+
+type RateLimit struct {
+	Kind     string
+	Limit    int
+	Interval time.Duration
+}
+
+type RateLimitUsage struct {
+	Kind     string
+	Limit    int
+	Used     int
+	Interval string
+}
+
+type rateRecord struct {
+	at     time.Time
+	weight int
+}
+
+type rateWindow struct {
+	limit   RateLimit
+	records []rateRecord
+}
+
+func (self *rateWindow) prune(now time.Time) {
+	i := 0
+	for i < len(self.records) && now.Sub(self.records[i].at) >= self.limit.Interval {
+		i++
+	}
+	self.records = self.records[i:]
+}
+
+func (self *rateWindow) used() int {
+	total := 0
+	for _, record := range self.records {
+		total += record.weight
+	}
+	return total
+}
+
+// waitFor returns how long until weight fits in the window.
+func (self *rateWindow) waitFor(weight int, now time.Time) time.Duration {
+	excess := self.used() + weight - self.limit.Limit
+	if excess <= 0 {
+		return 0
+	}
+	for _, record := range self.records {
+		excess -= record.weight
+		if excess <= 0 {
+			return self.limit.Interval - now.Sub(record.at)
+		}
+	}
+	return self.limit.Interval
+}
+
+// RateLimiter accounts weighted requests of one exchange in sliding
+// windows. It is shared by every endpoint talking to that exchange
+// because exchanges limit by IP/key, not by endpoint instance.
+type RateLimiter struct {
+	mu      sync.Mutex
+	windows []*rateWindow
+}
+
+func NewRateLimiter(limits []RateLimit) *RateLimiter {
+	windows := []*rateWindow{}
+	for _, limit := range limits {
+		windows = append(windows, &rateWindow{limit, []rateRecord{}})
+	}
+	return &RateLimiter{sync.Mutex{}, windows}
+}
+
+// Wait blocks until weights (keyed by rate kind) fit in every window of
+// the same kind and records them. It returns an error without recording
+// anything when a weight can never fit, capacity would not be available
+// within RATE_LIMIT_MAX_WAIT or ctx is done first.
+func (self *RateLimiter) Wait(ctx context.Context, weights map[string]int) error {
+	deadline := time.Now().Add(RATE_LIMIT_MAX_WAIT)
+	for {
+		self.mu.Lock()
+		now := time.Now()
+		var wait time.Duration
+		for _, window := range self.windows {
+			weight, applied := weights[window.limit.Kind]
+			if !applied {
+				continue
+			}
+			if weight > window.limit.Limit {
+				self.mu.Unlock()
+				return errors.New(fmt.Sprintf(
+					"Request weight %d exceeds %s limit %d per %s",
+					weight, window.limit.Kind, window.limit.Limit, window.limit.Interval))
+			}
+			window.prune(now)
+			if w := window.waitFor(weight, now); w > wait {
+				wait = w
+			}
+		}
+		if wait == 0 {
+			for _, window := range self.windows {
+				if weight, applied := weights[window.limit.Kind]; applied {
+					window.records = append(window.records, rateRecord{now, weight})
+				}
+			}
+			self.mu.Unlock()
+			return nil
+		}
+		self.mu.Unlock()
+		if now.Add(wait).After(deadline) {
+			return errors.New(fmt.Sprintf("Rate limit would be exceeded, need to wait %s", wait))
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (self *RateLimiter) Request(ctx context.Context, weight int) error {
+	return self.Wait(ctx, map[string]int{RATE_KIND_REQUEST: weight})
+}
+
+func (self *RateLimiter) Order(ctx context.Context, weight int) error {
+	return self.Wait(ctx, map[string]int{
+		RATE_KIND_REQUEST: weight,
+		RATE_KIND_ORDER:   1,
+	})
+}
+
+func (self *RateLimiter) Usage() []RateLimitUsage {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	now := time.Now()
+	result := []RateLimitUsage{}
+	for _, window := range self.windows {
+		window.prune(now)
+		result = append(result, RateLimitUsage{
+			window.limit.Kind,
+			window.limit.Limit,
+			window.used(),
+			window.limit.Interval.String(),
+		})
+	}
+	return result
+}
+
+var rateLimitersMu sync.Mutex
+var rateLimiters = map[ExchangeID]*RateLimiter{}
+
+// RegisterRateLimiter returns the limiter of exchange id, creating it
+// with limits on first registration.
+func RegisterRateLimiter(id ExchangeID, limits []RateLimit) *RateLimiter {
+	rateLimitersMu.Lock()
+	defer rateLimitersMu.Unlock()
+	if limiter, exist := rateLimiters[id]; exist {
+		return limiter
+	}
+	limiter := NewRateLimiter(limits)
+	rateLimiters[id] = limiter
+	return limiter
+}
+
+func GetRateLimitUsages() map[ExchangeID][]RateLimitUsage {
+	rateLimitersMu.Lock()
+	defer rateLimitersMu.Unlock()
+	result := map[ExchangeID][]RateLimitUsage{}
+	for id, limiter := range rateLimiters {
+		result[id] = limiter.Usage()
+	}
+	return result
+}
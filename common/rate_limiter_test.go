@@ -0,0 +1,73 @@
+package common
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterWaitsForCapacity(t *testing.T) {
+	limiter := NewRateLimiter([]RateLimit{
+		RateLimit{Kind: RATE_KIND_REQUEST, Limit: 2, Interval: 100 * time.Millisecond},
+	})
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := limiter.Request(context.Background(), 1); err != nil {
+			t.Fatalf("Expected request to be allowed but got error: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Fatalf("Expected third request to wait for the window, it waited %s", elapsed)
+	}
+	usage := limiter.Usage()
+	if len(usage) != 1 || usage[0].Used != 1 {
+		t.Fatalf("Expected 1 request in current window, got %+v", usage)
+	}
+}
+
+func TestRateLimiterRejectsOversizedWeight(t *testing.T) {
+	limiter := NewRateLimiter([]RateLimit{
+		RateLimit{Kind: RATE_KIND_REQUEST, Limit: 10, Interval: time.Minute},
+	})
+	if err := limiter.Request(context.Background(), 11); err == nil {
+		t.Fatalf("Expected request heavier than the limit to be rejected")
+	}
+}
+
+func TestRateLimiterCountsOrdersSeparately(t *testing.T) {
+	limiter := NewRateLimiter([]RateLimit{
+		RateLimit{Kind: RATE_KIND_REQUEST, Limit: 100, Interval: time.Minute},
+		RateLimit{Kind: RATE_KIND_ORDER, Limit: 1, Interval: time.Hour},
+	})
+	if err := limiter.Order(context.Background(), 1); err != nil {
+		t.Fatalf("Expected first order to be allowed but got error: %v", err)
+	}
+	if err := limiter.Request(context.Background(), 1); err != nil {
+		t.Fatalf("Expected request to be allowed but got error: %v", err)
+	}
+	// the next order can only fit in an hour which is over max wait
+	if err := limiter.Order(context.Background(), 1); err == nil {
+		t.Fatalf("Expected second order to be rejected")
+	}
+}
+
+func TestRateLimiterWaitIsCanceled(t *testing.T) {
+	limiter := NewRateLimiter([]RateLimit{
+		RateLimit{Kind: RATE_KIND_REQUEST, Limit: 1, Interval: 5 * time.Second},
+	})
+	if err := limiter.Request(context.Background(), 1); err != nil {
+		t.Fatalf("Expected first request to be allowed but got error: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := limiter.Request(ctx, 1); err != context.DeadlineExceeded {
+		t.Fatalf("Expected waiting request to be canceled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Expected canceled request to return right away, it waited %s", elapsed)
+	}
+	if usage := limiter.Usage(); usage[0].Used != 1 {
+		t.Fatalf("Expected canceled request not to be recorded, got %+v", usage)
+	}
+}
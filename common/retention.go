@@ -0,0 +1,63 @@
+package common
+
+import (
+	"encoding/json"
+	"io/ioutil"
+)
+
+// Kinds of stored data retention applies to, every version of the
+// other ones is kept
+const (
+	RETAIN_PRICES    string = "prices"
+	RETAIN_RATES     string = "rates"
+	RETAIN_AUTH_DATA string = "auth_data"
+	RETAIN_METRICS   string = "metrics"
+)
+
+// Retention keeps versions stored in the last MaxAge milliseconds and at
+// most MaxVersions of them, 0 doesn't limit either
+type Retention struct {
+	MaxAge      uint64 `json:"max_age"`
+	MaxVersions int    `json:"max_versions"`
+}
+
+// Cutoff returns the oldest timepoint kept at now
+func (self Retention) Cutoff(now uint64) uint64 {
+	if self.MaxAge == 0 || now < self.MaxAge {
+		return 0
+	}
+	return now - self.MaxAge
+}
+
+// RetentionPolicies holds a retention per kind of data, applied every
+// Interval milliseconds, never with 0 interval. Pruned versions are
+// appended to the gzip file at Archive unless it is empty.
+type RetentionPolicies struct {
+	Interval uint64               `json:"interval"`
+	Archive  string               `json:"archive"`
+	Kinds    map[string]Retention `json:"kinds"`
+}
+
+// DefaultRetentionPolicies keeps the last 1000 prices and metrics every
+// minute, as storage used to on every store
+func DefaultRetentionPolicies() *RetentionPolicies {
+	return &RetentionPolicies{
+		Interval: 60000,
+		Kinds: map[string]Retention{
+			RETAIN_PRICES:  Retention{0, 1000},
+			RETAIN_METRICS: Retention{0, 1000},
+		},
+	}
+}
+
+func GetRetentionPoliciesFromFile(path string) (*RetentionPolicies, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	result := &RetentionPolicies{Kinds: map[string]Retention{}}
+	if err = json.Unmarshal(raw, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,92 @@
+package common
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/rand"
+	"time"
+)
+
+// Kinds of data the fetcher is scheduled to fetch. Order books, auth
+// data and trade history are scheduled per exchange, rates and blocks
+// come from the blockchain and only have a default schedule.
+const (
+	FETCH_ORDERBOOK     string = "orderbook"
+	FETCH_AUTH_DATA     string = "authdata"
+	FETCH_RATE          string = "rate"
+	FETCH_BLOCK         string = "block"
+	FETCH_TRADE_HISTORY string = "tradehistory"
+)
+
+// Schedule fetches every Interval plus a random delay up to Jitter, both
+// in milliseconds. A schedule with 0 interval never fetches.
+type Schedule struct {
+	Interval uint64 `json:"interval"`
+	Jitter   uint64 `json:"jitter"`
+}
+
+// Delay returns how long to wait for the next fetch
+func (self Schedule) Delay() time.Duration {
+	delay := time.Duration(self.Interval) * time.Millisecond
+	if self.Jitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(self.Jitter)+1)) * time.Millisecond
+	}
+	return delay
+}
+
+// Schedules holds a schedule per data kind, exchanges can override the
+// schedule of a kind
+type Schedules struct {
+	Default   map[string]Schedule                `json:"default"`
+	Exchanges map[ExchangeID]map[string]Schedule `json:"exchanges"`
+}
+
+func NewSchedules(defaults map[string]Schedule) *Schedules {
+	return &Schedules{
+		Default:   defaults,
+		Exchanges: map[ExchangeID]map[string]Schedule{},
+	}
+}
+
+// DefaultSchedules fetches every exchange at the same pace but bitfinex,
+// whose books and balances have lower rate limits
+func DefaultSchedules() *Schedules {
+	result := NewSchedules(map[string]Schedule{
+		FETCH_ORDERBOOK:     Schedule{3000, 0},
+		FETCH_AUTH_DATA:     Schedule{2000, 0},
+		FETCH_RATE:          Schedule{3000, 0},
+		FETCH_BLOCK:         Schedule{5000, 0},
+		FETCH_TRADE_HISTORY: Schedule{60000, 0},
+	})
+	result.Exchanges[ExchangeID("bitfinex")] = map[string]Schedule{
+		FETCH_ORDERBOOK: Schedule{4000, 0},
+		FETCH_AUTH_DATA: Schedule{4000, 0},
+	}
+	return result
+}
+
+func GetSchedulesFromFile(path string) (*Schedules, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	result := NewSchedules(map[string]Schedule{})
+	if err = json.Unmarshal(raw, result); err != nil {
+		return nil, err
+	}
+	if result.Exchanges == nil {
+		result.Exchanges = map[ExchangeID]map[string]Schedule{}
+	}
+	return result, nil
+}
+
+// Schedule returns the schedule of kind on exchange, use an empty
+// exchange for data that doesn't come from exchanges
+func (self *Schedules) Schedule(exchange ExchangeID, kind string) Schedule {
+	if schedules, found := self.Exchanges[exchange]; found {
+		if schedule, found := schedules[kind]; found {
+			return schedule
+		}
+	}
+	return self.Default[kind]
+}
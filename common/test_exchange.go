@@ -1,6 +1,7 @@
 package common
 
 import (
+	"context"
 	"math/big"
 
 	ethereum "github.com/ethereum/go-ethereum/common"
@@ -15,15 +16,27 @@ func (self TestExchange) ID() ExchangeID {
 func (self TestExchange) Address(token Token) (address ethereum.Address, supported bool) {
 	return ethereum.Address{}, true
 }
-func (self TestExchange) Withdraw(token Token, amount *big.Int, address ethereum.Address, timepoint uint64) (string, error) {
+func (self TestExchange) VerifiedAddress(token Token) (address ethereum.Address, err error) {
+	return ethereum.Address{}, nil
+}
+func (self TestExchange) Withdraw(ctx context.Context, token Token, amount *big.Int, address ethereum.Address, timepoint uint64) (string, error) {
 	return "withdrawid", nil
 }
-func (self TestExchange) Trade(tradeType string, base Token, quote Token, rate float64, amount float64, timepoint uint64) (id string, done float64, remaining float64, finished bool, err error) {
+func (self TestExchange) Trade(ctx context.Context, tradeType string, base Token, quote Token, rate float64, amount float64, timepoint uint64) (id string, done float64, remaining float64, finished bool, err error) {
 	return "tradeid", 10, 5, false, nil
 }
-func (self TestExchange) CancelOrder(id ActivityID) error {
+func (self TestExchange) CancelOrder(ctx context.Context, id ActivityID) error {
 	return nil
 }
 func (self TestExchange) MarshalText() (text []byte, err error) {
 	return []byte("bittrex"), nil
 }
+func (self TestExchange) GetInfo() (ExchangeInfo, error) {
+	return ExchangeInfo{}, nil
+}
+func (self TestExchange) GetExchangeInfo(pair TokenPairID) (ExchangePrecisionLimit, error) {
+	return ExchangePrecisionLimit{}, nil
+}
+func (self TestExchange) GetFee() ExchangeFees {
+	return ExchangeFees{}
+}
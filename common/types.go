@@ -75,6 +75,21 @@ func (self *ExchangeInfo) Get(pair TokenPairID) (ExchangePrecisionLimit, error)
 	}
 }
 
+// Copy returns a snapshot of the info, made under the lock so it can be
+// returned by value and read while the info is updated
+func (self *ExchangeInfo) Copy() ExchangeInfo {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	data := map[TokenPairID]ExchangePrecisionLimit{}
+	for pair, info := range self.data {
+		data[pair] = info
+	}
+	return ExchangeInfo{
+		mu:   sync.RWMutex{},
+		data: data,
+	}
+}
+
 func (self *ExchangeInfo) GetData() map[TokenPairID]ExchangePrecisionLimit {
 	self.mu.RLock()
 	defer self.mu.RUnlock()
@@ -122,6 +137,71 @@ func NewFundingFee(withdraw map[string]float32, deposit map[string]float32) Fund
 	}
 }
 
+// ExchangeFeeTable holds fees of an exchange. It starts with hardcoded
+// fallback values and is refreshed from the exchange API while being
+// read concurrently.
+type ExchangeFeeTable struct {
+	mu   sync.RWMutex
+	fees ExchangeFees
+}
+
+func NewExchangeFeeTable(fallback ExchangeFees) *ExchangeFeeTable {
+	return &ExchangeFeeTable{
+		mu:   sync.RWMutex{},
+		fees: fallback,
+	}
+}
+
+// Get returns a copy of the fees so callers can't mutate the table
+func (self *ExchangeFeeTable) Get() ExchangeFees {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	result := NewExchangeFee(
+		TradingFee{},
+		NewFundingFee(map[string]float32{}, map[string]float32{}),
+	)
+	for k, v := range self.fees.Trading {
+		result.Trading[k] = v
+	}
+	for k, v := range self.fees.Funding.Withdraw {
+		result.Funding.Withdraw[k] = v
+	}
+	for k, v := range self.fees.Funding.Deposit {
+		result.Funding.Deposit[k] = v
+	}
+	return result
+}
+
+// UpdateTrading overrides trading fees that are given, the others
+// keep their current values
+func (self *ExchangeFeeTable) UpdateTrading(fees TradingFee) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	for k, v := range fees {
+		self.fees.Trading[k] = v
+	}
+}
+
+// UpdateWithdraw overrides withdraw fees of tokens that are given, the
+// others keep their current values
+func (self *ExchangeFeeTable) UpdateWithdraw(fees map[string]float32) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	for k, v := range fees {
+		self.fees.Funding.Withdraw[k] = v
+	}
+}
+
+// UpdateDeposit overrides deposit fees of tokens that are given, the
+// others keep their current values
+func (self *ExchangeFeeTable) UpdateDeposit(fees map[string]float32) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	for k, v := range fees {
+		self.fees.Funding.Deposit[k] = v
+	}
+}
+
 type TokenPairID string
 
 func NewTokenPairID(base, quote string) TokenPairID {
@@ -273,6 +353,12 @@ type ExchangePrice struct {
 	Bids       []PriceEntry
 	Asks       []PriceEntry
 	ReturnTime Timestamp
+	// last time the fetcher saw the book change
+	LastChanged Timestamp
+	Staleness   Staleness
+	// the book was carried forward from an earlier fetch because the
+	// exchange failed, Timestamp is still the one of that fetch
+	CarriedForward bool
 }
 
 func BigToFloat(b *big.Int, decimal int64) float64 {
@@ -325,6 +411,7 @@ type BalanceResponse struct {
 	Timestamp  Timestamp
 	ReturnTime Timestamp
 	Balance    float64
+	Staleness  Staleness
 }
 
 type AllBalanceResponse struct {
@@ -350,6 +437,44 @@ type Order struct {
 	Time        uint64
 }
 
+// TradeFill is one execution of an order on an exchange
+type TradeFill struct {
+	// id of the fill given by the exchange
+	ID string
+	// id of the order in the same format as the EID of its trade activity
+	OrderID   string
+	Pair      TokenPairID
+	Type      string
+	Price     float64
+	Qty       float64
+	Fee       float64
+	FeeAsset  string
+	Timestamp uint64
+}
+
+type TradeFillRecord struct {
+	ActivityID ActivityID
+	Exchange   ExchangeID
+	TradeFill
+}
+
+// ReserveEvent is an event logged by the reserve or pricing contract,
+// Kind is one of trade, set_rate, deposit and withdraw
+type ReserveEvent struct {
+	Kind        string
+	Name        string
+	Contract    string
+	BlockNumber uint64
+	BlockHash   string
+	TxHash      string
+	LogIndex    uint
+	// time of the block in milliseconds
+	Timestamp uint64
+	// decoded arguments by their names in the contract abi, addresses
+	// in hex and numbers in decimal
+	Params map[string]string
+}
+
 type OrderEntry struct {
 	Valid      bool
 	Error      string
@@ -375,6 +500,7 @@ type EBalanceEntry struct {
 	AvailableBalance map[string]float64
 	LockedBalance    map[string]float64
 	DepositBalance   map[string]float64
+	Staleness        Staleness
 }
 
 type AllEBalanceResponse struct {
@@ -393,6 +519,10 @@ type AuthDataSnapshot struct {
 	ReserveBalances   map[string]BalanceEntry
 	PendingActivities []ActivityRecord
 	Block             uint64
+	Breakers          map[ExchangeID]BreakerStatus
+	// exchanges, or "blockchain", whose activity statuses kept changing
+	// while their balances were fetched
+	Inconsistent []string
 }
 
 type AuthDataResponse struct {
@@ -408,9 +538,26 @@ type AuthDataResponse struct {
 		ReserveBalances   map[string]BalanceResponse
 		PendingActivities []ActivityRecord
 		Block             uint64
+		Breakers          map[ExchangeID]BreakerStatus
+		Inconsistent      []string
 	}
 }
 
+// States of the circuit breaker guarding fetches from an exchange
+const (
+	BREAKER_CLOSED    string = "closed"
+	BREAKER_OPEN      string = "open"
+	BREAKER_HALF_OPEN string = "half_open"
+)
+
+type BreakerStatus struct {
+	State     string
+	Failures  int
+	LastError string
+	OpenedAt  uint64
+	RetryAt   uint64
+}
+
 type RateEntry struct {
 	BaseBuy     *big.Int
 	CompactBuy  int8
@@ -63,3 +63,51 @@ func TestJSONToActivityID(t *testing.T) {
 		}
 	}
 }
+
+func TestActivityFilter(t *testing.T) {
+	record := ActivityRecord{
+		Action:      "trade",
+		ID:          ActivityID{1512189195897392628, "1872552297_OMGETH"},
+		Destination: "binance",
+		Params: map[string]interface{}{
+			"base":  Token{"OMG", "0x", 18},
+			"quote": "ETH",
+		},
+		ExchangeStatus: "done",
+	}
+	matching := []ActivityFilter{
+		ActivityFilter{},
+		ActivityFilter{Destination: "binance", Action: "trade", ExchangeStatus: "done"},
+		ActivityFilter{Token: "OMG"},
+		ActivityFilter{Token: "ETH"},
+		ActivityFilter{FromTime: 1512189195897, ToTime: 1512189195897},
+	}
+	for _, filter := range matching {
+		if !filter.Match(record) {
+			t.Errorf("Expected %+v to match", filter)
+		}
+	}
+	other := []ActivityFilter{
+		ActivityFilter{Destination: "bittrex"},
+		ActivityFilter{Token: "KNC"},
+		ActivityFilter{MiningStatus: "mined"},
+		ActivityFilter{FromTime: 1512189195898},
+		ActivityFilter{ToTime: 1512189195896},
+	}
+	for _, filter := range other {
+		if filter.Match(record) {
+			t.Errorf("Expected %+v not to match", filter)
+		}
+	}
+}
+
+func TestExchangeInfoCopyIsNotUpdated(t *testing.T) {
+	info := NewExchangeInfo()
+	info.Update("OMG-ETH", ExchangePrecisionLimit{Precision: TokenPairPrecision{Amount: 2, Price: 6}})
+	snapshot := info.Copy()
+	info.Update("KNC-ETH", ExchangePrecisionLimit{Precision: TokenPairPrecision{Amount: 0, Price: 8}})
+	data := snapshot.GetData()
+	if len(data) != 1 || data["OMG-ETH"].Precision.Amount != 2 {
+		t.Fatalf("Expected copy to only have OMG-ETH, got %v", data)
+	}
+}
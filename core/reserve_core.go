@@ -1,6 +1,7 @@
 package core
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
@@ -16,6 +17,7 @@ type ReserveCore struct {
 	blockchain      Blockchain
 	activityStorage ActivityStorage
 	rm              ethereum.Address
+	bus             *common.EventBus
 }
 
 func NewReserveCore(
@@ -26,18 +28,35 @@ func NewReserveCore(
 		blockchain,
 		storage,
 		rm,
+		nil,
 	}
 }
 
+// SetEventBus makes core publish an event for every activity it submits
+// to an exchange
+func (self *ReserveCore) SetEventBus(bus *common.EventBus) {
+	self.bus = bus
+}
+
+// publish tells subscribers about a submitted activity, failed ones
+// don't change anything to fetch
+func (self ReserveCore) publish(action string, id common.ActivityID, exchange common.Exchange, err error, timepoint uint64) {
+	if self.bus == nil || err != nil {
+		return
+	}
+	self.bus.Publish(common.ActivityEvent{id, action, exchange.ID(), timepoint})
+}
+
 func timebasedID(id string) common.ActivityID {
 	return common.NewActivityID(uint64(time.Now().UnixNano()), id)
 }
 
-func (self ReserveCore) CancelOrder(id common.ActivityID, exchange common.Exchange) error {
-	return exchange.CancelOrder(id)
+func (self ReserveCore) CancelOrder(ctx context.Context, id common.ActivityID, exchange common.Exchange) error {
+	return exchange.CancelOrder(ctx, id)
 }
 
 func (self ReserveCore) Trade(
+	ctx context.Context,
 	exchange common.Exchange,
 	tradeType string,
 	base common.Token,
@@ -46,7 +65,7 @@ func (self ReserveCore) Trade(
 	amount float64,
 	timepoint uint64) (common.ActivityID, float64, float64, bool, error) {
 
-	id, done, remaining, finished, err := exchange.Trade(tradeType, base, quote, rate, amount, timepoint)
+	id, done, remaining, finished, err := exchange.Trade(ctx, tradeType, base, quote, rate, amount, timepoint)
 	var status string
 	if err != nil {
 		status = "failed"
@@ -81,6 +100,7 @@ func (self ReserveCore) Trade(
 		"",
 		timepoint,
 	)
+	self.publish("trade", uid, exchange, err, timepoint)
 	log.Printf(
 		"Core ----------> %s on %s: base: %s, quote: %s, rate: %s, amount: %s, timestamp: %d ==> Result: id: %s, done: %s, remaining: %s, finished: %t, error: %s",
 		tradeType, exchange.ID(), base.ID, quote.ID,
@@ -100,12 +120,16 @@ func (self ReserveCore) Deposit(
 	amount *big.Int,
 	timepoint uint64) (common.ActivityID, error) {
 
-	address, supported := exchange.Address(token)
+	_, supported := exchange.Address(token)
 	tx := ethereum.Hash{}
 	var err error
 	if !supported {
 		tx = ethereum.Hash{}
 		err = errors.New(fmt.Sprintf("Exchange %s doesn't support token %s", exchange.ID(), token.ID))
+	} else if address, verr := exchange.VerifiedAddress(token); verr != nil {
+		// never send funds to an address the exchange didn't confirm
+		tx = ethereum.Hash{}
+		err = errors.New(fmt.Sprintf("Refused to deposit %s to %s: %s", token.ID, exchange.ID(), verr))
 	} else if self.activityStorage.HasPendingDeposit(token, exchange) {
 		tx = ethereum.Hash{}
 		err = errors.New(fmt.Sprintf("There is a pending %s deposit to %s currently, please try again", token.ID, exchange.ID()))
@@ -137,6 +161,7 @@ func (self ReserveCore) Deposit(
 		status,
 		timepoint,
 	)
+	self.publish("deposit", uid, exchange, err, timepoint)
 	log.Printf(
 		"Core ----------> Deposit to %s: token: %s, amount: %s, timestamp: %d ==> Result: tx: %s, error: %s",
 		exchange.ID(), token.ID, amount.Text(10), timepoint, tx.Hex(), err,
@@ -145,7 +170,7 @@ func (self ReserveCore) Deposit(
 }
 
 func (self ReserveCore) Withdraw(
-	exchange common.Exchange, token common.Token,
+	ctx context.Context, exchange common.Exchange, token common.Token,
 	amount *big.Int, timepoint uint64) (common.ActivityID, error) {
 
 	_, supported := exchange.Address(token)
@@ -154,7 +179,7 @@ func (self ReserveCore) Withdraw(
 	if !supported {
 		err = errors.New(fmt.Sprintf("Exchange %s doesn't support token %s", exchange.ID(), token.ID))
 	} else {
-		id, err = exchange.Withdraw(token, amount, self.rm, timepoint)
+		id, err = exchange.Withdraw(ctx, token, amount, self.rm, timepoint)
 	}
 	var status string
 	if err != nil {
@@ -183,6 +208,7 @@ func (self ReserveCore) Withdraw(
 		"",
 		timepoint,
 	)
+	self.publish("withdraw", uid, exchange, err, timepoint)
 	log.Printf(
 		"Core ----------> Withdraw from %s: token: %s, amount: %s, timestamp: %d ==> Result: id: %s, error: %s",
 		exchange.ID(), token.ID, amount.Text(10), timepoint, id, err,
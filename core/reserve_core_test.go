@@ -1,6 +1,8 @@
 package core
 
 import (
+	"context"
+	"errors"
 	"math/big"
 	"testing"
 
@@ -9,6 +11,7 @@ import (
 )
 
 type testExchange struct {
+	UnverifiedAddress bool
 }
 
 func (self testExchange) ID() common.ExchangeID {
@@ -17,18 +20,33 @@ func (self testExchange) ID() common.ExchangeID {
 func (self testExchange) Address(token common.Token) (address ethereum.Address, supported bool) {
 	return ethereum.Address{}, true
 }
-func (self testExchange) Withdraw(token common.Token, amount *big.Int, address ethereum.Address, timepoint uint64) (string, error) {
+func (self testExchange) VerifiedAddress(token common.Token) (address ethereum.Address, err error) {
+	if self.UnverifiedAddress {
+		return ethereum.Address{}, errors.New("address is not verified")
+	}
+	return ethereum.Address{}, nil
+}
+func (self testExchange) Withdraw(ctx context.Context, token common.Token, amount *big.Int, address ethereum.Address, timepoint uint64) (string, error) {
 	return "withdrawid", nil
 }
-func (self testExchange) Trade(tradeType string, base common.Token, quote common.Token, rate float64, amount float64, timepoint uint64) (id string, done float64, remaining float64, finished bool, err error) {
+func (self testExchange) Trade(ctx context.Context, tradeType string, base common.Token, quote common.Token, rate float64, amount float64, timepoint uint64) (id string, done float64, remaining float64, finished bool, err error) {
 	return "tradeid", 10, 5, false, nil
 }
-func (self testExchange) CancelOrder(id common.ActivityID) error {
+func (self testExchange) CancelOrder(ctx context.Context, id common.ActivityID) error {
 	return nil
 }
 func (self testExchange) MarshalText() (text []byte, err error) {
 	return []byte("bittrex"), nil
 }
+func (self testExchange) GetInfo() (common.ExchangeInfo, error) {
+	return common.ExchangeInfo{}, nil
+}
+func (self testExchange) GetExchangeInfo(pair common.TokenPairID) (common.ExchangePrecisionLimit, error) {
+	return common.ExchangePrecisionLimit{}, nil
+}
+func (self testExchange) GetFee() common.ExchangeFees {
+	return common.ExchangeFees{}
+}
 
 type testBlockchain struct {
 }
@@ -103,3 +121,40 @@ func TestNotAllowDeposit(t *testing.T) {
 		t.Fatalf("Expected to be able to deposit different token")
 	}
 }
+
+func TestNotAllowDepositToUnverifiedAddress(t *testing.T) {
+	core := getTestCore(false)
+	_, err := core.Deposit(
+		testExchange{UnverifiedAddress: true},
+		common.Token{"KNC", "0x1111111111111111111111111111111111111111", 18},
+		big.NewInt(10),
+		common.GetTimepoint(),
+	)
+	if err == nil {
+		t.Fatalf("Expected to refuse deposit to an address the exchange didn't verify")
+	}
+}
+
+func TestPublishSubmittedActivities(t *testing.T) {
+	core := getTestCore(true)
+	bus := common.NewEventBus()
+	events := bus.Subscribe(10)
+	core.SetEventBus(bus)
+	omg := common.Token{"OMG", "0x1111111111111111111111111111111111111111", 18}
+	tradeID, _, _, _, _ := core.Trade(context.Background(), testExchange{}, "buy", omg, omg, 0.01, 10, 1000)
+	// refused because of the pending deposit, nothing to publish
+	core.Deposit(testExchange{}, omg, big.NewInt(10), 1000)
+	withdrawID, _ := core.Withdraw(context.Background(), testExchange{}, omg, big.NewInt(10), 1000)
+	expect := func(event common.ActivityEvent, action string, id common.ActivityID) {
+		if event.Action != action || event.ID != id || event.Exchange != "bittrex" || event.Timepoint != 1000 {
+			t.Fatalf("Expected %s event of %s, got %+v", action, id, event)
+		}
+	}
+	expect(<-events, "trade", tradeID)
+	expect(<-events, "withdraw", withdrawID)
+	select {
+	case event := <-events:
+		t.Fatalf("Expected no other event, got %+v", event)
+	default:
+	}
+}
@@ -1,6 +1,16 @@
 package data
 
+import (
+	"io"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
 type Fetcher interface {
 	Run() error
 	Stop() error
+	Breakers() map[common.ExchangeID]common.BreakerStatus
+	GetSchedules() (*common.Schedules, error)
+	SetSchedules(schedules *common.Schedules) error
+	WriteMetrics(w io.Writer) error
 }
@@ -1,13 +1,22 @@
 package fetcher
 
 import (
+	"context"
+
 	"github.com/KyberNetwork/reserve-data/common"
 	ethereum "github.com/ethereum/go-ethereum/common"
 )
 
 type Blockchain interface {
-	FetchBalanceData(addr ethereum.Address, timepoint uint64) (map[string]common.BalanceEntry, error)
-	FetchRates(timepoint uint64) (common.AllRateEntry, error)
-	IsMined(tx ethereum.Hash) (bool, error)
-	CurrentBlock() (uint64, error)
+	FetchBalanceData(ctx context.Context, addr ethereum.Address, timepoint uint64) (map[string]common.BalanceEntry, error)
+	FetchRates(ctx context.Context, timepoint uint64) (common.AllRateEntry, error)
+	IsMined(ctx context.Context, tx ethereum.Hash) (bool, error)
+	CurrentBlock(ctx context.Context) (uint64, error)
+}
+
+// EventBlockchain is implemented by blockchains that can report events
+// logged by the reserve contracts
+type EventBlockchain interface {
+	Blockchain
+	FetchEvents(ctx context.Context, fromBlock, toBlock uint64) ([]common.ReserveEvent, error)
 }
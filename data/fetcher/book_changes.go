@@ -0,0 +1,48 @@
+package fetcher
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+// bookChanges remembers when each order book last changed so a book
+// frozen on exchange side can be told from a live one
+type bookChanges struct {
+	mu    sync.Mutex
+	books map[common.ExchangeID]map[common.TokenPairID]bookChange
+}
+
+type bookChange struct {
+	bids []common.PriceEntry
+	asks []common.PriceEntry
+	time common.Timestamp
+}
+
+func newBookChanges() *bookChanges {
+	return &bookChanges{
+		books: map[common.ExchangeID]map[common.TokenPairID]bookChange{},
+	}
+}
+
+// Update records price of pair on exchange and returns the last time its
+// book changed. Invalid prices don't count as a change.
+func (self *bookChanges) Update(exchange common.ExchangeID, pair common.TokenPairID, price common.ExchangePrice) common.Timestamp {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	books, found := self.books[exchange]
+	if !found {
+		books = map[common.TokenPairID]bookChange{}
+		self.books[exchange] = books
+	}
+	last, found := books[pair]
+	if !price.Valid {
+		return last.time
+	}
+	if !found || !reflect.DeepEqual(last.bids, price.Bids) || !reflect.DeepEqual(last.asks, price.Asks) {
+		last = bookChange{price.Bids, price.Asks, price.Timestamp}
+		books[pair] = last
+	}
+	return last.time
+}
@@ -0,0 +1,137 @@
+package fetcher
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+// RetryPolicy bounds how many times a failed fetch is tried within one
+// tick. Delays between attempts grow exponentially from BaseDelay up to
+// MaxDelay, with jitter so exchanges are not hit in lockstep.
+type RetryPolicy struct {
+	Attempts  int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// BreakerConfig opens the breaker of an exchange after Failures
+// consecutive failed ticks. After Cooldown one probe is let through,
+// its result closes or opens the breaker again.
+type BreakerConfig struct {
+	Failures int
+	Cooldown time.Duration
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{3, 200 * time.Millisecond, 2 * time.Second}
+}
+
+func DefaultBreakerConfig() BreakerConfig {
+	return BreakerConfig{5, time.Minute}
+}
+
+// Delay returns how long to wait before retry number attempt, counting
+// from 1. Half of the delay is fixed, the other half is random.
+func (self RetryPolicy) Delay(attempt int) time.Duration {
+	delay := self.BaseDelay
+	for i := 1; i < attempt && delay < self.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > self.MaxDelay {
+		delay = self.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+type breaker struct {
+	mu        sync.Mutex
+	config    BreakerConfig
+	state     string
+	failures  int
+	lastError string
+	openedAt  uint64
+	probing   bool
+}
+
+func newBreaker(config BreakerConfig) *breaker {
+	return &breaker{config: config, state: common.BREAKER_CLOSED}
+}
+
+func (self *breaker) retryAt() uint64 {
+	return self.openedAt + uint64(self.config.Cooldown/time.Millisecond)
+}
+
+// Allow tells if a call can go through at timepoint. An open breaker
+// turns half open once its cooldown is over and lets a single probe
+// through.
+func (self *breaker) Allow(timepoint uint64) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	switch self.state {
+	case common.BREAKER_OPEN:
+		if timepoint < self.retryAt() {
+			return errors.New(fmt.Sprintf(
+				"circuit breaker is open after %d failures, last error: %s", self.failures, self.lastError))
+		}
+		self.state = common.BREAKER_HALF_OPEN
+		self.probing = true
+		return nil
+	case common.BREAKER_HALF_OPEN:
+		if self.probing {
+			return errors.New("circuit breaker is half open, waiting for probe")
+		}
+		self.probing = true
+		return nil
+	}
+	return nil
+}
+
+// HalfOpen tells if the next call is a probe, probes are not retried
+func (self *breaker) HalfOpen() bool {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.state == common.BREAKER_HALF_OPEN
+}
+
+func (self *breaker) Success() {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.state = common.BREAKER_CLOSED
+	self.failures = 0
+	self.probing = false
+}
+
+func (self *breaker) Failure(err string, timepoint uint64) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.failures += 1
+	self.lastError = err
+	self.probing = false
+	if self.state == common.BREAKER_HALF_OPEN || self.failures >= self.config.Failures {
+		self.state = common.BREAKER_OPEN
+		self.openedAt = timepoint
+	}
+}
+
+func (self *breaker) Status() common.BreakerStatus {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	result := common.BreakerStatus{
+		State:     self.state,
+		Failures:  self.failures,
+		LastError: self.lastError,
+	}
+	if self.state != common.BREAKER_CLOSED {
+		result.OpenedAt = self.openedAt
+		result.RetryAt = self.retryAt()
+	}
+	return result
+}
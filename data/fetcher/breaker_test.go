@@ -0,0 +1,148 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+// failingExchange fails its first `failures` price and balance fetches
+type failingExchange struct {
+	failures int
+	calls    int
+}
+
+func (self *failingExchange) ID() common.ExchangeID {
+	return "failing"
+}
+
+func (self *failingExchange) Name() string {
+	return "failing"
+}
+
+func (self *failingExchange) TokenPairs() []common.TokenPair {
+	return []common.TokenPair{
+		common.TokenPair{
+			common.Token{"OMG", "0x1795b4560491c941c0635451f07332effe3ee7b3", 18},
+			common.Token{"ETH", "0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee", 18},
+		},
+	}
+}
+
+func (self *failingExchange) fail() bool {
+	self.calls += 1
+	return self.calls <= self.failures
+}
+
+func (self *failingExchange) FetchPriceData(ctx context.Context, timepoint uint64) (map[common.TokenPairID]common.ExchangePrice, error) {
+	if self.fail() {
+		return nil, errors.New("connection refused")
+	}
+	return map[common.TokenPairID]common.ExchangePrice{
+		"OMG-ETH": common.ExchangePrice{Valid: true},
+	}, nil
+}
+
+func (self *failingExchange) FetchEBalanceData(ctx context.Context, timepoint uint64) (common.EBalanceEntry, error) {
+	if self.fail() {
+		return common.EBalanceEntry{Valid: false, Error: "Code: -1001, Msg: disconnected"}, nil
+	}
+	return common.EBalanceEntry{Valid: true}, nil
+}
+
+func (self *failingExchange) OrderStatus(ctx context.Context, id common.ActivityID, timepoint uint64) (string, error) {
+	return "", nil
+}
+
+func (self *failingExchange) DepositStatus(ctx context.Context, id common.ActivityID, timepoint uint64) (string, error) {
+	return "", nil
+}
+
+func (self *failingExchange) WithdrawStatus(ctx context.Context, id common.ActivityID, timepoint uint64) (string, string, error) {
+	return "", "", nil
+}
+
+func newTestFetcher(ex Exchange, retry RetryPolicy, breaker BreakerConfig) *Fetcher {
+	fetcher := NewFetcher(nil, nil, ethereum.Address{})
+	fetcher.sleep = func(context.Context, time.Duration) {}
+	fetcher.SetResilience(retry, breaker)
+	fetcher.AddExchange(ex)
+	return fetcher
+}
+
+func TestRetryDelayIsBoundedWithJitter(t *testing.T) {
+	policy := RetryPolicy{5, 100 * time.Millisecond, time.Second}
+	for attempt, max := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		max = max * time.Millisecond
+		delay := policy.Delay(attempt + 1)
+		if delay < max/2 || delay > max {
+			t.Fatalf("Expected delay of retry %d between %s and %s, got %s", attempt+1, max/2, max, delay)
+		}
+	}
+}
+
+func TestFetchIsRetriedWithinTick(t *testing.T) {
+	ex := &failingExchange{failures: 2}
+	fetcher := newTestFetcher(ex, RetryPolicy{3, time.Millisecond, time.Millisecond}, BreakerConfig{1, time.Minute})
+	prices := fetcher.fetchPrices(context.Background(), ex, 1000)
+	if !prices["OMG-ETH"].Valid || ex.calls != 3 {
+		t.Fatalf("Expected the third attempt to succeed, got %v after %d calls", prices, ex.calls)
+	}
+	if status := fetcher.Breakers()["failing"]; status.State != common.BREAKER_CLOSED || status.Failures != 0 {
+		t.Fatalf("Expected breaker to stay closed, got %+v", status)
+	}
+}
+
+func TestBreakerOpensAndHalfOpens(t *testing.T) {
+	ex := &failingExchange{failures: 4}
+	fetcher := newTestFetcher(ex, RetryPolicy{2, time.Millisecond, time.Millisecond}, BreakerConfig{2, time.Minute})
+	fetcher.fetchBalances(context.Background(), ex, 1000)
+	if status := fetcher.Breakers()["failing"]; status.State != common.BREAKER_CLOSED || status.Failures != 1 {
+		t.Fatalf("Expected breaker to count one failed tick, got %+v", status)
+	}
+	fetcher.fetchBalances(context.Background(), ex, 2000)
+	status := fetcher.Breakers()["failing"]
+	if status.State != common.BREAKER_OPEN || status.LastError == "" {
+		t.Fatalf("Expected breaker to open, got %+v", status)
+	}
+	balances, err := fetcher.fetchBalances(context.Background(), ex, 3000)
+	if err != nil || balances.Valid || balances.Error == "" || ex.calls != 4 {
+		t.Fatalf("Expected an open breaker to skip the exchange, got %+v, %v after %d calls", balances, err, ex.calls)
+	}
+	prices := fetcher.fetchPrices(context.Background(), ex, 3000)
+	if len(prices) != 1 || prices["OMG-ETH"].Valid {
+		t.Fatalf("Expected an open breaker to give invalid prices, got %+v", prices)
+	}
+	// cooldown is over, a single probe goes through
+	breaker := fetcher.breakers["failing"]
+	breaker.openedAt -= 60000
+	balances, _ = fetcher.fetchBalances(context.Background(), ex, 4000)
+	if !balances.Valid || ex.calls != 5 {
+		t.Fatalf("Expected the probe to succeed, got %+v after %d calls", balances, ex.calls)
+	}
+	if status := fetcher.Breakers()["failing"]; status.State != common.BREAKER_CLOSED {
+		t.Fatalf("Expected a successful probe to close the breaker, got %+v", status)
+	}
+}
+
+func TestFailedProbeReopensBreaker(t *testing.T) {
+	b := newBreaker(BreakerConfig{1, time.Second})
+	b.Failure("timeout", 1000)
+	if err := b.Allow(1500); err == nil {
+		t.Fatalf("Expected breaker to be open during cooldown")
+	}
+	if err := b.Allow(2000); err != nil || !b.HalfOpen() {
+		t.Fatalf("Expected breaker to let a probe through after cooldown, got %v", err)
+	}
+	if err := b.Allow(2000); err == nil {
+		t.Fatalf("Expected only one probe at a time")
+	}
+	b.Failure("timeout", 2100)
+	if status := b.Status(); status.State != common.BREAKER_OPEN || status.RetryAt != 3100 {
+		t.Fatalf("Expected failed probe to open the breaker again, got %+v", status)
+	}
+}
@@ -0,0 +1,59 @@
+package fetcher
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+// SetCarryForward makes books of an exchange that failed to be fetched
+// carried forward from its last good fetch, marked CarriedForward, until they are
+// older than maxAge. 0 stops carrying books, which is the default.
+func (self *Fetcher) SetCarryForward(maxAge time.Duration) {
+	self.pricesMu.Lock()
+	defer self.pricesMu.Unlock()
+	self.carryMaxAge = maxAge
+}
+
+// carryForward remembers valid books of exchange just fetched and puts
+// the last good ones in place of those that failed. It must be called
+// with pricesMu held.
+func (self *Fetcher) carryForward(exchange Exchange, timepoint uint64) {
+	prices := self.prices[exchange.ID()]
+	lastGood, found := self.lastGood[exchange.ID()]
+	if !found {
+		lastGood = map[common.TokenPairID]common.ExchangePrice{}
+		self.lastGood[exchange.ID()] = lastGood
+	}
+	for pair, price := range prices {
+		if price.Valid {
+			lastGood[pair] = price
+		}
+	}
+	maxAge := uint64(self.carryMaxAge / time.Millisecond)
+	for _, tokenPair := range exchange.TokenPairs() {
+		pair := tokenPair.PairID()
+		price, fetched := prices[pair]
+		if fetched && price.Valid {
+			continue
+		}
+		good, found := lastGood[pair]
+		if !found {
+			continue
+		}
+		requested, err := strconv.ParseUint(string(good.Timestamp), 10, 64)
+		if err != nil || timepoint > requested+maxAge {
+			delete(lastGood, pair)
+			continue
+		}
+		reason := "no book was fetched"
+		if fetched {
+			reason = price.Error
+		}
+		good.CarriedForward = true
+		good.Error = fmt.Sprintf("carried forward, fetching failed: %s", reason)
+		prices[pair] = good
+	}
+}
@@ -0,0 +1,70 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+// flakyBooks returns books stamped with the timepoint they are fetched
+// at, unless it is down
+type flakyBooks struct {
+	failingExchange
+	down bool
+}
+
+func (self *flakyBooks) FetchPriceData(ctx context.Context, timepoint uint64) (map[common.TokenPairID]common.ExchangePrice, error) {
+	if self.down {
+		return nil, errors.New("connection refused")
+	}
+	return map[common.TokenPairID]common.ExchangePrice{
+		"OMG-ETH": common.ExchangePrice{Valid: true, Timestamp: common.Timestamp(fmt.Sprintf("%d", timepoint))},
+	}, nil
+}
+
+func fetchBooks(carry time.Duration) (*flakyBooks, *Fetcher, *priceStorage) {
+	storage := &priceStorage{}
+	fetcher := NewFetcher(storage, nil, ethereum.Address{})
+	fetcher.sleep = func(context.Context, time.Duration) {}
+	fetcher.SetCarryForward(carry)
+	ex := &flakyBooks{}
+	fetcher.AddExchange(ex)
+	fetcher.FetchOrderbook(context.Background(), 1000)
+	ex.down = true
+	return ex, fetcher, storage
+}
+
+func TestFailedBooksAreCarriedForward(t *testing.T) {
+	_, fetcher, storage := fetchBooks(10 * time.Second)
+	fetcher.FetchOrderbook(context.Background(), 5000)
+	price, found := storage.prices.Data["OMG-ETH"]["failing"]
+	if !found || !price.Valid || !price.CarriedForward || price.Timestamp != "1000" {
+		t.Fatalf("Expected last good book carried forward with its timestamp, got %+v", price)
+	}
+	fetcher.FetchOrderbook(context.Background(), 12000)
+	if price, found := storage.prices.Data["OMG-ETH"]["failing"]; found {
+		t.Fatalf("Expected book older than max age not to be carried, got %+v", price)
+	}
+}
+
+func TestFetchedBooksAreNotCarried(t *testing.T) {
+	ex, fetcher, storage := fetchBooks(10 * time.Second)
+	ex.down = false
+	fetcher.FetchOrderbook(context.Background(), 5000)
+	if price := storage.prices.Data["OMG-ETH"]["failing"]; price.CarriedForward || price.Timestamp != "5000" {
+		t.Fatalf("Expected fresh book, got %+v", price)
+	}
+}
+
+func TestBooksAreNotCarriedByDefault(t *testing.T) {
+	_, fetcher, storage := fetchBooks(0)
+	fetcher.FetchOrderbook(context.Background(), 5000)
+	if price, found := storage.prices.Data["OMG-ETH"]["failing"]; found {
+		t.Fatalf("Expected failed book not to be carried by default, got %+v", price)
+	}
+}
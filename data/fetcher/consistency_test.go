@@ -0,0 +1,267 @@
+package fetcher
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+// busyExchange reports a new status of every order each time it is
+// asked, after `stable` calls the status stops changing
+type busyExchange struct {
+	failingExchange
+	mu     sync.Mutex
+	polls  int
+	stable int
+	delay  time.Duration
+}
+
+func (self *busyExchange) OrderStatus(ctx context.Context, id common.ActivityID, timepoint uint64) (string, error) {
+	time.Sleep(self.delay)
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.polls += 1
+	if self.stable > 0 && self.polls > self.stable {
+		return "done", nil
+	}
+	return fmt.Sprintf("filled %d", self.polls), nil
+}
+
+// busyBlockchain flips whether the tx is mined every time it is asked
+type busyBlockchain struct {
+	polls int
+}
+
+func (self *busyBlockchain) FetchBalanceData(ctx context.Context, addr ethereum.Address, timepoint uint64) (map[string]common.BalanceEntry, error) {
+	return map[string]common.BalanceEntry{"ETH": common.BalanceEntry{Valid: true}}, nil
+}
+
+func (self *busyBlockchain) FetchRates(ctx context.Context, timepoint uint64) (common.AllRateEntry, error) {
+	return common.AllRateEntry{}, nil
+}
+
+func (self *busyBlockchain) IsMined(ctx context.Context, tx ethereum.Hash) (bool, error) {
+	self.polls += 1
+	return self.polls%2 == 0, nil
+}
+
+func (self *busyBlockchain) CurrentBlock(ctx context.Context) (uint64, error) {
+	return 0, nil
+}
+
+var pendingTrade = []common.ActivityRecord{
+	common.ActivityRecord{
+		Action:      "trade",
+		ID:          common.ActivityID{1, "1_OMGETH"},
+		Destination: "failing",
+	},
+}
+
+func fetchFromExchange(fetcher *Fetcher, ex Exchange) (bool, bool) {
+	balances, statuses, consistent := sync.Map{}, sync.Map{}, sync.Map{}
+	wait := sync.WaitGroup{}
+	wait.Add(1)
+	fetcher.FetchAuthDataFromExchange(context.Background(), &wait, ex, &balances, &statuses, &consistent, pendingTrade, 1000)
+	_, stored := balances.Load(ex.ID())
+	result, _ := consistent.Load(ex.ID())
+	return stored, result.(bool)
+}
+
+func TestDoubleCheckGivesUpAfterAttempts(t *testing.T) {
+	ex := &busyExchange{}
+	fetcher := newTestFetcher(ex, DefaultRetryPolicy(), DefaultBreakerConfig())
+	fetcher.SetConsistency(ConsistencyPolicy{3, time.Minute})
+	stored, consistent := fetchFromExchange(fetcher, ex)
+	if !stored || consistent {
+		t.Fatalf("Expected balances stored as inconsistent, got stored %v, consistent %v", stored, consistent)
+	}
+	if ex.polls != 6 {
+		t.Fatalf("Expected 3 attempts of 2 status checks, got %d checks", ex.polls)
+	}
+}
+
+func TestDoubleCheckGivesUpAfterDeadline(t *testing.T) {
+	ex := &busyExchange{delay: 5 * time.Millisecond}
+	fetcher := newTestFetcher(ex, DefaultRetryPolicy(), DefaultBreakerConfig())
+	fetcher.SetConsistency(ConsistencyPolicy{1000000, 30 * time.Millisecond})
+	start := time.Now()
+	stored, consistent := fetchFromExchange(fetcher, ex)
+	if !stored || consistent {
+		t.Fatalf("Expected balances stored as inconsistent, got stored %v, consistent %v", stored, consistent)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Expected double check to stop at its deadline, took %s", elapsed)
+	}
+}
+
+func TestDoubleCheckSettles(t *testing.T) {
+	ex := &busyExchange{stable: 2}
+	fetcher := newTestFetcher(ex, DefaultRetryPolicy(), DefaultBreakerConfig())
+	fetcher.SetConsistency(ConsistencyPolicy{5, time.Minute})
+	stored, consistent := fetchFromExchange(fetcher, ex)
+	if !stored || !consistent || ex.polls != 4 {
+		t.Fatalf("Expected consistent balances on the second attempt, got stored %v, consistent %v after %d checks", stored, consistent, ex.polls)
+	}
+}
+
+func TestBlockchainDoubleCheckGivesUp(t *testing.T) {
+	bc := &busyBlockchain{}
+	fetcher := NewFetcher(nil, nil, ethereum.Address{})
+	fetcher.SetBlockchain(bc)
+	fetcher.SetConsistency(ConsistencyPolicy{4, time.Minute})
+	pendings := []common.ActivityRecord{
+		common.ActivityRecord{
+			Action: "set_rates",
+			ID:     common.ActivityID{1, "0x1"},
+			Result: map[string]interface{}{"tx": "0x1"},
+		},
+	}
+	balances, statuses := map[string]common.BalanceEntry{}, sync.Map{}
+	if fetcher.FetchAuthDataFromBlockchain(context.Background(), balances, &statuses, pendings, 1000) {
+		t.Fatalf("Expected blockchain statuses to be inconsistent")
+	}
+	if !balances["ETH"].Valid || bc.polls != 8 {
+		t.Fatalf("Expected balances stored after 4 attempts, got %v after %d checks", balances, bc.polls)
+	}
+}
+
+type authStorage struct {
+	Storage
+	snapshot *common.AuthDataSnapshot
+}
+
+func (self *authStorage) GetPendingActivities() ([]common.ActivityRecord, error) {
+	return pendingTrade, nil
+}
+
+func (self *authStorage) UpdateActivity(id common.ActivityID, act common.ActivityRecord) error {
+	return nil
+}
+
+func (self *authStorage) StoreAuthSnapshot(data *common.AuthDataSnapshot, timepoint uint64) error {
+	self.snapshot = data
+	return nil
+}
+
+func TestSnapshotRecordsInconsistentExchanges(t *testing.T) {
+	storage := &authStorage{}
+	ex := &busyExchange{}
+	fetcher := NewFetcher(storage, nil, ethereum.Address{})
+	fetcher.SetBlockchain(&busyBlockchain{})
+	fetcher.SetConsistency(ConsistencyPolicy{2, time.Minute})
+	fetcher.AddExchange(ex)
+	fetcher.FetchAllAuthData(context.Background(), 1000)
+	if storage.snapshot == nil || len(storage.snapshot.Inconsistent) != 1 || storage.snapshot.Inconsistent[0] != "failing" {
+		t.Fatalf("Expected snapshot to record failing as inconsistent, got %+v", storage.snapshot)
+	}
+	if !storage.snapshot.Valid || !storage.snapshot.ExchangeBalances["failing"].Valid {
+		t.Fatalf("Expected snapshot to be stored with the last balances, got %+v", storage.snapshot)
+	}
+}
+
+func TestActivityBurstRefreshesOnce(t *testing.T) {
+	storage := &authStorage{}
+	ex := &failingExchange{}
+	bus := common.NewEventBus()
+	fetcher := NewFetcher(storage, NewScheduleRunner(common.NewSchedules(map[string]common.Schedule{})), ethereum.Address{})
+	fetcher.SetBlockchain(&busyBlockchain{})
+	fetcher.AddExchange(ex)
+	fetcher.SubscribeActivities(bus, 50*time.Millisecond)
+	if err := fetcher.Run(); err != nil {
+		t.Fatalf("Expected fetcher to run, got %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		bus.Publish(common.ActivityEvent{common.ActivityID{uint64(i), "1_OMGETH"}, "trade", "failing", 1000})
+		// events of exchanges not fetched are ignored
+		bus.Publish(common.ActivityEvent{common.ActivityID{uint64(i), "1_OMGETH"}, "trade", "unknown", 1000})
+	}
+	time.Sleep(200 * time.Millisecond)
+	fetcher.Stop()
+	if ex.calls != 1 {
+		t.Fatalf("Expected a burst of activities to fetch balances once, got %d fetches", ex.calls)
+	}
+	if storage.snapshot == nil || !storage.snapshot.ExchangeBalances["failing"].Valid {
+		t.Fatalf("Expected refreshed balances to be stored, got %+v", storage.snapshot)
+	}
+}
+
+// recordStorage keeps activities by id, as bolt and postgres do
+type recordStorage struct {
+	authStorage
+	mu      sync.Mutex
+	records map[common.ActivityID]common.ActivityRecord
+	updated []common.ActivityID
+}
+
+func (self *recordStorage) GetPendingActivities() ([]common.ActivityRecord, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	result := []common.ActivityRecord{}
+	for _, record := range self.records {
+		if record.IsPending() {
+			result = append(result, record)
+		}
+	}
+	return result, nil
+}
+
+func (self *recordStorage) UpdateActivity(id common.ActivityID, act common.ActivityRecord) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.records[id] = act
+	self.updated = append(self.updated, id)
+	return nil
+}
+
+// racingExchange lets another loop store activities while its balances
+// are being fetched
+type racingExchange struct {
+	failingExchange
+	meanwhile func()
+}
+
+func (self *racingExchange) FetchEBalanceData(ctx context.Context, timepoint uint64) (common.EBalanceEntry, error) {
+	if self.meanwhile != nil {
+		self.meanwhile()
+		self.meanwhile = nil
+	}
+	return self.failingExchange.FetchEBalanceData(ctx, timepoint)
+}
+
+func TestAuthDataKeepsActivitiesOfOtherExchanges(t *testing.T) {
+	other := common.ActivityRecord{
+		Action:      "trade",
+		ID:          common.ActivityID{2, "2_OMGETH"},
+		Destination: "other",
+	}
+	storage := &recordStorage{records: map[common.ActivityID]common.ActivityRecord{
+		pendingTrade[0].ID: pendingTrade[0],
+		other.ID:           other,
+	}}
+	ex := &racingExchange{}
+	ex.meanwhile = func() {
+		// the loop of other finishes its trade
+		done := other
+		done.ExchangeStatus = "done"
+		storage.UpdateActivity(done.ID, done)
+	}
+	fetcher := NewFetcher(storage, nil, ethereum.Address{})
+	fetcher.AddExchange(ex)
+	fetcher.fetchExchangeAuthData(context.Background(), 1000, []Exchange{ex})
+	if status := storage.records[other.ID].ExchangeStatus; status != "done" {
+		t.Fatalf("Expected trade on other to stay done, got %q", status)
+	}
+	for _, id := range storage.updated[1:] {
+		if id == other.ID {
+			t.Fatalf("Expected only activities of failing to be written, got %v", storage.updated)
+		}
+	}
+	if storage.snapshot == nil || len(storage.snapshot.PendingActivities) != 1 {
+		t.Fatalf("Expected only the trade on failing to be pending, got %+v", storage.snapshot)
+	}
+}
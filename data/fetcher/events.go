@@ -0,0 +1,84 @@
+package fetcher
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+const (
+	// blocks watched again on every fetch, events of blocks replaced by
+	// a reorganization no deeper than this are replaced as well
+	REORG_DEPTH uint64 = 12
+	// most blocks asked for events at once when catching up
+	MAX_EVENT_BLOCKS uint64 = 1000
+)
+
+// SetEventStartBlock makes events be watched from block when none was
+// watched before
+func (self *Fetcher) SetEventStartBlock(block uint64) {
+	self.eventStart = block
+}
+
+// eventRange returns the blocks to ask events for when the chain is at
+// block and events were watched up to last
+func (self *Fetcher) eventRange(last, block uint64) (uint64, uint64) {
+	var from uint64
+	if last == 0 {
+		from = self.eventStart
+		if from == 0 {
+			from = block
+		}
+	} else {
+		from = last + 1
+		if block < from {
+			// the chain got shorter, watch its head again
+			from = block + 1
+		}
+		if from > REORG_DEPTH {
+			from -= REORG_DEPTH
+		} else {
+			from = 1
+		}
+		if from < self.eventStart {
+			from = self.eventStart
+		}
+	}
+	to := block
+	if from <= to && to-from+1 > MAX_EVENT_BLOCKS {
+		to = from + MAX_EVENT_BLOCKS - 1
+	}
+	return from, to
+}
+
+// fetchEvents stores events the reserve contracts logged since the last
+// watched block, the last REORG_DEPTH blocks are watched again so events
+// of reorganized blocks don't stay
+func (self *Fetcher) fetchEvents(ctx context.Context, block uint64) {
+	blockchain, ok := self.blockchain.(EventBlockchain)
+	if !ok {
+		return
+	}
+	last, err := self.storage.LastEventBlock()
+	if err != nil {
+		log.Printf("Getting last watched block failed: %s\n", err)
+		return
+	}
+	from, to := self.eventRange(last, block)
+	if from > to {
+		return
+	}
+	start := time.Now()
+	events, err := blockchain.FetchEvents(ctx, from, to)
+	self.metrics.Observe("blockchain", METRIC_EVENTS, start, len(events), failure(err))
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		log.Printf("Fetching events of blocks %d to %d failed: %s\n", from, to, err)
+		return
+	}
+	if err = self.storage.StoreReserveEvents(from, to, events); err != nil {
+		log.Printf("Storing events of blocks %d to %d failed: %s\n", from, to, err)
+	}
+}
@@ -0,0 +1,101 @@
+package fetcher
+
+import (
+	"context"
+	"testing"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+// eventChain logs events at the blocks they are keyed by, up to head
+type eventChain struct {
+	busyBlockchain
+	head   uint64
+	events map[uint64]common.ReserveEvent
+	asked  [][2]uint64
+}
+
+func (self *eventChain) CurrentBlock(ctx context.Context) (uint64, error) {
+	return self.head, nil
+}
+
+func (self *eventChain) FetchEvents(ctx context.Context, fromBlock, toBlock uint64) ([]common.ReserveEvent, error) {
+	self.asked = append(self.asked, [2]uint64{fromBlock, toBlock})
+	result := []common.ReserveEvent{}
+	for block := fromBlock; block <= toBlock; block++ {
+		if event, found := self.events[block]; found {
+			result = append(result, event)
+		}
+	}
+	return result, nil
+}
+
+type eventStorage struct {
+	Storage
+	events []common.ReserveEvent
+	last   uint64
+}
+
+func (self *eventStorage) StoreReserveEvents(fromBlock, toBlock uint64, events []common.ReserveEvent) error {
+	kept := []common.ReserveEvent{}
+	for _, event := range self.events {
+		if event.BlockNumber < fromBlock {
+			kept = append(kept, event)
+		}
+	}
+	self.events = append(kept, events...)
+	self.last = toBlock
+	return nil
+}
+
+func (self *eventStorage) LastEventBlock() (uint64, error) {
+	return self.last, nil
+}
+
+func TestEventRange(t *testing.T) {
+	fetcher := NewFetcher(nil, nil, ethereum.Address{})
+	cases := []struct {
+		start, last, block, from, to uint64
+	}{
+		{0, 0, 500, 500, 500},
+		{100, 0, 5000, 100, 1099},
+		{0, 500, 510, 489, 510},
+		{0, 5, 10, 1, 10},
+		{490, 500, 510, 490, 510},
+		{0, 500, 495, 484, 495},
+	}
+	for _, c := range cases {
+		fetcher.SetEventStartBlock(c.start)
+		from, to := fetcher.eventRange(c.last, c.block)
+		if from != c.from || to != c.to {
+			t.Errorf("Expected blocks %d to %d after %d at %d, got %d to %d", c.from, c.to, c.last, c.block, from, to)
+		}
+	}
+}
+
+func TestReorganizedEventsAreReplaced(t *testing.T) {
+	storage := &eventStorage{}
+	chain := &eventChain{head: 100, events: map[uint64]common.ReserveEvent{
+		95: common.ReserveEvent{Kind: "trade", BlockNumber: 95, BlockHash: "0xa"},
+	}}
+	fetcher := NewFetcher(storage, nil, ethereum.Address{})
+	fetcher.SetBlockchain(chain)
+	fetcher.SetEventStartBlock(90)
+	fetcher.FetchCurrentBlock(context.Background(), 1000)
+	if len(storage.events) != 1 || storage.events[0].BlockHash != "0xa" || storage.last != 100 {
+		t.Fatalf("Expected the event of block 95 to be stored up to block 100, got %+v up to %d", storage.events, storage.last)
+	}
+	// the trade moved to another block after a reorganization
+	chain.head = 101
+	chain.events = map[uint64]common.ReserveEvent{
+		96: common.ReserveEvent{Kind: "trade", BlockNumber: 96, BlockHash: "0xb"},
+	}
+	fetcher.FetchCurrentBlock(context.Background(), 2000)
+	if len(storage.events) != 1 || storage.events[0].BlockHash != "0xb" || storage.last != 101 {
+		t.Fatalf("Expected only the reorganized event to be stored up to block 101, got %+v up to %d", storage.events, storage.last)
+	}
+	if chain.asked[1] != [2]uint64{90, 101} {
+		t.Fatalf("Expected blocks 90 to 101 to be watched again, got %v", chain.asked[1])
+	}
+}
@@ -1,6 +1,8 @@
 package fetcher
 
 import (
+	"context"
+
 	"github.com/KyberNetwork/reserve-data/common"
 )
 
@@ -8,10 +10,17 @@ type Exchange interface {
 	ID() common.ExchangeID
 	Name() string
 	TokenPairs() []common.TokenPair
-	FetchPriceData(timepoint uint64) (map[common.TokenPairID]common.ExchangePrice, error)
-	FetchEBalanceData(timepoint uint64) (common.EBalanceEntry, error)
+	FetchPriceData(ctx context.Context, timepoint uint64) (map[common.TokenPairID]common.ExchangePrice, error)
+	FetchEBalanceData(ctx context.Context, timepoint uint64) (common.EBalanceEntry, error)
 	// FetchOrderData(timepoint uint64) (common.OrderEntry, error)
-	OrderStatus(id common.ActivityID, timepoint uint64) (string, error)
-	DepositStatus(id common.ActivityID, timepoint uint64) (string, error)
-	WithdrawStatus(id common.ActivityID, timepoint uint64) (string, string, error)
+	OrderStatus(ctx context.Context, id common.ActivityID, timepoint uint64) (string, error)
+	DepositStatus(ctx context.Context, id common.ActivityID, timepoint uint64) (string, error)
+	WithdrawStatus(ctx context.Context, id common.ActivityID, timepoint uint64) (string, string, error)
+}
+
+// TradeHistoryExchange is implemented by exchanges that can report fills
+// of our orders
+type TradeHistoryExchange interface {
+	Exchange
+	TradeHistory(ctx context.Context, pair common.TokenPair, since uint64) ([]common.TradeFill, error)
 }
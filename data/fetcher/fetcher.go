@@ -1,8 +1,14 @@
 package fetcher
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/KyberNetwork/reserve-data/common"
 	ethereum "github.com/ethereum/go-ethereum/common"
@@ -15,6 +21,64 @@ type Fetcher struct {
 	runner       FetcherRunner
 	rmaddr       ethereum.Address
 	currentBlock uint64
+	changes      *bookChanges
+	retry        RetryPolicy
+	breaker      BreakerConfig
+	breakers     map[common.ExchangeID]*breaker
+	sleep        func(context.Context, time.Duration)
+	// cancels fetches of the running loops, which loops waits for
+	cancel context.CancelFunc
+	loops  sync.WaitGroup
+	// latest prices and balances of every exchange, so an exchange
+	// fetched on its own is stored along with the others
+	pricesMu sync.Mutex
+	prices   map[common.ExchangeID]map[common.TokenPairID]common.ExchangePrice
+	// last valid books, carried forward for up to carryMaxAge when an
+	// exchange fails, 0 doesn't carry any
+	lastGood    map[common.ExchangeID]map[common.TokenPairID]common.ExchangePrice
+	carryMaxAge time.Duration
+	// serializes storing auth data, so activities are updated from their
+	// latest stored state
+	authMu   sync.Mutex
+	balances map[common.ExchangeID]common.EBalanceEntry
+	// exchanges whose latest balances were taken without consistent
+	// activity statuses
+	inconsistent map[common.ExchangeID]bool
+	// latest reserve balances and whether mining statuses kept changing
+	// around them, they are fetched apart from exchanges when auth data
+	// is scheduled per exchange
+	reserveBalances     map[string]common.BalanceEntry
+	reserveInconsistent bool
+	consistency         ConsistencyPolicy
+	// activities core submitted, their exchanges are refreshed at most
+	// once per debounce
+	events   <-chan common.ActivityEvent
+	debounce time.Duration
+	// refreshes tick the auth data loop of an exchange, or the loop of
+	// every exchange, out of schedule
+	refreshes  map[common.ExchangeID]chan time.Time
+	refreshAll chan time.Time
+	metrics    *FetchMetrics
+	// block events are watched from when none was watched before, 0
+	// starts from the current block
+	eventStart uint64
+}
+
+// ConsistencyPolicy bounds the double check of auth data. Balances are
+// fetched again while activity statuses change around them, at most
+// Attempts times and until Deadline has passed since the first attempt.
+type ConsistencyPolicy struct {
+	Attempts int
+	Deadline time.Duration
+}
+
+func DefaultConsistencyPolicy() ConsistencyPolicy {
+	return ConsistencyPolicy{5, 10 * time.Second}
+}
+
+// exhausted tells if no more attempt should be made after attempt
+func (self ConsistencyPolicy) exhausted(attempt int, start time.Time) bool {
+	return attempt >= self.Attempts || time.Since(start) >= self.Deadline
 }
 
 func NewFetcher(
@@ -27,57 +91,447 @@ func NewFetcher(
 		blockchain: nil,
 		runner:     runner,
 		rmaddr:     address,
+		changes:    newBookChanges(),
+		retry:      DefaultRetryPolicy(),
+		breaker:    DefaultBreakerConfig(),
+		breakers:   map[common.ExchangeID]*breaker{},
+		refreshes:  map[common.ExchangeID]chan time.Time{},
+		refreshAll: make(chan time.Time, 1),
+		sleep:      sleepContext,
+		prices:     map[common.ExchangeID]map[common.TokenPairID]common.ExchangePrice{},
+		balances:   map[common.ExchangeID]common.EBalanceEntry{},
+		lastGood:   map[common.ExchangeID]map[common.TokenPairID]common.ExchangePrice{},
+
+		inconsistent:    map[common.ExchangeID]bool{},
+		reserveBalances: map[string]common.BalanceEntry{},
+		consistency:     DefaultConsistencyPolicy(),
+		metrics:         NewFetchMetrics(),
 	}
 }
 
+// SetResilience changes how fetches from exchanges are retried and when
+// their breakers open. It must be called before exchanges are added.
+func (self *Fetcher) SetResilience(retry RetryPolicy, breaker BreakerConfig) {
+	self.retry = retry
+	self.breaker = breaker
+}
+
 func (self *Fetcher) SetBlockchain(blockchain Blockchain) {
 	self.blockchain = blockchain
 }
 
 func (self *Fetcher) AddExchange(exchange Exchange) {
 	self.exchanges = append(self.exchanges, exchange)
+	self.breakers[exchange.ID()] = newBreaker(self.breaker)
+	self.refreshes[exchange.ID()] = make(chan time.Time, 1)
+}
+
+// SetConsistency changes how long auth data is double checked
+func (self *Fetcher) SetConsistency(policy ConsistencyPolicy) {
+	self.consistency = policy
+}
+
+// SubscribeActivities makes the fetcher refresh auth data of an exchange
+// right after core submits an activity to it. Activities coming within
+// debounce of the first one are refreshed by a single fetch.
+func (self *Fetcher) SubscribeActivities(bus *common.EventBus, debounce time.Duration) {
+	self.events = bus.Subscribe(100)
+	self.debounce = debounce
+}
+
+// WriteMetrics writes latency, result and size of every fetch so far in
+// prometheus text format
+func (self *Fetcher) WriteMetrics(w io.Writer) error {
+	return self.metrics.WritePrometheus(w)
+}
+
+// Breakers returns state of the circuit breaker of every exchange
+func (self *Fetcher) Breakers() map[common.ExchangeID]common.BreakerStatus {
+	result := map[common.ExchangeID]common.BreakerStatus{}
+	for id, breaker := range self.breakers {
+		result[id] = breaker.Status()
+	}
+	return result
+}
+
+// sleepContext sleeps for d or until ctx is canceled
+func sleepContext(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
 }
 
+// guard calls fetch, which returns the reason it failed or an empty
+// string, retrying it with backoff as long as the retry policy allows.
+// A tick that fails after all attempts counts as one failure of the
+// exchange's breaker. It returns an error without calling fetch if the
+// breaker is open. Fetches cut short by ctx are not retried and don't
+// count as failures.
+func (self *Fetcher) guard(ctx context.Context, exchange Exchange, fetch func() string) error {
+	breaker := self.breakers[exchange.ID()]
+	if err := breaker.Allow(common.GetTimepoint()); err != nil {
+		return err
+	}
+	attempts := self.retry.Attempts
+	if attempts < 1 || breaker.HalfOpen() {
+		attempts = 1
+	}
+	reason := ""
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			self.sleep(ctx, self.retry.Delay(attempt-1))
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		if reason = fetch(); reason == "" {
+			breaker.Success()
+			return nil
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		log.Printf("Fetching from %s failed, attempt %d/%d: %s\n", exchange.Name(), attempt, attempts, reason)
+	}
+	breaker.Failure(reason, common.GetTimepoint())
+	return nil
+}
+
+func (self *Fetcher) fetchPrices(ctx context.Context, exchange Exchange, timepoint uint64) map[common.TokenPairID]common.ExchangePrice {
+	var exdata map[common.TokenPairID]common.ExchangePrice
+	blocked := self.guard(ctx, exchange, func() string {
+		start := time.Now()
+		var err error
+		exdata, err = exchange.FetchPriceData(ctx, timepoint)
+		reason := pricesFailure(exdata, err)
+		self.metrics.Observe(string(exchange.ID()), METRIC_ORDERBOOK, start, len(exdata), reason)
+		return reason
+	})
+	if blocked != nil {
+		log.Printf("Skipped fetching prices from %s: %s\n", exchange.Name(), blocked)
+		self.metrics.Skip(string(exchange.ID()), METRIC_ORDERBOOK, "breaker_open")
+		timestamp := common.Timestamp(fmt.Sprintf("%d", timepoint))
+		exdata = map[common.TokenPairID]common.ExchangePrice{}
+		for _, pair := range exchange.TokenPairs() {
+			exdata[pair.PairID()] = common.ExchangePrice{
+				Valid:      false,
+				Error:      blocked.Error(),
+				Timestamp:  timestamp,
+				ReturnTime: common.GetTimestamp(),
+			}
+		}
+	}
+	return exdata
+}
+
+// pricesFailure returns why fetching books failed, an exchange is
+// failing if none of its books could be fetched
+func pricesFailure(exdata map[common.TokenPairID]common.ExchangePrice, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	reason := ""
+	for _, price := range exdata {
+		if price.Valid {
+			return ""
+		}
+		reason = fmt.Sprintf("no valid order book, last error: %s", price.Error)
+	}
+	return reason
+}
+
+// failure returns the reason of err, empty if there is none
+func failure(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func (self *Fetcher) fetchBalances(ctx context.Context, exchange Exchange, timepoint uint64) (common.EBalanceEntry, error) {
+	var balances common.EBalanceEntry
+	var err error
+	blocked := self.guard(ctx, exchange, func() string {
+		start := time.Now()
+		balances, err = exchange.FetchEBalanceData(ctx, timepoint)
+		reason := failure(err)
+		if err == nil && !balances.Valid {
+			reason = fmt.Sprintf("invalid balances: %s", balances.Error)
+		}
+		self.metrics.Observe(string(exchange.ID()), METRIC_BALANCE, start, len(balances.AvailableBalance), reason)
+		return reason
+	})
+	if blocked != nil {
+		log.Printf("Skipped fetching balances from %s: %s\n", exchange.Name(), blocked)
+		self.metrics.Skip(string(exchange.ID()), METRIC_BALANCE, "breaker_open")
+		return common.EBalanceEntry{
+			Valid:      false,
+			Error:      blocked.Error(),
+			Timestamp:  common.Timestamp(fmt.Sprintf("%d", timepoint)),
+			ReturnTime: common.GetTimestamp(),
+		}, nil
+	}
+	return balances, err
+}
+
+// Stop cancels in flight fetches and returns once every fetch loop has
+// exited, so nothing is written to storage after it returns
 func (self *Fetcher) Stop() error {
-	return self.runner.Stop()
+	if self.cancel == nil {
+		return errors.New("Fetcher is not running")
+	}
+	log.Printf("Fetcher is stopping...")
+	self.cancel()
+	err := self.runner.Stop()
+	self.loops.Wait()
+	self.cancel = nil
+	log.Printf("Fetcher stopped")
+	return err
+}
+
+// loop runs fetch loop until ctx is canceled
+func (self *Fetcher) loop(ctx context.Context, loop func(ctx context.Context)) {
+	self.loops.Add(1)
+	go func() {
+		defer self.loops.Done()
+		loop(ctx)
+	}()
 }
 
 func (self *Fetcher) Run() error {
+	if self.cancel != nil {
+		return errors.New("Fetcher is already running")
+	}
 	log.Printf("Fetcher runner is starting...")
+	ctx, cancel := context.WithCancel(context.Background())
+	self.cancel = cancel
 	self.runner.Start()
-	go self.RunOrderbookFetcher()
-	go self.RunAuthDataFetcher()
-	go self.RunRateFetcher()
-	go self.RunBlockFetcher()
+	if runner, ok := self.runner.(ExchangeRunner); ok {
+		for _, exchange := range self.exchanges {
+			exchange := exchange
+			self.loop(ctx, func(ctx context.Context) {
+				self.RunExchangeFetcher(ctx, runner, exchange, common.FETCH_ORDERBOOK, self.fetchOrderbook)
+			})
+			self.loop(ctx, func(ctx context.Context) {
+				self.RunExchangeFetcher(ctx, runner, exchange, common.FETCH_AUTH_DATA, self.fetchExchangeAuthData)
+			})
+			self.loop(ctx, func(ctx context.Context) {
+				self.RunExchangeFetcher(ctx, runner, exchange, common.FETCH_TRADE_HISTORY, self.fetchTradeHistory)
+			})
+		}
+		self.loop(ctx, self.RunBlockchainAuthDataFetcher)
+	} else {
+		self.loop(ctx, self.RunOrderbookFetcher)
+		self.loop(ctx, self.RunAuthDataFetcher)
+		self.loop(ctx, self.RunTradeHistoryFetcher)
+	}
+	self.loop(ctx, self.RunRateFetcher)
+	self.loop(ctx, self.RunBlockFetcher)
+	if self.events != nil {
+		self.loop(ctx, self.RunActivityRefresher)
+	}
 	log.Printf("Fetcher runner is running...")
 	return nil
 }
 
-func (self *Fetcher) RunBlockFetcher() {
+// RunExchangeFetcher fetches kind of data from exchange on its own ticks,
+// auth data is also fetched when the exchange is refreshed
+func (self *Fetcher) RunExchangeFetcher(
+	ctx context.Context,
+	runner ExchangeRunner, exchange Exchange, kind string,
+	fetch func(ctx context.Context, timepoint uint64, exchanges []Exchange)) {
+	ticker := runner.GetExchangeTicker(exchange.ID(), kind)
+	var refresh <-chan time.Time
+	if kind == common.FETCH_AUTH_DATA {
+		refresh = self.refreshes[exchange.ID()]
+	}
+	for {
+		var t time.Time
+		select {
+		case <-ctx.Done():
+			return
+		case t = <-ticker:
+		case t = <-refresh:
+		}
+		log.Printf("got signal in %s %s channel with timestamp %d", exchange.ID(), kind, common.TimeToTimepoint(t))
+		fetch(ctx, common.TimeToTimepoint(t), []Exchange{exchange})
+	}
+}
+
+// RunActivityRefresher refreshes auth data of exchanges core acted on,
+// once the debounce since the first event of a burst is over. Refreshes
+// go through the auth data loops, so they never run along with a
+// scheduled fetch of the same exchange.
+func (self *Fetcher) RunActivityRefresher(ctx context.Context) {
+	pending := map[common.ExchangeID]bool{}
+	timer := time.NewTimer(self.debounce)
+	timer.Stop()
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-self.events:
+			log.Printf("got %s event of %s on %s", event.Action, event.ID, event.Exchange)
+			if len(pending) == 0 {
+				timer.Reset(self.debounce)
+			}
+			pending[event.Exchange] = true
+		case <-timer.C:
+			_, perExchange := self.runner.(ExchangeRunner)
+			for id := range pending {
+				refresh, found := self.refreshes[id]
+				if !found {
+					continue
+				}
+				if !perExchange {
+					refresh = self.refreshAll
+				}
+				// a refresh still waiting covers this one
+				select {
+				case refresh <- time.Now():
+				default:
+				}
+				log.Printf("refreshing auth data of %s after activities", id)
+			}
+			pending = map[common.ExchangeID]bool{}
+		}
+	}
+}
+
+func (self *Fetcher) GetSchedules() (*common.Schedules, error) {
+	runner, ok := self.runner.(ExchangeRunner)
+	if !ok {
+		return nil, errors.New("Fetcher runner doesn't support schedules")
+	}
+	return runner.GetSchedules(), nil
+}
+
+func (self *Fetcher) SetSchedules(schedules *common.Schedules) error {
+	runner, ok := self.runner.(ExchangeRunner)
+	if !ok {
+		return errors.New("Fetcher runner doesn't support schedules")
+	}
+	return runner.SetSchedules(schedules)
+}
+
+func (self *Fetcher) RunBlockFetcher(ctx context.Context) {
 	for {
 		log.Printf("waiting for signal from block channel")
-		t := <-self.runner.GetBlockTicker()
+		var t time.Time
+		select {
+		case <-ctx.Done():
+			return
+		case t = <-self.runner.GetBlockTicker():
+		}
 		log.Printf("got signal in block channel with timestamp %d", common.TimeToTimepoint(t))
-		self.FetchCurrentBlock(common.TimeToTimepoint(t))
+		self.FetchCurrentBlock(ctx, common.TimeToTimepoint(t))
 		log.Printf("fetched block from blockchain")
 	}
 }
 
-func (self *Fetcher) RunRateFetcher() {
+func (self *Fetcher) RunTradeHistoryFetcher(ctx context.Context) {
+	for {
+		log.Printf("waiting for signal from runner trade history channel")
+		var t time.Time
+		select {
+		case <-ctx.Done():
+			return
+		case t = <-self.runner.GetTradeHistoryTicker():
+		}
+		log.Printf("got signal in trade history channel with timestamp %d", common.TimeToTimepoint(t))
+		self.FetchTradeHistory(ctx, common.TimeToTimepoint(t))
+		log.Printf("fetched trade history from exchanges")
+	}
+}
+
+// FetchTradeHistory stores new fills of every pair on exchanges that
+// report them, linking each fill to the trade activity of its order.
+// Fills of orders not placed by core have empty activity id. Fills at
+// the last stored timestamp are fetched again, storage keeps them once.
+func (self *Fetcher) FetchTradeHistory(ctx context.Context, timepoint uint64) {
+	self.fetchTradeHistory(ctx, timepoint, self.exchanges)
+}
+
+func (self *Fetcher) fetchTradeHistory(ctx context.Context, timepoint uint64, exchanges []Exchange) {
+	records, err := self.storage.GetAllRecords()
+	if err != nil {
+		log.Printf("Getting activities failed: %s\n", err)
+		return
+	}
+	for _, ex := range exchanges {
+		exchange, ok := ex.(TradeHistoryExchange)
+		if !ok {
+			continue
+		}
+		activities := map[string]common.ActivityID{}
+		for _, record := range records {
+			if record.Action == "trade" && record.Destination == string(exchange.ID()) {
+				activities[record.ID.EID] = record.ID
+			}
+		}
+		for _, pair := range exchange.TokenPairs() {
+			if ctx.Err() != nil {
+				return
+			}
+			since, err := self.storage.LastTradeFillTime(exchange.ID(), pair.PairID())
+			if err != nil {
+				log.Printf("Getting last trade fill of %s on %s failed: %s\n", pair.PairID(), exchange.ID(), err)
+				continue
+			}
+			start := time.Now()
+			fills, err := exchange.TradeHistory(ctx, pair, since)
+			self.metrics.Observe(string(exchange.ID()), METRIC_TRADE_HISTORY, start, len(fills), failure(err))
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				log.Printf("Fetching trade history of %s from %s failed: %s\n", pair.PairID(), exchange.ID(), err)
+				continue
+			}
+			fillRecords := []common.TradeFillRecord{}
+			for _, fill := range fills {
+				fillRecords = append(fillRecords, common.TradeFillRecord{
+					ActivityID: activities[fill.OrderID],
+					Exchange:   exchange.ID(),
+					TradeFill:  fill,
+				})
+			}
+			if err = self.storage.StoreTradeHistory(fillRecords); err != nil {
+				log.Printf("Storing trade history of %s failed: %s\n", exchange.ID(), err)
+			}
+		}
+	}
+}
+
+func (self *Fetcher) RunRateFetcher(ctx context.Context) {
 	for {
 		log.Printf("waiting for signal from runner rate channel")
-		t := <-self.runner.GetRateTicker()
+		var t time.Time
+		select {
+		case <-ctx.Done():
+			return
+		case t = <-self.runner.GetRateTicker():
+		}
 		log.Printf("got signal in rate channel with timestamp %d", common.TimeToTimepoint(t))
-		self.FetchRate(common.TimeToTimepoint(t))
+		self.FetchRate(ctx, common.TimeToTimepoint(t))
 		log.Printf("fetched rates from blockchain")
 	}
 }
 
-func (self *Fetcher) FetchRate(timepoint uint64) {
-	data, err := self.blockchain.FetchRates(timepoint)
+func (self *Fetcher) FetchRate(ctx context.Context, timepoint uint64) {
+	start := time.Now()
+	data, err := self.blockchain.FetchRates(ctx, timepoint)
+	self.metrics.Observe("blockchain", METRIC_RATE, start, len(data.Data), failure(err))
 	if err != nil {
 		log.Printf("Fetching rates from blockchain failed: %s\n", err)
 	}
+	if ctx.Err() != nil {
+		return
+	}
 	err = self.storage.StoreRate(data, timepoint)
 	// fmt.Printf("balance data: %v\n", data)
 	if err != nil {
@@ -85,17 +539,53 @@ func (self *Fetcher) FetchRate(timepoint uint64) {
 	}
 }
 
-func (self *Fetcher) RunAuthDataFetcher() {
+func (self *Fetcher) RunAuthDataFetcher(ctx context.Context) {
 	for {
 		log.Printf("waiting for signal from runner auth data channel")
-		t := <-self.runner.GetAuthDataTicker()
+		var t time.Time
+		select {
+		case <-ctx.Done():
+			return
+		case t = <-self.runner.GetAuthDataTicker():
+		case t = <-self.refreshAll:
+		}
 		log.Printf("got signal in auth data channel with timestamp %d", common.TimeToTimepoint(t))
-		self.FetchAllAuthData(common.TimeToTimepoint(t))
+		self.FetchAllAuthData(ctx, common.TimeToTimepoint(t))
 		log.Printf("fetched data from exchanges")
 	}
 }
 
-func (self *Fetcher) FetchAllAuthData(timepoint uint64) {
+// RunBlockchainAuthDataFetcher fetches reserve balances and mining
+// statuses on the default auth data schedule when exchanges have their
+// own, so the blockchain isn't asked once per exchange tick
+func (self *Fetcher) RunBlockchainAuthDataFetcher(ctx context.Context) {
+	for {
+		var t time.Time
+		select {
+		case <-ctx.Done():
+			return
+		case t = <-self.runner.GetAuthDataTicker():
+		}
+		log.Printf("got signal in blockchain auth data channel with timestamp %d", common.TimeToTimepoint(t))
+		self.fetchAuthData(ctx, common.TimeToTimepoint(t), []Exchange{}, true)
+	}
+}
+
+func (self *Fetcher) FetchAllAuthData(ctx context.Context, timepoint uint64) {
+	self.fetchAuthData(ctx, timepoint, self.exchanges, true)
+}
+
+// fetchExchangeAuthData fetches auth data of exchanges only, reserve
+// balances are the latest fetched ones
+func (self *Fetcher) fetchExchangeAuthData(ctx context.Context, timepoint uint64, exchanges []Exchange) {
+	self.fetchAuthData(ctx, timepoint, exchanges, false)
+}
+
+// fetchAuthData fetches balances and activity statuses of exchanges and,
+// if blockchain is set, of the blockchain. What isn't fetched keeps its
+// latest data. Every call stores a whole snapshot, so with auth data
+// scheduled per exchange each exchange tick stores one.
+func (self *Fetcher) fetchAuthData(ctx context.Context, timepoint uint64, exchanges []Exchange, blockchain bool) {
 	snapshot := common.AuthDataSnapshot{
 		Valid:             true,
 		Timestamp:         common.GetTimestamp(),
@@ -108,54 +598,134 @@ func (self *Fetcher) FetchAllAuthData(timepoint uint64) {
 	ebalances := sync.Map{}
 	estatuses := sync.Map{}
 	bstatuses := sync.Map{}
+	econsistent := sync.Map{}
 	pendings, err := self.storage.GetPendingActivities()
 	if err != nil {
 		log.Printf("Getting pending activites failed: %s\n", err)
 		return
 	}
 	wait := sync.WaitGroup{}
-	for _, exchange := range self.exchanges {
+	fetched := map[common.ExchangeID]bool{}
+	for _, exchange := range exchanges {
+		fetched[exchange.ID()] = true
 		wait.Add(1)
-		go self.FetchAuthDataFromExchange(
-			&wait, exchange, &ebalances, &estatuses,
+		go self.FetchAuthDataFromExchange(ctx,
+			&wait, exchange, &ebalances, &estatuses, &econsistent,
 			pendings, timepoint)
 	}
 	wait.Wait()
-	self.FetchAuthDataFromBlockchain(
-		bbalances, &bstatuses, pendings, timepoint)
+	if ctx.Err() != nil {
+		// partial balances would overwrite the latest good ones
+		log.Printf("Fetching auth data canceled: %s\n", ctx.Err())
+		return
+	}
+	blockchainConsistent := true
+	if blockchain {
+		blockchainConsistent = self.FetchAuthDataFromBlockchain(ctx, bbalances, &bstatuses, pendings, timepoint)
+		if ctx.Err() != nil {
+			log.Printf("Fetching auth data canceled: %s\n", ctx.Err())
+			return
+		}
+	}
+	// statuses were fetched for the pending activities read above, other
+	// loops may have updated them since so they are read again and
+	// stored one loop at a time
+	self.authMu.Lock()
+	defer self.authMu.Unlock()
+	inconsistent := self.mergeExchangeBalances(fetched, &ebalances, &econsistent)
+	if blockchain {
+		self.reserveBalances = bbalances
+		self.reserveInconsistent = !blockchainConsistent
+	}
+	if self.reserveInconsistent {
+		inconsistent = append(inconsistent, "blockchain")
+	}
+	sort.Strings(inconsistent)
+	snapshot.Inconsistent = inconsistent
 	snapshot.Block = self.currentBlock
+	snapshot.Breakers = self.Breakers()
 	snapshot.ReturnTime = common.GetTimestamp()
+	if pendings, err = self.storage.GetPendingActivities(); err != nil {
+		log.Printf("Getting pending activites failed: %s\n", err)
+		return
+	}
 	err = self.PersistSnapshot(
-		&ebalances, bbalances, &estatuses, &bstatuses,
-		pendings, &snapshot, timepoint)
+		&ebalances, self.reserveBalances, &estatuses, &bstatuses,
+		pendings, fetched, &snapshot, timepoint)
 	if err != nil {
 		log.Printf("Storing exchange balances failed: %s\n", err)
 		return
 	}
 }
 
+// mergeExchangeBalances keeps balances of the fetched exchanges as their
+// latest ones and adds the latest ones of the others to ebalances. It
+// returns exchanges whose balances are inconsistent. It must be called
+// with authMu held.
+func (self *Fetcher) mergeExchangeBalances(
+	fetched map[common.ExchangeID]bool,
+	ebalances *sync.Map,
+	econsistent *sync.Map) []string {
+	for id := range fetched {
+		if balances, found := ebalances.Load(id); found {
+			self.balances[id] = balances.(common.EBalanceEntry)
+		} else {
+			delete(self.balances, id)
+		}
+		if consistent, found := econsistent.Load(id); found && !consistent.(bool) {
+			self.inconsistent[id] = true
+		} else {
+			delete(self.inconsistent, id)
+		}
+	}
+	inconsistent := []string{}
+	for id, balances := range self.balances {
+		if !fetched[id] {
+			ebalances.Store(id, balances)
+		}
+		if self.inconsistent[id] {
+			log.Printf("Balances of %s are not consistent with activity statuses, timestamp %s\n", id, balances.Timestamp)
+			inconsistent = append(inconsistent, string(id))
+		}
+	}
+	return inconsistent
+}
+
+// FetchAuthDataFromBlockchain returns false if activity statuses kept
+// changing until the consistency policy gave up
 func (self *Fetcher) FetchAuthDataFromBlockchain(
+	ctx context.Context,
 	allBalances map[string]common.BalanceEntry,
 	allStatuses *sync.Map,
 	pendings []common.ActivityRecord,
-	timepoint uint64) {
+	timepoint uint64) bool {
 	// we apply double check strategy to mitigate race condition on exchange side like this:
 	// 1. Get list of pending activity status (A)
 	// 2. Get list of balances (B)
 	// 3. Get list of pending activity status again (C)
-	// 4. if C != A, repeat 1, otherwise return A, B
+	// 4. if C != A, repeat 1 unless attempts or time are up, otherwise return A, B
 	var balances map[string]common.BalanceEntry
 	var statuses map[common.ActivityID]common.ActivityStatus
 	var err error
-	for {
-		preStatuses := self.FetchStatusFromBlockchain(pendings)
-		balances, err = self.FetchBalanceFromBlockchain(timepoint)
+	consistent := false
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		preStatuses := self.FetchStatusFromBlockchain(ctx, pendings)
+		balances, err = self.FetchBalanceFromBlockchain(ctx, timepoint)
 		if err != nil {
 			log.Printf("Fetching blockchain balances failed: %v\n", err)
 			break
 		}
-		statuses = self.FetchStatusFromBlockchain(pendings)
+		statuses = self.FetchStatusFromBlockchain(ctx, pendings)
 		if unchanged(preStatuses, statuses) {
+			consistent = true
+			break
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		if self.consistency.exhausted(attempt, start) {
+			log.Printf("Blockchain activity statuses kept changing, gave up after %d attempts\n", attempt)
 			break
 		}
 	}
@@ -167,22 +737,29 @@ func (self *Fetcher) FetchAuthDataFromBlockchain(
 			allStatuses.Store(id, activityStatus)
 		}
 	}
+	return consistent || err != nil
 }
 
-func (self *Fetcher) FetchCurrentBlock(timepoint uint64) {
-	block, err := self.blockchain.CurrentBlock()
+func (self *Fetcher) FetchCurrentBlock(ctx context.Context, timepoint uint64) {
+	start := time.Now()
+	block, err := self.blockchain.CurrentBlock(ctx)
+	self.metrics.Observe("blockchain", METRIC_BLOCK, start, 1, failure(err))
 	if err != nil {
 		log.Printf("Fetching current block failed: %v. Ignored.", err)
 	} else {
 		self.currentBlock = block
+		self.fetchEvents(ctx, block)
 	}
 }
 
-func (self *Fetcher) FetchBalanceFromBlockchain(timepoint uint64) (map[string]common.BalanceEntry, error) {
-	return self.blockchain.FetchBalanceData(self.rmaddr, timepoint)
+func (self *Fetcher) FetchBalanceFromBlockchain(ctx context.Context, timepoint uint64) (map[string]common.BalanceEntry, error) {
+	start := time.Now()
+	balances, err := self.blockchain.FetchBalanceData(ctx, self.rmaddr, timepoint)
+	self.metrics.Observe("blockchain", METRIC_RESERVE_BALANCE, start, len(balances), failure(err))
+	return balances, err
 }
 
-func (self *Fetcher) FetchStatusFromBlockchain(pendings []common.ActivityRecord) map[common.ActivityID]common.ActivityStatus {
+func (self *Fetcher) FetchStatusFromBlockchain(ctx context.Context, pendings []common.ActivityRecord) map[common.ActivityID]common.ActivityStatus {
 	result := map[common.ActivityID]common.ActivityStatus{}
 	for _, activity := range pendings {
 		if activity.IsBlockchainPending() && (activity.Action == "set_rates" || activity.Action == "deposit" || activity.Action == "withdraw") {
@@ -190,7 +767,9 @@ func (self *Fetcher) FetchStatusFromBlockchain(pendings []common.ActivityRecord)
 			if tx.Big().IsInt64() && tx.Big().Int64() == 0 {
 				continue
 			}
-			isMined, err := self.blockchain.IsMined(tx)
+			start := time.Now()
+			isMined, err := self.blockchain.IsMined(ctx, tx)
+			self.metrics.Observe("blockchain", METRIC_MINING_STATUS, start, 1, failure(err))
 			if isMined {
 				result[activity.ID] = common.ActivityStatus{
 					activity.ExchangeStatus,
@@ -223,12 +802,17 @@ func unchanged(pre, post map[common.ActivityID]common.ActivityStatus) bool {
 	return true
 }
 
+// PersistSnapshot applies fetched statuses to pendings and stores the
+// snapshot. Only activities of the fetched exchanges or with a mining
+// status fetched are written back, the others are left to the loops
+// fetching them.
 func (self *Fetcher) PersistSnapshot(
 	ebalances *sync.Map,
 	bbalances map[string]common.BalanceEntry,
 	estatuses *sync.Map,
 	bstatuses *sync.Map,
 	pendings []common.ActivityRecord,
+	fetched map[common.ExchangeID]bool,
 	snapshot *common.AuthDataSnapshot,
 	timepoint uint64) error {
 
@@ -262,7 +846,7 @@ func (self *Fetcher) PersistSnapshot(
 				snapshot.Error = activityStatus.Error.Error()
 			}
 		}
-		status, _ = bstatuses.Load(activity.ID)
+		status, mined := bstatuses.Load(activity.ID)
 		if status != nil {
 			activityStatus = status.(common.ActivityStatus)
 			log.Printf("In PersistSnapshot: blockchain activity status for %+v: %+v", activity.ID, activityStatus)
@@ -279,6 +863,9 @@ func (self *Fetcher) PersistSnapshot(
 		if activity.IsPending() {
 			pendingActivities = append(pendingActivities, activity)
 		}
+		if !fetched[common.ExchangeID(activity.Destination)] && !mined {
+			continue
+		}
 		err := self.storage.UpdateActivity(activity.ID, activity)
 		if err != nil {
 			snapshot.Valid = false
@@ -292,9 +879,12 @@ func (self *Fetcher) PersistSnapshot(
 	return self.storage.StoreAuthSnapshot(snapshot, timepoint)
 }
 
+// FetchAuthDataFromExchange stores in allConsistent whether statuses of
+// exchange stopped changing before the consistency policy gave up
 func (self *Fetcher) FetchAuthDataFromExchange(
+	ctx context.Context,
 	wg *sync.WaitGroup, exchange Exchange,
-	allBalances *sync.Map, allStatuses *sync.Map,
+	allBalances *sync.Map, allStatuses *sync.Map, allConsistent *sync.Map,
 	pendings []common.ActivityRecord,
 	timepoint uint64) {
 	defer wg.Done()
@@ -302,22 +892,33 @@ func (self *Fetcher) FetchAuthDataFromExchange(
 	// 1. Get list of pending activity status (A)
 	// 2. Get list of balances (B)
 	// 3. Get list of pending activity status again (C)
-	// 4. if C != A, repeat 1, otherwise return A, B
+	// 4. if C != A, repeat 1 unless attempts or time are up, otherwise return A, B
 	var balances common.EBalanceEntry
 	var statuses map[common.ActivityID]common.ActivityStatus
 	var err error
-	for {
-		preStatuses := self.FetchStatusFromExchange(exchange, pendings, timepoint)
-		balances, err = exchange.FetchEBalanceData(timepoint)
+	consistent := false
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		preStatuses := self.FetchStatusFromExchange(ctx, exchange, pendings, timepoint)
+		balances, err = self.fetchBalances(ctx, exchange, timepoint)
 		if err != nil {
 			log.Printf("Fetching exchange balances from %s failed: %v\n", exchange.Name(), err)
 			break
 		}
-		statuses = self.FetchStatusFromExchange(exchange, pendings, timepoint)
+		statuses = self.FetchStatusFromExchange(ctx, exchange, pendings, timepoint)
 		if unchanged(preStatuses, statuses) {
+			consistent = true
+			break
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		if self.consistency.exhausted(attempt, start) {
+			log.Printf("Activity statuses on %s kept changing, gave up after %d attempts\n", exchange.Name(), attempt)
 			break
 		}
 	}
+	allConsistent.Store(exchange.ID(), consistent || err != nil)
 	if err == nil {
 		allBalances.Store(exchange.ID(), balances)
 		for id, activityStatus := range statuses {
@@ -326,7 +927,7 @@ func (self *Fetcher) FetchAuthDataFromExchange(
 	}
 }
 
-func (self *Fetcher) FetchStatusFromExchange(exchange Exchange, pendings []common.ActivityRecord, timepoint uint64) map[common.ActivityID]common.ActivityStatus {
+func (self *Fetcher) FetchStatusFromExchange(ctx context.Context, exchange Exchange, pendings []common.ActivityRecord, timepoint uint64) map[common.ActivityID]common.ActivityStatus {
 	result := map[common.ActivityID]common.ActivityStatus{}
 	for _, activity := range pendings {
 		if activity.IsExchangePending() && activity.Destination == string(exchange.ID()) {
@@ -334,19 +935,21 @@ func (self *Fetcher) FetchStatusFromExchange(exchange Exchange, pendings []commo
 			var status string
 			var tx string
 			id := activity.ID
+			start := time.Now()
 			if activity.Action == "trade" {
-				status, err = exchange.OrderStatus(id, timepoint)
+				status, err = exchange.OrderStatus(ctx, id, timepoint)
 			} else if activity.Action == "deposit" {
-				status, err = exchange.DepositStatus(id, timepoint)
+				status, err = exchange.DepositStatus(ctx, id, timepoint)
 				log.Printf("Got deposit status for %v: (%s), error(%v)", activity, status, err)
 			} else if activity.Action == "withdraw" {
 				log.Printf("Activity: %+v", activity)
 				tx = activity.Result["tx"].(string)
-				status, tx, err = exchange.WithdrawStatus(id, timepoint)
+				status, tx, err = exchange.WithdrawStatus(ctx, id, timepoint)
 				log.Printf("Got withdraw status for %v: (%s), error(%v)", activity, status, err)
 			} else {
 				continue
 			}
+			self.metrics.Observe(string(exchange.ID()), METRIC_ACTIVITY_STATUS, start, 1, failure(err))
 			result[id] = common.ActivityStatus{
 				status, tx, activity.MiningStatus, err,
 			}
@@ -355,25 +958,61 @@ func (self *Fetcher) FetchStatusFromExchange(exchange Exchange, pendings []commo
 	return result
 }
 
-func (self *Fetcher) RunOrderbookFetcher() {
+func (self *Fetcher) RunOrderbookFetcher(ctx context.Context) {
 	for {
 		log.Printf("waiting for signal from runner orderbook channel")
-		t := <-self.runner.GetOrderbookTicker()
+		var t time.Time
+		select {
+		case <-ctx.Done():
+			return
+		case t = <-self.runner.GetOrderbookTicker():
+		}
 		log.Printf("got signal in orderbook channel with timestamp %d", common.TimeToTimepoint(t))
-		self.FetchOrderbook(common.TimeToTimepoint(t))
+		self.FetchOrderbook(ctx, common.TimeToTimepoint(t))
 		log.Printf("fetched data from exchanges")
 	}
 }
 
-func (self *Fetcher) FetchOrderbook(timepoint uint64) {
-	data := NewConcurrentAllPriceData()
+func (self *Fetcher) FetchOrderbook(ctx context.Context, timepoint uint64) {
+	self.fetchOrderbook(ctx, timepoint, self.exchanges)
+}
+
+// fetchOrderbook fetches order books of exchanges and stores them with
+// the latest books of other exchanges
+func (self *Fetcher) fetchOrderbook(ctx context.Context, timepoint uint64, exchanges []Exchange) {
+	fetched := NewConcurrentAllPriceData()
 	// start fetching
 	wait := sync.WaitGroup{}
-	for _, exchange := range self.exchanges {
+	for _, exchange := range exchanges {
 		wait.Add(1)
-		go self.fetchPriceFromExchange(&wait, exchange, data, timepoint)
+		go self.fetchPriceFromExchange(ctx, &wait, exchange, fetched, timepoint)
 	}
 	wait.Wait()
+	if ctx.Err() != nil {
+		log.Printf("Fetching order books canceled: %s\n", ctx.Err())
+		return
+	}
+	self.pricesMu.Lock()
+	defer self.pricesMu.Unlock()
+	for _, exchange := range exchanges {
+		self.prices[exchange.ID()] = map[common.TokenPairID]common.ExchangePrice{}
+	}
+	for pair, onePrice := range fetched.GetData().Data {
+		for exchange, price := range onePrice {
+			self.prices[exchange][pair] = price
+		}
+	}
+	if self.carryMaxAge > 0 {
+		for _, exchange := range exchanges {
+			self.carryForward(exchange, timepoint)
+		}
+	}
+	data := NewConcurrentAllPriceData()
+	for exchange, prices := range self.prices {
+		for pair, price := range prices {
+			data.SetOnePrice(exchange, pair, price)
+		}
+	}
 	data.SetBlockNumber(self.currentBlock)
 	err := self.storage.StorePrice(data.GetData(), timepoint)
 	if err != nil {
@@ -381,13 +1020,11 @@ func (self *Fetcher) FetchOrderbook(timepoint uint64) {
 	}
 }
 
-func (self *Fetcher) fetchPriceFromExchange(wg *sync.WaitGroup, exchange Exchange, data *ConcurrentAllPriceData, timepoint uint64) {
+func (self *Fetcher) fetchPriceFromExchange(ctx context.Context, wg *sync.WaitGroup, exchange Exchange, data *ConcurrentAllPriceData, timepoint uint64) {
 	defer wg.Done()
-	exdata, err := exchange.FetchPriceData(timepoint)
-	if err != nil {
-		log.Printf("Fetching data from %s failed: %v\n", exchange.Name(), err)
-	}
+	exdata := self.fetchPrices(ctx, exchange, timepoint)
 	for pair, exchangeData := range exdata {
+		exchangeData.LastChanged = self.changes.Update(exchange.ID(), pair, exchangeData)
 		data.SetOnePrice(exchange.ID(), pair, exchangeData)
 	}
 }
@@ -1,8 +1,12 @@
 package fetcher
 
 import (
-	"github.com/KyberNetwork/reserve-data/common"
+	"context"
 	"testing"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	ethereum "github.com/ethereum/go-ethereum/common"
 )
 
 func TestUnchangedFunc(t *testing.T) {
@@ -94,3 +98,52 @@ func TestUnchangedFunc(t *testing.T) {
 		t.Fatalf("Expected unchanged() to return true, got false")
 	}
 }
+
+// blockingExchange fetches books until its fetch is canceled
+type blockingExchange struct {
+	failingExchange
+	started  chan bool
+	canceled chan error
+}
+
+func (self *blockingExchange) FetchPriceData(ctx context.Context, timepoint uint64) (map[common.TokenPairID]common.ExchangePrice, error) {
+	self.started <- true
+	<-ctx.Done()
+	self.canceled <- ctx.Err()
+	return nil, ctx.Err()
+}
+
+func TestStopCancelsFetchesAndWaits(t *testing.T) {
+	storage := &priceStorage{}
+	runner := NewScheduleRunner(common.NewSchedules(map[string]common.Schedule{
+		common.FETCH_ORDERBOOK: common.Schedule{10, 0},
+	}))
+	fetcher := NewFetcher(storage, runner, ethereum.Address{})
+	ex := &blockingExchange{started: make(chan bool, 1), canceled: make(chan error, 1)}
+	fetcher.AddExchange(ex)
+	if err := fetcher.Run(); err != nil {
+		t.Fatalf("Expected fetcher to run, got %v", err)
+	}
+	select {
+	case <-ex.started:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected books to be fetched")
+	}
+	if err := fetcher.Stop(); err != nil {
+		t.Fatalf("Expected fetcher to stop, got %v", err)
+	}
+	select {
+	case err := <-ex.canceled:
+		if err != context.Canceled {
+			t.Fatalf("Expected fetch to be canceled, got %v", err)
+		}
+	default:
+		t.Fatalf("Expected Stop to wait for the canceled fetch")
+	}
+	if storage.prices.Data != nil {
+		t.Fatalf("Expected canceled fetch not to be stored, got %+v", storage.prices)
+	}
+	if err := fetcher.Stop(); err == nil {
+		t.Fatalf("Expected stopping a stopped fetcher to fail")
+	}
+}
@@ -13,6 +13,7 @@ type HttpRunner struct {
 	aticker chan time.Time
 	rticker chan time.Time
 	bticker chan time.Time
+	tticker chan time.Time
 	server  *HttpRunnerServer
 }
 
@@ -32,6 +33,10 @@ func (self *HttpRunner) GetRateTicker() <-chan time.Time {
 	return self.rticker
 }
 
+func (self *HttpRunner) GetTradeHistoryTicker() <-chan time.Time {
+	return self.tticker
+}
+
 func (self *HttpRunner) Start() error {
 	if self.server != nil {
 		return errors.New("runner start already")
@@ -62,12 +67,14 @@ func NewHttpRunner(port int) *HttpRunner {
 	achan := make(chan time.Time)
 	rchan := make(chan time.Time)
 	bchan := make(chan time.Time)
+	tchan := make(chan time.Time)
 	runner := HttpRunner{
 		port,
 		ochan,
 		achan,
 		rchan,
 		bchan,
+		tchan,
 		nil,
 	}
 	runner.Start()
@@ -23,15 +23,15 @@ type HttpRunnerServer struct {
 func getTimePoint(c *gin.Context) uint64 {
 	timestamp := c.DefaultQuery("timestamp", "")
 	if timestamp == "" {
-		log.Printf("Interpreted timestamp(%s) to default - %s\n", timestamp, MAX_TIMESPOT)
+		log.Printf("Interpreted timestamp(%s) to default - %d\n", timestamp, MAX_TIMESPOT)
 		return MAX_TIMESPOT
 	} else {
 		timepoint, err := strconv.ParseUint(timestamp, 10, 64)
 		if err != nil {
-			log.Printf("Interpreted timestamp(%s) to default - %s\n", timestamp, MAX_TIMESPOT)
+			log.Printf("Interpreted timestamp(%s) to default - %d\n", timestamp, MAX_TIMESPOT)
 			return MAX_TIMESPOT
 		} else {
-			log.Printf("Interpreted timestamp(%s) to %s\n", timestamp, timepoint)
+			log.Printf("Interpreted timestamp(%s) to %d\n", timestamp, timepoint)
 			return timepoint
 		}
 	}
@@ -81,11 +81,23 @@ func (self *HttpRunnerServer) btick(c *gin.Context) {
 	)
 }
 
+func (self *HttpRunnerServer) ttick(c *gin.Context) {
+	timepoint := getTimePoint(c)
+	self.runner.tticker <- common.TimepointToTime(timepoint)
+	c.JSON(
+		http.StatusOK,
+		gin.H{
+			"success": true,
+		},
+	)
+}
+
 func (self *HttpRunnerServer) init() {
 	self.r.GET("/otick", self.otick)
 	self.r.GET("/atick", self.atick)
 	self.r.GET("/rtick", self.rtick)
 	self.r.GET("/btick", self.btick)
+	self.r.GET("/ttick", self.ttick)
 }
 
 func (self *HttpRunnerServer) Start() error {
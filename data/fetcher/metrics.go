@@ -0,0 +1,205 @@
+package fetcher
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kinds of fetches instrumented, exchanges and the blockchain are told
+// apart by the exchange label
+const (
+	METRIC_ORDERBOOK       string = "orderbook"
+	METRIC_BALANCE         string = "balance"
+	METRIC_ACTIVITY_STATUS string = "activity_status"
+	METRIC_TRADE_HISTORY   string = "tradehistory"
+	METRIC_RESERVE_BALANCE string = "reserve_balance"
+	METRIC_RATE            string = "rate"
+	METRIC_MINING_STATUS   string = "mining_status"
+	METRIC_BLOCK           string = "block"
+	METRIC_EVENTS          string = "events"
+)
+
+// Result of a fetch that succeeded, failed ones are counted by class
+const METRIC_SUCCESS string = "success"
+
+var durationBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+var sizeBuckets = []float64{1, 5, 10, 25, 50, 100, 250, 500, 1000}
+
+type histogram struct {
+	bounds []float64
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{bounds: bounds, counts: make([]uint64, len(bounds))}
+}
+
+func (self *histogram) observe(value float64) {
+	for i, bound := range self.bounds {
+		if value <= bound {
+			self.counts[i] += 1
+		}
+	}
+	self.sum += value
+	self.count += 1
+}
+
+type metricKey struct {
+	exchange string
+	kind     string
+}
+
+func (self metricKey) labels() string {
+	return fmt.Sprintf("exchange=%q,kind=%q", self.exchange, self.kind)
+}
+
+type fetchStats struct {
+	results  map[string]uint64
+	duration *histogram
+	size     *histogram
+	// unix seconds of the last successful fetch
+	lastSuccess float64
+}
+
+// FetchMetrics counts fetches per exchange, data kind and result, with
+// histograms of how long they took and how many entries they returned
+type FetchMetrics struct {
+	mu    sync.Mutex
+	stats map[metricKey]*fetchStats
+}
+
+func NewFetchMetrics() *FetchMetrics {
+	return &FetchMetrics{stats: map[metricKey]*fetchStats{}}
+}
+
+func (self *FetchMetrics) get(exchange, kind string) *fetchStats {
+	key := metricKey{exchange, kind}
+	stats, found := self.stats[key]
+	if !found {
+		stats = &fetchStats{
+			results:  map[string]uint64{},
+			duration: newHistogram(durationBuckets),
+			size:     newHistogram(sizeBuckets),
+		}
+		self.stats[key] = stats
+	}
+	return stats
+}
+
+// Observe records a fetch of kind from exchange started at start that
+// returned size entries, or failed for reason if it is not empty
+func (self *FetchMetrics) Observe(exchange, kind string, start time.Time, size int, reason string) {
+	now := time.Now()
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	stats := self.get(exchange, kind)
+	stats.duration.observe(now.Sub(start).Seconds())
+	if reason == "" {
+		stats.results[METRIC_SUCCESS] += 1
+		stats.size.observe(float64(size))
+		stats.lastSuccess = float64(now.UnixNano()) / 1e9
+	} else {
+		stats.results[ErrorClass(reason)] += 1
+	}
+}
+
+// Skip records a fetch that wasn't made, reason is its result
+func (self *FetchMetrics) Skip(exchange, kind, reason string) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.get(exchange, kind).results[reason] += 1
+}
+
+// ErrorClass groups failure reasons so they can be alerted on
+func ErrorClass(reason string) string {
+	reason = strings.ToLower(reason)
+	switch {
+	case strings.Contains(reason, "context canceled"):
+		return "canceled"
+	case strings.Contains(reason, "timeout") ||
+		strings.Contains(reason, "deadline exceeded"):
+		return "timeout"
+	case strings.Contains(reason, "connection") ||
+		strings.Contains(reason, "no such host") ||
+		strings.Contains(reason, "eof") ||
+		strings.Contains(reason, "disconnected"):
+		return "connection"
+	case strings.Contains(reason, "unmarshal") ||
+		strings.Contains(reason, "json") ||
+		strings.Contains(reason, "invalid character"):
+		return "decode"
+	case strings.Contains(reason, "invalid") ||
+		strings.Contains(reason, "no valid"):
+		return "invalid"
+	default:
+		return "other"
+	}
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func writeHistogram(w io.Writer, name string, key metricKey, h *histogram) {
+	for i, bound := range h.bounds {
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d\n", name, key.labels(), formatFloat(bound), h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, key.labels(), h.count)
+	fmt.Fprintf(w, "%s_sum{%s} %s\n", name, key.labels(), formatFloat(h.sum))
+	fmt.Fprintf(w, "%s_count{%s} %d\n", name, key.labels(), h.count)
+}
+
+// WritePrometheus writes every metric in prometheus text format
+func (self *FetchMetrics) WritePrometheus(writer io.Writer) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	keys := []metricKey{}
+	for key := range self.stats {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].exchange != keys[j].exchange {
+			return keys[i].exchange < keys[j].exchange
+		}
+		return keys[i].kind < keys[j].kind
+	})
+	w := bufio.NewWriter(writer)
+	fmt.Fprintln(w, "# HELP reserve_fetch_total Fetches by exchange, data kind and result.")
+	fmt.Fprintln(w, "# TYPE reserve_fetch_total counter")
+	for _, key := range keys {
+		results := []string{}
+		for result := range self.stats[key].results {
+			results = append(results, result)
+		}
+		sort.Strings(results)
+		for _, result := range results {
+			fmt.Fprintf(w, "reserve_fetch_total{%s,result=%q} %d\n", key.labels(), result, self.stats[key].results[result])
+		}
+	}
+	fmt.Fprintln(w, "# HELP reserve_fetch_duration_seconds How long fetches took, failed ones included.")
+	fmt.Fprintln(w, "# TYPE reserve_fetch_duration_seconds histogram")
+	for _, key := range keys {
+		writeHistogram(w, "reserve_fetch_duration_seconds", key, self.stats[key].duration)
+	}
+	fmt.Fprintln(w, "# HELP reserve_fetch_payload_entries How many entries successful fetches returned.")
+	fmt.Fprintln(w, "# TYPE reserve_fetch_payload_entries histogram")
+	for _, key := range keys {
+		writeHistogram(w, "reserve_fetch_payload_entries", key, self.stats[key].size)
+	}
+	fmt.Fprintln(w, "# HELP reserve_fetch_last_success_timestamp_seconds When the last successful fetch returned.")
+	fmt.Fprintln(w, "# TYPE reserve_fetch_last_success_timestamp_seconds gauge")
+	for _, key := range keys {
+		if self.stats[key].lastSuccess != 0 {
+			fmt.Fprintf(w, "reserve_fetch_last_success_timestamp_seconds{%s} %s\n", key.labels(), formatFloat(self.stats[key].lastSuccess))
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,69 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestErrorClass(t *testing.T) {
+	cases := map[string]string{
+		"Get https://api.binance.com: dial tcp: i/o timeout":   "timeout",
+		"context deadline exceeded":                            "timeout",
+		"context canceled":                                     "canceled",
+		"dial tcp 1.2.3.4:443: connect: connection refused":    "connection",
+		"Code: -1001, Msg: disconnected":                       "connection",
+		"no valid order book, last error: Invalid symbol":      "invalid",
+		"invalid character '<' looking for beginning of value": "decode",
+		"json: cannot unmarshal string into Go value":          "decode",
+		"Insufficient funds":                                   "other",
+	}
+	for reason, class := range cases {
+		if got := ErrorClass(reason); got != class {
+			t.Errorf("Expected %q to be %s, got %s", reason, class, got)
+		}
+	}
+}
+
+func TestFetchesAreMeasured(t *testing.T) {
+	ex := &failingExchange{failures: 1}
+	fetcher := newTestFetcher(ex, RetryPolicy{2, time.Millisecond, time.Millisecond}, BreakerConfig{1, time.Hour})
+	fetcher.fetchPrices(context.Background(), ex, 1000)
+	fetcher.fetchBalances(context.Background(), ex, 1000)
+	buffer := bytes.Buffer{}
+	if err := fetcher.WriteMetrics(&buffer); err != nil {
+		t.Fatalf("Expected metrics to be written, got %v", err)
+	}
+	output := buffer.String()
+	for _, line := range []string{
+		"# TYPE reserve_fetch_total counter",
+		`reserve_fetch_total{exchange="failing",kind="orderbook",result="connection"} 1`,
+		`reserve_fetch_total{exchange="failing",kind="orderbook",result="success"} 1`,
+		`reserve_fetch_total{exchange="failing",kind="balance",result="success"} 1`,
+		"# TYPE reserve_fetch_duration_seconds histogram",
+		`reserve_fetch_duration_seconds_bucket{exchange="failing",kind="orderbook",le="+Inf"} 2`,
+		`reserve_fetch_duration_seconds_count{exchange="failing",kind="orderbook"} 2`,
+		`reserve_fetch_payload_entries_bucket{exchange="failing",kind="orderbook",le="1"} 1`,
+		`reserve_fetch_payload_entries_count{exchange="failing",kind="orderbook"} 1`,
+		`reserve_fetch_last_success_timestamp_seconds{exchange="failing",kind="orderbook"} `,
+	} {
+		if !strings.Contains(output, line) {
+			t.Fatalf("Expected metrics to have %q, got:\n%s", line, output)
+		}
+	}
+}
+
+func TestSkippedFetchesAreCounted(t *testing.T) {
+	metrics := NewFetchMetrics()
+	metrics.Skip("binance", METRIC_ORDERBOOK, "breaker_open")
+	buffer := bytes.Buffer{}
+	metrics.WritePrometheus(&buffer)
+	if !strings.Contains(buffer.String(), `reserve_fetch_total{exchange="binance",kind="orderbook",result="breaker_open"} 1`) {
+		t.Fatalf("Expected skipped fetch to be counted, got:\n%s", buffer.String())
+	}
+	if strings.Contains(buffer.String(), "reserve_fetch_last_success_timestamp_seconds{") {
+		t.Fatalf("Expected no success time without a successful fetch, got:\n%s", buffer.String())
+	}
+}
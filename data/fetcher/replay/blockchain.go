@@ -0,0 +1,95 @@
+package replay
+
+import (
+	"context"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/data/fetcher"
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+// RecordingBlockchain records every response of the blockchain it wraps
+type RecordingBlockchain struct {
+	fetcher.Blockchain
+	recorder *Recorder
+}
+
+// recordingEventBlockchain keeps events of blockchains that report
+// them, events are not recorded
+type recordingEventBlockchain struct {
+	RecordingBlockchain
+	events fetcher.EventBlockchain
+}
+
+func (self *recordingEventBlockchain) FetchEvents(ctx context.Context, fromBlock, toBlock uint64) ([]common.ReserveEvent, error) {
+	return self.events.FetchEvents(ctx, fromBlock, toBlock)
+}
+
+func NewRecordingBlockchain(recorder *Recorder, blockchain fetcher.Blockchain) fetcher.Blockchain {
+	recording := RecordingBlockchain{blockchain, recorder}
+	if events, ok := blockchain.(fetcher.EventBlockchain); ok {
+		return &recordingEventBlockchain{recording, events}
+	}
+	return &recording
+}
+
+func (self *RecordingBlockchain) FetchBalanceData(ctx context.Context, addr ethereum.Address, timepoint uint64) (map[string]common.BalanceEntry, error) {
+	result, err := self.Blockchain.FetchBalanceData(ctx, addr, timepoint)
+	self.recorder.Record(timepoint, RECORD_RESERVE_BALANCE, BLOCKCHAIN_SOURCE, addr.Hex(), result, err)
+	return result, err
+}
+
+func (self *RecordingBlockchain) FetchRates(ctx context.Context, timepoint uint64) (common.AllRateEntry, error) {
+	result, err := self.Blockchain.FetchRates(ctx, timepoint)
+	self.recorder.Record(timepoint, RECORD_RATES, BLOCKCHAIN_SOURCE, "", result, err)
+	return result, err
+}
+
+func (self *RecordingBlockchain) IsMined(ctx context.Context, tx ethereum.Hash) (bool, error) {
+	result, err := self.Blockchain.IsMined(ctx, tx)
+	self.recorder.Record(common.GetTimepoint(), RECORD_MINED, BLOCKCHAIN_SOURCE, tx.Hex(), result, err)
+	return result, err
+}
+
+func (self *RecordingBlockchain) CurrentBlock(ctx context.Context) (uint64, error) {
+	result, err := self.Blockchain.CurrentBlock(ctx)
+	self.recorder.Record(common.GetTimepoint(), RECORD_BLOCK, BLOCKCHAIN_SOURCE, "", result, err)
+	return result, err
+}
+
+// ReplayBlockchain answers the fetcher with recorded blockchain
+// responses. Mining status and the current block don't come with a
+// timepoint, they are answered as of the tape's cursor.
+type ReplayBlockchain struct {
+	tape *Tape
+}
+
+func NewReplayBlockchain(tape *Tape) *ReplayBlockchain {
+	return &ReplayBlockchain{tape}
+}
+
+func (self *ReplayBlockchain) FetchBalanceData(ctx context.Context, addr ethereum.Address, timepoint uint64) (map[string]common.BalanceEntry, error) {
+	self.tape.Seek(timepoint)
+	result := map[string]common.BalanceEntry{}
+	err := self.tape.Load(RECORD_RESERVE_BALANCE, BLOCKCHAIN_SOURCE, addr.Hex(), timepoint, &result)
+	return result, err
+}
+
+func (self *ReplayBlockchain) FetchRates(ctx context.Context, timepoint uint64) (common.AllRateEntry, error) {
+	self.tape.Seek(timepoint)
+	result := common.AllRateEntry{}
+	err := self.tape.Load(RECORD_RATES, BLOCKCHAIN_SOURCE, "", timepoint, &result)
+	return result, err
+}
+
+func (self *ReplayBlockchain) IsMined(ctx context.Context, tx ethereum.Hash) (bool, error) {
+	result := false
+	err := self.tape.Load(RECORD_MINED, BLOCKCHAIN_SOURCE, tx.Hex(), self.tape.Cursor(), &result)
+	return result, err
+}
+
+func (self *ReplayBlockchain) CurrentBlock(ctx context.Context) (uint64, error) {
+	var result uint64
+	err := self.tape.Load(RECORD_BLOCK, BLOCKCHAIN_SOURCE, "", self.tape.Cursor(), &result)
+	return result, err
+}
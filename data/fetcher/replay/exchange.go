@@ -0,0 +1,133 @@
+package replay
+
+import (
+	"context"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/data/fetcher"
+)
+
+type withdrawStatus struct {
+	Status string `json:"status"`
+	Tx     string `json:"tx"`
+}
+
+// RecordingExchange records every response of the exchange it wraps
+type RecordingExchange struct {
+	fetcher.Exchange
+	recorder *Recorder
+}
+
+// recordingTradeHistoryExchange keeps trade history of exchanges that
+// report it, fills are not recorded
+type recordingTradeHistoryExchange struct {
+	RecordingExchange
+	history fetcher.TradeHistoryExchange
+}
+
+func (self *recordingTradeHistoryExchange) TradeHistory(ctx context.Context, pair common.TokenPair, since uint64) ([]common.TradeFill, error) {
+	return self.history.TradeHistory(ctx, pair, since)
+}
+
+// NewRecordingExchange wraps exchange so its responses are recorded by
+// recorder, along with what it takes to replay it
+func NewRecordingExchange(recorder *Recorder, exchange fetcher.Exchange) fetcher.Exchange {
+	recorder.Record(
+		common.GetTimepoint(), RECORD_EXCHANGE, string(exchange.ID()), "",
+		newExchangeRecord(exchange), nil)
+	recording := RecordingExchange{exchange, recorder}
+	if history, ok := exchange.(fetcher.TradeHistoryExchange); ok {
+		return &recordingTradeHistoryExchange{recording, history}
+	}
+	return &recording
+}
+
+func (self *RecordingExchange) FetchPriceData(ctx context.Context, timepoint uint64) (map[common.TokenPairID]common.ExchangePrice, error) {
+	result, err := self.Exchange.FetchPriceData(ctx, timepoint)
+	self.recorder.Record(timepoint, RECORD_PRICE, string(self.ID()), "", result, err)
+	return result, err
+}
+
+func (self *RecordingExchange) FetchEBalanceData(ctx context.Context, timepoint uint64) (common.EBalanceEntry, error) {
+	result, err := self.Exchange.FetchEBalanceData(ctx, timepoint)
+	self.recorder.Record(timepoint, RECORD_EXCHANGE_BALANCE, string(self.ID()), "", result, err)
+	return result, err
+}
+
+func (self *RecordingExchange) OrderStatus(ctx context.Context, id common.ActivityID, timepoint uint64) (string, error) {
+	result, err := self.Exchange.OrderStatus(ctx, id, timepoint)
+	self.recorder.Record(timepoint, RECORD_ORDER_STATUS, string(self.ID()), id.String(), result, err)
+	return result, err
+}
+
+func (self *RecordingExchange) DepositStatus(ctx context.Context, id common.ActivityID, timepoint uint64) (string, error) {
+	result, err := self.Exchange.DepositStatus(ctx, id, timepoint)
+	self.recorder.Record(timepoint, RECORD_DEPOSIT_STATUS, string(self.ID()), id.String(), result, err)
+	return result, err
+}
+
+func (self *RecordingExchange) WithdrawStatus(ctx context.Context, id common.ActivityID, timepoint uint64) (string, string, error) {
+	status, tx, err := self.Exchange.WithdrawStatus(ctx, id, timepoint)
+	self.recorder.Record(timepoint, RECORD_WITHDRAW_STATUS, string(self.ID()), id.String(), withdrawStatus{status, tx}, err)
+	return status, tx, err
+}
+
+// ReplayExchange answers the fetcher with the responses recorded from
+// an exchange at the timepoint it asks for
+type ReplayExchange struct {
+	tape  *Tape
+	id    common.ExchangeID
+	name  string
+	pairs []common.TokenPair
+}
+
+func NewReplayExchange(tape *Tape, id common.ExchangeID, name string, pairs []common.TokenPair) *ReplayExchange {
+	return &ReplayExchange{tape, id, name, pairs}
+}
+
+func (self *ReplayExchange) ID() common.ExchangeID {
+	return self.id
+}
+
+func (self *ReplayExchange) Name() string {
+	return self.name
+}
+
+func (self *ReplayExchange) TokenPairs() []common.TokenPair {
+	return self.pairs
+}
+
+func (self *ReplayExchange) FetchPriceData(ctx context.Context, timepoint uint64) (map[common.TokenPairID]common.ExchangePrice, error) {
+	self.tape.Seek(timepoint)
+	result := map[common.TokenPairID]common.ExchangePrice{}
+	err := self.tape.Load(RECORD_PRICE, string(self.id), "", timepoint, &result)
+	return result, err
+}
+
+func (self *ReplayExchange) FetchEBalanceData(ctx context.Context, timepoint uint64) (common.EBalanceEntry, error) {
+	self.tape.Seek(timepoint)
+	result := common.EBalanceEntry{}
+	err := self.tape.Load(RECORD_EXCHANGE_BALANCE, string(self.id), "", timepoint, &result)
+	return result, err
+}
+
+func (self *ReplayExchange) OrderStatus(ctx context.Context, id common.ActivityID, timepoint uint64) (string, error) {
+	self.tape.Seek(timepoint)
+	result := ""
+	err := self.tape.Load(RECORD_ORDER_STATUS, string(self.id), id.String(), timepoint, &result)
+	return result, err
+}
+
+func (self *ReplayExchange) DepositStatus(ctx context.Context, id common.ActivityID, timepoint uint64) (string, error) {
+	self.tape.Seek(timepoint)
+	result := ""
+	err := self.tape.Load(RECORD_DEPOSIT_STATUS, string(self.id), id.String(), timepoint, &result)
+	return result, err
+}
+
+func (self *ReplayExchange) WithdrawStatus(ctx context.Context, id common.ActivityID, timepoint uint64) (string, string, error) {
+	self.tape.Seek(timepoint)
+	result := withdrawStatus{}
+	err := self.tape.Load(RECORD_WITHDRAW_STATUS, string(self.id), id.String(), timepoint, &result)
+	return result.Status, result.Tx, err
+}
@@ -0,0 +1,81 @@
+package replay
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+)
+
+// Kinds of recorded responses
+const (
+	RECORD_EXCHANGE         string = "exchange"
+	RECORD_PRICE            string = "price"
+	RECORD_EXCHANGE_BALANCE string = "exchange_balance"
+	RECORD_ORDER_STATUS     string = "order_status"
+	RECORD_DEPOSIT_STATUS   string = "deposit_status"
+	RECORD_WITHDRAW_STATUS  string = "withdraw_status"
+	RECORD_RESERVE_BALANCE  string = "reserve_balance"
+	RECORD_RATES            string = "rates"
+	RECORD_MINED            string = "mined"
+	RECORD_BLOCK            string = "block"
+)
+
+// Source of records of blockchain responses
+const BLOCKCHAIN_SOURCE string = "blockchain"
+
+// Record is one response the fetcher received. Responses to calls made
+// for a timepoint are recorded at that timepoint, others at the time
+// they were received.
+type Record struct {
+	Timepoint uint64 `json:"timepoint"`
+	Kind      string `json:"kind"`
+	// exchange id or blockchain
+	Source string `json:"source"`
+	// activity id, tx or address the call was about, if any
+	Key   string          `json:"key,omitempty"`
+	Data  json.RawMessage `json:"data,omitempty"`
+	Error string          `json:"error,omitempty"`
+}
+
+// Recorder appends records to a file, one json record per line
+type Recorder struct {
+	mu      sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+}
+
+func NewRecorder(path string) (*Recorder, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{file: file, encoder: json.NewEncoder(file)}, nil
+}
+
+// Record appends data, or the error of the call, as kind of response
+// from source. Failing to record is logged, it never fails the fetch.
+func (self *Recorder) Record(timepoint uint64, kind, source, key string, data interface{}, err error) {
+	record := Record{Timepoint: timepoint, Kind: kind, Source: source, Key: key}
+	if err != nil {
+		record.Error = err.Error()
+	} else {
+		raw, merr := json.Marshal(data)
+		if merr != nil {
+			log.Printf("Recording %s from %s failed: %s\n", kind, source, merr)
+			return
+		}
+		record.Data = raw
+	}
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if werr := self.encoder.Encode(record); werr != nil {
+		log.Printf("Recording %s from %s failed: %s\n", kind, source, werr)
+	}
+}
+
+func (self *Recorder) Close() error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.file.Close()
+}
@@ -0,0 +1,239 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/data/fetcher"
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+var omgeth = common.TokenPair{
+	common.Token{"OMG", "0x1795b4560491c941c0635451f07332effe3ee7b3", 18},
+	common.Token{"ETH", "0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee", 18},
+}
+
+// liveExchange answers with data telling the timepoint it was asked at
+type liveExchange struct {
+	down map[uint64]bool
+}
+
+func (self *liveExchange) ID() common.ExchangeID {
+	return "live"
+}
+
+func (self *liveExchange) Name() string {
+	return "live"
+}
+
+func (self *liveExchange) TokenPairs() []common.TokenPair {
+	return []common.TokenPair{omgeth}
+}
+
+func (self *liveExchange) FetchPriceData(ctx context.Context, timepoint uint64) (map[common.TokenPairID]common.ExchangePrice, error) {
+	if self.down[timepoint] {
+		return nil, errors.New("exchange down")
+	}
+	return map[common.TokenPairID]common.ExchangePrice{
+		omgeth.PairID(): common.ExchangePrice{
+			Valid:     true,
+			Timestamp: common.Timestamp(fmt.Sprintf("%d", timepoint)),
+			Bids:      []common.PriceEntry{common.PriceEntry{float64(timepoint), 0.01}},
+		},
+	}, nil
+}
+
+func (self *liveExchange) FetchEBalanceData(ctx context.Context, timepoint uint64) (common.EBalanceEntry, error) {
+	return common.EBalanceEntry{
+		Valid:            true,
+		Timestamp:        common.Timestamp(fmt.Sprintf("%d", timepoint)),
+		AvailableBalance: map[string]float64{"ETH": float64(timepoint)},
+	}, nil
+}
+
+func (self *liveExchange) OrderStatus(ctx context.Context, id common.ActivityID, timepoint uint64) (string, error) {
+	return "done", nil
+}
+
+func (self *liveExchange) DepositStatus(ctx context.Context, id common.ActivityID, timepoint uint64) (string, error) {
+	return "done", nil
+}
+
+func (self *liveExchange) WithdrawStatus(ctx context.Context, id common.ActivityID, timepoint uint64) (string, string, error) {
+	return "done", "0x1", nil
+}
+
+type liveBlockchain struct{}
+
+func (self *liveBlockchain) FetchBalanceData(ctx context.Context, addr ethereum.Address, timepoint uint64) (map[string]common.BalanceEntry, error) {
+	balance := common.RawBalance(*big.NewInt(int64(timepoint)))
+	return map[string]common.BalanceEntry{
+		"ETH": common.BalanceEntry{Valid: true, Balance: balance},
+	}, nil
+}
+
+func (self *liveBlockchain) FetchRates(ctx context.Context, timepoint uint64) (common.AllRateEntry, error) {
+	return common.AllRateEntry{
+		Valid: true,
+		Data: map[string]common.RateEntry{
+			"OMG": common.RateEntry{big.NewInt(int64(timepoint)), 0, big.NewInt(1), 0, timepoint},
+		},
+	}, nil
+}
+
+func (self *liveBlockchain) IsMined(ctx context.Context, tx ethereum.Hash) (bool, error) {
+	return true, nil
+}
+
+func (self *liveBlockchain) CurrentBlock(ctx context.Context) (uint64, error) {
+	return 42, nil
+}
+
+// replayStorage keeps what the fetcher stores by timepoint
+type replayStorage struct {
+	fetcher.Storage
+	mu     sync.Mutex
+	prices map[uint64]common.AllPriceEntry
+	rates  map[uint64]common.AllRateEntry
+	auth   map[uint64]common.AuthDataSnapshot
+}
+
+func newReplayStorage() *replayStorage {
+	return &replayStorage{
+		prices: map[uint64]common.AllPriceEntry{},
+		rates:  map[uint64]common.AllRateEntry{},
+		auth:   map[uint64]common.AuthDataSnapshot{},
+	}
+}
+
+func (self *replayStorage) StorePrice(data common.AllPriceEntry, timepoint uint64) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.prices[timepoint] = data
+	return nil
+}
+
+func (self *replayStorage) StoreRate(data common.AllRateEntry, timepoint uint64) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.rates[timepoint] = data
+	return nil
+}
+
+func (self *replayStorage) StoreAuthSnapshot(data *common.AuthDataSnapshot, timepoint uint64) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.auth[timepoint] = *data
+	return nil
+}
+
+func (self *replayStorage) GetPendingActivities() ([]common.ActivityRecord, error) {
+	return []common.ActivityRecord{}, nil
+}
+
+func (self *replayStorage) stored() int {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return len(self.prices) + len(self.rates) + len(self.auth)
+}
+
+func record(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "replay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "recording.jsonl")
+	recorder, err := NewRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer recorder.Close()
+	ctx := context.Background()
+	ex := NewRecordingExchange(recorder, &liveExchange{down: map[uint64]bool{3000: true}})
+	bc := NewRecordingBlockchain(recorder, &liveBlockchain{})
+	ex.FetchPriceData(ctx, 1000)
+	bc.FetchRates(ctx, 1200)
+	ex.FetchEBalanceData(ctx, 1500)
+	bc.FetchBalanceData(ctx, ethereum.Address{}, 1500)
+	ex.FetchPriceData(ctx, 2000)
+	ex.FetchPriceData(ctx, 3000)
+	return path
+}
+
+func TestReplayAnswersAsRecorded(t *testing.T) {
+	path := record(t)
+	defer os.RemoveAll(filepath.Dir(path))
+	tape, err := LoadTape(path)
+	if err != nil {
+		t.Fatalf("Expected recording to load, got %v", err)
+	}
+	exchanges := tape.Exchanges()
+	if len(exchanges) != 1 || exchanges[0].ID() != "live" || len(exchanges[0].TokenPairs()) != 1 {
+		t.Fatalf("Expected the recorded exchange, got %+v", exchanges)
+	}
+	ctx := context.Background()
+	prices, err := exchanges[0].FetchPriceData(ctx, 2500)
+	if err != nil || prices[omgeth.PairID()].Bids[0].Quantity != 2000 {
+		t.Fatalf("Expected books recorded at 2000, got %+v, %v", prices, err)
+	}
+	if _, err = exchanges[0].FetchPriceData(ctx, 3000); err == nil || err.Error() != "exchange down" {
+		t.Fatalf("Expected recorded error, got %v", err)
+	}
+	if _, err = exchanges[0].FetchPriceData(ctx, 500); err == nil {
+		t.Fatalf("Expected nothing recorded before the first timepoint")
+	}
+	rates, err := NewReplayBlockchain(tape).FetchRates(ctx, 1200)
+	if err != nil || rates.Data["OMG"].BaseBuy.Int64() != 1200 {
+		t.Fatalf("Expected rates recorded at 1200, got %+v, %v", rates, err)
+	}
+}
+
+func TestReplayThroughFetcher(t *testing.T) {
+	path := record(t)
+	defer os.RemoveAll(filepath.Dir(path))
+	tape, err := LoadTape(path)
+	if err != nil {
+		t.Fatalf("Expected recording to load, got %v", err)
+	}
+	storage := newReplayStorage()
+	runner := NewReplayRunner(tape)
+	f := fetcher.NewFetcher(storage, runner, ethereum.Address{})
+	f.SetResilience(fetcher.RetryPolicy{1, 0, 0}, fetcher.DefaultBreakerConfig())
+	f.SetBlockchain(NewReplayBlockchain(tape))
+	for _, ex := range tape.Exchanges() {
+		f.AddExchange(ex)
+	}
+	if err = f.Run(); err != nil {
+		t.Fatalf("Expected fetcher to run, got %v", err)
+	}
+	<-runner.Done()
+	// prices at 1000, 2000 and 3000, rates at 1200 and auth data at 1500
+	deadline := time.Now().Add(time.Second)
+	for storage.stored() < 5 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	f.Stop()
+	if price := storage.prices[2000].Data[omgeth.PairID()]["live"]; !price.Valid || price.Bids[0].Quantity != 2000 {
+		t.Fatalf("Expected books replayed at 2000, got %+v", storage.prices)
+	}
+	if price, found := storage.prices[3000].Data[omgeth.PairID()]["live"]; found && price.Valid {
+		t.Fatalf("Expected failed fetch replayed at 3000, got %+v", price)
+	}
+	if rates := storage.rates[1200]; rates.Data["OMG"].Block != 1200 {
+		t.Fatalf("Expected rates replayed at 1200, got %+v", storage.rates)
+	}
+	auth := storage.auth[1500]
+	reserve := auth.ReserveBalances["ETH"].Balance
+	if auth.ExchangeBalances["live"].AvailableBalance["ETH"] != 1500 || reserve.ToFloat(0) != 1500 {
+		t.Fatalf("Expected balances replayed at 1500, got %+v", storage.auth)
+	}
+}
@@ -0,0 +1,119 @@
+package replay
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+type tick struct {
+	timepoint uint64
+	ticker    chan time.Time
+}
+
+// ReplayRunner ticks the fetcher at the timepoints of a recording, as
+// fast as the fetcher takes the ticks. Order books are ticked at the
+// timepoints prices were recorded at, auth data at the ones of balances,
+// rates and blocks at their own. Trade history is never ticked.
+type ReplayRunner struct {
+	tape    *Tape
+	oticker chan time.Time
+	aticker chan time.Time
+	rticker chan time.Time
+	bticker chan time.Time
+	tticker chan time.Time
+	stop    chan bool
+	done    chan bool
+}
+
+func NewReplayRunner(tape *Tape) *ReplayRunner {
+	return &ReplayRunner{
+		tape,
+		make(chan time.Time),
+		make(chan time.Time),
+		make(chan time.Time),
+		make(chan time.Time),
+		make(chan time.Time),
+		nil,
+		make(chan bool),
+	}
+}
+
+func (self *ReplayRunner) GetOrderbookTicker() <-chan time.Time {
+	return self.oticker
+}
+
+func (self *ReplayRunner) GetAuthDataTicker() <-chan time.Time {
+	return self.aticker
+}
+
+func (self *ReplayRunner) GetRateTicker() <-chan time.Time {
+	return self.rticker
+}
+
+func (self *ReplayRunner) GetBlockTicker() <-chan time.Time {
+	return self.bticker
+}
+
+func (self *ReplayRunner) GetTradeHistoryTicker() <-chan time.Time {
+	return self.tticker
+}
+
+// Done is closed once every tick of the recording has been taken, a
+// restarted runner replays the recording from the start
+func (self *ReplayRunner) Done() <-chan bool {
+	return self.done
+}
+
+func (self *ReplayRunner) ticks() []tick {
+	result := []tick{}
+	add := func(ticker chan time.Time, kinds ...string) {
+		for _, timepoint := range self.tape.Timepoints(kinds...) {
+			result = append(result, tick{timepoint, ticker})
+		}
+	}
+	// at the same timepoint the block goes first, as when fetching live
+	add(self.bticker, RECORD_BLOCK)
+	add(self.oticker, RECORD_PRICE)
+	add(self.aticker, RECORD_EXCHANGE_BALANCE, RECORD_RESERVE_BALANCE)
+	add(self.rticker, RECORD_RATES)
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].timepoint < result[j].timepoint
+	})
+	return result
+}
+
+func (self *ReplayRunner) run(ticks []tick, stop, done chan bool) {
+	for _, t := range ticks {
+		self.tape.Seek(t.timepoint)
+		select {
+		case <-stop:
+			return
+		case t.ticker <- common.TimepointToTime(t.timepoint):
+		}
+	}
+	log.Printf("Replayed %d ticks", len(ticks))
+	close(done)
+}
+
+func (self *ReplayRunner) Start() error {
+	if self.stop != nil {
+		return errors.New("runner start already")
+	}
+	self.stop = make(chan bool)
+	self.done = make(chan bool)
+	go self.run(self.ticks(), self.stop, self.done)
+	return nil
+}
+
+func (self *ReplayRunner) Stop() error {
+	if self.stop == nil {
+		return errors.New("runner stop already")
+	}
+	close(self.stop)
+	self.stop = nil
+	return nil
+}
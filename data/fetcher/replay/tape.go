@@ -0,0 +1,206 @@
+package replay
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/data/fetcher"
+)
+
+type tapeKey struct {
+	kind   string
+	source string
+	key    string
+}
+
+// exchangeRecord describes a recorded exchange so it can be replayed
+// without talking to it. Tokens marshal to their ids only, so pairs are
+// kept with whole tokens.
+type exchangeRecord struct {
+	Name  string       `json:"name"`
+	Pairs []pairRecord `json:"pairs"`
+}
+
+type tokenRecord struct {
+	ID      string `json:"id"`
+	Address string `json:"address"`
+	Decimal int64  `json:"decimal"`
+}
+
+type pairRecord struct {
+	Base  tokenRecord `json:"base"`
+	Quote tokenRecord `json:"quote"`
+}
+
+func newExchangeRecord(exchange fetcher.Exchange) exchangeRecord {
+	result := exchangeRecord{exchange.Name(), []pairRecord{}}
+	for _, pair := range exchange.TokenPairs() {
+		result.Pairs = append(result.Pairs, pairRecord{
+			tokenRecord{pair.Base.ID, pair.Base.Address, pair.Base.Decimal},
+			tokenRecord{pair.Quote.ID, pair.Quote.Address, pair.Quote.Decimal},
+		})
+	}
+	return result
+}
+
+func (self exchangeRecord) tokenPairs() []common.TokenPair {
+	result := []common.TokenPair{}
+	for _, pair := range self.Pairs {
+		result = append(result, common.TokenPair{
+			common.Token{pair.Base.ID, pair.Base.Address, pair.Base.Decimal},
+			common.Token{pair.Quote.ID, pair.Quote.Address, pair.Quote.Decimal},
+		})
+	}
+	return result
+}
+
+// Tape holds records loaded from a recording. Responses are looked up
+// by the timepoint they were recorded at, calls without a timepoint are
+// answered as of the tape's cursor.
+type Tape struct {
+	mu        sync.RWMutex
+	records   map[tapeKey][]Record
+	exchanges map[string]exchangeRecord
+	order     []string
+	cursor    uint64
+}
+
+func NewTape(records []Record) *Tape {
+	tape := &Tape{
+		records:   map[tapeKey][]Record{},
+		exchanges: map[string]exchangeRecord{},
+		order:     []string{},
+	}
+	for _, record := range records {
+		if record.Kind == RECORD_EXCHANGE {
+			info := exchangeRecord{}
+			if err := json.Unmarshal(record.Data, &info); err != nil {
+				log.Printf("Skipped recorded exchange %s: %s\n", record.Source, err)
+				continue
+			}
+			if _, found := tape.exchanges[record.Source]; !found {
+				tape.order = append(tape.order, record.Source)
+			}
+			tape.exchanges[record.Source] = info
+			continue
+		}
+		key := tapeKey{record.Kind, record.Source, record.Key}
+		tape.records[key] = append(tape.records[key], record)
+	}
+	for _, records := range tape.records {
+		sort.SliceStable(records, func(i, j int) bool {
+			return records[i].Timepoint < records[j].Timepoint
+		})
+	}
+	return tape
+}
+
+// LoadTape reads every record of the recording at path
+func LoadTape(path string) (*Tape, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	records := []Record{}
+	decoder := json.NewDecoder(file)
+	for {
+		record := Record{}
+		err = decoder.Decode(&record)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Recording %s is corrupted after %d records: %s", path, len(records), err))
+		}
+		records = append(records, record)
+	}
+	return NewTape(records), nil
+}
+
+// Seek moves the cursor forward to timepoint
+func (self *Tape) Seek(timepoint uint64) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if timepoint > self.cursor {
+		self.cursor = timepoint
+	}
+}
+
+func (self *Tape) Cursor() uint64 {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	return self.cursor
+}
+
+// Find returns the latest record of kind from source about key recorded
+// at or before timepoint
+func (self *Tape) Find(kind, source, key string, timepoint uint64) (Record, bool) {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	records := self.records[tapeKey{kind, source, key}]
+	i := sort.Search(len(records), func(i int) bool {
+		return records[i].Timepoint > timepoint
+	})
+	if i == 0 {
+		return Record{}, false
+	}
+	return records[i-1], true
+}
+
+// Load decodes the latest response of kind from source about key as of
+// timepoint into result, or returns the error the call got back then
+func (self *Tape) Load(kind, source, key string, timepoint uint64, result interface{}) error {
+	record, found := self.Find(kind, source, key, timepoint)
+	if !found {
+		return errors.New(fmt.Sprintf("No %s of %s %s recorded at or before %d", kind, source, key, timepoint))
+	}
+	if record.Error != "" {
+		return errors.New(record.Error)
+	}
+	return json.Unmarshal(record.Data, result)
+}
+
+// Timepoints returns every distinct timepoint records of kinds were
+// recorded at, in order
+func (self *Tape) Timepoints(kinds ...string) []uint64 {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	wanted := map[string]bool{}
+	for _, kind := range kinds {
+		wanted[kind] = true
+	}
+	seen := map[uint64]bool{}
+	result := []uint64{}
+	for key, records := range self.records {
+		if !wanted[key.kind] {
+			continue
+		}
+		for _, record := range records {
+			if !seen[record.Timepoint] {
+				seen[record.Timepoint] = true
+				result = append(result, record.Timepoint)
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result
+}
+
+// Exchanges returns a replay exchange for every exchange recorded, in
+// the order they were first recorded
+func (self *Tape) Exchanges() []fetcher.Exchange {
+	result := []fetcher.Exchange{}
+	for _, id := range self.order {
+		info := self.exchanges[id]
+		result = append(result, NewReplayExchange(self, common.ExchangeID(id), info.Name, info.tokenPairs()))
+	}
+	return result
+}
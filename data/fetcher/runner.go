@@ -10,6 +10,7 @@ type FetcherRunner interface {
 	GetAuthDataTicker() <-chan time.Time
 	GetRateTicker() <-chan time.Time
 	GetBlockTicker() <-chan time.Time
+	GetTradeHistoryTicker() <-chan time.Time
 	// Start must be non-blocking and must only return after runner
 	// gets to ready state before GetOrderbookTicker() and
 	// GetAuthDataTicker() get called
@@ -23,10 +24,12 @@ type TickerRunner struct {
 	aduration time.Duration
 	rduration time.Duration
 	bduration time.Duration
+	tduration time.Duration
 	oclock    *time.Ticker
 	aclock    *time.Ticker
 	rclock    *time.Ticker
 	bclock    *time.Ticker
+	tclock    *time.Ticker
 	signal    chan bool
 }
 
@@ -55,6 +58,13 @@ func (self *TickerRunner) GetRateTicker() <-chan time.Time {
 	return self.rclock.C
 }
 
+func (self *TickerRunner) GetTradeHistoryTicker() <-chan time.Time {
+	if self.tclock == nil {
+		<-self.signal
+	}
+	return self.tclock.C
+}
+
 func (self *TickerRunner) Start() error {
 	self.oclock = time.NewTicker(self.oduration)
 	self.signal <- true
@@ -64,6 +74,8 @@ func (self *TickerRunner) Start() error {
 	self.signal <- true
 	self.bclock = time.NewTicker(self.bduration)
 	self.signal <- true
+	self.tclock = time.NewTicker(self.tduration)
+	self.signal <- true
 	return nil
 }
 
@@ -72,19 +84,22 @@ func (self *TickerRunner) Stop() error {
 	self.aclock.Stop()
 	self.rclock.Stop()
 	self.bclock.Stop()
+	self.tclock.Stop()
 	return nil
 }
 
-func NewTickerRunner(oduration, aduration, rduration, bduration time.Duration) *TickerRunner {
+func NewTickerRunner(oduration, aduration, rduration, bduration, tduration time.Duration) *TickerRunner {
 	return &TickerRunner{
 		oduration,
 		aduration,
 		rduration,
 		bduration,
+		tduration,
+		nil,
 		nil,
 		nil,
 		nil,
 		nil,
-		make(chan bool, 4),
+		make(chan bool, 5),
 	}
 }
@@ -0,0 +1,157 @@
+package fetcher
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+// ExchangeRunner is a runner that triggers fetches per exchange. Fetcher
+// uses its exchange tickers for order books, auth data and trade history
+// instead of the global ones.
+type ExchangeRunner interface {
+	FetcherRunner
+	GetExchangeTicker(exchange common.ExchangeID, kind string) <-chan time.Time
+	GetSchedules() *common.Schedules
+	SetSchedules(schedules *common.Schedules) error
+}
+
+type scheduleKey struct {
+	exchange common.ExchangeID
+	kind     string
+}
+
+// ScheduleRunner ticks every exchange and data kind on its own schedule.
+// Schedules can be changed while it is running, pending ticks are
+// rescheduled right away. Like time.Ticker it drops ticks for slow
+// receivers.
+type ScheduleRunner struct {
+	mu        sync.RWMutex
+	schedules *common.Schedules
+	tickers   map[scheduleKey]chan time.Time
+	reset     chan bool
+	stop      chan bool
+}
+
+func NewScheduleRunner(schedules *common.Schedules) *ScheduleRunner {
+	return &ScheduleRunner{
+		schedules: schedules,
+		tickers:   map[scheduleKey]chan time.Time{},
+		reset:     make(chan bool),
+	}
+}
+
+func (self *ScheduleRunner) GetExchangeTicker(exchange common.ExchangeID, kind string) <-chan time.Time {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	key := scheduleKey{exchange, kind}
+	ticker, found := self.tickers[key]
+	if !found {
+		ticker = make(chan time.Time, 1)
+		self.tickers[key] = ticker
+		if self.stop != nil {
+			go self.run(key, ticker, self.stop)
+		}
+	}
+	return ticker
+}
+
+func (self *ScheduleRunner) GetOrderbookTicker() <-chan time.Time {
+	return self.GetExchangeTicker("", common.FETCH_ORDERBOOK)
+}
+
+func (self *ScheduleRunner) GetAuthDataTicker() <-chan time.Time {
+	return self.GetExchangeTicker("", common.FETCH_AUTH_DATA)
+}
+
+func (self *ScheduleRunner) GetRateTicker() <-chan time.Time {
+	return self.GetExchangeTicker("", common.FETCH_RATE)
+}
+
+func (self *ScheduleRunner) GetBlockTicker() <-chan time.Time {
+	return self.GetExchangeTicker("", common.FETCH_BLOCK)
+}
+
+func (self *ScheduleRunner) GetTradeHistoryTicker() <-chan time.Time {
+	return self.GetExchangeTicker("", common.FETCH_TRADE_HISTORY)
+}
+
+func (self *ScheduleRunner) GetSchedules() *common.Schedules {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	return self.schedules
+}
+
+// SetSchedules replaces all schedules, ticks already waiting are
+// rescheduled with the new ones
+func (self *ScheduleRunner) SetSchedules(schedules *common.Schedules) error {
+	if schedules == nil || schedules.Default == nil {
+		return errors.New("Schedules must have defaults")
+	}
+	if schedules.Exchanges == nil {
+		schedules.Exchanges = map[common.ExchangeID]map[string]common.Schedule{}
+	}
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.schedules = schedules
+	close(self.reset)
+	self.reset = make(chan bool)
+	return nil
+}
+
+func (self *ScheduleRunner) run(key scheduleKey, ticker chan time.Time, stop chan bool) {
+	for {
+		self.mu.RLock()
+		schedule := self.schedules.Schedule(key.exchange, key.kind)
+		reset := self.reset
+		self.mu.RUnlock()
+		var tick <-chan time.Time
+		var timer *time.Timer
+		if schedule.Interval > 0 {
+			timer = time.NewTimer(schedule.Delay())
+			tick = timer.C
+		}
+		select {
+		case <-stop:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case <-reset:
+			if timer != nil {
+				timer.Stop()
+			}
+		case t := <-tick:
+			select {
+			case ticker <- t:
+			default:
+			}
+		}
+	}
+}
+
+func (self *ScheduleRunner) Start() error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if self.stop != nil {
+		return errors.New("runner start already")
+	}
+	self.stop = make(chan bool)
+	for key, ticker := range self.tickers {
+		go self.run(key, ticker, self.stop)
+	}
+	return nil
+}
+
+func (self *ScheduleRunner) Stop() error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if self.stop == nil {
+		return errors.New("runner stop already")
+	}
+	close(self.stop)
+	self.stop = nil
+	return nil
+}
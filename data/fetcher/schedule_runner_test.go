@@ -0,0 +1,84 @@
+package fetcher
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+type priceStorage struct {
+	Storage
+	prices common.AllPriceEntry
+}
+
+func (self *priceStorage) StorePrice(data common.AllPriceEntry, timepoint uint64) error {
+	self.prices = data
+	return nil
+}
+
+func countTicks(ticker <-chan time.Time, wait time.Duration) int {
+	result := 0
+	timeout := time.After(wait)
+	for {
+		select {
+		case <-ticker:
+			result += 1
+		case <-timeout:
+			return result
+		}
+	}
+}
+
+func TestScheduleRunnerTicksPerExchange(t *testing.T) {
+	schedules := common.NewSchedules(map[string]common.Schedule{
+		common.FETCH_ORDERBOOK: common.Schedule{Interval: 10},
+	})
+	schedules.Exchanges["bittrex"] = map[string]common.Schedule{
+		common.FETCH_ORDERBOOK: common.Schedule{Interval: 0},
+	}
+	runner := NewScheduleRunner(schedules)
+	binance := runner.GetExchangeTicker("binance", common.FETCH_ORDERBOOK)
+	bittrex := runner.GetExchangeTicker("bittrex", common.FETCH_ORDERBOOK)
+	if err := runner.Start(); err != nil {
+		t.Fatalf("Expected runner to start, got %v", err)
+	}
+	defer runner.Stop()
+	if ticks := countTicks(binance, 100*time.Millisecond); ticks < 3 {
+		t.Fatalf("Expected binance books to tick every 10ms, got %d ticks", ticks)
+	}
+	if ticks := countTicks(bittrex, 50*time.Millisecond); ticks != 0 {
+		t.Fatalf("Expected disabled bittrex books not to tick, got %d ticks", ticks)
+	}
+	// enabling bittrex takes effect without restart
+	schedules = common.NewSchedules(map[string]common.Schedule{
+		common.FETCH_ORDERBOOK: common.Schedule{Interval: 10, Jitter: 5},
+	})
+	if err := runner.SetSchedules(schedules); err != nil {
+		t.Fatalf("Expected schedules to be set, got %v", err)
+	}
+	if ticks := countTicks(bittrex, 100*time.Millisecond); ticks < 3 {
+		t.Fatalf("Expected bittrex books to tick after reconfiguration, got %d ticks", ticks)
+	}
+	if err := runner.SetSchedules(&common.Schedules{}); err == nil {
+		t.Fatalf("Expected schedules without defaults to be rejected")
+	}
+}
+
+func TestFetchOneExchangeKeepsOtherBooks(t *testing.T) {
+	storage := &priceStorage{}
+	fetcher := NewFetcher(storage, nil, ethereum.Address{})
+	fetcher.sleep = func(context.Context, time.Duration) {}
+	first := &failingExchange{}
+	fetcher.AddExchange(first)
+	fetcher.prices["bittrex"] = map[common.TokenPairID]common.ExchangePrice{
+		"OMG-ETH": common.ExchangePrice{Valid: true, Timestamp: "1000"},
+	}
+	fetcher.fetchOrderbook(context.Background(), 2000, []Exchange{first})
+	prices := storage.prices.Data["OMG-ETH"]
+	if !prices["failing"].Valid || prices["bittrex"].Timestamp != "1000" {
+		t.Fatalf("Expected fetched books stored with the latest of other exchanges, got %+v", prices)
+	}
+}
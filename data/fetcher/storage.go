@@ -9,6 +9,16 @@ type Storage interface {
 	StoreRate(data common.AllRateEntry, timepoint uint64) error
 	StoreAuthSnapshot(data *common.AuthDataSnapshot, timepoint uint64) error
 
+	GetAllRecords() ([]common.ActivityRecord, error)
 	GetPendingActivities() ([]common.ActivityRecord, error)
 	UpdateActivity(id common.ActivityID, act common.ActivityRecord) error
+
+	StoreTradeHistory(records []common.TradeFillRecord) error
+	LastTradeFillTime(exchange common.ExchangeID, pair common.TokenPairID) (uint64, error)
+
+	// StoreReserveEvents replaces every stored event from fromBlock on
+	// with events and records that blocks up to toBlock were watched
+	StoreReserveEvents(fromBlock, toBlock uint64, events []common.ReserveEvent) error
+	// LastEventBlock returns the last watched block, 0 if there is none
+	LastEventBlock() (uint64, error)
 }
@@ -0,0 +1,69 @@
+package data
+
+import (
+	"fmt"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+// checkedTimepoint returns the timepoint freshness is checked at when
+// data is asked for at timepoint, requests without timestamp ask for the
+// latest data and timepoints after now are not reached yet
+func checkedTimepoint(timepoint uint64) uint64 {
+	if now := common.GetTimepoint(); timepoint > now {
+		return now
+	}
+	return timepoint
+}
+
+// checkPrices returns a copy of prices with staleness at timepoint set,
+// stale prices are marked invalid. Books carried forward from an earlier
+// fetch are always stale.
+func (self ReserveData) checkPrices(prices common.OnePrice, timepoint uint64) common.OnePrice {
+	timepoint = checkedTimepoint(timepoint)
+	result := common.OnePrice{}
+	for exchange, price := range prices {
+		price.Staleness = self.rules.Rule(exchange, common.DATA_PRICE).Check(
+			price.Timestamp, price.ReturnTime, price.LastChanged, timepoint)
+		if price.CarriedForward && !price.Staleness.Stale {
+			price.Staleness.Stale = true
+			price.Staleness.Reason = price.Error
+		}
+		if price.Staleness.Stale {
+			price.Valid = false
+			price.Error = price.Staleness.Reason
+		}
+		result[exchange] = price
+	}
+	return result
+}
+
+// checkAuthData sets staleness of every exchange and reserve balance at
+// timepoint, the whole response is invalid if any of them is stale
+func (self ReserveData) checkAuthData(result *common.AuthDataResponse, timepoint uint64) {
+	timepoint = checkedTimepoint(timepoint)
+	balances := map[common.ExchangeID]common.EBalanceEntry{}
+	for exchange, balance := range result.Data.ExchangeBalances {
+		balance.Staleness = self.rules.Rule(exchange, common.DATA_EXCHANGE_BALANCE).Check(
+			balance.Timestamp, balance.ReturnTime, "", timepoint)
+		if balance.Staleness.Stale {
+			balance.Valid = false
+			balance.Error = balance.Staleness.Reason
+			result.Data.Valid = false
+			result.Data.Error = fmt.Sprintf("%s balances: %s", exchange, balance.Staleness.Reason)
+		}
+		balances[exchange] = balance
+	}
+	result.Data.ExchangeBalances = balances
+	for tokenID, balance := range result.Data.ReserveBalances {
+		balance.Staleness = self.rules.Rule("", common.DATA_RESERVE_BALANCE).Check(
+			balance.Timestamp, balance.ReturnTime, "", timepoint)
+		if balance.Staleness.Stale {
+			balance.Valid = false
+			balance.Error = balance.Staleness.Reason
+			result.Data.Valid = false
+			result.Data.Error = fmt.Sprintf("reserve %s balance: %s", tokenID, balance.Staleness.Reason)
+		}
+		result.Data.ReserveBalances[tokenID] = balance
+	}
+}
@@ -0,0 +1,118 @@
+package data
+
+import (
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+func testRules() *common.FreshnessRules {
+	return common.NewFreshnessRules(map[string]common.FreshnessRule{
+		common.DATA_PRICE:            common.FreshnessRule{MaxAge: 1000},
+		common.DATA_EXCHANGE_BALANCE: common.FreshnessRule{MaxAge: 1000},
+		common.DATA_RESERVE_BALANCE:  common.FreshnessRule{MaxAge: 1000},
+	})
+}
+
+func TestStalePricesAreInvalid(t *testing.T) {
+	app := ReserveData{rules: testRules()}
+	prices := common.OnePrice{
+		"binance": common.ExchangePrice{Valid: true, Timestamp: "10000"},
+		"bittrex": common.ExchangePrice{Valid: true, Timestamp: "8000"},
+	}
+	result := app.checkPrices(prices, 10500)
+	if !result["binance"].Valid || result["binance"].Staleness.Age != 500 {
+		t.Fatalf("Expected binance price to be valid, got %+v", result["binance"])
+	}
+	if result["bittrex"].Valid || !result["bittrex"].Staleness.Stale || result["bittrex"].Error == "" {
+		t.Fatalf("Expected bittrex price to be stale, got %+v", result["bittrex"])
+	}
+	if !prices["bittrex"].Valid {
+		t.Fatalf("Expected stored prices to be left untouched")
+	}
+}
+
+func TestCarriedPricesAreStale(t *testing.T) {
+	app := ReserveData{rules: testRules()}
+	prices := common.OnePrice{
+		"binance": common.ExchangePrice{
+			Valid:          true,
+			Timestamp:      "10000",
+			CarriedForward: true,
+			Error:          "carried forward, fetching failed: timeout",
+		},
+	}
+	result := app.checkPrices(prices, 10500)
+	price := result["binance"]
+	if price.Valid || !price.Staleness.Stale || price.Error != "carried forward, fetching failed: timeout" {
+		t.Fatalf("Expected carried binance price to be stale, got %+v", price)
+	}
+}
+
+func TestStaleBalanceInvalidatesAuthData(t *testing.T) {
+	app := ReserveData{rules: testRules()}
+	result := common.AuthDataResponse{}
+	result.Data.Valid = true
+	result.Data.ExchangeBalances = map[common.ExchangeID]common.EBalanceEntry{
+		"binance": common.EBalanceEntry{Valid: true, Timestamp: "10000"},
+	}
+	result.Data.ReserveBalances = map[string]common.BalanceResponse{
+		"ETH": common.BalanceResponse{Valid: true, Timestamp: "8000"},
+	}
+	app.checkAuthData(&result, 10500)
+	if !result.Data.ExchangeBalances["binance"].Valid {
+		t.Fatalf("Expected binance balances to be valid, got %+v", result.Data.ExchangeBalances["binance"])
+	}
+	if result.Data.ReserveBalances["ETH"].Valid || !result.Data.ReserveBalances["ETH"].Staleness.Stale {
+		t.Fatalf("Expected reserve ETH balance to be stale, got %+v", result.Data.ReserveBalances["ETH"])
+	}
+	if result.Data.Valid || result.Data.Error == "" {
+		t.Fatalf("Expected auth data to be invalid with a reason, got %+v", result.Data)
+	}
+}
+
+// latestStorage has fresh prices and auth data as the latest version
+type latestStorage struct {
+	Storage
+	now uint64
+}
+
+func (self latestStorage) CurrentPriceVersion(timepoint uint64) (common.Version, error) {
+	return common.Version(self.now), nil
+}
+
+func (self latestStorage) GetAllPrices(version common.Version) (common.AllPriceEntry, error) {
+	timestamp := common.Timestamp(strconv.FormatUint(self.now, 10))
+	return common.AllPriceEntry{Data: map[common.TokenPairID]common.OnePrice{
+		"OMG-ETH": common.OnePrice{"binance": common.ExchangePrice{Valid: true, Timestamp: timestamp}},
+	}}, nil
+}
+
+func (self latestStorage) CurrentAuthDataVersion(timepoint uint64) (common.Version, error) {
+	return common.Version(self.now), nil
+}
+
+func (self latestStorage) GetAuthData(version common.Version) (common.AuthDataSnapshot, error) {
+	timestamp := common.Timestamp(strconv.FormatUint(self.now, 10))
+	return common.AuthDataSnapshot{
+		Valid: true,
+		ExchangeBalances: map[common.ExchangeID]common.EBalanceEntry{
+			"binance": common.EBalanceEntry{Valid: true, Timestamp: timestamp},
+		},
+	}, nil
+}
+
+// requests without timestamp ask for data at the largest timepoint
+func TestLatestDataIsCheckedAsOfNow(t *testing.T) {
+	app := ReserveData{storage: latestStorage{now: common.GetTimepoint()}, rules: testRules()}
+	prices, err := app.GetAllPrices(math.MaxUint64)
+	if err != nil || !prices.Data["OMG-ETH"]["binance"].Valid {
+		t.Fatalf("Expected latest prices to be fresh, got %+v, %v", prices.Data["OMG-ETH"]["binance"], err)
+	}
+	authData, err := app.GetAuthData(math.MaxUint64)
+	if err != nil || !authData.Data.Valid {
+		t.Fatalf("Expected latest auth data to be fresh, got %+v, %v", authData.Data, err)
+	}
+}
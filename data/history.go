@@ -0,0 +1,80 @@
+package data
+
+import (
+	"log"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+// GetPriceHistory returns a page of the prices stored in the range of
+// query, only those of pair unless it is empty, and where the next page
+// starts. Prices are as fresh as they were when stored.
+func (self ReserveData) GetPriceHistory(pair common.TokenPairID, query common.HistoryQuery) ([]common.AllPriceResponse, uint64, error) {
+	timestamp := common.GetTimestamp()
+	versions, err := self.storage.PriceVersions(query.FromTime, query.ToTime)
+	if err != nil {
+		return nil, 0, err
+	}
+	versions, next := query.Select(versions)
+	result := []common.AllPriceResponse{}
+	for _, version := range versions {
+		prices, err := self.pricesAt(version, timestamp, uint64(version))
+		if err != nil {
+			// pruned since versions were listed
+			log.Printf("Skipping price version %d: %s", version, err)
+			continue
+		}
+		if pair != "" {
+			onePrice, found := prices.Data[pair]
+			if !found {
+				continue
+			}
+			prices.Data = map[common.TokenPairID]common.OnePrice{pair: onePrice}
+		}
+		result = append(result, prices)
+	}
+	return result, next, nil
+}
+
+// GetRateHistory returns a page of the rates stored in the range of
+// query and where the next page starts
+func (self ReserveData) GetRateHistory(query common.HistoryQuery) ([]common.AllRateResponse, uint64, error) {
+	timestamp := common.GetTimestamp()
+	versions, err := self.storage.RateVersions(query.FromTime, query.ToTime)
+	if err != nil {
+		return nil, 0, err
+	}
+	versions, next := query.Select(versions)
+	result := []common.AllRateResponse{}
+	for _, version := range versions {
+		rates, err := self.ratesAt(version, timestamp)
+		if err != nil {
+			log.Printf("Skipping rate version %d: %s", version, err)
+			continue
+		}
+		result = append(result, rates)
+	}
+	return result, next, nil
+}
+
+// GetAuthDataHistory returns a page of the auth data stored in the range
+// of query and where the next page starts. Auth data is as fresh as it
+// was when stored.
+func (self ReserveData) GetAuthDataHistory(query common.HistoryQuery) ([]common.AuthDataResponse, uint64, error) {
+	timestamp := common.GetTimestamp()
+	versions, err := self.storage.AuthDataVersions(query.FromTime, query.ToTime)
+	if err != nil {
+		return nil, 0, err
+	}
+	versions, next := query.Select(versions)
+	result := []common.AuthDataResponse{}
+	for _, version := range versions {
+		authData, err := self.authDataAt(version, timestamp, uint64(version))
+		if err != nil {
+			log.Printf("Skipping auth data version %d: %s", version, err)
+			continue
+		}
+		result = append(result, authData)
+	}
+	return result, next, nil
+}
@@ -1,12 +1,15 @@
 package data
 
 import (
+	"io"
+
 	"github.com/KyberNetwork/reserve-data/common"
 )
 
 type ReserveData struct {
 	storage Storage
 	fetcher Fetcher
+	rules   *common.FreshnessRules
 }
 
 func (self ReserveData) CurrentPriceVersion(timepoint uint64) (common.Version, error) {
@@ -19,18 +22,26 @@ func (self ReserveData) GetAllPrices(timepoint uint64) (common.AllPriceResponse,
 	if err != nil {
 		return common.AllPriceResponse{}, err
 	} else {
-		result := common.AllPriceResponse{}
-		data, err := self.storage.GetAllPrices(version)
-		returnTime := common.GetTimestamp()
-		result.Version = version
-		result.Timestamp = timestamp
-		result.ReturnTime = returnTime
-		result.Data = data.Data
-		result.Block = data.Block
-		return result, err
+		return self.pricesAt(version, timestamp, timepoint)
 	}
 }
 
+// pricesAt returns prices of version as fresh as they were at timepoint
+func (self ReserveData) pricesAt(version common.Version, timestamp common.Timestamp, timepoint uint64) (common.AllPriceResponse, error) {
+	result := common.AllPriceResponse{}
+	data, err := self.storage.GetAllPrices(version)
+	returnTime := common.GetTimestamp()
+	result.Version = version
+	result.Timestamp = timestamp
+	result.ReturnTime = returnTime
+	result.Data = map[common.TokenPairID]common.OnePrice{}
+	for pair, prices := range data.Data {
+		result.Data[pair] = self.checkPrices(prices, timepoint)
+	}
+	result.Block = data.Block
+	return result, err
+}
+
 func (self ReserveData) GetOnePrice(pairID common.TokenPairID, timepoint uint64) (common.OnePriceResponse, error) {
 	timestamp := common.GetTimestamp()
 	version, err := self.storage.CurrentPriceVersion(timepoint)
@@ -43,7 +54,7 @@ func (self ReserveData) GetOnePrice(pairID common.TokenPairID, timepoint uint64)
 		result.Version = version
 		result.Timestamp = timestamp
 		result.ReturnTime = returnTime
-		result.Data = data
+		result.Data = self.checkPrices(data, timepoint)
 		return result, err
 	}
 }
@@ -58,29 +69,38 @@ func (self ReserveData) GetAuthData(timepoint uint64) (common.AuthDataResponse,
 	if err != nil {
 		return common.AuthDataResponse{}, err
 	} else {
-		result := common.AuthDataResponse{}
-		data, err := self.storage.GetAuthData(version)
-		returnTime := common.GetTimestamp()
-		result.Version = version
-		result.Timestamp = timestamp
-		result.ReturnTime = returnTime
-		result.Data.Valid = data.Valid
-		result.Data.Error = data.Error
-		result.Data.Timestamp = data.Timestamp
-		result.Data.ReturnTime = data.ReturnTime
-		result.Data.ExchangeBalances = data.ExchangeBalances
-		result.Data.PendingActivities = data.PendingActivities
-		result.Data.Block = data.Block
-		result.Data.ReserveBalances = map[string]common.BalanceResponse{}
-		for tokenID, balance := range data.ReserveBalances {
-			result.Data.ReserveBalances[tokenID] = balance.ToBalanceResponse(
-				common.MustGetToken(tokenID).Decimal,
-			)
-		}
-		return result, err
+		return self.authDataAt(version, timestamp, timepoint)
 	}
 }
 
+// authDataAt returns auth data of version as fresh as it was at
+// timepoint
+func (self ReserveData) authDataAt(version common.Version, timestamp common.Timestamp, timepoint uint64) (common.AuthDataResponse, error) {
+	result := common.AuthDataResponse{}
+	data, err := self.storage.GetAuthData(version)
+	returnTime := common.GetTimestamp()
+	result.Version = version
+	result.Timestamp = timestamp
+	result.ReturnTime = returnTime
+	result.Data.Valid = data.Valid
+	result.Data.Error = data.Error
+	result.Data.Timestamp = data.Timestamp
+	result.Data.ReturnTime = data.ReturnTime
+	result.Data.ExchangeBalances = data.ExchangeBalances
+	result.Data.PendingActivities = data.PendingActivities
+	result.Data.Block = data.Block
+	result.Data.Breakers = data.Breakers
+	result.Data.Inconsistent = data.Inconsistent
+	result.Data.ReserveBalances = map[string]common.BalanceResponse{}
+	for tokenID, balance := range data.ReserveBalances {
+		result.Data.ReserveBalances[tokenID] = balance.ToBalanceResponse(
+			common.MustGetToken(tokenID).Decimal,
+		)
+	}
+	self.checkAuthData(&result, timepoint)
+	return result, err
+}
+
 func (self ReserveData) CurrentRateVersion(timepoint uint64) (common.Version, error) {
 	return self.storage.CurrentRateVersion(timepoint)
 }
@@ -91,39 +111,72 @@ func (self ReserveData) GetAllRates(timepoint uint64) (common.AllRateResponse, e
 	if err != nil {
 		return common.AllRateResponse{}, err
 	} else {
-		result := common.AllRateResponse{}
-		rates, err := self.storage.GetAllRates(version)
-		returnTime := common.GetTimestamp()
-		result.Version = version
-		result.Timestamp = timestamp
-		result.ReturnTime = returnTime
-		data := map[string]common.RateResponse{}
-		for tokenID, rate := range rates.Data {
-			data[tokenID] = common.RateResponse{
-				Valid:       rates.Valid,
-				Error:       rates.Error,
-				Timestamp:   rates.Timestamp,
-				ReturnTime:  rates.ReturnTime,
-				BaseBuy:     common.BigToFloat(rate.BaseBuy, 18),
-				CompactBuy:  rate.CompactBuy,
-				BaseSell:    common.BigToFloat(rate.BaseSell, 18),
-				CompactSell: rate.CompactSell,
-				Block:       rate.Block,
-			}
+		return self.ratesAt(version, timestamp)
+	}
+}
+
+func (self ReserveData) ratesAt(version common.Version, timestamp common.Timestamp) (common.AllRateResponse, error) {
+	result := common.AllRateResponse{}
+	rates, err := self.storage.GetAllRates(version)
+	returnTime := common.GetTimestamp()
+	result.Version = version
+	result.Timestamp = timestamp
+	result.ReturnTime = returnTime
+	data := map[string]common.RateResponse{}
+	for tokenID, rate := range rates.Data {
+		data[tokenID] = common.RateResponse{
+			Valid:       rates.Valid,
+			Error:       rates.Error,
+			Timestamp:   rates.Timestamp,
+			ReturnTime:  rates.ReturnTime,
+			BaseBuy:     common.BigToFloat(rate.BaseBuy, 18),
+			CompactBuy:  rate.CompactBuy,
+			BaseSell:    common.BigToFloat(rate.BaseSell, 18),
+			CompactSell: rate.CompactSell,
+			Block:       rate.Block,
 		}
-		result.Data = data
-		return result, err
 	}
+	result.Data = data
+	return result, err
 }
 
 func (self ReserveData) GetRecords() ([]common.ActivityRecord, error) {
 	return self.storage.GetAllRecords()
 }
 
+func (self ReserveData) GetActivities(filter common.ActivityFilter, cursor string, limit int) ([]common.ActivityRecord, string, error) {
+	return self.storage.GetActivities(filter, cursor, limit)
+}
+
 func (self ReserveData) GetPendingActivities() ([]common.ActivityRecord, error) {
 	return self.storage.GetPendingActivities()
 }
 
+func (self ReserveData) GetTradeHistory(fromTime, toTime uint64) ([]common.TradeFillRecord, error) {
+	return self.storage.GetTradeHistory(fromTime, toTime)
+}
+
+func (self ReserveData) GetReserveEvents(fromBlock, toBlock uint64) ([]common.ReserveEvent, error) {
+	return self.storage.GetReserveEvents(fromBlock, toBlock)
+}
+
+func (self ReserveData) GetBreakers() map[common.ExchangeID]common.BreakerStatus {
+	return self.fetcher.Breakers()
+}
+
+// WriteFetchMetrics writes metrics of fetches in prometheus text format
+func (self ReserveData) WriteFetchMetrics(w io.Writer) error {
+	return self.fetcher.WriteMetrics(w)
+}
+
+func (self ReserveData) GetSchedules() (*common.Schedules, error) {
+	return self.fetcher.GetSchedules()
+}
+
+func (self ReserveData) SetSchedules(schedules *common.Schedules) error {
+	return self.fetcher.SetSchedules(schedules)
+}
+
 func (self ReserveData) Run() error {
 	return self.fetcher.Run()
 }
@@ -132,6 +185,6 @@ func (self ReserveData) Stop() error {
 	return self.fetcher.Stop()
 }
 
-func NewReserveData(storage Storage, fetcher Fetcher) *ReserveData {
-	return &ReserveData{storage, fetcher}
+func NewReserveData(storage Storage, fetcher Fetcher, rules *common.FreshnessRules) *ReserveData {
+	return &ReserveData{storage, fetcher, rules}
 }
@@ -6,15 +6,23 @@ import (
 
 type Storage interface {
 	CurrentPriceVersion(timepoint uint64) (common.Version, error)
+	PriceVersions(fromTime, toTime uint64) ([]common.Version, error)
 	GetAllPrices(common.Version) (common.AllPriceEntry, error)
 	GetOnePrice(common.TokenPairID, common.Version) (common.OnePrice, error)
 
 	CurrentAuthDataVersion(timepoint uint64) (common.Version, error)
+	AuthDataVersions(fromTime, toTime uint64) ([]common.Version, error)
 	GetAuthData(common.Version) (common.AuthDataSnapshot, error)
 
 	CurrentRateVersion(timepoint uint64) (common.Version, error)
+	RateVersions(fromTime, toTime uint64) ([]common.Version, error)
 	GetAllRates(common.Version) (common.AllRateEntry, error)
 
 	GetAllRecords() ([]common.ActivityRecord, error)
+	GetActivities(filter common.ActivityFilter, cursor string, limit int) ([]common.ActivityRecord, string, error)
 	GetPendingActivities() ([]common.ActivityRecord, error)
+
+	GetTradeHistory(fromTime, toTime uint64) ([]common.TradeFillRecord, error)
+
+	GetReserveEvents(fromBlock, toBlock uint64) ([]common.ReserveEvent, error)
 }
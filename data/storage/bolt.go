@@ -23,13 +23,22 @@ const (
 	PENDING_ACTIVITY_BUCKET string = "pending_activities"
 	BITTREX_DEPOSIT_HISTORY string = "bittrex_deposit_history"
 	METRIC_BUCKET           string = "metrics"
-	MAX_NUMBER_VERSION      int    = 1000
+	TRADE_HISTORY_BUCKET    string = "trade_history"
+	LAST_TRADE_FILL_BUCKET  string = "last_trade_fill"
+	RESERVE_EVENT_BUCKET    string = "reserve_events"
+	EVENT_BLOCK_BUCKET      string = "reserve_event_block"
+	ACTIVITY_INDEX_BUCKET   string = "activity_index"
 )
 
 type BoltStorage struct {
 	db *bolt.DB
 }
 
+// Close releases the database file, no storage call can be made after
+func (self *BoltStorage) Close() error {
+	return self.db.Close()
+}
+
 func NewBoltStorage(path string) (*BoltStorage, error) {
 	// init instance
 	var err error
@@ -40,38 +49,58 @@ func NewBoltStorage(path string) (*BoltStorage, error) {
 	}
 	// init buckets
 	db.Update(func(tx *bolt.Tx) error {
-		_, err = tx.CreateBucket([]byte(PRICE_BUCKET))
+		_, err = tx.CreateBucketIfNotExists([]byte(PRICE_BUCKET))
 		if err != nil {
 			return err
 		}
-		_, err = tx.CreateBucket([]byte(RATE_BUCKET))
+		_, err = tx.CreateBucketIfNotExists([]byte(RATE_BUCKET))
 		if err != nil {
 			return err
 		}
-		_, err = tx.CreateBucket([]byte(ORDER_BUCKET))
+		_, err = tx.CreateBucketIfNotExists([]byte(ORDER_BUCKET))
 		if err != nil {
 			return err
 		}
-		_, err = tx.CreateBucket([]byte(ACTIVITY_BUCKET))
+		_, err = tx.CreateBucketIfNotExists([]byte(ACTIVITY_BUCKET))
 		if err != nil {
 			return err
 		}
-		_, err = tx.CreateBucket([]byte(PENDING_ACTIVITY_BUCKET))
+		_, err = tx.CreateBucketIfNotExists([]byte(PENDING_ACTIVITY_BUCKET))
 		if err != nil {
 			return err
 		}
-		_, err = tx.CreateBucket([]byte(BITTREX_DEPOSIT_HISTORY))
+		_, err = tx.CreateBucketIfNotExists([]byte(BITTREX_DEPOSIT_HISTORY))
 		if err != nil {
 			return err
 		}
-		_, err = tx.CreateBucket([]byte(AUTH_DATA_BUCKET))
+		_, err = tx.CreateBucketIfNotExists([]byte(AUTH_DATA_BUCKET))
 		if err != nil {
 			return err
 		}
-		_, err = tx.CreateBucket([]byte(METRIC_BUCKET))
+		_, err = tx.CreateBucketIfNotExists([]byte(METRIC_BUCKET))
 		if err != nil {
 			return err
 		}
+		_, err = tx.CreateBucketIfNotExists([]byte(TRADE_HISTORY_BUCKET))
+		if err != nil {
+			return err
+		}
+		_, err = tx.CreateBucketIfNotExists([]byte(LAST_TRADE_FILL_BUCKET))
+		if err != nil {
+			return err
+		}
+		_, err = tx.CreateBucketIfNotExists([]byte(RESERVE_EVENT_BUCKET))
+		if err != nil {
+			return err
+		}
+		_, err = tx.CreateBucketIfNotExists([]byte(EVENT_BLOCK_BUCKET))
+		if err != nil {
+			return err
+		}
+		if tx.Bucket([]byte(ACTIVITY_INDEX_BUCKET)) == nil {
+			// activities stored before indexes existed are indexed once
+			return indexAllActivities(tx)
+		}
 		return nil
 	})
 	return &BoltStorage{db}, nil
@@ -122,34 +151,89 @@ func (self *BoltStorage) CurrentPriceVersion(timepoint uint64) (common.Version,
 	return common.Version(result), err
 }
 
-// GetNumberOfVersion return number of version storing in a bucket
-func (self *BoltStorage) GetNumberOfVersion(tx *bolt.Tx, bucket string) int {
-	result := 0
-	b := tx.Bucket([]byte(bucket))
-	c := b.Cursor()
-	for k, _ := c.First(); k != nil; k, _ = c.Next() {
-		result++
-	}
-	return result
+// versionsBetween returns every version of bucket from fromTime to
+// toTime, in order
+func (self *BoltStorage) versionsBetween(bucket string, fromTime, toTime uint64) ([]common.Version, error) {
+	result := []common.Version{}
+	self.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte(bucket)).Cursor()
+		for k, _ := c.Seek(uint64ToBytes(fromTime)); k != nil && bytesToUint64(k) <= toTime; k, _ = c.Next() {
+			result = append(result, common.Version(bytesToUint64(k)))
+		}
+		return nil
+	})
+	return result, nil
 }
 
-// PruneOutdatedData Remove first version out of database
-func (self *BoltStorage) PruneOutdatedData(tx *bolt.Tx, bucket string) error {
-	var err error
-	b := tx.Bucket([]byte(bucket))
-	c := b.Cursor()
-	for self.GetNumberOfVersion(tx, bucket) >= MAX_NUMBER_VERSION {
-		k, _ := c.First()
-		if k == nil {
-			err = errors.New(fmt.Sprintf("There no version in %s", bucket))
-			return err
+func (self *BoltStorage) PriceVersions(fromTime, toTime uint64) ([]common.Version, error) {
+	return self.versionsBetween(PRICE_BUCKET, fromTime, toTime)
+}
+
+func (self *BoltStorage) AuthDataVersions(fromTime, toTime uint64) ([]common.Version, error) {
+	return self.versionsBetween(AUTH_DATA_BUCKET, fromTime, toTime)
+}
+
+func (self *BoltStorage) RateVersions(fromTime, toTime uint64) ([]common.Version, error) {
+	return self.versionsBetween(RATE_BUCKET, fromTime, toTime)
+}
+
+// buckets retention applies to, per kind of data
+var retainedBuckets = map[string]string{
+	common.RETAIN_PRICES:    PRICE_BUCKET,
+	common.RETAIN_RATES:     RATE_BUCKET,
+	common.RETAIN_AUTH_DATA: AUTH_DATA_BUCKET,
+	common.RETAIN_METRICS:   METRIC_BUCKET,
+}
+
+// Prune removes the oldest versions of kind retention doesn't keep.
+// Versions are keyed by time so they are removed from the first one on
+// until one is kept. They are archived and synced once all are
+// collected, the removal is rolled back if that fails.
+func (self *BoltStorage) Prune(kind string, retention common.Retention, now uint64, archive Archive) (int, error) {
+	bucket, found := retainedBuckets[kind]
+	if !found {
+		return 0, errors.New(fmt.Sprintf("There is no retention for %s", kind))
+	}
+	cutoff := retention.Cutoff(now)
+	pruned := 0
+	err := self.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		count := b.Stats().KeyN
+		keys := [][]byte{}
+		values := [][]byte{}
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			tooMany := retention.MaxVersions > 0 && count-len(keys) > retention.MaxVersions
+			if !tooMany && bytesToUint64(k) >= cutoff {
+				break
+			}
+			keys = append(keys, append([]byte{}, k...))
+			values = append(values, v)
+		}
+		if archive != nil && len(keys) > 0 {
+			for i, k := range keys {
+				data, err := snapshotJSON(bucket, values[i])
+				if err != nil {
+					return err
+				}
+				if err = archive.Archive(kind, bytesToUint64(k), data); err != nil {
+					return err
+				}
+			}
+			if err := archive.Sync(); err != nil {
+				return err
+			}
 		}
-		err = b.Delete([]byte(k))
-		if err != nil {
-			panic(err)
+		// deleting while iterating makes the cursor skip keys
+		for _, k := range keys {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
 		}
-	}
-	return err
+		pruned = len(keys)
+		return nil
+	})
+	return pruned, err
 }
 
 func (self *BoltStorage) GetAllPrices(version common.Version) (common.AllPriceEntry, error) {
@@ -159,9 +243,9 @@ func (self *BoltStorage) GetAllPrices(version common.Version) (common.AllPriceEn
 		b := tx.Bucket([]byte(PRICE_BUCKET))
 		data := b.Get(uint64ToBytes(uint64(version)))
 		if data == nil {
-			err = errors.New(fmt.Sprintf("version %s doesn't exist", version))
+			err = errors.New(fmt.Sprintf("version %d doesn't exist", version))
 		} else {
-			err = json.Unmarshal(data, &result)
+			err = decodeSnapshot(data, &result)
 		}
 		return nil
 	})
@@ -175,9 +259,9 @@ func (self *BoltStorage) GetOnePrice(pair common.TokenPairID, version common.Ver
 		b := tx.Bucket([]byte(PRICE_BUCKET))
 		data := b.Get(uint64ToBytes(uint64(version)))
 		if data == nil {
-			err = errors.New(fmt.Sprintf("version %s doesn't exist", version))
+			err = errors.New(fmt.Sprintf("version %d doesn't exist", version))
 		} else {
-			err = json.Unmarshal(data, &result)
+			err = decodeSnapshot(data, &result)
 		}
 		return nil
 	})
@@ -211,7 +295,7 @@ func (self *BoltStorage) GetAuthData(version common.Version) (common.AuthDataSna
 		b := tx.Bucket([]byte(AUTH_DATA_BUCKET))
 		data := b.Get(uint64ToBytes(uint64(version)))
 		if data == nil {
-			err = errors.New(fmt.Sprintf("version %s doesn't exist", version))
+			err = errors.New(fmt.Sprintf("version %d doesn't exist", version))
 		} else {
 			err = json.Unmarshal(data, &result)
 		}
@@ -238,9 +322,9 @@ func (self *BoltStorage) GetAllRates(version common.Version) (common.AllRateEntr
 		b := tx.Bucket([]byte(RATE_BUCKET))
 		data := b.Get(uint64ToBytes(uint64(version)))
 		if data == nil {
-			err = errors.New(fmt.Sprintf("version %s doesn't exist", version))
+			err = errors.New(fmt.Sprintf("version %d doesn't exist", version))
 		} else {
-			err = json.Unmarshal(data, &result)
+			err = decodeSnapshot(data, &result)
 		}
 		return nil
 	})
@@ -250,19 +334,13 @@ func (self *BoltStorage) GetAllRates(version common.Version) (common.AllRateEntr
 func (self *BoltStorage) StorePrice(data common.AllPriceEntry, timepoint uint64) error {
 	var err error
 	self.db.Update(func(tx *bolt.Tx) error {
-		var dataJson []byte
+		var encoded []byte
 		b := tx.Bucket([]byte(PRICE_BUCKET))
-
-		// remove outdated data from bucket
-		log.Printf("Version number: %d\n", self.GetNumberOfVersion(tx, PRICE_BUCKET))
-		self.PruneOutdatedData(tx, PRICE_BUCKET)
-		log.Printf("After prune number version: %d\n", self.GetNumberOfVersion(tx, PRICE_BUCKET))
-
-		dataJson, err = json.Marshal(data)
+		encoded, err = encodeSnapshot(data)
 		if err != nil {
 			return err
 		}
-		return b.Put(uint64ToBytes(timepoint), dataJson)
+		return b.Put(uint64ToBytes(timepoint), encoded)
 	})
 	return err
 }
@@ -286,13 +364,13 @@ func (self *BoltStorage) StoreAuthSnapshot(
 func (self *BoltStorage) StoreRate(data common.AllRateEntry, timepoint uint64) error {
 	var err error
 	self.db.Update(func(tx *bolt.Tx) error {
-		var dataJson []byte
+		var encoded []byte
 		b := tx.Bucket([]byte(RATE_BUCKET))
-		dataJson, err = json.Marshal(data)
+		encoded, err = encodeSnapshot(data)
 		if err != nil {
 			return err
 		}
-		return b.Put(uint64ToBytes(timepoint), dataJson)
+		return b.Put(uint64ToBytes(timepoint), encoded)
 	})
 	return err
 }
@@ -325,6 +403,10 @@ func (self *BoltStorage) Record(
 			return err
 		}
 		idByte, _ := id.MarshalText()
+		err = indexActivity(tx, idByte, record)
+		if err != nil {
+			return err
+		}
 		err = b.Put(idByte, dataJson)
 		if err != nil {
 			return err
@@ -357,6 +439,141 @@ func (self *BoltStorage) GetAllRecords() ([]common.ActivityRecord, error) {
 	return result, err
 }
 
+// activityIndexKeys returns the keys record is found by in the activity
+// index, one per field value followed by the id so activities of a value
+// are ordered by time. Every activity has the "time" key.
+func activityIndexKeys(record common.ActivityRecord) [][]byte {
+	fields := []string{
+		"time:",
+		"destination:" + record.Destination,
+		"action:" + record.Action,
+		"exchange_status:" + record.ExchangeStatus,
+		"mining_status:" + record.MiningStatus,
+	}
+	for _, token := range record.Tokens() {
+		fields = append(fields, "token:"+token)
+	}
+	result := [][]byte{}
+	for _, field := range fields {
+		key := append([]byte(field+"|"), uint64ToBytes(record.ID.Timepoint)...)
+		result = append(result, append(key, []byte(record.ID.EID)...))
+	}
+	return result
+}
+
+// indexActivity replaces index keys of the activity stored at id, if
+// any, by those of record
+func indexActivity(tx *bolt.Tx, id []byte, record common.ActivityRecord) error {
+	ib := tx.Bucket([]byte(ACTIVITY_INDEX_BUCKET))
+	if old := tx.Bucket([]byte(ACTIVITY_BUCKET)).Get(id); old != nil {
+		oldRecord := common.ActivityRecord{}
+		if err := json.Unmarshal(old, &oldRecord); err != nil {
+			return err
+		}
+		for _, key := range activityIndexKeys(oldRecord) {
+			if err := ib.Delete(key); err != nil {
+				return err
+			}
+		}
+	}
+	for _, key := range activityIndexKeys(record) {
+		if err := ib.Put(key, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func indexAllActivities(tx *bolt.Tx) error {
+	ib, err := tx.CreateBucket([]byte(ACTIVITY_INDEX_BUCKET))
+	if err != nil {
+		return err
+	}
+	return tx.Bucket([]byte(ACTIVITY_BUCKET)).ForEach(func(k, v []byte) error {
+		record := common.ActivityRecord{}
+		if err := json.Unmarshal(v, &record); err != nil {
+			return err
+		}
+		for _, key := range activityIndexKeys(record) {
+			if err := ib.Put(key, k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// activityIndexPrefix returns the index keys of one of the field values
+// filter selects, the time keys without any
+func activityIndexPrefix(filter common.ActivityFilter) []byte {
+	switch {
+	case filter.Token != "":
+		return []byte("token:" + filter.Token + "|")
+	case filter.Destination != "":
+		return []byte("destination:" + filter.Destination + "|")
+	case filter.Action != "":
+		return []byte("action:" + filter.Action + "|")
+	case filter.ExchangeStatus != "":
+		return []byte("exchange_status:" + filter.ExchangeStatus + "|")
+	case filter.MiningStatus != "":
+		return []byte("mining_status:" + filter.MiningStatus + "|")
+	}
+	return []byte("time:|")
+}
+
+// GetActivities returns activities filter selects, newest first, made
+// before the one with id cursor if it isn't empty. At most limit of them
+// are returned along with the cursor of the next page, empty if there is
+// none. Activities are walked through the index of one field value then
+// checked against the rest of filter.
+func (self *BoltStorage) GetActivities(filter common.ActivityFilter, cursor string, limit int) ([]common.ActivityRecord, string, error) {
+	limit = common.ActivityLimit(limit)
+	prefix := activityIndexPrefix(filter)
+	from, to := filter.IDRange()
+	upper := append(append(append([]byte{}, prefix...), uint64ToBytes(to)...), 0xff)
+	if cursor != "" {
+		id, err := common.StringToActivityID(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		cursorKey := append(append(append([]byte{}, prefix...), uint64ToBytes(id.Timepoint)...), []byte(id.EID)...)
+		if bytes.Compare(cursorKey, upper) < 0 {
+			upper = cursorKey
+		}
+	}
+	result := []common.ActivityRecord{}
+	next := ""
+	err := self.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(ACTIVITY_BUCKET))
+		c := tx.Bucket([]byte(ACTIVITY_INDEX_BUCKET)).Cursor()
+		k, v := c.Seek(upper)
+		if k == nil {
+			k, v = c.Last()
+		} else {
+			k, v = c.Prev()
+		}
+		for ; k != nil && bytes.HasPrefix(k, prefix); k, v = c.Prev() {
+			if bytesToUint64(k[len(prefix):len(prefix)+8]) < from {
+				break
+			}
+			record := common.ActivityRecord{}
+			if err := json.Unmarshal(b.Get(v), &record); err != nil {
+				return err
+			}
+			if !filter.Match(record) {
+				continue
+			}
+			if len(result) == limit {
+				next = result[limit-1].ID.String()
+				break
+			}
+			result = append(result, record)
+		}
+		return nil
+	})
+	return result, next, err
+}
+
 func (self *BoltStorage) GetPendingActivities() ([]common.ActivityRecord, error) {
 	result := []common.ActivityRecord{}
 	var err error
@@ -396,6 +613,7 @@ func (self *BoltStorage) UpdateActivity(id common.ActivityID, activity common.Ac
 			}
 		}
 		b := tx.Bucket([]byte(ACTIVITY_BUCKET))
+		err = indexActivity(tx, idBytes, activity)
 		if err != nil {
 			return err
 		}
@@ -445,12 +663,149 @@ func (self *BoltStorage) HasPendingDeposit(token common.Token, exchange common.E
 	return result
 }
 
+// trade fills are keyed by their timestamp followed by exchange and
+// fill id so they are ordered by time and stored only once
+func tradeFillKey(record common.TradeFillRecord) []byte {
+	return append(
+		uint64ToBytes(record.Timestamp),
+		[]byte(fmt.Sprintf("%s|%s", record.Exchange, record.ID))...,
+	)
+}
+
+func (self *BoltStorage) StoreTradeHistory(records []common.TradeFillRecord) error {
+	var err error
+	self.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(TRADE_HISTORY_BUCKET))
+		lb := tx.Bucket([]byte(LAST_TRADE_FILL_BUCKET))
+		for _, record := range records {
+			var dataJson []byte
+			dataJson, err = json.Marshal(record)
+			if err != nil {
+				return err
+			}
+			err = b.Put(tradeFillKey(record), dataJson)
+			if err != nil {
+				return err
+			}
+			lastKey := []byte(fmt.Sprintf("%s|%s", record.Exchange, record.Pair))
+			last := lb.Get(lastKey)
+			if last == nil || bytesToUint64(last) < record.Timestamp {
+				err = lb.Put(lastKey, uint64ToBytes(record.Timestamp))
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	return err
+}
+
+// LastTradeFillTime returns timestamp of the latest stored fill of pair
+// on exchange, 0 if there is none
+func (self *BoltStorage) LastTradeFillTime(exchange common.ExchangeID, pair common.TokenPairID) (uint64, error) {
+	var result uint64
+	self.db.View(func(tx *bolt.Tx) error {
+		lb := tx.Bucket([]byte(LAST_TRADE_FILL_BUCKET))
+		last := lb.Get([]byte(fmt.Sprintf("%s|%s", exchange, pair)))
+		if last != nil {
+			result = bytesToUint64(last)
+		}
+		return nil
+	})
+	return result, nil
+}
+
+func (self *BoltStorage) GetTradeHistory(fromTime, toTime uint64) ([]common.TradeFillRecord, error) {
+	result := []common.TradeFillRecord{}
+	var err error
+	self.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte(TRADE_HISTORY_BUCKET)).Cursor()
+		min := uint64ToBytes(fromTime)
+		for k, v := c.Seek(min); k != nil && bytesToUint64(k[:8]) <= toTime; k, v = c.Next() {
+			record := common.TradeFillRecord{}
+			err = json.Unmarshal(v, &record)
+			if err != nil {
+				return err
+			}
+			result = append(result, record)
+		}
+		return nil
+	})
+	return result, err
+}
+
+// reserve events are keyed by their block followed by their index in
+// the block so they are ordered as they were logged
+func reserveEventKey(event common.ReserveEvent) []byte {
+	return append(uint64ToBytes(event.BlockNumber), uint64ToBytes(uint64(event.LogIndex))...)
+}
+
+var lastEventBlockKey = []byte("last")
+
+func (self *BoltStorage) StoreReserveEvents(fromBlock, toBlock uint64, events []common.ReserveEvent) error {
+	var err error
+	self.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(RESERVE_EVENT_BUCKET))
+		c := b.Cursor()
+		for k, _ := c.Seek(uint64ToBytes(fromBlock)); k != nil; k, _ = c.Seek(uint64ToBytes(fromBlock)) {
+			err = b.Delete(k)
+			if err != nil {
+				return err
+			}
+		}
+		for _, event := range events {
+			var dataJson []byte
+			dataJson, err = json.Marshal(event)
+			if err != nil {
+				return err
+			}
+			err = b.Put(reserveEventKey(event), dataJson)
+			if err != nil {
+				return err
+			}
+		}
+		err = tx.Bucket([]byte(EVENT_BLOCK_BUCKET)).Put(lastEventBlockKey, uint64ToBytes(toBlock))
+		return err
+	})
+	return err
+}
+
+func (self *BoltStorage) LastEventBlock() (uint64, error) {
+	var result uint64
+	self.db.View(func(tx *bolt.Tx) error {
+		last := tx.Bucket([]byte(EVENT_BLOCK_BUCKET)).Get(lastEventBlockKey)
+		if last != nil {
+			result = bytesToUint64(last)
+		}
+		return nil
+	})
+	return result, nil
+}
+
+func (self *BoltStorage) GetReserveEvents(fromBlock, toBlock uint64) ([]common.ReserveEvent, error) {
+	result := []common.ReserveEvent{}
+	var err error
+	self.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte(RESERVE_EVENT_BUCKET)).Cursor()
+		for k, v := c.Seek(uint64ToBytes(fromBlock)); k != nil && bytesToUint64(k[:8]) <= toBlock; k, v = c.Next() {
+			event := common.ReserveEvent{}
+			err = json.Unmarshal(v, &event)
+			if err != nil {
+				return err
+			}
+			result = append(result, event)
+		}
+		return nil
+	})
+	return result, err
+}
+
 func (self *BoltStorage) StoreMetric(data *metric.MetricEntry, timepoint uint64) error {
 	var err error
 	self.db.Update(func(tx *bolt.Tx) error {
 		var dataJson []byte
 		b := tx.Bucket([]byte(METRIC_BUCKET))
-		self.PruneOutdatedData(tx, METRIC_BUCKET)
 		dataJson, err = json.Marshal(data)
 		if err != nil {
 			return err
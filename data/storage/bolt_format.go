@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/boltdb/bolt"
+)
+
+// Snapshots of prices and rates start with the byte of the format they
+// are encoded in. Those stored as json before formats existed start with
+// '{' and are still read.
+const (
+	FORMAT_GOB byte = 1
+)
+
+// buckets of snapshots stored in binary, with a new value of what they
+// hold. Auth data keeps json, activity params in it don't gob encode.
+var binarySnapshots = map[string]func() interface{}{
+	PRICE_BUCKET: func() interface{} { return &common.AllPriceEntry{} },
+	RATE_BUCKET:  func() interface{} { return &common.AllRateEntry{} },
+}
+
+// encodeSnapshot encodes data in the latest format
+func encodeSnapshot(data interface{}) ([]byte, error) {
+	buffer := bytes.NewBuffer([]byte{FORMAT_GOB})
+	if err := gob.NewEncoder(buffer).Encode(data); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// decodeSnapshot decodes data encoded in any format into result
+func decodeSnapshot(data []byte, result interface{}) error {
+	var err error
+	if len(data) > 0 && data[0] == FORMAT_GOB {
+		err = gob.NewDecoder(bytes.NewReader(data[1:])).Decode(result)
+	} else {
+		err = json.Unmarshal(data, result)
+	}
+	if err != nil {
+		return err
+	}
+	normalizeSnapshot(result)
+	return nil
+}
+
+// normalizeSnapshot replaces nil slices and maps of a decoded snapshot
+// with empty ones. Gob doesn't tell them apart, doing it for every
+// format keeps the json served the same however a snapshot is stored.
+func normalizeSnapshot(result interface{}) {
+	switch snapshot := result.(type) {
+	case *common.AllPriceEntry:
+		if snapshot.Data == nil {
+			snapshot.Data = map[common.TokenPairID]common.OnePrice{}
+		}
+		for pair, onePrice := range snapshot.Data {
+			if onePrice == nil {
+				snapshot.Data[pair] = common.OnePrice{}
+			}
+			for exchangeID, price := range onePrice {
+				if price.Bids == nil {
+					price.Bids = []common.PriceEntry{}
+				}
+				if price.Asks == nil {
+					price.Asks = []common.PriceEntry{}
+				}
+				onePrice[exchangeID] = price
+			}
+		}
+	case *common.AllRateEntry:
+		if snapshot.Data == nil {
+			snapshot.Data = map[string]common.RateEntry{}
+		}
+	}
+}
+
+// snapshotJSON returns data stored in bucket as json
+func snapshotJSON(bucket string, data []byte) ([]byte, error) {
+	newValue, binary := binarySnapshots[bucket]
+	if !binary || len(data) == 0 || data[0] != FORMAT_GOB {
+		return data, nil
+	}
+	value := newValue()
+	if err := decodeSnapshot(data, value); err != nil {
+		return nil, err
+	}
+	return json.Marshal(value)
+}
+
+// most snapshots re-encoded in a transaction
+const REENCODE_BATCH int = 100
+
+// Reencode encodes snapshots stored in an older format in the latest
+// one, a batch per transaction so the file stays usable meanwhile. It
+// returns how many were re-encoded.
+func (self *BoltStorage) Reencode() (int, error) {
+	total := 0
+	for bucket, newValue := range binarySnapshots {
+		var last []byte
+		for {
+			count, next, err := self.reencodeBatch(bucket, newValue, last)
+			total += count
+			if err != nil {
+				return total, errors.New(fmt.Sprintf("Re-encoding %s failed: %s", bucket, err))
+			}
+			if next == nil {
+				break
+			}
+			last = next
+		}
+	}
+	return total, nil
+}
+
+// reencodeBatch re-encodes up to REENCODE_BATCH snapshots of bucket
+// after key last, nil for the first one. It returns the key to go on
+// after, nil at the end of the bucket.
+func (self *BoltStorage) reencodeBatch(bucket string, newValue func() interface{}, last []byte) (int, []byte, error) {
+	count := 0
+	var next []byte
+	err := self.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		c := b.Cursor()
+		k, v := c.First()
+		if last != nil {
+			k, v = c.Seek(last)
+			if k != nil && bytes.Equal(k, last) {
+				k, v = c.Next()
+			}
+		}
+		encoded := map[string][]byte{}
+		for ; k != nil && len(encoded) < REENCODE_BATCH; k, v = c.Next() {
+			next = append([]byte{}, k...)
+			if len(v) > 0 && v[0] == FORMAT_GOB {
+				continue
+			}
+			value := newValue()
+			if err := json.Unmarshal(v, value); err != nil {
+				return err
+			}
+			data, err := encodeSnapshot(value)
+			if err != nil {
+				return err
+			}
+			encoded[string(next)] = data
+		}
+		if k == nil {
+			next = nil
+		}
+		// values are put once the cursor is done with the bucket
+		for key, data := range encoded {
+			if err := b.Put([]byte(key), data); err != nil {
+				return err
+			}
+		}
+		count = len(encoded)
+		return nil
+	})
+	return count, next, err
+}
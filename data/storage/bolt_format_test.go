@@ -0,0 +1,184 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/boltdb/bolt"
+)
+
+// putJSON stores data as json the way snapshots used to be stored
+func putJSON(t *testing.T, storage *BoltStorage, bucket string, timepoint uint64, data interface{}) {
+	dataJson, _ := json.Marshal(data)
+	err := storage.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(bucket)).Put(uint64ToBytes(timepoint), dataJson)
+	})
+	if err != nil {
+		t.Fatalf("Couldn't store json snapshot: %s", err)
+	}
+}
+
+func stored(storage *BoltStorage, bucket string, timepoint uint64) []byte {
+	var result []byte
+	storage.db.View(func(tx *bolt.Tx) error {
+		result = append(result, tx.Bucket([]byte(bucket)).Get(uint64ToBytes(timepoint))...)
+		return nil
+	})
+	return result
+}
+
+func TestJSONSnapshotsAreReencoded(t *testing.T) {
+	boltFile := "test_bolt_format.db"
+	defer os.Remove(boltFile)
+	storage := newTestBoltStorage(t, boltFile)
+	prices := common.AllPriceEntry{Block: 1, Data: map[common.TokenPairID]common.OnePrice{
+		"OMG-ETH": common.OnePrice{"binance": common.ExchangePrice{
+			Valid: true,
+			Bids:  []common.PriceEntry{{10, 0.015}},
+		}},
+	}}
+	rates := common.AllRateEntry{Valid: true, Data: map[string]common.RateEntry{
+		"OMG": common.RateEntry{BaseBuy: big.NewInt(1000), BaseSell: big.NewInt(2000), Block: 5},
+	}}
+	putJSON(t, storage, PRICE_BUCKET, 1000, prices)
+	putJSON(t, storage, RATE_BUCKET, 1000, rates)
+	storage.StorePrice(prices, 2000)
+	if stored(storage, PRICE_BUCKET, 2000)[0] != FORMAT_GOB {
+		t.Fatalf("Expected new prices to be stored in the latest format")
+	}
+	for _, version := range []common.Version{1000, 2000} {
+		result, err := storage.GetAllPrices(version)
+		if err != nil || result.Data["OMG-ETH"]["binance"].Bids[0].Rate != 0.015 {
+			t.Fatalf("Expected prices of version %d to be read, got %+v, %v", version, result, err)
+		}
+	}
+	count, err := storage.Reencode()
+	if err != nil || count != 2 {
+		t.Fatalf("Expected json prices and rates to be re-encoded, got %d, %v", count, err)
+	}
+	if stored(storage, PRICE_BUCKET, 1000)[0] != FORMAT_GOB || stored(storage, RATE_BUCKET, 1000)[0] != FORMAT_GOB {
+		t.Fatalf("Expected snapshots to be in the latest format after re-encoding")
+	}
+	result, err := storage.GetAllRates(1000)
+	if err != nil || result.Data["OMG"].BaseSell.Cmp(big.NewInt(2000)) != 0 || result.Data["OMG"].Block != 5 {
+		t.Fatalf("Expected re-encoded rates to be the same, got %+v, %v", result, err)
+	}
+	if count, err = storage.Reencode(); err != nil || count != 0 {
+		t.Fatalf("Expected nothing to re-encode again, got %d, %v", count, err)
+	}
+}
+
+func TestReencodingGoesOnAcrossBatches(t *testing.T) {
+	boltFile := "test_bolt_format_batches.db"
+	defer os.Remove(boltFile)
+	storage := newTestBoltStorage(t, boltFile)
+	total := REENCODE_BATCH*2 + 1
+	for i := 1; i <= total; i++ {
+		putJSON(t, storage, PRICE_BUCKET, uint64(i), common.AllPriceEntry{Block: uint64(i)})
+	}
+	count, err := storage.Reencode()
+	if err != nil || count != total {
+		t.Fatalf("Expected %d snapshots to be re-encoded, got %d, %v", total, count, err)
+	}
+	if stored(storage, PRICE_BUCKET, uint64(total))[0] != FORMAT_GOB {
+		t.Fatalf("Expected the last snapshot to be re-encoded")
+	}
+}
+
+func TestReencodingKeepsJSONOutput(t *testing.T) {
+	boltFile := "test_bolt_format_output.db"
+	defer os.Remove(boltFile)
+	storage := newTestBoltStorage(t, boltFile)
+	putJSON(t, storage, PRICE_BUCKET, 1000, common.AllPriceEntry{Block: 1, Data: map[common.TokenPairID]common.OnePrice{
+		"OMG-ETH": common.OnePrice{
+			"binance": common.ExchangePrice{
+				Valid: true,
+				Bids:  []common.PriceEntry{{10, 0.015}},
+				Asks:  []common.PriceEntry{},
+			},
+			"bittrex": common.ExchangePrice{Valid: false, Error: "timeout"},
+		},
+		"KNC-ETH": common.OnePrice{},
+	}})
+	putJSON(t, storage, RATE_BUCKET, 1000, common.AllRateEntry{Valid: true})
+	output := func() []byte {
+		prices, err := storage.GetAllPrices(1000)
+		if err != nil {
+			t.Fatalf("Couldn't get prices: %s", err)
+		}
+		rates, err := storage.GetAllRates(1000)
+		if err != nil {
+			t.Fatalf("Couldn't get rates: %s", err)
+		}
+		result, _ := json.Marshal([]interface{}{prices, rates})
+		return result
+	}
+	before := output()
+	if _, err := storage.Reencode(); err != nil {
+		t.Fatalf("Couldn't re-encode: %s", err)
+	}
+	after := output()
+	if !bytes.Equal(before, after) {
+		t.Fatalf("Expected the same json after re-encoding\nbefore: %s\nafter:  %s", before, after)
+	}
+}
+
+// benchmarkPrices is a snapshot of 20 pairs on 5 exchanges with books
+// 50 levels deep
+func benchmarkPrices() common.AllPriceEntry {
+	book := []common.PriceEntry{}
+	for i := 0; i < 50; i++ {
+		book = append(book, common.PriceEntry{Quantity: float64(i) * 1.5, Rate: 0.001 * float64(i+1)})
+	}
+	data := map[common.TokenPairID]common.OnePrice{}
+	for i := 0; i < 20; i++ {
+		onePrice := common.OnePrice{}
+		for j := 0; j < 5; j++ {
+			onePrice[common.ExchangeID(fmt.Sprintf("exchange%d", j))] = common.ExchangePrice{
+				Valid:     true,
+				Timestamp: "1514114579228",
+				Bids:      book,
+				Asks:      book,
+			}
+		}
+		data[common.TokenPairID(fmt.Sprintf("TOKEN%d-ETH", i))] = onePrice
+	}
+	return common.AllPriceEntry{Block: 1, Data: data}
+}
+
+func BenchmarkPricesGob(b *testing.B) {
+	prices := benchmarkPrices()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		data, err := encodeSnapshot(prices)
+		if err != nil {
+			b.Fatal(err)
+		}
+		result := common.AllPriceEntry{}
+		if err = decodeSnapshot(data, &result); err != nil {
+			b.Fatal(err)
+		}
+		b.SetBytes(int64(len(data)))
+	}
+}
+
+func BenchmarkPricesJSON(b *testing.B) {
+	prices := benchmarkPrices()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		data, err := json.Marshal(prices)
+		if err != nil {
+			b.Fatal(err)
+		}
+		result := common.AllPriceEntry{}
+		if err = decodeSnapshot(data, &result); err != nil {
+			b.Fatal(err)
+		}
+		b.SetBytes(int64(len(data)))
+	}
+}
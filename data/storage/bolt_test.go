@@ -5,41 +5,71 @@ import (
 	"testing"
 
 	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/boltdb/bolt"
 )
 
-func TestHasPendingDepositBoltStorage(t *testing.T) {
-	boltFile := "test_bolt.db"
+func newTestBoltStorage(t *testing.T, boltFile string) *BoltStorage {
 	os.Remove(boltFile)
 	storage, err := NewBoltStorage(boltFile)
 	if err != nil {
 		t.Fatalf("Couldn't init bolt storage %v", err)
 	}
-	token := common.Token{"OMG", "0x1111111111111111111111111111111111111111", 18}
-	exchange := common.TestExchange{}
-	timepoint := common.GetTimepoint()
-	out := storage.HasPendingDeposit(token, exchange)
-	if out != false {
-		t.Fatalf("Expected ram storage to return true false there is no pending deposit for the same currency and exchange")
+	return storage
+}
+
+func TestHasPendingDepositBoltStorage(t *testing.T) {
+	boltFile := "test_bolt.db"
+	defer os.Remove(boltFile)
+	testHasPendingDeposit(t, newTestBoltStorage(t, boltFile))
+}
+
+func TestPriceVersionsBoltStorage(t *testing.T) {
+	boltFile := "test_bolt_prices.db"
+	defer os.Remove(boltFile)
+	testPriceVersions(t, newTestBoltStorage(t, boltFile))
+}
+
+func TestTradeHistoryBoltStorage(t *testing.T) {
+	boltFile := "test_bolt_trade_history.db"
+	defer os.Remove(boltFile)
+	testTradeHistory(t, newTestBoltStorage(t, boltFile))
+}
+
+func TestReserveEventsBoltStorage(t *testing.T) {
+	boltFile := "test_bolt_reserve_events.db"
+	defer os.Remove(boltFile)
+	testReserveEvents(t, newTestBoltStorage(t, boltFile))
+}
+
+func TestPruneBoltStorage(t *testing.T) {
+	boltFile := "test_bolt_prune.db"
+	defer os.Remove(boltFile)
+	testPrune(t, newTestBoltStorage(t, boltFile))
+}
+
+func TestActivitiesBoltStorage(t *testing.T) {
+	boltFile := "test_bolt_activities.db"
+	defer os.Remove(boltFile)
+	testActivities(t, newTestBoltStorage(t, boltFile))
+}
+
+func TestActivitiesAreIndexedOnOpen(t *testing.T) {
+	boltFile := "test_bolt_activity_index.db"
+	defer os.Remove(boltFile)
+	storage := newTestBoltStorage(t, boltFile)
+	storage.Record("deposit", common.ActivityID{1000000000, "1"}, "binance", map[string]interface{}{}, map[string]interface{}{}, "", "submitted", 1000)
+	// as if stored before activities were indexed
+	storage.db.Update(func(tx *bolt.Tx) error {
+		return tx.DeleteBucket([]byte(ACTIVITY_INDEX_BUCKET))
+	})
+	storage.Close()
+	storage, err := NewBoltStorage(boltFile)
+	if err != nil {
+		t.Fatalf("Couldn't reopen bolt storage %v", err)
 	}
-	storage.Record(
-		"deposit",
-		common.ActivityID{1, "1"},
-		string(exchange.ID()),
-		map[string]interface{}{
-			"exchange":  exchange,
-			"token":     token,
-			"amount":    "1.0",
-			"timepoint": timepoint,
-		},
-		map[string]interface{}{
-			"tx":    "",
-			"error": nil,
-		},
-		"",
-		"submitted",
-		common.GetTimepoint())
-	out = storage.HasPendingDeposit(token, exchange)
-	if out != true {
-		t.Fatalf("Expected ram storage to return true when there is pending deposit")
+	defer storage.Close()
+	activities, _, err := storage.GetActivities(common.ActivityFilter{Destination: "binance"}, "", 10)
+	if err != nil || len(activities) != 1 {
+		t.Fatalf("Expected the stored deposit to be indexed, got %v, %v", activities, err)
 	}
 }
@@ -0,0 +1,604 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/metric"
+)
+
+// PostgresStorage keeps the same data as BoltStorage in a postgres
+// database, so it can be shared by several processes and queried with
+// sql. The driver registered as "postgres" is used, the reserve binary
+// registers github.com/lib/pq.
+type PostgresStorage struct {
+	db *sql.DB
+}
+
+// NewPostgresStorage connects to the database at dsn and migrates its
+// schema to the latest version
+func NewPostgresStorage(dsn string) (*PostgresStorage, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err = migratePostgres(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &PostgresStorage{db}, nil
+}
+
+// Close releases connections to the database, no storage call can be
+// made after
+func (self *PostgresStorage) Close() error {
+	return self.db.Close()
+}
+
+// pgInt turns a timepoint, block or id into a BIGINT, the largest ones
+// standing for no bound are capped
+func pgInt(u uint64) int64 {
+	if u > math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return int64(u)
+}
+
+// currentVersion returns the latest timepoint of table at or before
+// timepoint
+func (self *PostgresStorage) currentVersion(table string, timepoint uint64) (common.Version, error) {
+	var result sql.NullInt64
+	err := self.db.QueryRow(
+		fmt.Sprintf("SELECT MAX(timepoint) FROM %s WHERE timepoint <= $1", table),
+		pgInt(timepoint),
+	).Scan(&result)
+	if err != nil {
+		return 0, err
+	}
+	if !result.Valid {
+		return 0, errors.New(fmt.Sprintf("There is no data before timepoint %d", timepoint))
+	}
+	return common.Version(result.Int64), nil
+}
+
+// versionsBetween returns every version of table from fromTime to
+// toTime, in order
+func (self *PostgresStorage) versionsBetween(table string, fromTime, toTime uint64) ([]common.Version, error) {
+	result := []common.Version{}
+	rows, err := self.db.Query(
+		fmt.Sprintf("SELECT timepoint FROM %s WHERE timepoint >= $1 AND timepoint <= $2 ORDER BY timepoint", table),
+		pgInt(fromTime), pgInt(toTime),
+	)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var version int64
+		if err = rows.Scan(&version); err != nil {
+			return result, err
+		}
+		result = append(result, common.Version(version))
+	}
+	return result, rows.Err()
+}
+
+func (self *PostgresStorage) PriceVersions(fromTime, toTime uint64) ([]common.Version, error) {
+	return self.versionsBetween("prices", fromTime, toTime)
+}
+
+func (self *PostgresStorage) AuthDataVersions(fromTime, toTime uint64) ([]common.Version, error) {
+	return self.versionsBetween("auth_data", fromTime, toTime)
+}
+
+func (self *PostgresStorage) RateVersions(fromTime, toTime uint64) ([]common.Version, error) {
+	return self.versionsBetween("rates", fromTime, toTime)
+}
+
+// getVersion decodes data of table stored at version into result
+func (self *PostgresStorage) getVersion(table string, version common.Version, result interface{}) error {
+	var data []byte
+	err := self.db.QueryRow(
+		fmt.Sprintf("SELECT data FROM %s WHERE timepoint = $1", table),
+		pgInt(uint64(version)),
+	).Scan(&data)
+	if err == sql.ErrNoRows {
+		return errors.New(fmt.Sprintf("version %d doesn't exist", version))
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, result)
+}
+
+// storeVersion stores data in table at timepoint
+func (self *PostgresStorage) storeVersion(table string, timepoint uint64, data interface{}) error {
+	dataJson, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = self.db.Exec(
+		fmt.Sprintf(`INSERT INTO %s (timepoint, data) VALUES ($1, $2)
+			ON CONFLICT (timepoint) DO UPDATE SET data = EXCLUDED.data`, table),
+		pgInt(timepoint), dataJson,
+	)
+	return err
+}
+
+// tables retention applies to, per kind of data, with the column
+// their versions are in
+var retainedTables = map[string][2]string{
+	common.RETAIN_PRICES:    {"prices", "timepoint"},
+	common.RETAIN_RATES:     {"rates", "timepoint"},
+	common.RETAIN_AUTH_DATA: {"auth_data", "timepoint"},
+	common.RETAIN_METRICS:   {"metrics", "timestamp"},
+}
+
+// Prune removes versions of kind retention doesn't keep in one
+// transaction, it is rolled back if archiving or syncing them fails
+func (self *PostgresStorage) Prune(kind string, retention common.Retention, now uint64, archive Archive) (int, error) {
+	retained, found := retainedTables[kind]
+	if !found {
+		return 0, errors.New(fmt.Sprintf("There is no retention for %s", kind))
+	}
+	table, column := retained[0], retained[1]
+	condition := fmt.Sprintf("%s < $1", column)
+	args := []interface{}{pgInt(retention.Cutoff(now))}
+	if retention.MaxVersions > 0 {
+		condition += fmt.Sprintf(" OR %s <= (SELECT %s FROM %s ORDER BY %s DESC OFFSET $2 LIMIT 1)",
+			column, column, table, column)
+		args = append(args, retention.MaxVersions)
+	}
+	tx, err := self.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	rows, err := tx.Query(
+		fmt.Sprintf("DELETE FROM %s WHERE %s RETURNING %s, data", table, condition, column),
+		args...,
+	)
+	if err != nil {
+		return 0, err
+	}
+	pruned := 0
+	for rows.Next() {
+		var version int64
+		var data []byte
+		if err = rows.Scan(&version, &data); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if archive != nil {
+			if err = archive.Archive(kind, uint64(version), data); err != nil {
+				rows.Close()
+				return 0, err
+			}
+		}
+		pruned++
+	}
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+	if archive != nil && pruned > 0 {
+		if err = archive.Sync(); err != nil {
+			return 0, err
+		}
+	}
+	return pruned, tx.Commit()
+}
+
+func (self *PostgresStorage) CurrentPriceVersion(timepoint uint64) (common.Version, error) {
+	return self.currentVersion("prices", timepoint)
+}
+
+func (self *PostgresStorage) GetAllPrices(version common.Version) (common.AllPriceEntry, error) {
+	result := common.AllPriceEntry{}
+	err := self.getVersion("prices", version, &result)
+	return result, err
+}
+
+func (self *PostgresStorage) GetOnePrice(pair common.TokenPairID, version common.Version) (common.OnePrice, error) {
+	result, err := self.GetAllPrices(version)
+	if err != nil {
+		return common.OnePrice{}, err
+	}
+	price, exist := result.Data[pair]
+	if !exist {
+		return common.OnePrice{}, errors.New("Pair of token is not supported")
+	}
+	return price, nil
+}
+
+func (self *PostgresStorage) CurrentAuthDataVersion(timepoint uint64) (common.Version, error) {
+	return self.currentVersion("auth_data", timepoint)
+}
+
+func (self *PostgresStorage) GetAuthData(version common.Version) (common.AuthDataSnapshot, error) {
+	result := common.AuthDataSnapshot{}
+	err := self.getVersion("auth_data", version, &result)
+	return result, err
+}
+
+func (self *PostgresStorage) CurrentRateVersion(timepoint uint64) (common.Version, error) {
+	return self.currentVersion("rates", timepoint)
+}
+
+func (self *PostgresStorage) GetAllRates(version common.Version) (common.AllRateEntry, error) {
+	result := common.AllRateEntry{}
+	err := self.getVersion("rates", version, &result)
+	return result, err
+}
+
+func (self *PostgresStorage) StorePrice(data common.AllPriceEntry, timepoint uint64) error {
+	return self.storeVersion("prices", timepoint, data)
+}
+
+func (self *PostgresStorage) StoreAuthSnapshot(data *common.AuthDataSnapshot, timepoint uint64) error {
+	return self.storeVersion("auth_data", timepoint, data)
+}
+
+func (self *PostgresStorage) StoreRate(data common.AllRateEntry, timepoint uint64) error {
+	return self.storeVersion("rates", timepoint, data)
+}
+
+// putActivity inserts or replaces activity, it is pending as long as
+// the activity is
+func (self *PostgresStorage) putActivity(activity common.ActivityRecord) error {
+	dataJson, err := json.Marshal(activity)
+	if err != nil {
+		return err
+	}
+	_, err = self.db.Exec(
+		`INSERT INTO activities (id, action, destination, pending, data,
+			timepoint, exchange_status, mining_status, tokens)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (id) DO UPDATE SET
+			action = EXCLUDED.action,
+			destination = EXCLUDED.destination,
+			pending = EXCLUDED.pending,
+			data = EXCLUDED.data,
+			timepoint = EXCLUDED.timepoint,
+			exchange_status = EXCLUDED.exchange_status,
+			mining_status = EXCLUDED.mining_status,
+			tokens = EXCLUDED.tokens`,
+		activity.ID.String(), activity.Action, activity.Destination, activity.IsPending(), dataJson,
+		pgInt(activity.ID.Timepoint), activity.ExchangeStatus, activity.MiningStatus, pgTextArray(activity.Tokens()),
+	)
+	return err
+}
+
+func (self *PostgresStorage) Record(
+	action string,
+	id common.ActivityID,
+	destination string,
+	params map[string]interface{}, result map[string]interface{},
+	estatus string,
+	mstatus string,
+	timepoint uint64) error {
+	return self.putActivity(common.ActivityRecord{
+		Action:         action,
+		ID:             id,
+		Destination:    destination,
+		Params:         params,
+		Result:         result,
+		ExchangeStatus: estatus,
+		MiningStatus:   mstatus,
+		Timestamp:      common.Timestamp(strconv.FormatUint(timepoint, 10)),
+	})
+}
+
+func (self *PostgresStorage) UpdateActivity(id common.ActivityID, activity common.ActivityRecord) error {
+	activity.ID = id
+	return self.putActivity(activity)
+}
+
+func (self *PostgresStorage) getActivities(query string, args ...interface{}) ([]common.ActivityRecord, error) {
+	result := []common.ActivityRecord{}
+	rows, err := self.db.Query(query, args...)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var data []byte
+		if err = rows.Scan(&data); err != nil {
+			return result, err
+		}
+		record := common.ActivityRecord{}
+		if err = json.Unmarshal(data, &record); err != nil {
+			return result, err
+		}
+		result = append(result, record)
+	}
+	return result, rows.Err()
+}
+
+func (self *PostgresStorage) GetAllRecords() ([]common.ActivityRecord, error) {
+	return self.getActivities("SELECT data FROM activities ORDER BY id")
+}
+
+// GetActivities returns activities filter selects, newest first, made
+// before the one with id cursor if it isn't empty. At most limit of them
+// are returned along with the cursor of the next page, empty if there is
+// none.
+func (self *PostgresStorage) GetActivities(filter common.ActivityFilter, cursor string, limit int) ([]common.ActivityRecord, string, error) {
+	limit = common.ActivityLimit(limit)
+	from, to := filter.IDRange()
+	conditions := []string{"timepoint >= $1", "timepoint <= $2"}
+	args := []interface{}{pgInt(from), pgInt(to)}
+	for column, value := range map[string]string{
+		"destination":     filter.Destination,
+		"action":          filter.Action,
+		"exchange_status": filter.ExchangeStatus,
+		"mining_status":   filter.MiningStatus,
+	} {
+		if value != "" {
+			args = append(args, value)
+			conditions = append(conditions, fmt.Sprintf("%s = $%d", column, len(args)))
+		}
+	}
+	if filter.Token != "" {
+		args = append(args, pgTextArray([]string{filter.Token}))
+		conditions = append(conditions, fmt.Sprintf("tokens @> $%d::TEXT[]", len(args)))
+	}
+	if cursor != "" {
+		id, err := common.StringToActivityID(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		args = append(args, pgInt(id.Timepoint), id.String())
+		conditions = append(conditions, fmt.Sprintf("(timepoint, id) < ($%d, $%d)", len(args)-1, len(args)))
+	}
+	args = append(args, limit+1)
+	result, err := self.getActivities(
+		fmt.Sprintf("SELECT data FROM activities WHERE %s ORDER BY timepoint DESC, id DESC LIMIT $%d",
+			strings.Join(conditions, " AND "), len(args)),
+		args...,
+	)
+	if err != nil || len(result) <= limit {
+		return result, "", err
+	}
+	return result[:limit], result[limit-1].ID.String(), nil
+}
+
+// pgTextArray formats values as a postgres array literal
+func pgTextArray(values []string) string {
+	quoted := []string{}
+	for _, value := range values {
+		value = strings.Replace(value, `\`, `\\`, -1)
+		quoted = append(quoted, `"`+strings.Replace(value, `"`, `\"`, -1)+`"`)
+	}
+	return "{" + strings.Join(quoted, ",") + "}"
+}
+
+func (self *PostgresStorage) GetPendingActivities() ([]common.ActivityRecord, error) {
+	return self.getActivities("SELECT data FROM activities WHERE pending ORDER BY id")
+}
+
+func (self *PostgresStorage) HasPendingDeposit(token common.Token, exchange common.Exchange) bool {
+	records, err := self.getActivities(
+		"SELECT data FROM activities WHERE pending AND action = 'deposit' AND destination = $1",
+		string(exchange.ID()),
+	)
+	if err != nil {
+		log.Printf("Getting pending deposits failed: %s\n", err)
+		return false
+	}
+	for _, record := range records {
+		if id, ok := record.Params["token"].(string); ok && id == token.ID {
+			return true
+		}
+	}
+	return false
+}
+
+func (self *PostgresStorage) IsNewBittrexDeposit(id uint64, actID common.ActivityID) bool {
+	var stored string
+	err := self.db.QueryRow(
+		"SELECT activity_id FROM bittrex_deposits WHERE id = $1", pgInt(id),
+	).Scan(&stored)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("Getting bittrex deposit %d failed: %s\n", id, err)
+		}
+		return true
+	}
+	return stored == actID.String()
+}
+
+func (self *PostgresStorage) RegisterBittrexDeposit(id uint64, actID common.ActivityID) error {
+	_, err := self.db.Exec(
+		`INSERT INTO bittrex_deposits (id, activity_id) VALUES ($1, $2)
+		ON CONFLICT (id) DO UPDATE SET activity_id = EXCLUDED.activity_id`,
+		pgInt(id), actID.String(),
+	)
+	return err
+}
+
+func (self *PostgresStorage) StoreTradeHistory(records []common.TradeFillRecord) error {
+	tx, err := self.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, record := range records {
+		dataJson, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(
+			`INSERT INTO trade_history (timestamp, exchange, id, pair, data)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (timestamp, exchange, id) DO UPDATE SET data = EXCLUDED.data`,
+			pgInt(record.Timestamp), string(record.Exchange), record.ID, string(record.Pair), dataJson,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (self *PostgresStorage) LastTradeFillTime(exchange common.ExchangeID, pair common.TokenPairID) (uint64, error) {
+	var result sql.NullInt64
+	err := self.db.QueryRow(
+		"SELECT MAX(timestamp) FROM trade_history WHERE exchange = $1 AND pair = $2",
+		string(exchange), string(pair),
+	).Scan(&result)
+	if err != nil || !result.Valid {
+		return 0, err
+	}
+	return uint64(result.Int64), nil
+}
+
+func (self *PostgresStorage) GetTradeHistory(fromTime, toTime uint64) ([]common.TradeFillRecord, error) {
+	result := []common.TradeFillRecord{}
+	rows, err := self.db.Query(
+		`SELECT data FROM trade_history WHERE timestamp >= $1 AND timestamp <= $2
+		ORDER BY timestamp, exchange, id`,
+		pgInt(fromTime), pgInt(toTime),
+	)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var data []byte
+		if err = rows.Scan(&data); err != nil {
+			return result, err
+		}
+		record := common.TradeFillRecord{}
+		if err = json.Unmarshal(data, &record); err != nil {
+			return result, err
+		}
+		result = append(result, record)
+	}
+	return result, rows.Err()
+}
+
+func (self *PostgresStorage) StoreReserveEvents(fromBlock, toBlock uint64, events []common.ReserveEvent) error {
+	tx, err := self.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err = tx.Exec("DELETE FROM reserve_events WHERE block >= $1", pgInt(fromBlock)); err != nil {
+		return err
+	}
+	for _, event := range events {
+		dataJson, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(
+			"INSERT INTO reserve_events (block, log_index, data) VALUES ($1, $2, $3)",
+			pgInt(event.BlockNumber), int64(event.LogIndex), dataJson,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec(
+		`INSERT INTO reserve_event_block (id, last) VALUES (1, $1)
+		ON CONFLICT (id) DO UPDATE SET last = EXCLUDED.last`,
+		pgInt(toBlock),
+	)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (self *PostgresStorage) LastEventBlock() (uint64, error) {
+	var result int64
+	err := self.db.QueryRow("SELECT last FROM reserve_event_block WHERE id = 1").Scan(&result)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return uint64(result), err
+}
+
+func (self *PostgresStorage) GetReserveEvents(fromBlock, toBlock uint64) ([]common.ReserveEvent, error) {
+	result := []common.ReserveEvent{}
+	rows, err := self.db.Query(
+		`SELECT data FROM reserve_events WHERE block >= $1 AND block <= $2
+		ORDER BY block, log_index`,
+		pgInt(fromBlock), pgInt(toBlock),
+	)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var data []byte
+		if err = rows.Scan(&data); err != nil {
+			return result, err
+		}
+		event := common.ReserveEvent{}
+		if err = json.Unmarshal(data, &event); err != nil {
+			return result, err
+		}
+		result = append(result, event)
+	}
+	return result, rows.Err()
+}
+
+func (self *PostgresStorage) StoreMetric(data *metric.MetricEntry, timepoint uint64) error {
+	dataJson, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = self.db.Exec(
+		`INSERT INTO metrics (timestamp, data) VALUES ($1, $2)
+		ON CONFLICT (timestamp) DO UPDATE SET data = EXCLUDED.data`,
+		pgInt(data.Timestamp), dataJson,
+	)
+	return err
+}
+
+func (self *PostgresStorage) GetMetric(tokens []common.Token, fromTime, toTime uint64) (map[string]metric.MetricList, error) {
+	result := map[string]metric.MetricList{}
+	for _, tok := range tokens {
+		result[tok.ID] = metric.MetricList{}
+	}
+	rows, err := self.db.Query(
+		"SELECT data FROM metrics WHERE timestamp >= $1 AND timestamp <= $2 ORDER BY timestamp",
+		pgInt(fromTime), pgInt(toTime),
+	)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var dataJson []byte
+		if err = rows.Scan(&dataJson); err != nil {
+			return result, err
+		}
+		data := metric.MetricEntry{}
+		if err = json.Unmarshal(dataJson, &data); err != nil {
+			return result, err
+		}
+		for tok, m := range data.Data {
+			if metricList, found := result[tok]; found {
+				result[tok] = append(metricList, metric.TokenMetricResponse{
+					Timestamp: data.Timestamp,
+					AfpMid:    m.AfpMid,
+					Spread:    m.Spread,
+				})
+			}
+		}
+	}
+	return result, rows.Err()
+}
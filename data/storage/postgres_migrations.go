@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// postgresMigrations creates and changes the schema of PostgresStorage,
+// each one is applied once in its own transaction in this order. Applied
+// migrations must never be changed, add a new one instead.
+var postgresMigrations = []string{
+	// 1: one table per bolt bucket, data is kept in the same json
+	`CREATE TABLE prices (
+		timepoint BIGINT PRIMARY KEY,
+		data JSONB NOT NULL
+	);
+	CREATE TABLE rates (
+		timepoint BIGINT PRIMARY KEY,
+		data JSONB NOT NULL
+	);
+	CREATE TABLE auth_data (
+		timepoint BIGINT PRIMARY KEY,
+		data JSONB NOT NULL
+	);
+	CREATE TABLE activities (
+		id TEXT PRIMARY KEY,
+		action TEXT NOT NULL,
+		destination TEXT NOT NULL,
+		pending BOOLEAN NOT NULL,
+		data JSONB NOT NULL
+	);
+	CREATE INDEX activities_pending ON activities (pending) WHERE pending;
+	CREATE TABLE bittrex_deposits (
+		id BIGINT PRIMARY KEY,
+		activity_id TEXT NOT NULL
+	);
+	CREATE TABLE metrics (
+		timestamp BIGINT PRIMARY KEY,
+		data JSONB NOT NULL
+	);`,
+	// 2: trade fills, stored once per exchange and fill id
+	`CREATE TABLE trade_history (
+		timestamp BIGINT NOT NULL,
+		exchange TEXT NOT NULL,
+		id TEXT NOT NULL,
+		pair TEXT NOT NULL,
+		data JSONB NOT NULL,
+		PRIMARY KEY (timestamp, exchange, id)
+	);
+	CREATE INDEX trade_history_last ON trade_history (exchange, pair, timestamp);`,
+	// 3: events of the reserve contracts and the last watched block
+	`CREATE TABLE reserve_events (
+		block BIGINT NOT NULL,
+		log_index BIGINT NOT NULL,
+		data JSONB NOT NULL,
+		PRIMARY KEY (block, log_index)
+	);
+	CREATE TABLE reserve_event_block (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		last BIGINT NOT NULL
+	);`,
+	// 4: columns activities are queried by, filled from stored data
+	`ALTER TABLE activities
+		ADD COLUMN timepoint BIGINT NOT NULL DEFAULT 0,
+		ADD COLUMN exchange_status TEXT NOT NULL DEFAULT '',
+		ADD COLUMN mining_status TEXT NOT NULL DEFAULT '',
+		ADD COLUMN tokens TEXT[] NOT NULL DEFAULT '{}';
+	UPDATE activities SET
+		timepoint = split_part(id, '|', 1)::BIGINT,
+		exchange_status = COALESCE(data->>'ExchangeStatus', ''),
+		mining_status = COALESCE(data->>'MiningStatus', ''),
+		tokens = ARRAY(
+			SELECT token FROM (
+				SELECT data->'Params'->>'token' AS token
+				UNION ALL SELECT data->'Params'->>'base'
+				UNION ALL SELECT data->'Params'->>'quote'
+				UNION ALL SELECT jsonb_array_elements_text(
+					CASE jsonb_typeof(data->'Params'->'tokens')
+					WHEN 'array' THEN data->'Params'->'tokens'
+					ELSE '[]'::JSONB END)
+			) AS params WHERE token IS NOT NULL
+		);
+	CREATE INDEX activities_time ON activities (timepoint, id);
+	CREATE INDEX activities_destination ON activities (destination, timepoint);
+	CREATE INDEX activities_action ON activities (action, timepoint);
+	CREATE INDEX activities_exchange_status ON activities (exchange_status, timepoint);
+	CREATE INDEX activities_mining_status ON activities (mining_status, timepoint);
+	CREATE INDEX activities_tokens ON activities USING GIN (tokens);`,
+}
+
+// migratePostgres applies migrations db hasn't had yet. The migrations
+// table is locked while one is applied so processes starting together
+// don't apply it twice.
+func migratePostgres(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return err
+	}
+	for {
+		applied, err := applyNextMigration(db)
+		if err != nil || !applied {
+			return err
+		}
+	}
+}
+
+// applyNextMigration returns false if every migration was applied
+func applyNextMigration(db *sql.DB) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	if _, err = tx.Exec("LOCK TABLE schema_migrations IN EXCLUSIVE MODE"); err != nil {
+		return false, err
+	}
+	var current int
+	err = tx.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&current)
+	if err != nil {
+		return false, err
+	}
+	if current > len(postgresMigrations) {
+		return false, errors.New(fmt.Sprintf("Database schema version %d is newer than the %d migrations known", current, len(postgresMigrations)))
+	}
+	if current == len(postgresMigrations) {
+		return false, nil
+	}
+	version := current + 1
+	if _, err = tx.Exec(postgresMigrations[current]); err != nil {
+		return false, errors.New(fmt.Sprintf("Migration %d failed: %s", version, err))
+	}
+	if _, err = tx.Exec("INSERT INTO schema_migrations (version) VALUES ($1)", version); err != nil {
+		return false, err
+	}
+	if err = tx.Commit(); err != nil {
+		return false, err
+	}
+	log.Printf("Applied migration %d\n", version)
+	return true, nil
+}
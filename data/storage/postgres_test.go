@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"os"
+	"testing"
+
+	_ "github.com/lib/pq"
+)
+
+// newTestPostgresStorage connects to the database in
+// KYBER_TEST_POSTGRES, which must be one for tests only as every table
+// is emptied
+func newTestPostgresStorage(t *testing.T) *PostgresStorage {
+	dsn := os.Getenv("KYBER_TEST_POSTGRES")
+	if dsn == "" {
+		t.Skip("KYBER_TEST_POSTGRES is not set")
+	}
+	storage, err := NewPostgresStorage(dsn)
+	if err != nil {
+		t.Fatalf("Couldn't init postgres storage %v", err)
+	}
+	_, err = storage.db.Exec(`TRUNCATE prices, rates, auth_data, activities,
+		bittrex_deposits, metrics, trade_history, reserve_events, reserve_event_block`)
+	if err != nil {
+		t.Fatalf("Couldn't empty postgres storage %v", err)
+	}
+	return storage
+}
+
+func TestMigrationsAreAppliedOnce(t *testing.T) {
+	storage := newTestPostgresStorage(t)
+	defer storage.Close()
+	if err := migratePostgres(storage.db); err != nil {
+		t.Fatalf("Expected migrating again to do nothing, got %v", err)
+	}
+	var version int
+	storage.db.QueryRow("SELECT MAX(version) FROM schema_migrations").Scan(&version)
+	if version != len(postgresMigrations) {
+		t.Fatalf("Expected schema version %d, got %d", len(postgresMigrations), version)
+	}
+}
+
+func TestHasPendingDepositPostgresStorage(t *testing.T) {
+	storage := newTestPostgresStorage(t)
+	defer storage.Close()
+	testHasPendingDeposit(t, storage)
+}
+
+func TestPriceVersionsPostgresStorage(t *testing.T) {
+	storage := newTestPostgresStorage(t)
+	defer storage.Close()
+	testPriceVersions(t, storage)
+}
+
+func TestTradeHistoryPostgresStorage(t *testing.T) {
+	storage := newTestPostgresStorage(t)
+	defer storage.Close()
+	testTradeHistory(t, storage)
+}
+
+func TestReserveEventsPostgresStorage(t *testing.T) {
+	storage := newTestPostgresStorage(t)
+	defer storage.Close()
+	testReserveEvents(t, storage)
+}
+
+func TestPrunePostgresStorage(t *testing.T) {
+	storage := newTestPostgresStorage(t)
+	defer storage.Close()
+	testPrune(t, storage)
+}
+
+func TestActivitiesPostgresStorage(t *testing.T) {
+	storage := newTestPostgresStorage(t)
+	defer storage.Close()
+	testActivities(t, storage)
+}
@@ -3,6 +3,7 @@ package storage
 import (
 	"container/list"
 	"errors"
+	"sort"
 	"strconv"
 	"sync"
 
@@ -100,6 +101,42 @@ func (self *RamActivityStorage) GetAllRecords() ([]common.ActivityRecord, error)
 	return activitiesFromList(self.records), nil
 }
 
+// GetActivities returns activities filter selects, newest first, made
+// before the one with id cursor if it isn't empty. At most limit of them
+// are returned along with the cursor of the next page, empty if there is
+// none.
+func (self *RamActivityStorage) GetActivities(filter common.ActivityFilter, cursor string, limit int) ([]common.ActivityRecord, string, error) {
+	limit = common.ActivityLimit(limit)
+	var before *common.ActivityID
+	if cursor != "" {
+		id, err := common.StringToActivityID(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		before = &id
+	}
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	records := []common.ActivityRecord{}
+	for ele := self.records.Front(); ele != nil; ele = ele.Next() {
+		record := *ele.Value.(*common.ActivityRecord)
+		if filter.Match(record) && (before == nil || activityBefore(record.ID, *before)) {
+			records = append(records, record)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return activityBefore(records[j].ID, records[i].ID)
+	})
+	if len(records) <= limit {
+		return records, "", nil
+	}
+	return records[:limit], records[limit-1].ID.String(), nil
+}
+
+func activityBefore(id, other common.ActivityID) bool {
+	return id.Timepoint < other.Timepoint || (id.Timepoint == other.Timepoint && id.EID < other.EID)
+}
+
 func (self *RamActivityStorage) GetPendingRecords() ([]common.ActivityRecord, error) {
 	self.mu.RLock()
 	defer self.mu.RUnlock()
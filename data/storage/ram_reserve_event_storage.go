@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+type RamReserveEventStorage struct {
+	mu     sync.RWMutex
+	events []common.ReserveEvent
+	last   uint64
+}
+
+func NewRamReserveEventStorage() *RamReserveEventStorage {
+	return &RamReserveEventStorage{
+		mu:     sync.RWMutex{},
+		events: []common.ReserveEvent{},
+		last:   0,
+	}
+}
+
+func (self *RamReserveEventStorage) StoreEvents(fromBlock, toBlock uint64, events []common.ReserveEvent) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	kept := []common.ReserveEvent{}
+	for _, event := range self.events {
+		if event.BlockNumber < fromBlock {
+			kept = append(kept, event)
+		}
+	}
+	kept = append(kept, events...)
+	sort.SliceStable(kept, func(i, j int) bool {
+		if kept[i].BlockNumber != kept[j].BlockNumber {
+			return kept[i].BlockNumber < kept[j].BlockNumber
+		}
+		return kept[i].LogIndex < kept[j].LogIndex
+	})
+	self.events = kept
+	self.last = toBlock
+	return nil
+}
+
+func (self *RamReserveEventStorage) LastBlock() uint64 {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	return self.last
+}
+
+func (self *RamReserveEventStorage) GetEvents(fromBlock, toBlock uint64) []common.ReserveEvent {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	result := []common.ReserveEvent{}
+	for _, event := range self.events {
+		if event.BlockNumber >= fromBlock && event.BlockNumber <= toBlock {
+			result = append(result, event)
+		}
+	}
+	return result
+}
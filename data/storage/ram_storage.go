@@ -1,6 +1,8 @@
 package storage
 
 import (
+	"errors"
+
 	"github.com/KyberNetwork/reserve-data/common"
 )
 
@@ -17,6 +19,8 @@ type RamStorage struct {
 	rate     *RamRateStorage
 	activity *RamActivityStorage
 	bittrex  *RamBittrexStorage
+	trade    *RamTradeHistoryStorage
+	events   *RamReserveEventStorage
 }
 
 func NewRamStorage() *RamStorage {
@@ -26,6 +30,8 @@ func NewRamStorage() *RamStorage {
 		NewRamRateStorage(),
 		NewRamActivityStorage(),
 		NewRamBittrexStorage(),
+		NewRamTradeHistoryStorage(),
+		NewRamReserveEventStorage(),
 	}
 }
 
@@ -44,6 +50,20 @@ func (self *RamStorage) CurrentRateVersion(timepoint uint64) (common.Version, er
 	return common.Version(version), err
 }
 
+// versions of ram storage are counters, not timepoints, it has no
+// history to look up by time
+func (self *RamStorage) PriceVersions(fromTime, toTime uint64) ([]common.Version, error) {
+	return nil, errors.New("Ram storage doesn't keep history")
+}
+
+func (self *RamStorage) AuthDataVersions(fromTime, toTime uint64) ([]common.Version, error) {
+	return nil, errors.New("Ram storage doesn't keep history")
+}
+
+func (self *RamStorage) RateVersions(fromTime, toTime uint64) ([]common.Version, error) {
+	return nil, errors.New("Ram storage doesn't keep history")
+}
+
 func (self *RamStorage) GetAllPrices(version common.Version) (common.AllPriceEntry, error) {
 	return self.price.GetAllPrices(int64(version))
 }
@@ -96,6 +116,10 @@ func (self *RamStorage) GetAllRecords() ([]common.ActivityRecord, error) {
 	return self.activity.GetAllRecords()
 }
 
+func (self *RamStorage) GetActivities(filter common.ActivityFilter, cursor string, limit int) ([]common.ActivityRecord, string, error) {
+	return self.activity.GetActivities(filter, cursor, limit)
+}
+
 func (self *RamStorage) GetPendingActivities() ([]common.ActivityRecord, error) {
 	return self.activity.GetPendingRecords()
 }
@@ -111,3 +135,27 @@ func (self *RamStorage) RegisterBittrexDeposit(id uint64, actID common.ActivityI
 func (self *RamStorage) HasPendingDeposit(token common.Token, exchange common.Exchange) bool {
 	return self.activity.HasPendingDeposit(token, exchange)
 }
+
+func (self *RamStorage) StoreTradeHistory(records []common.TradeFillRecord) error {
+	return self.trade.StoreNewData(records)
+}
+
+func (self *RamStorage) LastTradeFillTime(exchange common.ExchangeID, pair common.TokenPairID) (uint64, error) {
+	return self.trade.LastTime(exchange, pair), nil
+}
+
+func (self *RamStorage) GetTradeHistory(fromTime, toTime uint64) ([]common.TradeFillRecord, error) {
+	return self.trade.GetRecords(fromTime, toTime), nil
+}
+
+func (self *RamStorage) StoreReserveEvents(fromBlock, toBlock uint64, events []common.ReserveEvent) error {
+	return self.events.StoreEvents(fromBlock, toBlock, events)
+}
+
+func (self *RamStorage) LastEventBlock() (uint64, error) {
+	return self.events.LastBlock(), nil
+}
+
+func (self *RamStorage) GetReserveEvents(fromBlock, toBlock uint64) ([]common.ReserveEvent, error) {
+	return self.events.GetEvents(fromBlock, toBlock), nil
+}
@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+type RamTradeHistoryStorage struct {
+	mu   sync.RWMutex
+	data map[string]common.TradeFillRecord
+	last map[string]uint64
+}
+
+func NewRamTradeHistoryStorage() *RamTradeHistoryStorage {
+	return &RamTradeHistoryStorage{
+		mu:   sync.RWMutex{},
+		data: map[string]common.TradeFillRecord{},
+		last: map[string]uint64{},
+	}
+}
+
+func (self *RamTradeHistoryStorage) StoreNewData(records []common.TradeFillRecord) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	for _, record := range records {
+		self.data[string(record.Exchange)+"|"+record.ID] = record
+		lastKey := string(record.Exchange) + "|" + string(record.Pair)
+		if self.last[lastKey] < record.Timestamp {
+			self.last[lastKey] = record.Timestamp
+		}
+	}
+	return nil
+}
+
+func (self *RamTradeHistoryStorage) LastTime(exchange common.ExchangeID, pair common.TokenPairID) uint64 {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	return self.last[string(exchange)+"|"+string(pair)]
+}
+
+func (self *RamTradeHistoryStorage) GetRecords(fromTime, toTime uint64) []common.TradeFillRecord {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	result := []common.TradeFillRecord{}
+	for _, record := range self.data {
+		if record.Timestamp >= fromTime && record.Timestamp <= toTime {
+			result = append(result, record)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Timestamp < result[j].Timestamp
+	})
+	return result
+}
@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+// Archive receives versions before they are pruned, data is json.
+// Storage calls Sync once it has given every version of a prune and
+// only removes them if it succeeds. Versions of a prune that fails after
+// archiving them are archived again by the next one, so readers keep
+// the last line of a version.
+type Archive interface {
+	Archive(kind string, version uint64, data []byte) error
+	Sync() error
+}
+
+// PruningStorage removes versions of a kind of data its retention
+// doesn't keep any more, giving them to archive first if it isn't nil.
+// It returns how many versions were removed.
+type PruningStorage interface {
+	Prune(kind string, retention common.Retention, now uint64, archive Archive) (int, error)
+}
+
+type archivedVersion struct {
+	Kind    string          `json:"kind"`
+	Version uint64          `json:"version"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// FileArchive appends pruned versions as json lines to a gzip file, one
+// gzip member per prune that pruned any. Readers of gzip files read
+// every member.
+type FileArchive struct {
+	path string
+	file *os.File
+	gz   *gzip.Writer
+	enc  *json.Encoder
+}
+
+func NewFileArchive(path string) *FileArchive {
+	return &FileArchive{path: path}
+}
+
+func (self *FileArchive) Archive(kind string, version uint64, data []byte) error {
+	if self.file == nil {
+		file, err := os.OpenFile(self.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			return err
+		}
+		self.file = file
+	}
+	if self.gz == nil {
+		self.gz = gzip.NewWriter(self.file)
+		self.enc = json.NewEncoder(self.gz)
+	}
+	return self.enc.Encode(archivedVersion{kind, version, json.RawMessage(data)})
+}
+
+// Sync ends the gzip member of versions archived since the last sync and
+// writes it to disk
+func (self *FileArchive) Sync() error {
+	if self.gz == nil {
+		return nil
+	}
+	gz := self.gz
+	self.gz, self.enc = nil, nil
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return self.file.Sync()
+}
+
+// Close syncs versions archived since the last sync and closes the file
+func (self *FileArchive) Close() error {
+	if self.file == nil {
+		return nil
+	}
+	err := self.Sync()
+	if closeErr := self.file.Close(); err == nil {
+		err = closeErr
+	}
+	self.file = nil
+	return err
+}
+
+// Compactor prunes storage by retention policies in the background, so
+// storing data never waits for it
+type Compactor struct {
+	storage  PruningStorage
+	policies *common.RetentionPolicies
+	stop     chan bool
+	done     sync.WaitGroup
+}
+
+func NewCompactor(storage PruningStorage, policies *common.RetentionPolicies) *Compactor {
+	return &Compactor{
+		storage:  storage,
+		policies: policies,
+		stop:     make(chan bool),
+	}
+}
+
+// Compact prunes every kind of data once as of now
+func (self *Compactor) Compact(now uint64) {
+	var archive Archive
+	if self.policies.Archive != "" {
+		fileArchive := NewFileArchive(self.policies.Archive)
+		defer func() {
+			if err := fileArchive.Close(); err != nil {
+				log.Printf("Closing archive %s failed: %s", self.policies.Archive, err)
+			}
+		}()
+		archive = fileArchive
+	}
+	for kind, retention := range self.policies.Kinds {
+		pruned, err := self.storage.Prune(kind, retention, now, archive)
+		if err != nil {
+			log.Printf("Pruning %s failed: %s", kind, err)
+			continue
+		}
+		if pruned > 0 {
+			log.Printf("Pruned %d versions of %s", pruned, kind)
+		}
+	}
+}
+
+func (self *Compactor) Run() {
+	if self.policies.Interval == 0 {
+		return
+	}
+	ticker := time.NewTicker(time.Duration(self.policies.Interval) * time.Millisecond)
+	self.done.Add(1)
+	go func() {
+		defer self.done.Done()
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				self.Compact(uint64(common.GetTimepoint()))
+			case <-self.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop waits for a running compaction to finish
+func (self *Compactor) Stop() {
+	close(self.stop)
+	self.done.Wait()
+}
@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+func TestCompactionArchivesPrunedVersions(t *testing.T) {
+	boltFile := "test_bolt_compaction.db"
+	archiveFile := "test_archive.gz"
+	defer os.Remove(boltFile)
+	defer os.Remove(archiveFile)
+	os.Remove(archiveFile)
+	storage := newTestBoltStorage(t, boltFile)
+	compactor := NewCompactor(storage, &common.RetentionPolicies{
+		Archive: archiveFile,
+		Kinds: map[string]common.Retention{
+			common.RETAIN_PRICES: common.Retention{0, 1},
+		},
+	})
+	// the first compaction prunes nothing, the other two append a gzip
+	// member each
+	for timepoint := uint64(1000); timepoint <= 3000; timepoint += 1000 {
+		storage.StorePrice(common.AllPriceEntry{Block: timepoint}, timepoint)
+		compactor.Compact(timepoint)
+	}
+	file, err := os.Open(archiveFile)
+	if err != nil {
+		t.Fatalf("Archive wasn't written: %s", err)
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatalf("Archive isn't gzip: %s", err)
+	}
+	blocks := []uint64{}
+	scanner := bufio.NewScanner(gz)
+	for scanner.Scan() {
+		version := archivedVersion{}
+		prices := common.AllPriceEntry{}
+		if err = json.Unmarshal(scanner.Bytes(), &version); err != nil {
+			t.Fatalf("Archived line isn't json: %s", err)
+		}
+		if err = json.Unmarshal(version.Data, &prices); err != nil || version.Kind != common.RETAIN_PRICES {
+			t.Fatalf("Archived %s isn't prices: %v", version.Kind, err)
+		}
+		blocks = append(blocks, prices.Block)
+	}
+	if len(blocks) != 2 || blocks[0] != 1000 || blocks[1] != 2000 {
+		t.Fatalf("Expected prices of 1000 and 2000 to be archived, got %v", blocks)
+	}
+}
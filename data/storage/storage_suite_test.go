@@ -0,0 +1,275 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+// suiteStorage is what the tests shared by every persistent storage
+// run against
+type suiteStorage interface {
+	Record(action string, id common.ActivityID, destination string, params map[string]interface{}, result map[string]interface{}, estatus string, mstatus string, timepoint uint64) error
+	HasPendingDeposit(token common.Token, exchange common.Exchange) bool
+
+	StorePrice(data common.AllPriceEntry, timepoint uint64) error
+	CurrentPriceVersion(timepoint uint64) (common.Version, error)
+	GetAllPrices(version common.Version) (common.AllPriceEntry, error)
+	PriceVersions(fromTime, toTime uint64) ([]common.Version, error)
+	Prune(kind string, retention common.Retention, now uint64, archive Archive) (int, error)
+
+	StoreTradeHistory(records []common.TradeFillRecord) error
+	LastTradeFillTime(exchange common.ExchangeID, pair common.TokenPairID) (uint64, error)
+	GetTradeHistory(fromTime, toTime uint64) ([]common.TradeFillRecord, error)
+
+	StoreReserveEvents(fromBlock, toBlock uint64, events []common.ReserveEvent) error
+	LastEventBlock() (uint64, error)
+	GetReserveEvents(fromBlock, toBlock uint64) ([]common.ReserveEvent, error)
+
+	UpdateActivity(id common.ActivityID, activity common.ActivityRecord) error
+	GetActivities(filter common.ActivityFilter, cursor string, limit int) ([]common.ActivityRecord, string, error)
+}
+
+func testHasPendingDeposit(t *testing.T, storage suiteStorage) {
+	token := common.Token{"OMG", "0x1111111111111111111111111111111111111111", 18}
+	exchange := common.TestExchange{}
+	timepoint := common.GetTimepoint()
+	out := storage.HasPendingDeposit(token, exchange)
+	if out != false {
+		t.Fatalf("Expected ram storage to return true false there is no pending deposit for the same currency and exchange")
+	}
+	storage.Record(
+		"deposit",
+		common.ActivityID{1, "1"},
+		string(exchange.ID()),
+		map[string]interface{}{
+			"exchange":  exchange,
+			"token":     token,
+			"amount":    "1.0",
+			"timepoint": timepoint,
+		},
+		map[string]interface{}{
+			"tx":    "",
+			"error": nil,
+		},
+		"",
+		"submitted",
+		common.GetTimepoint())
+	out = storage.HasPendingDeposit(token, exchange)
+	if out != true {
+		t.Fatalf("Expected ram storage to return true when there is pending deposit")
+	}
+}
+
+func testPriceVersions(t *testing.T, storage suiteStorage) {
+	if _, err := storage.CurrentPriceVersion(1000); err == nil {
+		t.Fatalf("Expected no price version before any is stored")
+	}
+	storage.StorePrice(common.AllPriceEntry{Block: 1}, 1000)
+	storage.StorePrice(common.AllPriceEntry{Block: 2}, 2000)
+	version, err := storage.CurrentPriceVersion(1999)
+	if err != nil || version != 1000 {
+		t.Fatalf("Expected version 1000 at 1999, got %d, %v", version, err)
+	}
+	prices, err := storage.GetAllPrices(2000)
+	if err != nil || prices.Block != 2 {
+		t.Fatalf("Expected prices of version 2000, got %+v, %v", prices, err)
+	}
+	if _, err = storage.GetAllPrices(1500); err == nil {
+		t.Fatalf("Expected version 1500 not to exist")
+	}
+	storage.StorePrice(common.AllPriceEntry{Block: 3}, 3000)
+	versions, err := storage.PriceVersions(1500, 3000)
+	if err != nil || fmt.Sprint(versions) != "[2000 3000]" {
+		t.Fatalf("Expected versions 2000 and 3000 from 1500 to 3000, got %v, %v", versions, err)
+	}
+}
+
+func testTradeHistory(t *testing.T, storage suiteStorage) {
+	fill := common.TradeFillRecord{
+		ActivityID: common.ActivityID{1, "11279447_OMGETH"},
+		Exchange:   "binance",
+		TradeFill: common.TradeFill{
+			ID:        "2120521",
+			OrderID:   "11279447_OMGETH",
+			Pair:      "OMG-ETH",
+			Type:      "buy",
+			Price:     0.0152,
+			Qty:       10,
+			Fee:       0.01,
+			FeeAsset:  "OMG",
+			Timestamp: 1000,
+		},
+	}
+	// the same fill fetched twice must be stored once
+	storage.StoreTradeHistory([]common.TradeFillRecord{fill})
+	storage.StoreTradeHistory([]common.TradeFillRecord{fill})
+	last, _ := storage.LastTradeFillTime("binance", "OMG-ETH")
+	if last != 1000 {
+		t.Fatalf("Expected last trade fill time to be 1000, got %d", last)
+	}
+	fills, err := storage.GetTradeHistory(0, 2000)
+	if err != nil {
+		t.Fatalf("Couldn't get trade history %v", err)
+	}
+	if len(fills) != 1 || fills[0].ActivityID != fill.ActivityID {
+		t.Fatalf("Expected one fill linked to its activity, got %+v", fills)
+	}
+	fills, _ = storage.GetTradeHistory(0, 999)
+	if len(fills) != 0 {
+		t.Fatalf("Expected no fill before 999, got %+v", fills)
+	}
+}
+
+func testReserveEvents(t *testing.T, storage suiteStorage) {
+	storage.StoreReserveEvents(10, 20, []common.ReserveEvent{
+		common.ReserveEvent{Kind: "trade", BlockNumber: 12, LogIndex: 1},
+		common.ReserveEvent{Kind: "set_rate", BlockNumber: 12, LogIndex: 0},
+		common.ReserveEvent{Kind: "withdraw", BlockNumber: 18, LogIndex: 0},
+	})
+	// block 18 was reorganized away
+	storage.StoreReserveEvents(15, 25, []common.ReserveEvent{
+		common.ReserveEvent{Kind: "deposit", BlockNumber: 22, LogIndex: 3},
+	})
+	last, _ := storage.LastEventBlock()
+	if last != 25 {
+		t.Fatalf("Expected last watched block to be 25, got %d", last)
+	}
+	events, err := storage.GetReserveEvents(0, 100)
+	if err != nil {
+		t.Fatalf("Couldn't get reserve events %v", err)
+	}
+	kinds := []string{}
+	for _, event := range events {
+		kinds = append(kinds, event.Kind)
+	}
+	if len(kinds) != 3 || kinds[0] != "set_rate" || kinds[1] != "trade" || kinds[2] != "deposit" {
+		t.Fatalf("Expected set_rate, trade and deposit in log order, got %v", kinds)
+	}
+	events, _ = storage.GetReserveEvents(13, 21)
+	if len(events) != 0 {
+		t.Fatalf("Expected no event from block 13 to 21, got %+v", events)
+	}
+}
+
+// archived keeps versions it is given
+type archived []uint64
+
+func (self *archived) Archive(kind string, version uint64, data []byte) error {
+	*self = append(*self, version)
+	return nil
+}
+
+func (self *archived) Sync() error {
+	return nil
+}
+
+// unsyncedArchive fails to sync what it is given
+type unsyncedArchive struct {
+	archived
+}
+
+func (self *unsyncedArchive) Sync() error {
+	return errors.New("disk full")
+}
+
+func testPrune(t *testing.T, storage suiteStorage) {
+	for timepoint := uint64(1000); timepoint <= 5000; timepoint += 1000 {
+		storage.StorePrice(common.AllPriceEntry{Block: timepoint}, timepoint)
+	}
+	// nothing is removed unless it is synced to the archive
+	pruned, err := storage.Prune(common.RETAIN_PRICES, common.Retention{0, 3}, 5000, &unsyncedArchive{})
+	if err == nil || pruned != 0 {
+		t.Fatalf("Expected prune to fail when the archive can't be synced, got %d, %v", pruned, err)
+	}
+	archive := &archived{}
+	pruned, err = storage.Prune(common.RETAIN_PRICES, common.Retention{0, 3}, 5000, archive)
+	if err != nil || pruned != 2 || fmt.Sprint(*archive) != "[1000 2000]" {
+		t.Fatalf("Expected the 2 oldest versions to be pruned beyond 3, got %d %v, %v", pruned, *archive, err)
+	}
+	pruned, err = storage.Prune(common.RETAIN_PRICES, common.Retention{1500, 0}, 5000, nil)
+	if err != nil || pruned != 1 {
+		t.Fatalf("Expected versions older than 3500 to be pruned, got %d, %v", pruned, err)
+	}
+	versions, _ := storage.PriceVersions(0, 10000)
+	if fmt.Sprint(versions) != "[4000 5000]" {
+		t.Fatalf("Expected versions 4000 and 5000 to be kept, got %v", versions)
+	}
+	if _, err = storage.Prune("orders", common.Retention{0, 1}, 5000, nil); err == nil {
+		t.Fatalf("Expected data without retention not to be pruned")
+	}
+}
+
+// activityEIDs returns eids of activities, in order
+func activityEIDs(activities []common.ActivityRecord) string {
+	result := []string{}
+	for _, activity := range activities {
+		result = append(result, activity.ID.EID)
+	}
+	return fmt.Sprint(result)
+}
+
+func testActivities(t *testing.T, storage suiteStorage) {
+	omg := common.Token{"OMG", "0x1111111111111111111111111111111111111111", 18}
+	knc := common.Token{"KNC", "0x2222222222222222222222222222222222222222", 18}
+	eth := common.Token{"ETH", "0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee", 18}
+	activities := []struct {
+		action, destination string
+		params              map[string]interface{}
+		estatus, mstatus    string
+	}{
+		{"deposit", "binance", map[string]interface{}{"token": omg}, "", "submitted"},
+		{"trade", "binance", map[string]interface{}{"base": omg, "quote": eth}, "submitted", ""},
+		{"withdraw", "bittrex", map[string]interface{}{"token": knc}, "submitted", ""},
+		{"trade", "bittrex", map[string]interface{}{"base": knc, "quote": eth}, "done", ""},
+		{"set_rates", "blockchain", map[string]interface{}{"tokens": []common.Token{omg, knc}}, "", "submitted"},
+	}
+	for i, activity := range activities {
+		timepoint := uint64(i+1) * 1000
+		storage.Record(
+			activity.action, common.ActivityID{timepoint * 1000000, fmt.Sprintf("a%d", i+1)}, activity.destination,
+			activity.params, map[string]interface{}{}, activity.estatus, activity.mstatus, timepoint,
+		)
+	}
+	cursor := ""
+	for _, expected := range []string{"[a5 a4]", "[a3 a2]", "[a1]"} {
+		page, next, err := storage.GetActivities(common.ActivityFilter{}, cursor, 2)
+		if err != nil || activityEIDs(page) != expected {
+			t.Fatalf("Expected page %s after %q, got %s, %v", expected, cursor, activityEIDs(page), err)
+		}
+		cursor = next
+	}
+	if cursor != "" {
+		t.Fatalf("Expected no page after the last one, got %q", cursor)
+	}
+	filters := []struct {
+		filter   common.ActivityFilter
+		expected string
+	}{
+		{common.ActivityFilter{Token: "OMG"}, "[a5 a2 a1]"},
+		{common.ActivityFilter{Destination: "binance", Action: "trade"}, "[a2]"},
+		{common.ActivityFilter{FromTime: 2000, ToTime: 4000}, "[a4 a3 a2]"},
+		{common.ActivityFilter{Token: "ETH", ToTime: 3000}, "[a2]"},
+	}
+	for _, c := range filters {
+		page, _, err := storage.GetActivities(c.filter, "", 10)
+		if err != nil || activityEIDs(page) != c.expected {
+			t.Fatalf("Expected %s for %+v, got %s, %v", c.expected, c.filter, activityEIDs(page), err)
+		}
+	}
+	// statuses are found by their new value once updated
+	page, _, _ := storage.GetActivities(common.ActivityFilter{Destination: "binance", Action: "trade"}, "", 1)
+	trade := page[0]
+	trade.ExchangeStatus = "done"
+	storage.UpdateActivity(trade.ID, trade)
+	page, _, err := storage.GetActivities(common.ActivityFilter{ExchangeStatus: "done"}, "", 10)
+	if err != nil || activityEIDs(page) != "[a4 a2]" {
+		t.Fatalf("Expected done activities a4 and a2, got %s, %v", activityEIDs(page), err)
+	}
+	page, _, err = storage.GetActivities(common.ActivityFilter{ExchangeStatus: "submitted"}, "", 10)
+	if err != nil || activityEIDs(page) != "[a3]" {
+		t.Fatalf("Expected submitted activity a3, got %s, %v", activityEIDs(page), err)
+	}
+}
@@ -0,0 +1,12 @@
+// Package all registers every exchange adapter, it is imported for its
+// side effects only. A new adapter is made available by adding its
+// package here.
+package all
+
+import (
+	_ "github.com/KyberNetwork/reserve-data/exchange/binance"
+	_ "github.com/KyberNetwork/reserve-data/exchange/bitfinex"
+	_ "github.com/KyberNetwork/reserve-data/exchange/bittrex"
+	_ "github.com/KyberNetwork/reserve-data/exchange/huobi"
+	_ "github.com/KyberNetwork/reserve-data/exchange/liqui"
+)
@@ -1,6 +1,7 @@
 package exchange
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
@@ -18,9 +19,15 @@ const BINANCE_EPSILON float64 = 0.0000000001 // 10e-10
 type Binance struct {
 	interf       BinanceInterface
 	pairs        []common.TokenPair
-	addresses    map[string]ethereum.Address
+	addresses    *common.ExchangeAddresses
 	exchangeInfo *common.ExchangeInfo
-	fees         common.ExchangeFees
+	fees         *common.ExchangeFeeTable
+	// books are kept in sync from diff depth streams when streaming is on
+	streaming bool
+	books     map[common.TokenPairID]*OrderBook
+	// closing stop ends the streams
+	stop    chan struct{}
+	streams sync.WaitGroup
 }
 
 func (self *Binance) MarshalText() (text []byte, err error) {
@@ -28,18 +35,28 @@ func (self *Binance) MarshalText() (text []byte, err error) {
 }
 
 func (self *Binance) Address(token common.Token) (ethereum.Address, bool) {
-	addr, supported := self.addresses[token.ID]
-	return addr, supported
+	return self.addresses.Get(token.ID)
+}
+
+func (self *Binance) VerifiedAddress(token common.Token) (ethereum.Address, error) {
+	return self.addresses.Verified(token.ID)
 }
 
 func (self *Binance) UpdateAllDepositAddresses(address string) {
-	for k, _ := range self.addresses {
-		self.addresses[k] = ethereum.HexToAddress(address)
-	}
+	self.addresses.UpdateAll(ethereum.HexToAddress(address))
 }
 
 func (self *Binance) UpdateDepositAddress(token common.Token, address string) {
-	self.addresses[token.ID] = ethereum.HexToAddress(address)
+	self.addresses.Update(token.ID, ethereum.HexToAddress(address))
+}
+
+// DiscoverDepositAddresses verifies configured deposit addresses against
+// the ones binance reports
+func (self *Binance) DiscoverDepositAddresses() error {
+	return discoverDepositAddresses(self.ID(), self.addresses, func(token common.Token) (string, error) {
+		result, err := self.interf.GetDepositAddress(token.ID)
+		return result.Address, err
+	})
 }
 
 func (self *Binance) UpdatePrecisionLimit(pair common.TokenPair, symbols []BinanceSymbol) {
@@ -89,7 +106,7 @@ func (self *Binance) UpdatePairsPrecision() {
 }
 
 func (self *Binance) GetInfo() (common.ExchangeInfo, error) {
-	return *self.exchangeInfo, nil
+	return self.exchangeInfo.Copy(), nil
 }
 
 func (self *Binance) GetExchangeInfo(pair common.TokenPairID) (common.ExchangePrecisionLimit, error) {
@@ -98,7 +115,37 @@ func (self *Binance) GetExchangeInfo(pair common.TokenPairID) (common.ExchangePr
 }
 
 func (self *Binance) GetFee() common.ExchangeFees {
-	return self.fees
+	return self.fees.Get()
+}
+
+// UpdateFees refreshes trading fees from account commissions and
+// withdraw fees from asset details, tokens binance doesn't list keep
+// their fallback fees
+func (self *Binance) UpdateFees() error {
+	info, err := self.interf.GetInfo(context.Background(), common.GetTimepoint())
+	if err != nil {
+		return err
+	}
+	if info.Code != 0 {
+		return errors.New(fmt.Sprintf("Getting account info failed: %d, %s", info.Code, info.Msg))
+	}
+	// commissions are in basis points
+	self.fees.UpdateTrading(common.TradingFee{
+		"maker": float32(info.MakerCommission) / 10000,
+		"taker": float32(info.TakerCommission) / 10000,
+	})
+	details, err := self.interf.GetAssetDetail()
+	if err != nil {
+		return err
+	}
+	withdrawFees := map[string]float32{}
+	for asset, detail := range details.AssetDetail {
+		if _, supported := common.SupportedTokens[asset]; supported {
+			withdrawFees[asset] = float32(detail.WithdrawFee)
+		}
+	}
+	self.fees.UpdateWithdraw(withdrawFees)
+	return nil
 }
 
 func (self *Binance) ID() common.ExchangeID {
@@ -113,8 +160,8 @@ func (self *Binance) Name() string {
 	return "binance"
 }
 
-func (self *Binance) QueryOrder(symbol string, id uint64, timepoint uint64) (done float64, remaining float64, finished bool, err error) {
-	result, err := self.interf.OrderStatus(symbol, id, timepoint)
+func (self *Binance) QueryOrder(ctx context.Context, symbol string, id uint64, timepoint uint64) (done float64, remaining float64, finished bool, err error) {
+	result, err := self.interf.OrderStatus(ctx, symbol, id, timepoint)
 	if err != nil {
 		return 0, 0, false, err
 	} else {
@@ -124,14 +171,15 @@ func (self *Binance) QueryOrder(symbol string, id uint64, timepoint uint64) (don
 	}
 }
 
-func (self *Binance) Trade(tradeType string, base common.Token, quote common.Token, rate float64, amount float64, timepoint uint64) (id string, done float64, remaining float64, finished bool, err error) {
-	result, err := self.interf.Trade(tradeType, base, quote, rate, amount, timepoint)
+func (self *Binance) Trade(ctx context.Context, tradeType string, base common.Token, quote common.Token, rate float64, amount float64, timepoint uint64) (id string, done float64, remaining float64, finished bool, err error) {
+	result, err := self.interf.Trade(ctx, tradeType, base, quote, rate, amount, timepoint)
 	symbol := base.ID + quote.ID
 
 	if err != nil {
 		return "", 0, 0, false, err
 	} else {
 		done, remaining, finished, err := self.QueryOrder(
+			ctx,
 			base.ID+quote.ID,
 			result.OrderID,
 			timepoint+20,
@@ -141,19 +189,49 @@ func (self *Binance) Trade(tradeType string, base common.Token, quote common.Tok
 	}
 }
 
-func (self *Binance) Withdraw(token common.Token, amount *big.Int, address ethereum.Address, timepoint uint64) (string, error) {
-	tx, err := self.interf.Withdraw(token, amount, address, timepoint)
+func (self *Binance) TradeHistory(ctx context.Context, pair common.TokenPair, since uint64) ([]common.TradeFill, error) {
+	trades, err := self.interf.MyTrades(ctx, pair, since)
+	if err != nil {
+		return nil, err
+	}
+	symbol := pair.Base.ID + pair.Quote.ID
+	result := []common.TradeFill{}
+	for _, trade := range trades {
+		price, _ := strconv.ParseFloat(trade.Price, 64)
+		qty, _ := strconv.ParseFloat(trade.Qty, 64)
+		fee, _ := strconv.ParseFloat(trade.Commission, 64)
+		tradeType := "sell"
+		if trade.IsBuyer {
+			tradeType = "buy"
+		}
+		result = append(result, common.TradeFill{
+			ID:        strconv.FormatUint(trade.ID, 10),
+			OrderID:   fmt.Sprintf("%s_%s", strconv.FormatUint(trade.OrderID, 10), symbol),
+			Pair:      pair.PairID(),
+			Type:      tradeType,
+			Price:     price,
+			Qty:       qty,
+			Fee:       fee,
+			FeeAsset:  trade.CommissionAsset,
+			Timestamp: trade.Time,
+		})
+	}
+	return result, nil
+}
+
+func (self *Binance) Withdraw(ctx context.Context, token common.Token, amount *big.Int, address ethereum.Address, timepoint uint64) (string, error) {
+	tx, err := self.interf.Withdraw(ctx, token, amount, address, timepoint)
 	return tx, err
 }
 
-func (self *Binance) CancelOrder(id common.ActivityID) error {
+func (self *Binance) CancelOrder(ctx context.Context, id common.ActivityID) error {
 	idParts := strings.Split(id.EID, "_")
 	idNo, err := strconv.ParseUint(idParts[0], 10, 64)
 	if err != nil {
 		return err
 	}
 	symbol := idParts[1]
-	result, err := self.interf.CancelOrder(symbol, idNo)
+	result, err := self.interf.CancelOrder(ctx, symbol, idNo)
 	if err != nil {
 		return err
 	}
@@ -164,6 +242,7 @@ func (self *Binance) CancelOrder(id common.ActivityID) error {
 }
 
 func (self *Binance) FetchOnePairData(
+	ctx context.Context,
 	wg *sync.WaitGroup,
 	pair common.TokenPair,
 	data *sync.Map,
@@ -175,7 +254,7 @@ func (self *Binance) FetchOnePairData(
 	timestamp := common.Timestamp(fmt.Sprintf("%d", timepoint))
 	result.Timestamp = timestamp
 	result.Valid = true
-	resp_data, err := self.interf.GetDepthOnePair(pair, timepoint)
+	resp_data, err := self.interf.GetDepthOnePair(ctx, pair, timepoint)
 	returnTime := common.GetTimestamp()
 	result.ReturnTime = returnTime
 	if err != nil {
@@ -213,13 +292,16 @@ func (self *Binance) FetchOnePairData(
 	data.Store(pair.PairID(), result)
 }
 
-func (self *Binance) FetchPriceData(timepoint uint64) (map[common.TokenPairID]common.ExchangePrice, error) {
+func (self *Binance) FetchPriceData(ctx context.Context, timepoint uint64) (map[common.TokenPairID]common.ExchangePrice, error) {
+	if self.streaming {
+		return self.streamedPriceData(timepoint), nil
+	}
 	wait := sync.WaitGroup{}
 	data := sync.Map{}
 	pairs := self.pairs
 	for _, pair := range pairs {
 		wait.Add(1)
-		go self.FetchOnePairData(&wait, pair, &data, timepoint)
+		go self.FetchOnePairData(ctx, &wait, pair, &data, timepoint)
 	}
 	wait.Wait()
 	result := map[common.TokenPairID]common.ExchangePrice{}
@@ -247,7 +329,7 @@ func (self *Binance) OpenOrdersForOnePair(
 			orgQty, _ := strconv.ParseFloat(order.OrigQty, 64)
 			executedQty, _ := strconv.ParseFloat(order.ExecutedQty, 64)
 			orders = append(orders, common.Order{
-				ID:          fmt.Sprintf("%s_%s%s", order.OrderId, strings.ToUpper(pair.Base.ID), strings.ToUpper(pair.Quote.ID)),
+				ID:          fmt.Sprintf("%d_%s%s", order.OrderId, strings.ToUpper(pair.Base.ID), strings.ToUpper(pair.Quote.ID)),
 				Base:        strings.ToUpper(pair.Base.ID),
 				Quote:       strings.ToUpper(pair.Quote.ID),
 				OrderId:     fmt.Sprintf("%d", order.OrderId),
@@ -293,11 +375,11 @@ func (self *Binance) FetchOrderData(timepoint uint64) (common.OrderEntry, error)
 	return result, nil
 }
 
-func (self *Binance) FetchEBalanceData(timepoint uint64) (common.EBalanceEntry, error) {
+func (self *Binance) FetchEBalanceData(ctx context.Context, timepoint uint64) (common.EBalanceEntry, error) {
 	result := common.EBalanceEntry{}
 	result.Timestamp = common.Timestamp(fmt.Sprintf("%d", timepoint))
 	result.Valid = true
-	resp_data, err := self.interf.GetInfo(timepoint)
+	resp_data, err := self.interf.GetInfo(ctx, timepoint)
 	result.ReturnTime = common.GetTimestamp()
 	if err != nil {
 		result.Valid = false
@@ -308,7 +390,7 @@ func (self *Binance) FetchEBalanceData(timepoint uint64) (common.EBalanceEntry,
 		result.DepositBalance = map[string]float64{}
 		if resp_data.Code != 0 {
 			result.Valid = false
-			result.Error = fmt.Sprintf("Code: %s, Msg: %s", resp_data.Code, resp_data.Msg)
+			result.Error = fmt.Sprintf("Code: %d, Msg: %s", resp_data.Code, resp_data.Msg)
 		} else {
 			for _, b := range resp_data.Balances {
 				tokenID := b.Asset
@@ -326,7 +408,7 @@ func (self *Binance) FetchEBalanceData(timepoint uint64) (common.EBalanceEntry,
 	return result, nil
 }
 
-func (self *Binance) DepositStatus(id common.ActivityID, timepoint uint64) (string, error) {
+func (self *Binance) DepositStatus(ctx context.Context, id common.ActivityID, timepoint uint64) (string, error) {
 	idParts := strings.Split(id.EID, "|")
 	if len(idParts) != 3 {
 		// here, the exchange id part in id is malformed
@@ -337,7 +419,7 @@ func (self *Binance) DepositStatus(id common.ActivityID, timepoint uint64) (stri
 	txID := idParts[0]
 	startTime := timepoint - 86400000
 	endTime := timepoint
-	deposits, err := self.interf.DepositHistory(startTime, endTime)
+	deposits, err := self.interf.DepositHistory(ctx, startTime, endTime)
 	if err != nil || !deposits.Success {
 		return "", err
 	} else {
@@ -354,11 +436,11 @@ func (self *Binance) DepositStatus(id common.ActivityID, timepoint uint64) (stri
 	}
 }
 
-func (self *Binance) WithdrawStatus(id common.ActivityID, timepoint uint64) (string, string, error) {
+func (self *Binance) WithdrawStatus(ctx context.Context, id common.ActivityID, timepoint uint64) (string, string, error) {
 	withdrawID := id.EID
 	startTime := timepoint - 86400000
 	endTime := timepoint
-	withdraws, err := self.interf.WithdrawHistory(startTime, endTime)
+	withdraws, err := self.interf.WithdrawHistory(ctx, startTime, endTime)
 	if err != nil || !withdraws.Success {
 		return "", "", err
 	} else {
@@ -375,7 +457,7 @@ func (self *Binance) WithdrawStatus(id common.ActivityID, timepoint uint64) (str
 	}
 }
 
-func (self *Binance) OrderStatus(id common.ActivityID, timepoint uint64) (string, error) {
+func (self *Binance) OrderStatus(ctx context.Context, id common.ActivityID, timepoint uint64) (string, error) {
 	tradeID := id.EID
 	parts := strings.Split(tradeID, "_")
 	orderID, err := strconv.ParseUint(parts[0], 10, 64)
@@ -384,7 +466,7 @@ func (self *Binance) OrderStatus(id common.ActivityID, timepoint uint64) (string
 		panic(err)
 	}
 	symbol := parts[1]
-	order, err := self.interf.OrderStatus(symbol, orderID, timepoint)
+	order, err := self.interf.OrderStatus(ctx, symbol, orderID, timepoint)
 	if err != nil {
 		return "", err
 	}
@@ -406,33 +488,39 @@ func NewBinance(interf BinanceInterface) *Binance {
 			common.MustCreateTokenPair("KNC", "ETH"),
 			common.MustCreateTokenPair("LINK", "ETH"),
 		},
-		map[string]ethereum.Address{},
+		common.NewExchangeAddresses(),
 		common.NewExchangeInfo(),
-		common.NewExchangeFee(
-			common.TradingFee{
-				"taker": 0.001,
-				"maker": 0.001,
-			},
-			common.NewFundingFee(
-				map[string]float32{
-					"ETH":  0.01,
-					"EOS":  0.7,
-					"MCO":  0.3,
-					"OMG":  0.3,
-					"KNC":  2.0,
-					"FUN":  80.0,
-					"LINK": 10.0,
-				},
-				map[string]float32{
-					"ETH":  0,
-					"EOS":  0,
-					"MCO":  0,
-					"OMG":  0,
-					"KNC":  0,
-					"FUN":  0,
-					"LINK": 0,
+		common.NewExchangeFeeTable(
+			common.NewExchangeFee(
+				common.TradingFee{
+					"taker": 0.001,
+					"maker": 0.001,
 				},
+				common.NewFundingFee(
+					map[string]float32{
+						"ETH":  0.01,
+						"EOS":  0.7,
+						"MCO":  0.3,
+						"OMG":  0.3,
+						"KNC":  2.0,
+						"FUN":  80.0,
+						"LINK": 10.0,
+					},
+					map[string]float32{
+						"ETH":  0,
+						"EOS":  0,
+						"MCO":  0,
+						"OMG":  0,
+						"KNC":  0,
+						"FUN":  0,
+						"LINK": 0,
+					},
+				),
 			),
 		),
+		false,
+		map[common.TokenPairID]*OrderBook{},
+		make(chan struct{}),
+		sync.WaitGroup{},
 	}
 }
@@ -1,12 +1,14 @@
 package binance
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"math/big"
+	"net"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -16,11 +18,46 @@ import (
 	"github.com/KyberNetwork/reserve-data/common"
 	"github.com/KyberNetwork/reserve-data/exchange"
 	ethereum "github.com/ethereum/go-ethereum/common"
+	"golang.org/x/net/websocket"
 )
 
+// https://www.binance.com/api/v1/exchangeInfo rateLimits
+var BINANCE_RATE_LIMITS = []common.RateLimit{
+	common.RateLimit{Kind: common.RATE_KIND_REQUEST, Limit: 1200, Interval: time.Minute},
+	common.RateLimit{Kind: common.RATE_KIND_ORDER, Limit: 10, Interval: time.Second},
+	common.RateLimit{Kind: common.RATE_KIND_ORDER, Limit: 100000, Interval: 24 * time.Hour},
+}
+
+// a depth stream that stays silent this long is considered stale and
+// resynced, binance pushes updates every second while a book changes
+const BINANCE_STREAM_READ_TIMEOUT time.Duration = 30 * time.Second
+
+// binanceWeight returns weight of a request as documented by binance,
+// requests not listed weigh 1
+func binanceWeight(path string, params map[string]string) int {
+	switch {
+	case strings.HasSuffix(path, "/api/v1/depth"):
+		limit, _ := strconv.Atoi(params["limit"])
+		if limit <= 100 {
+			return 1
+		} else if limit <= 500 {
+			return 5
+		} else {
+			return 10
+		}
+	case strings.HasSuffix(path, "/api/v3/account"),
+		strings.HasSuffix(path, "/api/v3/myTrades"):
+		return 5
+	default:
+		return 1
+	}
+}
+
 type BinanceEndpoint struct {
-	signer Signer
-	interf Interface
+	signer        Signer
+	interf        Interface
+	limiter       *common.RateLimiter
+	streamTimeout time.Duration
 }
 
 func (self *BinanceEndpoint) fillRequest(req *http.Request, signNeeded bool, timepoint uint64) {
@@ -46,6 +83,14 @@ func (self *BinanceEndpoint) fillRequest(req *http.Request, signNeeded bool, tim
 func (self *BinanceEndpoint) GetResponse(
 	method string, url string,
 	params map[string]string, signNeeded bool, timepoint uint64) ([]byte, error) {
+	return self.GetResponseContext(context.Background(), method, url, params, signNeeded, timepoint)
+}
+
+// GetResponseContext is GetResponse canceled along with ctx
+func (self *BinanceEndpoint) GetResponseContext(
+	ctx context.Context,
+	method string, url string,
+	params map[string]string, signNeeded bool, timepoint uint64) ([]byte, error) {
 
 	client := &http.Client{
 		Timeout: time.Duration(30 * time.Second),
@@ -58,9 +103,19 @@ func (self *BinanceEndpoint) GetResponse(
 		q.Add(k, v)
 	}
 	req.URL.RawQuery = q.Encode()
+	req = req.WithContext(ctx)
 	self.fillRequest(req, signNeeded, timepoint)
 	var err error
 	var resp_body []byte
+	weight := binanceWeight(req.URL.Path, params)
+	if method == "POST" && strings.HasSuffix(req.URL.Path, "/api/v3/order") {
+		err = self.limiter.Order(ctx, weight)
+	} else {
+		err = self.limiter.Request(ctx, weight)
+	}
+	if err != nil {
+		return resp_body, err
+	}
 	log.Printf("request to binance: %s\n", req.URL)
 	resp, err := client.Do(req)
 	if err != nil {
@@ -74,9 +129,11 @@ func (self *BinanceEndpoint) GetResponse(
 }
 
 func (self *BinanceEndpoint) GetDepthOnePair(
+	ctx context.Context,
 	pair common.TokenPair, timepoint uint64) (exchange.Binaresp, error) {
 
-	resp_body, err := self.GetResponse(
+	resp_body, err := self.GetResponseContext(
+		ctx,
 		"GET", self.interf.PublicEndpoint()+"/api/v1/depth",
 		map[string]string{
 			"symbol": fmt.Sprintf("%s%s", pair.Base.ID, pair.Quote.ID),
@@ -95,6 +152,75 @@ func (self *BinanceEndpoint) GetDepthOnePair(
 	}
 }
 
+func (self *BinanceEndpoint) GetDepthSnapshot(pair common.TokenPair) (exchange.Binaresp, error) {
+	resp_body, err := self.GetResponse(
+		"GET", self.interf.PublicEndpoint()+"/api/v1/depth",
+		map[string]string{
+			"symbol": fmt.Sprintf("%s%s", pair.Base.ID, pair.Quote.ID),
+			"limit":  "1000",
+		},
+		false,
+		common.GetTimepoint(),
+	)
+
+	resp_data := exchange.Binaresp{}
+	if err != nil {
+		return resp_data, err
+	}
+	err = json.Unmarshal(resp_body, &resp_data)
+	if err == nil && resp_data.Code != 0 {
+		err = errors.New(fmt.Sprintf("Getting depth snapshot from binance failed: %d, %s", resp_data.Code, resp_data.Msg))
+	}
+	return resp_data, err
+}
+
+func (self *BinanceEndpoint) SubscribeDepth(
+	pair common.TokenPair,
+	updates chan<- exchange.Binadepthupdate,
+	stop <-chan struct{}) error {
+
+	streamURL := fmt.Sprintf(
+		"%s/ws/%s@depth",
+		self.interf.StreamEndpoint(),
+		strings.ToLower(pair.Base.ID+pair.Quote.ID),
+	)
+	log.Printf("subscribing to binance stream: %s\n", streamURL)
+	ws, err := websocket.Dial(streamURL, "", self.interf.PublicEndpoint())
+	if err != nil {
+		return err
+	}
+	quit := make(chan struct{})
+	defer close(quit)
+	// closing the connection unblocks the pending Receive below
+	go func() {
+		select {
+		case <-stop:
+		case <-quit:
+		}
+		ws.Close()
+	}()
+	for {
+		update := exchange.Binadepthupdate{}
+		ws.SetReadDeadline(time.Now().Add(self.streamTimeout))
+		if err := websocket.JSON.Receive(ws, &update); err != nil {
+			select {
+			case <-stop:
+				return nil
+			default:
+			}
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				return errors.New(fmt.Sprintf("No depth update for %s", self.streamTimeout))
+			}
+			return err
+		}
+		select {
+		case updates <- update:
+		case <-stop:
+			return nil
+		}
+	}
+}
+
 // Relevant params:
 // symbol ("%s%s", base, quote)
 // side (BUY/SELL)
@@ -105,7 +231,7 @@ func (self *BinanceEndpoint) GetDepthOnePair(
 //
 // In this version, we only support LIMIT order which means only buy/sell with acceptable price,
 // and GTC time in force which means that the order will be active until it's implicitly canceled
-func (self *BinanceEndpoint) Trade(tradeType string, base, quote common.Token, rate, amount float64, timepoint uint64) (exchange.Binatrade, error) {
+func (self *BinanceEndpoint) Trade(ctx context.Context, tradeType string, base, quote common.Token, rate, amount float64, timepoint uint64) (exchange.Binatrade, error) {
 	result := exchange.Binatrade{}
 	symbol := base.ID + quote.ID
 	orderType := "LIMIT"
@@ -119,7 +245,8 @@ func (self *BinanceEndpoint) Trade(tradeType string, base, quote common.Token, r
 	if orderType == "LIMIT" {
 		params["price"] = strconv.FormatFloat(rate, 'f', -1, 64)
 	}
-	resp_body, err := self.GetResponse(
+	resp_body, err := self.GetResponseContext(
+		ctx,
 		"POST",
 		self.interf.AuthenticatedEndpoint()+"/api/v3/order",
 		params,
@@ -134,10 +261,11 @@ func (self *BinanceEndpoint) Trade(tradeType string, base, quote common.Token, r
 	}
 }
 
-func (self *BinanceEndpoint) WithdrawHistory(startTime, endTime uint64) (exchange.Binawithdrawals, error) {
+func (self *BinanceEndpoint) WithdrawHistory(ctx context.Context, startTime, endTime uint64) (exchange.Binawithdrawals, error) {
 	result := exchange.Binawithdrawals{}
 	timepoint := common.GetTimepoint()
-	resp_body, err := self.GetResponse(
+	resp_body, err := self.GetResponseContext(
+		ctx,
 		"GET",
 		self.interf.AuthenticatedEndpoint()+"/wapi/v3/withdrawHistory.html",
 		map[string]string{
@@ -156,10 +284,11 @@ func (self *BinanceEndpoint) WithdrawHistory(startTime, endTime uint64) (exchang
 	return result, err
 }
 
-func (self *BinanceEndpoint) DepositHistory(startTime, endTime uint64) (exchange.Binadeposits, error) {
+func (self *BinanceEndpoint) DepositHistory(ctx context.Context, startTime, endTime uint64) (exchange.Binadeposits, error) {
 	result := exchange.Binadeposits{}
 	timepoint := common.GetTimepoint()
-	resp_body, err := self.GetResponse(
+	resp_body, err := self.GetResponseContext(
+		ctx,
 		"GET",
 		self.interf.AuthenticatedEndpoint()+"/wapi/v3/depositHistory.html",
 		map[string]string{
@@ -178,9 +307,10 @@ func (self *BinanceEndpoint) DepositHistory(startTime, endTime uint64) (exchange
 	return result, err
 }
 
-func (self *BinanceEndpoint) CancelOrder(symbol string, id uint64) (exchange.Binacancel, error) {
+func (self *BinanceEndpoint) CancelOrder(ctx context.Context, symbol string, id uint64) (exchange.Binacancel, error) {
 	result := exchange.Binacancel{}
-	resp_body, err := self.GetResponse(
+	resp_body, err := self.GetResponseContext(
+		ctx,
 		"DELETE",
 		self.interf.AuthenticatedEndpoint()+"/api/v3/order",
 		map[string]string{
@@ -199,9 +329,10 @@ func (self *BinanceEndpoint) CancelOrder(symbol string, id uint64) (exchange.Bin
 	return result, err
 }
 
-func (self *BinanceEndpoint) OrderStatus(symbol string, id uint64, timepoint uint64) (exchange.Binaorder, error) {
+func (self *BinanceEndpoint) OrderStatus(ctx context.Context, symbol string, id uint64, timepoint uint64) (exchange.Binaorder, error) {
 	result := exchange.Binaorder{}
-	resp_body, err := self.GetResponse(
+	resp_body, err := self.GetResponseContext(
+		ctx,
 		"GET",
 		self.interf.AuthenticatedEndpoint()+"/api/v3/order",
 		map[string]string{
@@ -220,9 +351,10 @@ func (self *BinanceEndpoint) OrderStatus(symbol string, id uint64, timepoint uin
 	return result, err
 }
 
-func (self *BinanceEndpoint) Withdraw(token common.Token, amount *big.Int, address ethereum.Address, timepoint uint64) (string, error) {
+func (self *BinanceEndpoint) Withdraw(ctx context.Context, token common.Token, amount *big.Int, address ethereum.Address, timepoint uint64) (string, error) {
 	result := exchange.Binawithdraw{}
-	resp_body, err := self.GetResponse(
+	resp_body, err := self.GetResponseContext(
+		ctx,
 		"POST",
 		self.interf.AuthenticatedEndpoint()+"/wapi/v3/withdraw.html",
 		map[string]string{
@@ -246,9 +378,10 @@ func (self *BinanceEndpoint) Withdraw(token common.Token, amount *big.Int, addre
 	}
 }
 
-func (self *BinanceEndpoint) GetInfo(timepoint uint64) (exchange.Binainfo, error) {
+func (self *BinanceEndpoint) GetInfo(ctx context.Context, timepoint uint64) (exchange.Binainfo, error) {
 	result := exchange.Binainfo{}
-	resp_body, err := self.GetResponse(
+	resp_body, err := self.GetResponseContext(
+		ctx,
 		"GET",
 		self.interf.AuthenticatedEndpoint()+"/api/v3/account",
 		map[string]string{},
@@ -282,6 +415,29 @@ func (self *BinanceEndpoint) OpenOrdersForOnePair(
 	}
 }
 
+func (self *BinanceEndpoint) MyTrades(
+	ctx context.Context,
+	pair common.TokenPair, since uint64) (exchange.Binamytrades, error) {
+
+	result := exchange.Binamytrades{}
+	resp_body, err := self.GetResponseContext(
+		ctx,
+		"GET",
+		self.interf.AuthenticatedEndpoint()+"/api/v3/myTrades",
+		map[string]string{
+			"symbol":    pair.Base.ID + pair.Quote.ID,
+			"startTime": strconv.FormatUint(since, 10),
+			"limit":     "500",
+		},
+		true,
+		common.GetTimepoint(),
+	)
+	if err == nil {
+		err = json.Unmarshal(resp_body, &result)
+	}
+	return result, err
+}
+
 func (self *BinanceEndpoint) GetDepositAddress(asset string) (exchange.Binadepositaddress, error) {
 	result := exchange.Binadepositaddress{}
 	timepoint := common.GetTimepoint()
@@ -303,6 +459,25 @@ func (self *BinanceEndpoint) GetDepositAddress(asset string) (exchange.Binadepos
 	return result, err
 }
 
+func (self *BinanceEndpoint) GetAssetDetail() (exchange.Binaassetdetail, error) {
+	result := exchange.Binaassetdetail{}
+	timepoint := common.GetTimepoint()
+	resp_body, err := self.GetResponse(
+		"GET",
+		self.interf.AuthenticatedEndpoint()+"/wapi/v3/assetDetail.html",
+		map[string]string{},
+		true,
+		timepoint,
+	)
+	if err == nil {
+		err = json.Unmarshal(resp_body, &result)
+		if err == nil && !result.Success {
+			err = errors.New(result.Msg)
+		}
+	}
+	return result, err
+}
+
 func (self *BinanceEndpoint) GetExchangeInfo() (exchange.BinanceExchangeInfo, error) {
 	result := exchange.BinanceExchangeInfo{}
 	timepoint := common.GetTimepoint()
@@ -320,21 +495,26 @@ func (self *BinanceEndpoint) GetExchangeInfo() (exchange.BinanceExchangeInfo, er
 }
 
 func NewBinanceEndpoint(signer Signer, interf Interface) *BinanceEndpoint {
-	return &BinanceEndpoint{signer, interf}
+	return &BinanceEndpoint{
+		signer,
+		interf,
+		common.RegisterRateLimiter("binance", BINANCE_RATE_LIMITS),
+		BINANCE_STREAM_READ_TIMEOUT,
+	}
 }
 
 func NewRealBinanceEndpoint(signer Signer) *BinanceEndpoint {
-	return &BinanceEndpoint{signer, NewRealInterface()}
+	return NewBinanceEndpoint(signer, NewRealInterface())
 }
 
 func NewSimulatedBinanceEndpoint(signer Signer) *BinanceEndpoint {
-	return &BinanceEndpoint{signer, NewSimulatedInterface()}
+	return NewBinanceEndpoint(signer, NewSimulatedInterface())
 }
 
 func NewRopstenBinanceEndpoint(signer Signer) *BinanceEndpoint {
-	return &BinanceEndpoint{signer, NewRopstenInterface()}
+	return NewBinanceEndpoint(signer, NewRopstenInterface())
 }
 
 func NewDevBinanceEndpoint(signer Signer) *BinanceEndpoint {
-	return &BinanceEndpoint{signer, NewDevInterface()}
+	return NewBinanceEndpoint(signer, NewDevInterface())
 }
@@ -0,0 +1,66 @@
+package binance
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/exchange"
+	"golang.org/x/net/websocket"
+)
+
+type testInterface struct {
+	url string
+}
+
+func (self *testInterface) PublicEndpoint() string {
+	return self.url
+}
+
+func (self *testInterface) AuthenticatedEndpoint() string {
+	return self.url
+}
+
+func (self *testInterface) StreamEndpoint() string {
+	return strings.Replace(self.url, "http", "ws", 1)
+}
+
+func TestSubscribeDepthTimesOutOnSilentStream(t *testing.T) {
+	// sends one update then stays silent without closing the connection
+	server := httptest.NewServer(websocket.Handler(func(ws *websocket.Conn) {
+		websocket.JSON.Send(ws, map[string]interface{}{"U": 1, "u": 2})
+		buffer := make([]byte, 512)
+		for {
+			if _, err := ws.Read(buffer); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	endpoint := NewBinanceEndpoint(nil, &testInterface{server.URL})
+	endpoint.streamTimeout = 200 * time.Millisecond
+	updates := make(chan exchange.Binadepthupdate, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- endpoint.SubscribeDepth(common.TokenPair{Base: common.Token{ID: "KNC"}, Quote: common.Token{ID: "ETH"}}, updates, make(chan struct{}))
+	}()
+	select {
+	case update := <-updates:
+		if update.FinalUpdateID != 2 {
+			t.Errorf("Expected update 2, got %+v", update)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected an update before the stream goes silent")
+	}
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), "No depth update") {
+			t.Errorf("Expected silent stream to fail with a timeout, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Expected silent stream to time out")
+	}
+}
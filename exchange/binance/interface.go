@@ -2,11 +2,13 @@ package binance
 
 import (
 	"os"
+	"strings"
 )
 
 type Interface interface {
 	PublicEndpoint() string
 	AuthenticatedEndpoint() string
+	StreamEndpoint() string
 }
 
 type RealInterface struct{}
@@ -19,6 +21,10 @@ func (self *RealInterface) AuthenticatedEndpoint() string {
 	return "https://www.binance.com"
 }
 
+func (self *RealInterface) StreamEndpoint() string {
+	return "wss://stream.binance.com:9443"
+}
+
 func NewRealInterface() *RealInterface {
 	return &RealInterface{}
 }
@@ -26,7 +32,7 @@ func NewRealInterface() *RealInterface {
 type SimulatedInterface struct{}
 
 func (self *SimulatedInterface) baseurl() string {
-	baseurl := "127.0.0.1"
+	baseurl := "http://127.0.0.1"
 	if len(os.Args) > 1 {
 		baseurl = os.Args[1]
 	}
@@ -41,6 +47,10 @@ func (self *SimulatedInterface) AuthenticatedEndpoint() string {
 	return self.baseurl()
 }
 
+func (self *SimulatedInterface) StreamEndpoint() string {
+	return strings.Replace(self.baseurl(), "http", "ws", 1)
+}
+
 func NewSimulatedInterface() *SimulatedInterface {
 	return &SimulatedInterface{}
 }
@@ -63,6 +73,10 @@ func (self *RopstenInterface) AuthenticatedEndpoint() string {
 	return self.baseurl()
 }
 
+func (self *RopstenInterface) StreamEndpoint() string {
+	return "wss://stream.binance.com:9443"
+}
+
 func NewRopstenInterface() *RopstenInterface {
 	return &RopstenInterface{}
 }
@@ -79,6 +93,10 @@ func (self *DevInterface) AuthenticatedEndpoint() string {
 	// return "http://192.168.25.16:5100"
 }
 
+func (self *DevInterface) StreamEndpoint() string {
+	return "wss://stream.binance.com:9443"
+}
+
 func NewDevInterface() *DevInterface {
 	return &DevInterface{}
 }
@@ -0,0 +1,40 @@
+package binance
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/KyberNetwork/reserve-data/exchange"
+)
+
+func init() {
+	exchange.RegisterExchange("binance", NewExchange)
+}
+
+// NewExchange builds binance adapter on the endpoint of env, streamed
+// order books are enabled by KYBER_ORDERBOOK_STREAMING
+func NewExchange(env string, signer interface{}, storage interface{}) (exchange.Adapter, error) {
+	binanceSigner, ok := signer.(Signer)
+	if !ok {
+		return nil, errors.New("Signer doesn't have binance keys")
+	}
+	var endpoint *BinanceEndpoint
+	switch env {
+	case exchange.ENV_MAINNET:
+		endpoint = NewRealBinanceEndpoint(binanceSigner)
+	case exchange.ENV_ROPSTEN:
+		endpoint = NewRopstenBinanceEndpoint(binanceSigner)
+	case exchange.ENV_DEV:
+		endpoint = NewDevBinanceEndpoint(binanceSigner)
+	case exchange.ENV_SIMULATION:
+		endpoint = NewSimulatedBinanceEndpoint(binanceSigner)
+	default:
+		return nil, errors.New(fmt.Sprintf("Environment %s is not supported", env))
+	}
+	bin := exchange.NewBinance(endpoint)
+	if os.Getenv("KYBER_ORDERBOOK_STREAMING") == "true" {
+		bin.StartOrderbookStream()
+	}
+	return bin, nil
+}
@@ -21,8 +21,8 @@ type Binainfo struct {
 	Balances         []struct {
 		Asset  string `json:"asset"`
 		Free   string `json:"free"`
-		Locked string `json:"locked`
-	} `json:"balances`
+		Locked string `json:"locked"`
+	} `json:"balances"`
 }
 
 type FilterLimit struct {
@@ -77,6 +77,20 @@ type Binaorder struct {
 
 type Binaorders []Binaorder
 
+type Binamytrade struct {
+	ID              uint64 `json:"id"`
+	OrderID         uint64 `json:"orderId"`
+	Price           string `json:"price"`
+	Qty             string `json:"qty"`
+	Commission      string `json:"commission"`
+	CommissionAsset string `json:"commissionAsset"`
+	Time            uint64 `json:"time"`
+	IsBuyer         bool   `json:"isBuyer"`
+	IsMaker         bool   `json:"isMaker"`
+}
+
+type Binamytrades []Binamytrade
+
 type Binadepositaddress struct {
 	Msg        string `json:"msg"`
 	Address    string `json:"address"`
@@ -170,3 +184,26 @@ type Binawithdrawal struct {
 	ApplyTime uint64  `json:"applyTime"`
 	Status    int     `json:"status"`
 }
+
+// Diff depth event from <symbol>@depth stream. Each level is
+// [price, quantity, []] where price and quantity are strings
+type Binadepthupdate struct {
+	EventType     string          `json:"e"`
+	EventTime     uint64          `json:"E"`
+	Symbol        string          `json:"s"`
+	FirstUpdateID int64           `json:"U"`
+	FinalUpdateID int64           `json:"u"`
+	Bids          [][]interface{} `json:"b"`
+	Asks          [][]interface{} `json:"a"`
+}
+
+type Binaassetdetail struct {
+	Success     bool   `json:"success"`
+	Msg         string `json:"msg"`
+	AssetDetail map[string]struct {
+		MinWithdrawAmount string  `json:"minWithdrawAmount"`
+		DepositStatus     bool    `json:"depositStatus"`
+		WithdrawFee       float64 `json:"withdrawFee"`
+		WithdrawStatus    bool    `json:"withdrawStatus"`
+	} `json:"assetDetail"`
+}
@@ -1,6 +1,7 @@
 package exchange
 
 import (
+	"context"
 	"math/big"
 
 	"github.com/KyberNetwork/reserve-data/common"
@@ -9,34 +10,53 @@ import (
 
 type BinanceInterface interface {
 	GetDepthOnePair(
-		pair common.TokenPair, timepoint uint64) (Binaresp, error)
+		ctx context.Context, pair common.TokenPair, timepoint uint64) (Binaresp, error)
+
+	// Full depth snapshot used to (re)initialize a streamed order book
+	GetDepthSnapshot(pair common.TokenPair) (Binaresp, error)
+
+	// SubscribeDepth pushes diff depth events of pair to updates until
+	// the connection drops or stop is closed
+	SubscribeDepth(
+		pair common.TokenPair,
+		updates chan<- Binadepthupdate,
+		stop <-chan struct{}) error
 
 	OpenOrdersForOnePair(
 		pair common.TokenPair, timepoint uint64) (Binaorders, error)
 
-	GetInfo(timepoint uint64) (Binainfo, error)
+	GetInfo(ctx context.Context, timepoint uint64) (Binainfo, error)
 
 	GetExchangeInfo() (BinanceExchangeInfo, error)
 
+	GetAssetDetail() (Binaassetdetail, error)
+
+	GetDepositAddress(asset string) (Binadepositaddress, error)
+
 	Withdraw(
+		ctx context.Context,
 		token common.Token,
 		amount *big.Int,
 		address ethereum.Address,
 		timepoint uint64) (string, error)
 
 	Trade(
+		ctx context.Context,
 		tradeType string,
 		base, quote common.Token,
 		rate, amount float64,
 		timepoint uint64) (Binatrade, error)
 
-	CancelOrder(symbol string, id uint64) (Binacancel, error)
+	CancelOrder(ctx context.Context, symbol string, id uint64) (Binacancel, error)
 
-	DepositHistory(startTime, endTime uint64) (Binadeposits, error)
+	DepositHistory(ctx context.Context, startTime, endTime uint64) (Binadeposits, error)
 
 	WithdrawHistory(
-		startTime, endTime uint64) (Binawithdrawals, error)
+		ctx context.Context, startTime, endTime uint64) (Binawithdrawals, error)
 
 	OrderStatus(
-		symbol string, id uint64, timepoint uint64) (Binaorder, error)
+		ctx context.Context, symbol string, id uint64, timepoint uint64) (Binaorder, error)
+
+	// Fills of our orders on pair executed from since (millisecond)
+	MyTrades(ctx context.Context, pair common.TokenPair, since uint64) (Binamytrades, error)
 }
@@ -0,0 +1,176 @@
+package exchange
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+const (
+	BINANCE_STREAM_RETRY  time.Duration = 3 * time.Second
+	BINANCE_STREAM_BUFFER int           = 1000
+	BINANCE_STREAM_DEPTH  int           = 50
+)
+
+// StartOrderbookStream switches the exchange to streaming mode. Each pair
+// gets a local order book that is synced from binance diff depth stream,
+// FetchPriceData then returns these books instead of polling REST depth.
+// It must be called before the fetcher starts.
+func (self *Binance) StartOrderbookStream() {
+	for _, pair := range self.pairs {
+		book := NewOrderBook()
+		self.books[pair.PairID()] = book
+		self.streams.Add(1)
+		go self.streamOnePair(pair, book, BINANCE_STREAM_RETRY)
+	}
+	self.streaming = true
+}
+
+// Close ends the order book streams and waits for them to close their
+// connections. It is meant to be called once, on shutdown.
+func (self *Binance) Close() error {
+	select {
+	case <-self.stop:
+	default:
+		close(self.stop)
+	}
+	self.streams.Wait()
+	return nil
+}
+
+func (self *Binance) streamedPriceData(timepoint uint64) map[common.TokenPairID]common.ExchangePrice {
+	result := map[common.TokenPairID]common.ExchangePrice{}
+	for pairID, book := range self.books {
+		result[pairID] = book.Snapshot(BINANCE_STREAM_DEPTH, timepoint)
+	}
+	return result
+}
+
+// streamOnePair keeps book in sync, resyncing it retry after the stream
+// drops, until Close
+func (self *Binance) streamOnePair(pair common.TokenPair, book *OrderBook, retry time.Duration) {
+	defer self.streams.Done()
+	for {
+		err := self.syncOnePair(pair, book)
+		select {
+		case <-self.stop:
+			return
+		default:
+		}
+		log.Printf("Binance %s order book stream stopped: %s, resyncing", pair.PairID(), err)
+		book.Invalidate(err.Error())
+		timer := time.NewTimer(retry)
+		select {
+		case <-self.stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// syncOnePair follows binance's procedure to manage a local order book:
+// buffer the stream, get a depth snapshot, drop buffered events older
+// than the snapshot then apply the rest. It returns when the stream
+// drops, a gap in update ids shows up or the exchange is closed, the
+// caller resyncs from a fresh snapshot.
+func (self *Binance) syncOnePair(pair common.TokenPair, book *OrderBook) error {
+	updates := make(chan Binadepthupdate, BINANCE_STREAM_BUFFER)
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- self.interf.SubscribeDepth(pair, updates, stop)
+		close(updates)
+	}()
+	defer func() {
+		close(stop)
+		for range updates {
+		}
+	}()
+
+	snapshot, err := self.interf.GetDepthSnapshot(pair)
+	if err != nil {
+		return err
+	}
+	book.Reset(
+		snapshot.LastUpdatedId,
+		binanceLevels(snapshot.Bids),
+		binanceLevels(snapshot.Asks),
+	)
+	lastID := snapshot.LastUpdatedId
+	synced := false
+	for {
+		var update Binadepthupdate
+		var open bool
+		select {
+		case <-self.stop:
+			return errors.New("Stream closed")
+		case update, open = <-updates:
+		}
+		if !open {
+			break
+		}
+		if update.FinalUpdateID <= lastID {
+			continue
+		}
+		if (!synced && update.FirstUpdateID > lastID+1) ||
+			(synced && update.FirstUpdateID != lastID+1) {
+			return errors.New(fmt.Sprintf(
+				"Gap in depth updates: expected %d, got %d", lastID+1, update.FirstUpdateID))
+		}
+		synced = true
+		bids, err := binanceStreamLevels(update.Bids)
+		if err != nil {
+			return err
+		}
+		asks, err := binanceStreamLevels(update.Asks)
+		if err != nil {
+			return err
+		}
+		book.Apply(update.FinalUpdateID, bids, asks)
+		lastID = update.FinalUpdateID
+	}
+	err = <-done
+	if err == nil {
+		err = errors.New("Stream closed")
+	}
+	return err
+}
+
+func binanceLevels(levels [][]string) []common.PriceEntry {
+	result := []common.PriceEntry{}
+	for _, level := range levels {
+		rate, _ := strconv.ParseFloat(level[0], 64)
+		quantity, _ := strconv.ParseFloat(level[1], 64)
+		result = append(result, common.PriceEntry{quantity, rate})
+	}
+	return result
+}
+
+func binanceStreamLevels(levels [][]interface{}) ([]common.PriceEntry, error) {
+	result := []common.PriceEntry{}
+	for _, level := range levels {
+		if len(level) < 2 {
+			return result, errors.New("Malformed depth level from binance stream")
+		}
+		rateStr, ok1 := level[0].(string)
+		quantityStr, ok2 := level[1].(string)
+		if !ok1 || !ok2 {
+			return result, errors.New("Malformed depth level from binance stream")
+		}
+		rate, err := strconv.ParseFloat(rateStr, 64)
+		if err != nil {
+			return result, err
+		}
+		quantity, err := strconv.ParseFloat(quantityStr, 64)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, common.PriceEntry{quantity, rate})
+	}
+	return result, nil
+}
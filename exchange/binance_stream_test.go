@@ -0,0 +1,143 @@
+package exchange
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+// testBinanceStream serves depth snapshots and, per subscription, a
+// batch of updates before waiting to be stopped
+type testBinanceStream struct {
+	BinanceInterface
+	snapshots chan Binaresp
+	batches   chan []Binadepthupdate
+}
+
+func newTestBinanceStream() *testBinanceStream {
+	return &testBinanceStream{
+		snapshots: make(chan Binaresp, 10),
+		batches:   make(chan []Binadepthupdate, 10),
+	}
+}
+
+func (self *testBinanceStream) GetDepthSnapshot(pair common.TokenPair) (Binaresp, error) {
+	return <-self.snapshots, nil
+}
+
+func (self *testBinanceStream) SubscribeDepth(
+	pair common.TokenPair,
+	updates chan<- Binadepthupdate,
+	stop <-chan struct{}) error {
+	select {
+	case batch := <-self.batches:
+		for _, update := range batch {
+			updates <- update
+		}
+	case <-stop:
+		return nil
+	}
+	<-stop
+	return nil
+}
+
+func depthUpdate(first, final int64, bids, asks [][]interface{}) Binadepthupdate {
+	return Binadepthupdate{FirstUpdateID: first, FinalUpdateID: final, Bids: bids, Asks: asks}
+}
+
+func level(rate, quantity string) []interface{} {
+	return []interface{}{rate, quantity}
+}
+
+func bookString(book *OrderBook) string {
+	snapshot := book.Snapshot(10, 0)
+	return fmt.Sprintf("%t %v %v", snapshot.Valid, snapshot.Bids, snapshot.Asks)
+}
+
+// waitForBook waits until the book of the stream is at updateID and
+// reads expected
+func waitForBook(t *testing.T, book *OrderBook, updateID int64, expected string) {
+	deadline := time.Now().Add(2 * time.Second)
+	for book.UpdateID() != updateID || bookString(book) != expected {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected book %s at %d, got %s at %d", expected, updateID, bookString(book), book.UpdateID())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestStreamedBookSkipsOldUpdatesAndStopsAtGap(t *testing.T) {
+	stream := newTestBinanceStream()
+	stream.snapshots <- Binaresp{
+		LastUpdatedId: 100,
+		Bids:          [][]string{{"0.010", "5"}},
+		Asks:          [][]string{{"0.020", "3"}},
+	}
+	stream.batches <- []Binadepthupdate{
+		// older than the snapshot
+		depthUpdate(90, 95, [][]interface{}{level("0.010", "1")}, nil),
+		// first one straddles the snapshot
+		depthUpdate(99, 101, [][]interface{}{level("0.010", "7")}, nil),
+		depthUpdate(102, 103, nil, [][]interface{}{level("0.020", "0"), level("0.021", "4")}),
+		// 104 is missing
+		depthUpdate(105, 106, [][]interface{}{level("0.011", "9")}, nil),
+	}
+	bin := &Binance{interf: stream, stop: make(chan struct{})}
+	book := NewOrderBook()
+	err := bin.syncOnePair(common.TokenPair{}, book)
+	if err == nil || err.Error() != "Gap in depth updates: expected 104, got 105" {
+		t.Fatalf("Expected the gap to stop syncing, got %v", err)
+	}
+	if got := bookString(book); got != "true [{7 0.01}] [{4 0.021}]" {
+		t.Fatalf("Expected updates up to the gap to be applied, got %s", got)
+	}
+	if book.UpdateID() != 103 {
+		t.Fatalf("Expected book to be at update 103, got %d", book.UpdateID())
+	}
+}
+
+func TestStreamedBookNeedsUpdateRightAfterSnapshot(t *testing.T) {
+	stream := newTestBinanceStream()
+	stream.snapshots <- Binaresp{LastUpdatedId: 100}
+	stream.batches <- []Binadepthupdate{
+		depthUpdate(103, 104, [][]interface{}{level("0.010", "1")}, nil),
+	}
+	bin := &Binance{interf: stream, stop: make(chan struct{})}
+	err := bin.syncOnePair(common.TokenPair{}, NewOrderBook())
+	if err == nil || err.Error() != "Gap in depth updates: expected 101, got 103" {
+		t.Fatalf("Expected updates missing after the snapshot to stop syncing, got %v", err)
+	}
+}
+
+func TestStreamedBookResyncsFromSnapshot(t *testing.T) {
+	stream := newTestBinanceStream()
+	stream.snapshots <- Binaresp{LastUpdatedId: 100, Bids: [][]string{{"0.010", "5"}}}
+	stream.batches <- []Binadepthupdate{
+		depthUpdate(101, 101, [][]interface{}{level("0.010", "6")}, nil),
+		depthUpdate(103, 103, [][]interface{}{level("0.010", "8")}, nil),
+	}
+	bin := &Binance{interf: stream, stop: make(chan struct{})}
+	book := NewOrderBook()
+	bin.streams.Add(1)
+	go bin.streamOnePair(common.TokenPair{}, book, 50*time.Millisecond)
+	// the gap invalidates the book until the next snapshot
+	waitForBook(t, book, 101, "false [] []")
+	stream.snapshots <- Binaresp{LastUpdatedId: 200, Bids: [][]string{{"0.012", "2"}}}
+	stream.batches <- []Binadepthupdate{
+		depthUpdate(150, 180, [][]interface{}{level("0.010", "1")}, nil),
+		depthUpdate(195, 201, [][]interface{}{level("0.013", "3")}, nil),
+	}
+	waitForBook(t, book, 201, "true [{3 0.013} {2 0.012}] []")
+
+	closed := make(chan error)
+	go func() {
+		closed <- bin.Close()
+	}()
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Expected closing to end the stream")
+	}
+}
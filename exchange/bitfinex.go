@@ -1,17 +1,27 @@
 package exchange
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
 	"math/big"
+	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/KyberNetwork/reserve-data/common"
 	ethereum "github.com/ethereum/go-ethereum/common"
 )
 
+const BITFINEX_EPSILON float64 = 0.0000000001 // 10e-10
+
 type Bitfinex struct {
-	interf    BitfinexInterface
-	pairs     []common.TokenPair
-	addresses map[string]ethereum.Address
+	interf       BitfinexInterface
+	pairs        []common.TokenPair
+	addresses    *common.ExchangeAddresses
+	exchangeInfo *common.ExchangeInfo
+	fees         *common.ExchangeFeeTable
 }
 
 func (self *Bitfinex) MarshalText() (text []byte, err error) {
@@ -19,18 +29,94 @@ func (self *Bitfinex) MarshalText() (text []byte, err error) {
 }
 
 func (self *Bitfinex) Address(token common.Token) (ethereum.Address, bool) {
-	addr, supported := self.addresses[token.ID]
-	return addr, supported
+	return self.addresses.Get(token.ID)
+}
+
+func (self *Bitfinex) VerifiedAddress(token common.Token) (ethereum.Address, error) {
+	return self.addresses.Verified(token.ID)
 }
 
 func (self *Bitfinex) UpdateAllDepositAddresses(address string) {
-	for k, _ := range self.addresses {
-		self.addresses[k] = ethereum.HexToAddress(address)
-	}
+	self.addresses.UpdateAll(ethereum.HexToAddress(address))
 }
 
 func (self *Bitfinex) UpdateDepositAddress(token common.Token, address string) {
-	self.addresses[token.ID] = ethereum.HexToAddress(address)
+	self.addresses.Update(token.ID, ethereum.HexToAddress(address))
+}
+
+// DiscoverDepositAddresses verifies configured deposit addresses against
+// the ones of bitfinex exchange wallet
+func (self *Bitfinex) DiscoverDepositAddresses() error {
+	return discoverDepositAddresses(self.ID(), self.addresses, func(token common.Token) (string, error) {
+		result, err := self.interf.GetDepositAddress(token.ID)
+		return result.Address, err
+	})
+}
+
+func (self *Bitfinex) UpdatePrecisionLimit(pair common.TokenPair, symbols BitExchangeInfo) {
+	pairName := strings.ToLower(pair.Base.ID) + strings.ToLower(pair.Quote.ID)
+	for _, symbol := range symbols {
+		if symbol.Pair == pairName {
+			exchangePrecisionLimit := common.ExchangePrecisionLimit{}
+			//update precision
+			exchangePrecisionLimit.Precision.Price = symbol.PricePrecision
+			// bitfinex doesn't publish amount precision, it accepts up to
+			// 8 decimals for every pair
+			exchangePrecisionLimit.Precision.Amount = 8
+			// update limit
+			minQuantity, _ := strconv.ParseFloat(symbol.MinimumOrderSize, 32)
+			exchangePrecisionLimit.AmountLimit.Min = float32(minQuantity)
+			maxQuantity, _ := strconv.ParseFloat(symbol.MaximumOrderSize, 32)
+			exchangePrecisionLimit.AmountLimit.Max = float32(maxQuantity)
+			self.exchangeInfo.Update(pair.PairID(), exchangePrecisionLimit)
+			break
+		}
+	}
+}
+
+func (self *Bitfinex) UpdatePairsPrecision() {
+	exchangeInfo, err := self.interf.GetExchangeInfo()
+	if err != nil {
+		log.Printf("Get exchange info failed: %s\n", err)
+	} else {
+		for _, pair := range self.pairs {
+			self.UpdatePrecisionLimit(pair, exchangeInfo)
+		}
+	}
+}
+
+func (self *Bitfinex) GetInfo() (common.ExchangeInfo, error) {
+	return self.exchangeInfo.Copy(), nil
+}
+
+func (self *Bitfinex) GetExchangeInfo(pair common.TokenPairID) (common.ExchangePrecisionLimit, error) {
+	data, err := self.exchangeInfo.Get(pair)
+	return data, err
+}
+
+func (self *Bitfinex) GetFee() common.ExchangeFees {
+	return self.fees.Get()
+}
+
+// UpdateFees refreshes withdraw fees from bitfinex account fees
+func (self *Bitfinex) UpdateFees() error {
+	fees, err := self.interf.GetAccountFees()
+	if err != nil {
+		return err
+	}
+	withdrawFees := map[string]float32{}
+	for currency, fee := range fees.Withdraw {
+		tokenID := strings.ToUpper(currency)
+		if _, supported := common.SupportedTokens[tokenID]; supported {
+			value, err := strconv.ParseFloat(fee, 32)
+			if err != nil {
+				return err
+			}
+			withdrawFees[tokenID] = float32(value)
+		}
+	}
+	self.fees.UpdateWithdraw(withdrawFees)
+	return nil
 }
 
 func (self *Bitfinex) ID() common.ExchangeID {
@@ -45,21 +131,114 @@ func (self *Bitfinex) Name() string {
 	return "bitfinex"
 }
 
-func (self *Bitfinex) Trade(tradeType string, base common.Token, quote common.Token, rate float64, amount float64, timepoint uint64) (done float64, remaining float64, finished bool, err error) {
-	return self.interf.Trade(tradeType, base, quote, rate, amount, timepoint)
+func (self *Bitfinex) QueryOrder(ctx context.Context, id uint64, timepoint uint64) (done float64, remaining float64, finished bool, err error) {
+	result, err := self.interf.OrderStatus(ctx, id, timepoint)
+	if err != nil {
+		return 0, 0, false, err
+	} else {
+		done, _ := strconv.ParseFloat(result.ExecutedAmount, 64)
+		remaining, _ := strconv.ParseFloat(result.RemainingAmount, 64)
+		return done, remaining, !result.IsLive, nil
+	}
+}
+
+func (self *Bitfinex) Trade(ctx context.Context, tradeType string, base common.Token, quote common.Token, rate float64, amount float64, timepoint uint64) (id string, done float64, remaining float64, finished bool, err error) {
+	result, err := self.interf.Trade(ctx, tradeType, base, quote, rate, amount, timepoint)
+	if err != nil {
+		return "", 0, 0, false, err
+	} else {
+		done, remaining, finished, err := self.QueryOrder(ctx, result.ID, timepoint+20)
+		return strconv.FormatUint(result.ID, 10), done, remaining, finished, err
+	}
+}
+
+func (self *Bitfinex) Withdraw(ctx context.Context, token common.Token, amount *big.Int, address ethereum.Address, timepoint uint64) (string, error) {
+	result, err := self.interf.Withdraw(ctx, token, amount, address, timepoint)
+	if err != nil {
+		return "", err
+	}
+	if len(result) == 0 {
+		return "", errors.New("Malformed withdraw response from bitfinex")
+	}
+	if result[0].Status != "success" {
+		return "", errors.New("Withdraw rejected by bitfinex: " + result[0].Message)
+	}
+	return strconv.FormatUint(result[0].WithdrawalID, 10) + "|" + token.ID, nil
 }
 
-func (self *Bitfinex) Withdraw(token common.Token, amount *big.Int, address ethereum.Address, timepoint uint64) error {
-	return self.interf.Withdraw(token, amount, address, timepoint)
+func (self *Bitfinex) CancelOrder(ctx context.Context, id common.ActivityID) error {
+	orderID, err := strconv.ParseUint(id.EID, 10, 64)
+	if err != nil {
+		return err
+	}
+	result, err := self.interf.CancelOrder(ctx, orderID)
+	if err != nil {
+		return err
+	}
+	if result.Message != "" {
+		return errors.New("Couldn't cancel order id " + id.EID + " err: " + result.Message)
+	}
+	return nil
 }
 
-func (self *Bitfinex) FetchPriceData(timepoint uint64) (map[common.TokenPairID]common.ExchangePrice, error) {
+func (self *Bitfinex) FetchOnePairData(
+	ctx context.Context,
+	wg *sync.WaitGroup,
+	pair common.TokenPair,
+	data *sync.Map,
+	timepoint uint64) {
+
+	defer wg.Done()
+	result := common.ExchangePrice{}
+
+	timestamp := common.Timestamp(fmt.Sprintf("%d", timepoint))
+	result.Timestamp = timestamp
+	result.Valid = true
+	resp_data, err := self.interf.GetDepthOnePair(ctx, pair, timepoint)
+	returnTime := common.GetTimestamp()
+	result.ReturnTime = returnTime
+	if err != nil {
+		result.Valid = false
+		result.Error = err.Error()
+	} else {
+		if resp_data.Message != "" {
+			result.Valid = false
+			result.Error = resp_data.Message
+		} else {
+			for _, buy := range resp_data.Bids {
+				quantity, _ := strconv.ParseFloat(buy["amount"], 64)
+				rate, _ := strconv.ParseFloat(buy["price"], 64)
+				result.Bids = append(
+					result.Bids,
+					common.PriceEntry{
+						quantity,
+						rate,
+					},
+				)
+			}
+			for _, sell := range resp_data.Asks {
+				quantity, _ := strconv.ParseFloat(sell["amount"], 64)
+				rate, _ := strconv.ParseFloat(sell["price"], 64)
+				result.Asks = append(
+					result.Asks,
+					common.PriceEntry{
+						quantity,
+						rate,
+					},
+				)
+			}
+		}
+	}
+	data.Store(pair.PairID(), result)
+}
+
+func (self *Bitfinex) FetchPriceData(ctx context.Context, timepoint uint64) (map[common.TokenPairID]common.ExchangePrice, error) {
 	wait := sync.WaitGroup{}
 	data := sync.Map{}
 	pairs := self.pairs
 	for _, pair := range pairs {
 		wait.Add(1)
-		go self.interf.FetchOnePairData(&wait, pair, &data, timepoint)
+		go self.FetchOnePairData(ctx, &wait, pair, &data, timepoint)
 	}
 	wait.Wait()
 	result := map[common.TokenPairID]common.ExchangePrice{}
@@ -70,11 +249,115 @@ func (self *Bitfinex) FetchPriceData(timepoint uint64) (map[common.TokenPairID]c
 	return result, nil
 }
 
-func (self *Bitfinex) FetchEBalanceData(timepoint uint64) (common.EBalanceEntry, error) {
+func (self *Bitfinex) FetchEBalanceData(ctx context.Context, timepoint uint64) (common.EBalanceEntry, error) {
 	result := common.EBalanceEntry{}
+	result.Timestamp = common.Timestamp(fmt.Sprintf("%d", timepoint))
+	result.Valid = true
+	resp_data, err := self.interf.GetInfo(ctx, timepoint)
+	result.ReturnTime = common.GetTimestamp()
+	if err != nil {
+		result.Valid = false
+		result.Error = err.Error()
+	} else {
+		result.AvailableBalance = map[string]float64{}
+		result.LockedBalance = map[string]float64{}
+		result.DepositBalance = map[string]float64{}
+		for _, b := range resp_data {
+			// only exchange wallet is used to trade
+			if b.Type != "exchange" {
+				continue
+			}
+			tokenID := strings.ToUpper(b.Currency)
+			_, exist := common.SupportedTokens[tokenID]
+			if exist {
+				total, _ := strconv.ParseFloat(b.Amount, 64)
+				avai, _ := strconv.ParseFloat(b.Available, 64)
+				result.AvailableBalance[tokenID] = avai
+				result.LockedBalance[tokenID] = total - avai
+				result.DepositBalance[tokenID] = 0
+			}
+		}
+	}
 	return result, nil
 }
 
+func (self *Bitfinex) DepositStatus(ctx context.Context, id common.ActivityID, timepoint uint64) (string, error) {
+	idParts := strings.Split(id.EID, "|")
+	if len(idParts) != 3 {
+		// here, the exchange id part in id is malformed
+		// 1. because analytic didn't pass original ID
+		// 2. id is not constructed correctly in a form of uuid + "|" + token + "|" + amount
+		return "", errors.New("Invalid deposit id")
+	}
+	txID := ethereum.HexToHash(idParts[0])
+	movements, err := self.interf.Movements(ctx, idParts[1], timepoint)
+	if err != nil {
+		return "", err
+	}
+	for _, movement := range movements {
+		if movement.Type == "DEPOSIT" && ethereum.HexToHash(movement.TxID) == txID {
+			switch movement.Status {
+			case "COMPLETED":
+				return "done", nil
+			case "CANCELED":
+				return "failed", nil
+			default:
+				return "", nil
+			}
+		}
+	}
+	// bitfinex only lists a deposit after it sees the tx on chain
+	return "", nil
+}
+
+func (self *Bitfinex) WithdrawStatus(ctx context.Context, id common.ActivityID, timepoint uint64) (string, string, error) {
+	idParts := strings.Split(id.EID, "|")
+	if len(idParts) != 2 {
+		// here, the exchange id part in id is malformed
+		// 1. because analytic didn't pass original ID
+		// 2. id is not constructed correctly in a form of id + "|" + token
+		return "", "", errors.New("Invalid withdraw id")
+	}
+	withdrawID, err := strconv.ParseUint(idParts[0], 10, 64)
+	if err != nil {
+		return "", "", errors.New("Invalid withdraw id")
+	}
+	movements, err := self.interf.Movements(ctx, idParts[1], timepoint)
+	if err != nil {
+		return "", "", err
+	}
+	for _, movement := range movements {
+		if movement.Type == "WITHDRAWAL" && movement.ID == withdrawID {
+			switch movement.Status {
+			case "COMPLETED":
+				return "done", movement.TxID, nil
+			case "CANCELED":
+				return "failed", movement.TxID, nil
+			default:
+				return "", movement.TxID, nil
+			}
+		}
+	}
+	return "", "", errors.New("Withdrawal doesn't exist. This shouldn't happen unless withdrawal id returned from bitfinex and activity ID are not consistently designed")
+}
+
+func (self *Bitfinex) OrderStatus(ctx context.Context, id common.ActivityID, timepoint uint64) (string, error) {
+	orderID, err := strconv.ParseUint(id.EID, 10, 64)
+	if err != nil {
+		// if this crashes, it means core put malformed activity ID
+		panic(err)
+	}
+	order, err := self.interf.OrderStatus(ctx, orderID, timepoint)
+	if err != nil {
+		return "", err
+	}
+	if order.IsLive {
+		return "", nil
+	} else {
+		return "done", nil
+	}
+}
+
 func NewBitfinex(interf BitfinexInterface) *Bitfinex {
 	return &Bitfinex{
 		interf,
@@ -82,18 +365,27 @@ func NewBitfinex(interf BitfinexInterface) *Bitfinex {
 			common.MustCreateTokenPair("OMG", "ETH"),
 			common.MustCreateTokenPair("EOS", "ETH"),
 		},
-		map[string]ethereum.Address{
-			"ETH": ethereum.HexToAddress("0x5b082bc7928e1fd5b757426fe7225cc7a6a75c55"),
-			"OMG": ethereum.HexToAddress("0x5b082bc7928e1fd5b757426fe7225cc7a6a75c55"),
-			"DGD": ethereum.HexToAddress("0x5b082bc7928e1fd5b757426fe7225cc7a6a75c55"),
-			"CVC": ethereum.HexToAddress("0x5b082bc7928e1fd5b757426fe7225cc7a6a75c55"),
-			"MCO": ethereum.HexToAddress("0x5b082bc7928e1fd5b757426fe7225cc7a6a75c55"),
-			"GNT": ethereum.HexToAddress("0x5b082bc7928e1fd5b757426fe7225cc7a6a75c55"),
-			"ADX": ethereum.HexToAddress("0x5b082bc7928e1fd5b757426fe7225cc7a6a75c55"),
-			"EOS": ethereum.HexToAddress("0x5b082bc7928e1fd5b757426fe7225cc7a6a75c55"),
-			"PAY": ethereum.HexToAddress("0x5b082bc7928e1fd5b757426fe7225cc7a6a75c55"),
-			"BAT": ethereum.HexToAddress("0x5b082bc7928e1fd5b757426fe7225cc7a6a75c55"),
-			"KNC": ethereum.HexToAddress("0x5b082bc7928e1fd5b757426fe7225cc7a6a75c55"),
-		},
+		common.NewExchangeAddresses(),
+		common.NewExchangeInfo(),
+		common.NewExchangeFeeTable(
+			common.NewExchangeFee(
+				common.TradingFee{
+					"taker": 0.002,
+					"maker": 0.001,
+				},
+				common.NewFundingFee(
+					map[string]float32{
+						"ETH": 0.01,
+						"OMG": 0.1,
+						"EOS": 0.1,
+					},
+					map[string]float32{
+						"ETH": 0,
+						"OMG": 0,
+						"EOS": 0,
+					},
+				),
+			),
+		),
 	}
 }
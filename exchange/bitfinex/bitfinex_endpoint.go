@@ -2,6 +2,7 @@ package bitfinex
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -10,7 +11,6 @@ import (
 	"log"
 	"math/big"
 	"net/http"
-	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -18,226 +18,359 @@ import (
 	"github.com/KyberNetwork/reserve-data/common"
 	"github.com/KyberNetwork/reserve-data/exchange"
 	ethereum "github.com/ethereum/go-ethereum/common"
-	"sync"
 )
 
+// bitfinex identifies the chain of a withdrawal by the method name
+// rather than the currency
+var withdrawMethods = map[string]string{
+	"ETH": "ethereum",
+	"OMG": "omisego",
+	"EOS": "eos",
+}
+
+// Strict bitfinex endpoints the fetcher calls on every tick, each one
+// is limited in its own window besides the overall one
+const (
+	BITFINEX_RATE_KIND_BOOK      string = "book"
+	BITFINEX_RATE_KIND_BALANCES  string = "balances"
+	BITFINEX_RATE_KIND_MOVEMENTS string = "movements"
+)
+
+// bitfinex v1 rest api allows 10 to 90 requests per minute on each
+// endpoint. Every request counts toward the highest one, order books,
+// balances and movements also toward their own limit.
+var BITFINEX_RATE_LIMITS = []common.RateLimit{
+	common.RateLimit{Kind: common.RATE_KIND_REQUEST, Limit: 90, Interval: time.Minute},
+	common.RateLimit{Kind: BITFINEX_RATE_KIND_BOOK, Limit: 60, Interval: time.Minute},
+	common.RateLimit{Kind: BITFINEX_RATE_KIND_BALANCES, Limit: 20, Interval: time.Minute},
+	common.RateLimit{Kind: BITFINEX_RATE_KIND_MOVEMENTS, Limit: 20, Interval: time.Minute},
+}
+
+// rateWeights returns the rate kinds a request to path counts toward
+func rateWeights(path string) map[string]int {
+	weights := map[string]int{common.RATE_KIND_REQUEST: 1}
+	switch {
+	case strings.HasPrefix(path, "/book/"):
+		weights[BITFINEX_RATE_KIND_BOOK] = 1
+	case path == "/balances":
+		weights[BITFINEX_RATE_KIND_BALANCES] = 1
+	case path == "/history/movements":
+		weights[BITFINEX_RATE_KIND_MOVEMENTS] = 1
+	}
+	return weights
+}
+
 type BitfinexEndpoint struct {
-	signer Signer
-	interf Interface
+	signer  Signer
+	interf  Interface
+	limiter *common.RateLimiter
 }
 
 func nonce() string {
-	epsilon := 30 * time.Millisecond
-	anchor := int64(50299954901)
-	timestamp := time.Now().UnixNano()/int64(epsilon) - anchor
-	return strconv.Itoa(int(timestamp))
+	return strconv.FormatInt(time.Now().UnixNano(), 10)
 }
 
-func (self *BitfinexEndpoint) fillRequest(req *http.Request, signNeeded bool, timepoint uint64) {
+func (self *BitfinexEndpoint) fillRequest(req *http.Request, payload []byte, signNeeded bool) {
 	if req.Method == "POST" || req.Method == "PUT" {
 		req.Header.Add("Content-Type", "application/json;charset=utf-8")
 	}
 	req.Header.Add("Accept", "application/json")
-	if signNeeded == true {
-		payload := map[string]interface{}{
-			"request": req.URL.Path,
-			"nonce":   fmt.Sprintf("%v", timepoint),
-		}
-		payloadJson, _ := json.Marshal(payload)
-		payloadEnc := base64.StdEncoding.EncodeToString(payloadJson)
+	if signNeeded {
+		payloadEnc := base64.StdEncoding.EncodeToString(payload)
 		req.Header.Add("X-BFX-APIKEY", self.signer.GetBitfinexKey())
 		req.Header.Add("X-BFX-PAYLOAD", payloadEnc)
-		req.Header.Add("X-BFX-SIGNATURE", self.signer.BitfinexSign(req.URL.String()))
+		req.Header.Add("X-BFX-SIGNATURE", self.signer.BitfinexSign(payloadEnc))
 	}
 }
 
-func (self *BitfinexEndpoint) FetchOnePairData(
-	wg *sync.WaitGroup,
-	pair common.TokenPair,
-	data *sync.Map,
-	timepoint uint64) {
-
-	defer wg.Done()
-	result := common.ExchangePrice{}
+// GetResponse sends a public GET request when signNeeded is false.
+// Otherwise it sends an authenticated POST request whose params are
+// carried in the signed payload together with the request path and nonce.
+func (self *BitfinexEndpoint) GetResponse(
+	baseurl string, path string,
+	params map[string]interface{}, signNeeded bool) ([]byte, error) {
+	return self.GetResponseContext(context.Background(), baseurl, path, params, signNeeded)
+}
 
-	client := &http.Client{}
-	url := self.interf.PublicEndpoint() + fmt.Sprintf(
-		"/book/%s%s",
-		strings.ToLower(pair.Base.ID),
-		strings.ToLower(pair.Quote.ID))
-	req, _ := http.NewRequest("GET", url, nil)
-	q := req.URL.Query()
-	q.Set("group", "1")
-	q.Set("limit_bids", "50")
-	q.Set("limit_asks", "50")
-	req.URL.RawQuery = q.Encode()
-	self.fillRequest(req, false, timepoint)
+// GetResponseContext is GetResponse canceled along with ctx
+func (self *BitfinexEndpoint) GetResponseContext(
+	ctx context.Context,
+	baseurl string, path string,
+	params map[string]interface{}, signNeeded bool) ([]byte, error) {
 
+	client := &http.Client{
+		Timeout: time.Duration(30 * time.Second),
+	}
+	var req *http.Request
+	var payload []byte
+	if signNeeded {
+		body := map[string]interface{}{}
+		for k, v := range params {
+			body[k] = v
+		}
+		body["request"] = "/v1" + path
+		body["nonce"] = nonce()
+		payload, _ = json.Marshal(body)
+		req, _ = http.NewRequest("POST", baseurl+path, bytes.NewBuffer(payload))
+	} else {
+		req, _ = http.NewRequest("GET", baseurl+path, nil)
+		q := req.URL.Query()
+		for k, v := range params {
+			q.Add(k, fmt.Sprintf("%v", v))
+		}
+		req.URL.RawQuery = q.Encode()
+	}
+	req = req.WithContext(ctx)
+	self.fillRequest(req, payload, signNeeded)
+	var err error
+	var resp_body []byte
+	if err = self.limiter.Wait(ctx, rateWeights(path)); err != nil {
+		return resp_body, err
+	}
+	log.Printf("request to bitfinex: %s\n", req.URL)
 	resp, err := client.Do(req)
-	result.Timestamp = common.Timestamp(fmt.Sprintf("%d", timepoint))
-	result.Valid = true
 	if err != nil {
-		result.Valid = false
-		result.Error = err.Error()
+		return resp_body, err
 	} else {
 		defer resp.Body.Close()
-		resp_body, err := ioutil.ReadAll(resp.Body)
-		returnTime := common.GetTimestamp()
-		result.ReturnTime = returnTime
-		if err != nil {
-			result.Valid = false
-			result.Error = err.Error()
-		} else {
-			resp_data := exchange.Bitfresp{}
-			json.Unmarshal(resp_body, &resp_data)
-			if len(resp_data.Asks) == 0 && len(resp_data.Bids) == 0 {
-				result.Valid = false
-			} else {
-				for _, buy := range resp_data.Bids {
-					quantity, _ := strconv.ParseFloat(buy["amount"], 64)
-					rate, _ := strconv.ParseFloat(buy["price"], 64)
-					result.Bids = append(
-						result.Bids,
-						common.PriceEntry{
-							quantity,
-							rate,
-						},
-					)
-				}
-				for _, sell := range resp_data.Asks {
-					quantity, _ := strconv.ParseFloat(sell["amount"], 64)
-					rate, _ := strconv.ParseFloat(sell["price"], 64)
-					result.Asks = append(
-						result.Asks,
-						common.PriceEntry{
-							quantity,
-							rate,
-						},
-					)
-				}
-			}
+		resp_body, err = ioutil.ReadAll(resp.Body)
+		log.Printf("request to %s, got response from bitfinex: %s\n", req.URL, common.TruncStr(resp_body))
+		if err == nil && resp.StatusCode != 200 {
+			err = errors.New("Unsuccessful response from Bitfinex: Status " + resp.Status + ", " + string(common.TruncStr(resp_body)))
 		}
+		return resp_body, err
+	}
+}
+
+func (self *BitfinexEndpoint) GetDepthOnePair(
+	ctx context.Context,
+	pair common.TokenPair, timepoint uint64) (exchange.Bitfresp, error) {
+
+	resp_data := exchange.Bitfresp{}
+	resp_body, err := self.GetResponseContext(
+		ctx,
+		self.interf.PublicEndpoint(),
+		fmt.Sprintf("/book/%s%s", strings.ToLower(pair.Base.ID), strings.ToLower(pair.Quote.ID)),
+		map[string]interface{}{
+			"group":      "1",
+			"limit_bids": "50",
+			"limit_asks": "50",
+		},
+		false,
+	)
+	if err != nil {
+		return resp_data, err
+	} else {
+		json.Unmarshal(resp_body, &resp_data)
+		return resp_data, nil
 	}
-	data.Store(pair.PairID(), result)
 }
 
-func (self *BitfinexEndpoint) Trade(tradeType string, base, quote common.Token, rate, amount float64, timepoint uint64) (done float64, remaining float64, finished bool, err error) {
+// Relevant params:
+// symbol ("%s%s", base, quote) in lower case
+// side (buy/sell)
+// type ("exchange limit")
+// amount
+// price
+//
+// In this version, we only support limit order on exchange wallet
+func (self *BitfinexEndpoint) Trade(ctx context.Context, tradeType string, base, quote common.Token, rate, amount float64, timepoint uint64) (exchange.Bitftrade, error) {
 	result := exchange.Bitftrade{}
-	client := &http.Client{
-		Timeout: time.Duration(30 * time.Second)}
-	data := url.Values{}
-	data.Set("method", "Trade")
-	data.Set("pair", fmt.Sprintf("%s_%s", strings.ToLower(base.ID), strings.ToLower(quote.ID)))
-	data.Set("type", tradeType)
-	data.Set("rate", strconv.FormatFloat(rate, 'f', -1, 64))
-	data.Set("amount", strconv.FormatFloat(amount, 'f', -1, 64))
-	params := data.Encode()
-	req, _ := http.NewRequest(
-		"POST",
+	resp_body, err := self.GetResponseContext(
+		ctx,
 		self.interf.AuthenticatedEndpoint(),
-		bytes.NewBufferString(params),
+		"/order/new",
+		map[string]interface{}{
+			"symbol":   strings.ToLower(base.ID + quote.ID),
+			"amount":   strconv.FormatFloat(amount, 'f', -1, 64),
+			"price":    strconv.FormatFloat(rate, 'f', -1, 64),
+			"exchange": "bitfinex",
+			"side":     strings.ToLower(tradeType),
+			"type":     "exchange limit",
+		},
+		true,
 	)
-	req.Header.Add("Content-Length", strconv.Itoa(len(params)))
-	req.Header.Add("Accept", "application/json")
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Key", self.signer.GetBitfinexKey())
-	req.Header.Add("Sign", self.signer.BitfinexSign(params))
-	resp, err := client.Do(req)
-	if err == nil && resp.StatusCode == 200 {
-		defer resp.Body.Close()
-		resp_body, err := ioutil.ReadAll(resp.Body)
-		log.Printf("response: %s\n", resp_body)
-		if err == nil {
-			err = json.Unmarshal(resp_body, &result)
+	if err == nil {
+		json.Unmarshal(resp_body, &result)
+		if result.Message != "" {
+			err = errors.New("Trade rejected by Bitfinex: " + result.Message)
 		}
-	} else {
-		log.Printf("Error: %v, Code: %v\n", err, resp)
 	}
-	return
+	return result, err
 }
 
-func (self *BitfinexEndpoint) Withdraw(token common.Token, amount *big.Int, address ethereum.Address, timepoint uint64) error {
-	// ignoring timepoint because it's only relevant in simulation
-	result := exchange.Bitfwithdraw{}
-	client := &http.Client{
-		Timeout: time.Duration(30 * time.Second),
+func (self *BitfinexEndpoint) CancelOrder(ctx context.Context, id uint64) (exchange.Bitfcancel, error) {
+	result := exchange.Bitfcancel{}
+	resp_body, err := self.GetResponseContext(
+		ctx,
+		self.interf.AuthenticatedEndpoint(),
+		"/order/cancel",
+		map[string]interface{}{
+			"order_id": id,
+		},
+		true,
+	)
+	if err == nil {
+		json.Unmarshal(resp_body, &result)
 	}
-	data := url.Values{}
-	data.Set("method", "WithdrawCoin")
-	data.Set("coinName", token.ID)
-	data.Set("amount", strconv.FormatFloat(common.BigToFloat(amount, token.Decimal), 'f', -1, 64))
-	data.Set("address", address.Hex())
-	params := data.Encode()
-	req, _ := http.NewRequest(
-		"POST",
+	return result, err
+}
+
+func (self *BitfinexEndpoint) OrderStatus(ctx context.Context, id uint64, timepoint uint64) (exchange.Bitforder, error) {
+	result := exchange.Bitforder{}
+	resp_body, err := self.GetResponseContext(
+		ctx,
 		self.interf.AuthenticatedEndpoint(),
-		bytes.NewBufferString(params),
+		"/order/status",
+		map[string]interface{}{
+			"order_id": id,
+		},
+		true,
 	)
-	req.Header.Add("Content-Length", strconv.Itoa(len(params)))
-	req.Header.Add("Accept", "application/json")
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Key", self.signer.GetBitfinexKey())
-	req.Header.Add("Sign", self.signer.BitfinexSign(params))
-	resp, err := client.Do(req)
-	if err == nil && resp.StatusCode == 200 {
-		defer resp.Body.Close()
-		resp_body, err := ioutil.ReadAll(resp.Body)
-		log.Printf("response: %s\n", resp_body)
-		if err == nil {
-			err = json.Unmarshal(resp_body, &result)
-		}
-		if err != nil {
-			return err
-		}
-		if result.Error != "" {
-			return errors.New(result.Error)
+	if err == nil {
+		json.Unmarshal(resp_body, &result)
+		if result.Message != "" {
+			err = errors.New(result.Message)
 		}
-		return nil
+	}
+	return result, err
+}
+
+func (self *BitfinexEndpoint) Movements(ctx context.Context, currency string, timepoint uint64) (exchange.Bitfmovements, error) {
+	result := exchange.Bitfmovements{}
+	resp_body, err := self.GetResponseContext(
+		ctx,
+		self.interf.AuthenticatedEndpoint(),
+		"/history/movements",
+		map[string]interface{}{
+			"currency": strings.ToUpper(currency),
+			"limit":    100,
+		},
+		true,
+	)
+	if err == nil {
+		err = json.Unmarshal(resp_body, &result)
+	}
+	return result, err
+}
+
+func (self *BitfinexEndpoint) Withdraw(ctx context.Context, token common.Token, amount *big.Int, address ethereum.Address, timepoint uint64) (exchange.Bitfwithdraw, error) {
+	result := exchange.Bitfwithdraw{}
+	method, supported := withdrawMethods[token.ID]
+	if !supported {
+		return result, errors.New("Bitfinex withdrawal of " + token.ID + " is not supported")
+	}
+	resp_body, err := self.GetResponseContext(
+		ctx,
+		self.interf.AuthenticatedEndpoint(),
+		"/withdraw",
+		map[string]interface{}{
+			"withdraw_type":  method,
+			"walletselected": "exchange",
+			"amount":         strconv.FormatFloat(common.BigToFloat(amount, token.Decimal), 'f', -1, 64),
+			"address":        address.Hex(),
+		},
+		true,
+	)
+	if err == nil {
+		err = json.Unmarshal(resp_body, &result)
+		return result, err
 	} else {
-		log.Printf("Error: %v, Code: %v\n", err, resp)
-		return errors.New("withdraw rejected by Bitfinex")
+		log.Printf("Error: %v", err)
+		return result, errors.New("withdraw rejected by Bitfinex")
 	}
 }
 
-func (self *BitfinexEndpoint) GetInfo(timepoint uint64) (exchange.Bitfinfo, error) {
+func (self *BitfinexEndpoint) GetInfo(ctx context.Context, timepoint uint64) (exchange.Bitfinfo, error) {
 	result := exchange.Bitfinfo{}
-	client := &http.Client{
-		Timeout: time.Duration(30 * time.Second)}
-	data := url.Values{}
-	data.Set("method", "getInfo")
-	data.Add("nonce", nonce())
-	params := data.Encode()
-	log.Printf("endpoint: %v\n", self.interf.AuthenticatedEndpoint())
-	req, _ := http.NewRequest(
-		"POST",
+	resp_body, err := self.GetResponseContext(
+		ctx,
 		self.interf.AuthenticatedEndpoint(),
-		bytes.NewBufferString(params),
+		"/balances",
+		map[string]interface{}{},
+		true,
 	)
-	log.Printf("params: %v\n", params)
-	req.Header.Add("Content-Length", strconv.Itoa(len(params)))
-	req.Header.Add("Accept", "application/json")
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Key", self.signer.GetBitfinexKey())
-	req.Header.Add("Sign", self.signer.BitfinexSign(params))
-	resp, err := client.Do(req)
 	if err == nil {
-		defer resp.Body.Close()
-		resp_body, err := ioutil.ReadAll(resp.Body)
-		if err == nil {
-			json.Unmarshal(resp_body, &result)
+		err = json.Unmarshal(resp_body, &result)
+	}
+	return result, err
+}
+
+func (self *BitfinexEndpoint) GetAccountFees() (exchange.Bitfaccountfees, error) {
+	result := exchange.Bitfaccountfees{}
+	resp_body, err := self.GetResponse(
+		self.interf.AuthenticatedEndpoint(),
+		"/account_fees",
+		map[string]interface{}{},
+		true,
+	)
+	if err == nil {
+		err = json.Unmarshal(resp_body, &result)
+		if err == nil && result.Message != "" {
+			err = errors.New(result.Message)
 		}
 	}
 	return result, err
 }
 
+// GetDepositAddress returns the current deposit address of the exchange
+// wallet, renew is off so it never rotates the address
+func (self *BitfinexEndpoint) GetDepositAddress(currency string) (exchange.Bitfdepositaddress, error) {
+	result := exchange.Bitfdepositaddress{}
+	// deposits use the same method names as withdrawals
+	method, supported := withdrawMethods[currency]
+	if !supported {
+		return result, errors.New("Bitfinex deposit of " + currency + " is not supported")
+	}
+	resp_body, err := self.GetResponse(
+		self.interf.AuthenticatedEndpoint(),
+		"/deposit/new",
+		map[string]interface{}{
+			"method":      method,
+			"wallet_name": "exchange",
+			"renew":       0,
+		},
+		true,
+	)
+	if err == nil {
+		err = json.Unmarshal(resp_body, &result)
+		if err == nil && result.Result != "success" {
+			err = errors.New("Getting deposit address from Bitfinex failed: " + result.Message)
+		}
+	}
+	return result, err
+}
+
+func (self *BitfinexEndpoint) GetExchangeInfo() (exchange.BitExchangeInfo, error) {
+	result := exchange.BitExchangeInfo{}
+	resp_body, err := self.GetResponse(
+		self.interf.PublicEndpoint(),
+		"/symbols_details",
+		map[string]interface{}{},
+		false,
+	)
+	if err == nil {
+		err = json.Unmarshal(resp_body, &result)
+	}
+	return result, err
+}
+
 func NewBitfinexEndpoint(signer Signer, interf Interface) *BitfinexEndpoint {
-	return &BitfinexEndpoint{signer, interf}
+	return &BitfinexEndpoint{signer, interf, common.RegisterRateLimiter("bitfinex", BITFINEX_RATE_LIMITS)}
 }
 
 func NewRealBitfinexEndpoint(signer Signer) *BitfinexEndpoint {
-	return &BitfinexEndpoint{signer, NewRealInterface()}
+	return NewBitfinexEndpoint(signer, NewRealInterface())
 }
 
 func NewSimulatedBitfinexEndpoint(signer Signer) *BitfinexEndpoint {
-	return &BitfinexEndpoint{signer, NewSimulatedInterface()}
+	return NewBitfinexEndpoint(signer, NewSimulatedInterface())
+}
+
+func NewRopstenBitfinexEndpoint(signer Signer) *BitfinexEndpoint {
+	return NewBitfinexEndpoint(signer, NewRopstenInterface())
+}
+
+func NewDevBitfinexEndpoint(signer Signer) *BitfinexEndpoint {
+	return NewBitfinexEndpoint(signer, NewDevInterface())
 }
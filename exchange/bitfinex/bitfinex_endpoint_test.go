@@ -0,0 +1,67 @@
+package bitfinex
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/exchange"
+)
+
+func TestRateLimitsFitDefaultSchedule(t *testing.T) {
+	common.SupportedTokens = map[string]common.Token{}
+	for _, id := range []string{"ETH", "OMG", "EOS"} {
+		common.SupportedTokens[id] = common.Token{ID: id}
+	}
+	pairs := len(exchange.NewBitfinex(nil).TokenPairs())
+	schedules := common.DefaultSchedules()
+	minute := uint64(time.Minute / time.Millisecond)
+	perMinute := func(kind string) int {
+		return int(minute / schedules.Schedule("bitfinex", kind).Interval)
+	}
+	books := pairs * perMinute(common.FETCH_ORDERBOOK)
+	balances := perMinute(common.FETCH_AUTH_DATA)
+
+	limiter := common.NewRateLimiter(BITFINEX_RATE_LIMITS)
+	start := time.Now()
+	for i := 0; i < books+balances; i++ {
+		path := "/book/omgeth"
+		if i >= books {
+			path = "/balances"
+		}
+		if err := limiter.Wait(context.Background(), rateWeights(path)); err != nil {
+			t.Fatalf("Expected %d books and %d balances a minute to fit, request %d failed: %v", books, balances, i+1, err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Expected a minute of the default schedule not to wait, it waited %s", elapsed)
+	}
+	// leave a quarter of every limit to status checks and retries
+	for _, usage := range limiter.Usage() {
+		if usage.Used*4 > usage.Limit*3 {
+			t.Errorf("Expected default schedule to leave headroom, it uses %d of %d %s requests", usage.Used, usage.Limit, usage.Kind)
+		}
+	}
+}
+
+func TestRateWeights(t *testing.T) {
+	tests := map[string]string{
+		"/book/omgeth":       BITFINEX_RATE_KIND_BOOK,
+		"/balances":          BITFINEX_RATE_KIND_BALANCES,
+		"/history/movements": BITFINEX_RATE_KIND_MOVEMENTS,
+		"/order/status":      "",
+	}
+	for path, kind := range tests {
+		weights := rateWeights(path)
+		if weights[common.RATE_KIND_REQUEST] != 1 {
+			t.Errorf("Expected %s to count as a request, got %v", path, weights)
+		}
+		if kind == "" && len(weights) != 1 {
+			t.Errorf("Expected %s to count toward no endpoint limit, got %v", path, weights)
+		}
+		if kind != "" && (weights[kind] != 1 || len(weights) != 2) {
+			t.Errorf("Expected %s to count toward %s limit, got %v", path, kind, weights)
+		}
+	}
+}
@@ -46,3 +46,33 @@ func (self *SimulatedInterface) AuthenticatedEndpoint() string {
 func NewSimulatedInterface() *SimulatedInterface {
 	return &SimulatedInterface{}
 }
+
+type RopstenInterface struct {
+	simulated *SimulatedInterface
+}
+
+func (self *RopstenInterface) PublicEndpoint() string {
+	return "https://api.bitfinex.com/v1"
+}
+
+func (self *RopstenInterface) AuthenticatedEndpoint() string {
+	return self.simulated.baseurl()
+}
+
+func NewRopstenInterface() *RopstenInterface {
+	return &RopstenInterface{NewSimulatedInterface()}
+}
+
+type DevInterface struct{}
+
+func (self *DevInterface) PublicEndpoint() string {
+	return "https://api.bitfinex.com/v1"
+}
+
+func (self *DevInterface) AuthenticatedEndpoint() string {
+	return "https://api.bitfinex.com/v1"
+}
+
+func NewDevInterface() *DevInterface {
+	return &DevInterface{}
+}
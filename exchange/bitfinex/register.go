@@ -0,0 +1,34 @@
+package bitfinex
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/KyberNetwork/reserve-data/exchange"
+)
+
+func init() {
+	exchange.RegisterExchange("bitfinex", NewExchange)
+}
+
+// NewExchange builds bitfinex adapter on the endpoint of env
+func NewExchange(env string, signer interface{}, storage interface{}) (exchange.Adapter, error) {
+	bitfinexSigner, ok := signer.(Signer)
+	if !ok {
+		return nil, errors.New("Signer doesn't have bitfinex keys")
+	}
+	var endpoint *BitfinexEndpoint
+	switch env {
+	case exchange.ENV_MAINNET:
+		endpoint = NewRealBitfinexEndpoint(bitfinexSigner)
+	case exchange.ENV_ROPSTEN:
+		endpoint = NewRopstenBitfinexEndpoint(bitfinexSigner)
+	case exchange.ENV_DEV:
+		endpoint = NewDevBitfinexEndpoint(bitfinexSigner)
+	case exchange.ENV_SIMULATION:
+		endpoint = NewSimulatedBitfinexEndpoint(bitfinexSigner)
+	default:
+		return nil, errors.New(fmt.Sprintf("Environment %s is not supported", env))
+	}
+	return exchange.NewBitfinex(endpoint), nil
+}
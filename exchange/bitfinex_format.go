@@ -1,35 +1,78 @@
 package exchange
 
 type Bitfresp struct {
-	Asks []map[string]string `json:"asks"`
-	Bids []map[string]string `json:"bids"`
+	Asks    []map[string]string `json:"asks"`
+	Bids    []map[string]string `json:"bids"`
+	Message string              `json:"message"`
 }
 
-type Bitfinfo struct {
-	Success int `json:"success"`
-	Return  map[string]map[string]float64
-	Error   string `json:"error"`
+// [{"type": "exchange", "currency": "eth", "amount": "1.0", "available": "1.0"}]
+type Bitfinfo []struct {
+	Type      string `json:"type"`
+	Currency  string `json:"currency"`
+	Amount    string `json:"amount"`
+	Available string `json:"available"`
 }
 
-type BitExchangeInfo struct {
-	Pairs []struct {
-		Pair           string `json:"pair"`
-		PricePrecision int    `json:"price_precision"`
-	}
+type BitfSymbolDetail struct {
+	Pair             string `json:"pair"`
+	PricePrecision   int    `json:"price_precision"`
+	MaximumOrderSize string `json:"maximum_order_size"`
+	MinimumOrderSize string `json:"minimum_order_size"`
 }
 
-type Bitfwithdraw struct {
-	Success int `json:"success"`
-	Return  map[string]interface{}
-	Error   string `json:"error"`
+type BitExchangeInfo []BitfSymbolDetail
+
+type Bitfwithdraw []struct {
+	Status       string `json:"status"`
+	Message      string `json:"message"`
+	WithdrawalID uint64 `json:"withdrawal_id"`
+}
+
+type Bitforder struct {
+	ID              uint64 `json:"id"`
+	Symbol          string `json:"symbol"`
+	Price           string `json:"price"`
+	Side            string `json:"side"`
+	Type            string `json:"type"`
+	IsLive          bool   `json:"is_live"`
+	IsCancelled     bool   `json:"is_cancelled"`
+	ExecutedAmount  string `json:"executed_amount"`
+	RemainingAmount string `json:"remaining_amount"`
+	OriginalAmount  string `json:"original_amount"`
+	Message         string `json:"message"`
+}
+
+type Bitftrade Bitforder
+
+type Bitfcancel Bitforder
+
+// Status can be one of "PENDING", "UNCONFIRMED", "SENDING",
+// "COMPLETED" and "CANCELED"
+type Bitfmovement struct {
+	ID        uint64 `json:"id"`
+	TxID      string `json:"txid"`
+	Currency  string `json:"currency"`
+	Method    string `json:"method"`
+	Type      string `json:"type"`
+	Amount    string `json:"amount"`
+	Address   string `json:"address"`
+	Status    string `json:"status"`
+	Timestamp string `json:"timestamp"`
+}
+
+type Bitfmovements []Bitfmovement
+
+// {"withdraw": {"BTC": "0.0005", "ETH": "0.01"}}
+type Bitfdepositaddress struct {
+	Result   string `json:"result"`
+	Method   string `json:"method"`
+	Currency string `json:"currency"`
+	Address  string `json:"address"`
+	Message  string `json:"message"`
 }
 
-type Bitftrade struct {
-	Success int `json:"success"`
-	Return  struct {
-		Done      float64 `json:"received"`
-		Remaining float64 `json:"remains"`
-		OrderID   int64   `json:"order_id"`
-	}
-	Error string `json:"error"`
+type Bitfaccountfees struct {
+	Withdraw map[string]string `json:"withdraw"`
+	Message  string            `json:"message"`
 }
@@ -1,32 +1,43 @@
 package exchange
 
 import (
+	"context"
 	"math/big"
 
 	"github.com/KyberNetwork/reserve-data/common"
 	ethereum "github.com/ethereum/go-ethereum/common"
-	"sync"
 )
 
 type BitfinexInterface interface {
+	GetDepthOnePair(
+		ctx context.Context, pair common.TokenPair, timepoint uint64) (Bitfresp, error)
 
-	GetInfo(timepoint uint64) (Bitfinfo, error)
+	GetInfo(ctx context.Context, timepoint uint64) (Bitfinfo, error)
 
-	FetchOnePairData(
-		wg *sync.WaitGroup,
-		pair common.TokenPair,
-		data *sync.Map,
-		timepoint uint64)
+	GetExchangeInfo() (BitExchangeInfo, error)
+
+	GetAccountFees() (Bitfaccountfees, error)
+
+	GetDepositAddress(currency string) (Bitfdepositaddress, error)
 
 	Withdraw(
+		ctx context.Context,
 		token common.Token,
 		amount *big.Int,
 		address ethereum.Address,
-		timepoint uint64) error
+		timepoint uint64) (Bitfwithdraw, error)
 
 	Trade(
+		ctx context.Context,
 		tradeType string,
 		base, quote common.Token,
 		rate, amount float64,
-		timepoint uint64) (done float64, remaining float64, finished bool, err error)
+		timepoint uint64) (Bitftrade, error)
+
+	CancelOrder(ctx context.Context, id uint64) (Bitfcancel, error)
+
+	// History of deposits and withdrawals of a currency
+	Movements(ctx context.Context, currency string, timepoint uint64) (Bitfmovements, error)
+
+	OrderStatus(ctx context.Context, id uint64, timepoint uint64) (Bitforder, error)
 }
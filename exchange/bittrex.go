@@ -1,6 +1,7 @@
 package exchange
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
@@ -19,10 +20,10 @@ const BITTREX_EPSILON float64 = 0.00000001
 type Bittrex struct {
 	interf       BittrexInterface
 	pairs        []common.TokenPair
-	addresses    map[string]ethereum.Address
+	addresses    *common.ExchangeAddresses
 	storage      BittrexStorage
 	exchangeInfo *common.ExchangeInfo
-	fees         common.ExchangeFees
+	fees         *common.ExchangeFeeTable
 }
 
 func (self *Bittrex) MarshalText() (text []byte, err error) {
@@ -30,22 +31,32 @@ func (self *Bittrex) MarshalText() (text []byte, err error) {
 }
 
 func (self *Bittrex) Address(token common.Token) (ethereum.Address, bool) {
-	addr, supported := self.addresses[token.ID]
-	return addr, supported
+	return self.addresses.Get(token.ID)
+}
+
+func (self *Bittrex) VerifiedAddress(token common.Token) (ethereum.Address, error) {
+	return self.addresses.Verified(token.ID)
 }
 
 func (self *Bittrex) GetFee() common.ExchangeFees {
-	return self.fees
+	return self.fees.Get()
 }
 
 func (self *Bittrex) UpdateAllDepositAddresses(address string) {
-	for k, _ := range self.addresses {
-		self.addresses[k] = ethereum.HexToAddress(address)
-	}
+	self.addresses.UpdateAll(ethereum.HexToAddress(address))
 }
 
 func (self *Bittrex) UpdateDepositAddress(token common.Token, address string) {
-	self.addresses[token.ID] = ethereum.HexToAddress(address)
+	self.addresses.Update(token.ID, ethereum.HexToAddress(address))
+}
+
+// DiscoverDepositAddresses verifies configured deposit addresses against
+// the ones bittrex reports
+func (self *Bittrex) DiscoverDepositAddresses() error {
+	return discoverDepositAddresses(self.ID(), self.addresses, func(token common.Token) (string, error) {
+		result, err := self.interf.GetDepositAddress(token.ID)
+		return result.Result.Address, err
+	})
 }
 
 func (self *Bittrex) UpdatePrecisionLimit(pair common.TokenPair, symbols []BittPairInfo) {
@@ -71,7 +82,7 @@ func (self *Bittrex) GetExchangeInfo(pair common.TokenPairID) (common.ExchangePr
 }
 
 func (self *Bittrex) GetInfo() (common.ExchangeInfo, error) {
-	return *self.exchangeInfo, nil
+	return self.exchangeInfo.Copy(), nil
 }
 
 func (self *Bittrex) UpdatePairsPrecision() {
@@ -86,6 +97,23 @@ func (self *Bittrex) UpdatePairsPrecision() {
 	}
 }
 
+// UpdateFees refreshes withdraw fees from bittrex currency list, bittrex
+// doesn't publish trading fees so they keep their fallback values
+func (self *Bittrex) UpdateFees() error {
+	currencies, err := self.interf.GetCurrencies()
+	if err != nil {
+		return err
+	}
+	withdrawFees := map[string]float32{}
+	for _, currency := range currencies.Result {
+		if _, supported := common.SupportedTokens[currency.Currency]; supported {
+			withdrawFees[currency.Currency] = float32(currency.TxFee)
+		}
+	}
+	self.fees.UpdateWithdraw(withdrawFees)
+	return nil
+}
+
 func (self *Bittrex) ID() common.ExchangeID {
 	return common.ExchangeID("bittrex")
 }
@@ -98,8 +126,8 @@ func (self *Bittrex) Name() string {
 	return "bittrex"
 }
 
-func (self *Bittrex) QueryOrder(uuid string, timepoint uint64) (float64, float64, bool, error) {
-	result, err := self.interf.OrderStatus(uuid, timepoint)
+func (self *Bittrex) QueryOrder(ctx context.Context, uuid string, timepoint uint64) (float64, float64, bool, error) {
+	result, err := self.interf.OrderStatus(ctx, uuid, timepoint)
 	if err != nil {
 		return 0, 0, false, err
 	} else {
@@ -109,8 +137,8 @@ func (self *Bittrex) QueryOrder(uuid string, timepoint uint64) (float64, float64
 	}
 }
 
-func (self *Bittrex) Trade(tradeType string, base common.Token, quote common.Token, rate float64, amount float64, timepoint uint64) (string, float64, float64, bool, error) {
-	result, err := self.interf.Trade(tradeType, base, quote, rate, amount, timepoint)
+func (self *Bittrex) Trade(ctx context.Context, tradeType string, base common.Token, quote common.Token, rate float64, amount float64, timepoint uint64) (string, float64, float64, bool, error) {
+	result, err := self.interf.Trade(ctx, tradeType, base, quote, rate, amount, timepoint)
 
 	if err != nil {
 		return "", 0, 0, false, errors.New("Trade rejected by Bittrex")
@@ -118,7 +146,7 @@ func (self *Bittrex) Trade(tradeType string, base common.Token, quote common.Tok
 		if result.Success {
 			uuid := result.Result["uuid"]
 			done, remaining, finished, err := self.QueryOrder(
-				uuid, timepoint+20)
+				ctx, uuid, timepoint+20)
 			return uuid, done, remaining, finished, err
 		} else {
 			return "", 0, 0, false, errors.New(result.Error)
@@ -126,8 +154,8 @@ func (self *Bittrex) Trade(tradeType string, base common.Token, quote common.Tok
 	}
 }
 
-func (self *Bittrex) Withdraw(token common.Token, amount *big.Int, address ethereum.Address, timepoint uint64) (string, error) {
-	resp, err := self.interf.Withdraw(token, amount, address, timepoint)
+func (self *Bittrex) Withdraw(ctx context.Context, token common.Token, amount *big.Int, address ethereum.Address, timepoint uint64) (string, error) {
+	resp, err := self.interf.Withdraw(ctx, token, amount, address, timepoint)
 	if err != nil {
 		return "", err
 	} else {
@@ -143,7 +171,9 @@ func bitttimestampToUint64(input string) uint64 {
 	var t time.Time
 	var err error
 	len := len(input)
-	if len == 23 {
+	if len == 19 {
+		t, err = time.Parse("2006-01-02T15:04:05", input)
+	} else if len == 23 {
 		t, err = time.Parse("2006-01-02T15:04:05.000", input)
 	} else if len == 22 {
 		t, err = time.Parse("2006-01-02T15:04:05.00", input)
@@ -156,7 +186,43 @@ func bitttimestampToUint64(input string) uint64 {
 	return uint64(t.UnixNano() / int64(time.Millisecond))
 }
 
-func (self *Bittrex) DepositStatus(id common.ActivityID, timepoint uint64) (string, error) {
+// TradeHistory returns closed orders of pair since a timepoint. Bittrex
+// doesn't expose single fills so each order is reported as one fill at
+// its average price.
+func (self *Bittrex) TradeHistory(ctx context.Context, pair common.TokenPair, since uint64) ([]common.TradeFill, error) {
+	history, err := self.interf.OrderHistory(ctx, pair)
+	if err != nil {
+		return nil, err
+	}
+	result := []common.TradeFill{}
+	for _, order := range history.Result {
+		if order.Closed == "" {
+			continue
+		}
+		timestamp := bitttimestampToUint64(order.Closed)
+		if timestamp < since {
+			continue
+		}
+		tradeType := "sell"
+		if order.OrderType == "LIMIT_BUY" {
+			tradeType = "buy"
+		}
+		result = append(result, common.TradeFill{
+			ID:        order.OrderUuid,
+			OrderID:   order.OrderUuid,
+			Pair:      pair.PairID(),
+			Type:      tradeType,
+			Price:     order.PricePerUnit,
+			Qty:       order.Quantity - order.QuantityRemaining,
+			Fee:       order.Commission,
+			FeeAsset:  pair.Quote.ID,
+			Timestamp: timestamp,
+		})
+	}
+	return result, nil
+}
+
+func (self *Bittrex) DepositStatus(ctx context.Context, id common.ActivityID, timepoint uint64) (string, error) {
 	timestamp := id.Timepoint
 	idParts := strings.Split(id.EID, "|")
 	if len(idParts) != 3 {
@@ -170,7 +236,7 @@ func (self *Bittrex) DepositStatus(id common.ActivityID, timepoint uint64) (stri
 	if err != nil {
 		panic(err)
 	}
-	histories, err := self.interf.DepositHistory(currency, timepoint)
+	histories, err := self.interf.DepositHistory(ctx, currency, timepoint)
 	if err != nil {
 		return "", err
 	} else {
@@ -187,9 +253,9 @@ func (self *Bittrex) DepositStatus(id common.ActivityID, timepoint uint64) (stri
 	}
 }
 
-func (self *Bittrex) CancelOrder(id common.ActivityID) error {
+func (self *Bittrex) CancelOrder(ctx context.Context, id common.ActivityID) error {
 	uuid := id.EID
-	resp, err := self.interf.CancelOrder(uuid, common.GetTimepoint())
+	resp, err := self.interf.CancelOrder(ctx, uuid, common.GetTimepoint())
 	if err != nil {
 		return err
 	} else {
@@ -201,7 +267,7 @@ func (self *Bittrex) CancelOrder(id common.ActivityID) error {
 	}
 }
 
-func (self *Bittrex) WithdrawStatus(id common.ActivityID, timepoint uint64) (string, string, error) {
+func (self *Bittrex) WithdrawStatus(ctx context.Context, id common.ActivityID, timepoint uint64) (string, string, error) {
 	idParts := strings.Split(id.EID, "|")
 	if len(idParts) != 2 {
 		// here, the exchange id part in id is malformed
@@ -211,7 +277,7 @@ func (self *Bittrex) WithdrawStatus(id common.ActivityID, timepoint uint64) (str
 	}
 	uuid := idParts[0]
 	currency := idParts[1]
-	histories, err := self.interf.WithdrawHistory(currency, timepoint)
+	histories, err := self.interf.WithdrawHistory(ctx, currency, timepoint)
 	if err != nil {
 		return "", "", err
 	} else {
@@ -228,9 +294,9 @@ func (self *Bittrex) WithdrawStatus(id common.ActivityID, timepoint uint64) (str
 	}
 }
 
-func (self *Bittrex) OrderStatus(id common.ActivityID, timepoint uint64) (string, error) {
+func (self *Bittrex) OrderStatus(ctx context.Context, id common.ActivityID, timepoint uint64) (string, error) {
 	uuid := id.EID
-	resp_data, err := self.interf.OrderStatus(uuid, timepoint)
+	resp_data, err := self.interf.OrderStatus(ctx, uuid, timepoint)
 	if err != nil {
 		return "", err
 	} else {
@@ -242,12 +308,12 @@ func (self *Bittrex) OrderStatus(id common.ActivityID, timepoint uint64) (string
 	}
 }
 
-func (self *Bittrex) FetchOnePairData(wq *sync.WaitGroup, pair common.TokenPair, data *sync.Map, timepoint uint64) {
+func (self *Bittrex) FetchOnePairData(ctx context.Context, wq *sync.WaitGroup, pair common.TokenPair, data *sync.Map, timepoint uint64) {
 	defer wq.Done()
 	result := common.ExchangePrice{}
 	result.Timestamp = common.Timestamp(fmt.Sprintf("%d", timepoint))
 	result.Valid = true
-	onePairData, err := self.interf.FetchOnePairData(pair, timepoint)
+	onePairData, err := self.interf.FetchOnePairData(ctx, pair, timepoint)
 	returnTime := common.GetTimestamp()
 	result.ReturnTime = returnTime
 	if err != nil {
@@ -281,13 +347,13 @@ func (self *Bittrex) FetchOnePairData(wq *sync.WaitGroup, pair common.TokenPair,
 	data.Store(pair.PairID(), result)
 }
 
-func (self *Bittrex) FetchPriceData(timepoint uint64) (map[common.TokenPairID]common.ExchangePrice, error) {
+func (self *Bittrex) FetchPriceData(ctx context.Context, timepoint uint64) (map[common.TokenPairID]common.ExchangePrice, error) {
 	wait := sync.WaitGroup{}
 	data := sync.Map{}
 	pairs := self.pairs
 	for _, pair := range pairs {
 		wait.Add(1)
-		go self.FetchOnePairData(&wait, pair, &data, timepoint)
+		go self.FetchOnePairData(ctx, &wait, pair, &data, timepoint)
 	}
 	wait.Wait()
 	result := map[common.TokenPairID]common.ExchangePrice{}
@@ -298,11 +364,11 @@ func (self *Bittrex) FetchPriceData(timepoint uint64) (map[common.TokenPairID]co
 	return result, nil
 }
 
-func (self *Bittrex) FetchEBalanceData(timepoint uint64) (common.EBalanceEntry, error) {
+func (self *Bittrex) FetchEBalanceData(ctx context.Context, timepoint uint64) (common.EBalanceEntry, error) {
 	result := common.EBalanceEntry{}
 	result.Timestamp = common.Timestamp(fmt.Sprintf("%d", timepoint))
 	result.Valid = true
-	resp_data, err := self.interf.GetInfo(timepoint)
+	resp_data, err := self.interf.GetInfo(ctx, timepoint)
 	result.ReturnTime = common.GetTimestamp()
 	if err != nil {
 		result.Valid = false
@@ -343,39 +409,41 @@ func NewBittrex(interf BittrexInterface, storage BittrexStorage) *Bittrex {
 			common.MustCreateTokenPair("PAY", "ETH"),
 			common.MustCreateTokenPair("BAT", "ETH"),
 		},
-		map[string]ethereum.Address{},
+		common.NewExchangeAddresses(),
 		storage,
 		common.NewExchangeInfo(),
-		common.NewExchangeFee(
-			common.TradingFee{
-				"taker": 0.0025,
-				"maker": 0.0025,
-			},
-			common.NewFundingFee(
-				map[string]float32{
-					"ETH": 0.006,
-					"OMG": 0.3,
-					"DGD": 0.034,
-					"CVC": 6,
-					"FUN": 36,
-					"MCO": 0.35,
-					"GNT": 6,
-					"ADX": 2.5,
-					"PAY": 1.5,
-					"BAT": 11,
-				},
-				map[string]float32{
-					"ETH": 0,
-					"OMG": 0,
-					"DGD": 0,
-					"CVC": 0,
-					"FUN": 0,
-					"MCO": 0,
-					"GNT": 0,
-					"ADX": 0,
-					"PAY": 0,
-					"BAT": 0,
+		common.NewExchangeFeeTable(
+			common.NewExchangeFee(
+				common.TradingFee{
+					"taker": 0.0025,
+					"maker": 0.0025,
 				},
+				common.NewFundingFee(
+					map[string]float32{
+						"ETH": 0.006,
+						"OMG": 0.3,
+						"DGD": 0.034,
+						"CVC": 6,
+						"FUN": 36,
+						"MCO": 0.35,
+						"GNT": 6,
+						"ADX": 2.5,
+						"PAY": 1.5,
+						"BAT": 11,
+					},
+					map[string]float32{
+						"ETH": 0,
+						"OMG": 0,
+						"DGD": 0,
+						"CVC": 0,
+						"FUN": 0,
+						"MCO": 0,
+						"GNT": 0,
+						"ADX": 0,
+						"PAY": 0,
+						"BAT": 0,
+					},
+				),
 			),
 		),
 	}
@@ -1,7 +1,9 @@
 package bittrex
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -17,9 +19,18 @@ import (
 	ethereum "github.com/ethereum/go-ethereum/common"
 )
 
+// bittrex doesn't publish its limits, this is sized to the default
+// schedule: one book request per pair every 3s, balances every 2s and
+// order history per pair every minute, with headroom for activity
+// status checks
+var BITTREX_RATE_LIMITS = []common.RateLimit{
+	common.RateLimit{Kind: common.RATE_KIND_REQUEST, Limit: 300, Interval: time.Minute},
+}
+
 type BittrexEndpoint struct {
-	signer Signer
-	interf Interface
+	signer  Signer
+	interf  Interface
+	limiter *common.RateLimiter
 }
 
 func nonce() string {
@@ -52,6 +63,13 @@ func (self *BittrexEndpoint) fillRequest(req *http.Request, signNeeded bool) {
 }
 
 func (self *BittrexEndpoint) GetResponse(
+	url string, params map[string]string, signNeeded bool, timepoint uint64) ([]byte, error) {
+	return self.GetResponseContext(context.Background(), url, params, signNeeded, timepoint)
+}
+
+// GetResponseContext is GetResponse canceled along with ctx
+func (self *BittrexEndpoint) GetResponseContext(
+	ctx context.Context,
 	url string, params map[string]string, signNeeded bool, timepoint uint64) ([]byte, error) {
 	client := &http.Client{
 		Timeout: time.Duration(30 * time.Second),
@@ -64,9 +82,13 @@ func (self *BittrexEndpoint) GetResponse(
 		q.Add(k, v)
 	}
 	req.URL.RawQuery = q.Encode()
+	req = req.WithContext(ctx)
 	self.fillRequest(req, signNeeded)
 	var err error
 	var resp_body []byte
+	if err = self.limiter.Request(ctx, 1); err != nil {
+		return resp_body, err
+	}
 	log.Printf("request to bittrex: %s\n", req.URL)
 	resp, err := client.Do(req)
 	if err != nil {
@@ -94,11 +116,51 @@ func (self *BittrexEndpoint) GetExchangeInfo() (exchange.BittExchangeInfo, error
 	return result, err
 }
 
+func (self *BittrexEndpoint) GetCurrencies() (exchange.Bittcurrencies, error) {
+	result := exchange.Bittcurrencies{}
+	timepoint := common.GetTimepoint()
+	resp_body, err := self.GetResponse(
+		addPath(self.interf.PublicEndpoint(timepoint), "getcurrencies"),
+		map[string]string{},
+		false,
+		timepoint,
+	)
+	if err == nil {
+		err = json.Unmarshal(resp_body, &result)
+		if err == nil && !result.Success {
+			err = errors.New(result.Error)
+		}
+	}
+	return result, err
+}
+
+func (self *BittrexEndpoint) GetDepositAddress(currency string) (exchange.Bittdepositaddress, error) {
+	result := exchange.Bittdepositaddress{}
+	timepoint := common.GetTimepoint()
+	resp_body, err := self.GetResponse(
+		addPath(self.interf.AccountEndpoint(timepoint), "getdepositaddress"),
+		map[string]string{
+			"currency": strings.ToUpper(currency),
+		},
+		true,
+		timepoint,
+	)
+	if err == nil {
+		err = json.Unmarshal(resp_body, &result)
+		if err == nil && !result.Success {
+			err = errors.New(result.Error)
+		}
+	}
+	return result, err
+}
+
 func (self *BittrexEndpoint) FetchOnePairData(
+	ctx context.Context,
 	pair common.TokenPair, timepoint uint64) (exchange.Bittresp, error) {
 
 	data := exchange.Bittresp{}
-	resp_body, err := self.GetResponse(
+	resp_body, err := self.GetResponseContext(
+		ctx,
 		addPath(self.interf.PublicEndpoint(timepoint), "getorderbook"),
 		map[string]string{
 			"market": fmt.Sprintf("%s-%s", pair.Quote.ID, pair.Base.ID),
@@ -117,6 +179,7 @@ func (self *BittrexEndpoint) FetchOnePairData(
 }
 
 func (self *BittrexEndpoint) Trade(
+	ctx context.Context,
 	tradeType string,
 	base, quote common.Token,
 	rate, amount float64,
@@ -134,7 +197,8 @@ func (self *BittrexEndpoint) Trade(
 		"quantity": strconv.FormatFloat(amount, 'f', -1, 64),
 		"rate":     strconv.FormatFloat(rate, 'f', -1, 64),
 	}
-	resp_body, err := self.GetResponse(
+	resp_body, err := self.GetResponseContext(
+		ctx,
 		url, params, true, timepoint)
 
 	if err != nil {
@@ -145,9 +209,10 @@ func (self *BittrexEndpoint) Trade(
 	}
 }
 
-func (self *BittrexEndpoint) OrderStatus(uuid string, timepoint uint64) (exchange.Bitttraderesult, error) {
+func (self *BittrexEndpoint) OrderStatus(ctx context.Context, uuid string, timepoint uint64) (exchange.Bitttraderesult, error) {
 	result := exchange.Bitttraderesult{}
-	resp_body, err := self.GetResponse(
+	resp_body, err := self.GetResponseContext(
+		ctx,
 		addPath(self.interf.AccountEndpoint(timepoint), "getorder"),
 		map[string]string{
 			"uuid": uuid,
@@ -163,9 +228,31 @@ func (self *BittrexEndpoint) OrderStatus(uuid string, timepoint uint64) (exchang
 	}
 }
 
-func (self *BittrexEndpoint) WithdrawHistory(currency string, timepoint uint64) (exchange.Bittwithdrawhistory, error) {
+func (self *BittrexEndpoint) OrderHistory(ctx context.Context, pair common.TokenPair) (exchange.Bittorderhistory, error) {
+	result := exchange.Bittorderhistory{}
+	timepoint := common.GetTimepoint()
+	resp_body, err := self.GetResponseContext(
+		ctx,
+		addPath(self.interf.AccountEndpoint(timepoint), "getorderhistory"),
+		map[string]string{
+			"market": fmt.Sprintf("%s-%s", strings.ToUpper(pair.Quote.ID), strings.ToUpper(pair.Base.ID)),
+		},
+		true,
+		timepoint,
+	)
+	if err == nil {
+		err = json.Unmarshal(resp_body, &result)
+		if err == nil && !result.Success {
+			err = errors.New(result.Error)
+		}
+	}
+	return result, err
+}
+
+func (self *BittrexEndpoint) WithdrawHistory(ctx context.Context, currency string, timepoint uint64) (exchange.Bittwithdrawhistory, error) {
 	result := exchange.Bittwithdrawhistory{}
-	resp_body, err := self.GetResponse(
+	resp_body, err := self.GetResponseContext(
+		ctx,
 		addPath(self.interf.AccountEndpoint(timepoint), "getwithdrawalhistory"),
 		map[string]string{
 			"currency": currency,
@@ -181,9 +268,10 @@ func (self *BittrexEndpoint) WithdrawHistory(currency string, timepoint uint64)
 	}
 }
 
-func (self *BittrexEndpoint) DepositHistory(currency string, timepoint uint64) (exchange.Bittdeposithistory, error) {
+func (self *BittrexEndpoint) DepositHistory(ctx context.Context, currency string, timepoint uint64) (exchange.Bittdeposithistory, error) {
 	result := exchange.Bittdeposithistory{}
-	resp_body, err := self.GetResponse(
+	resp_body, err := self.GetResponseContext(
+		ctx,
 		addPath(self.interf.AccountEndpoint(timepoint), "getdeposithistory"),
 		map[string]string{
 			"currency": currency,
@@ -199,9 +287,10 @@ func (self *BittrexEndpoint) DepositHistory(currency string, timepoint uint64) (
 	}
 }
 
-func (self *BittrexEndpoint) Withdraw(token common.Token, amount *big.Int, address ethereum.Address, timepoint uint64) (exchange.Bittwithdraw, error) {
+func (self *BittrexEndpoint) Withdraw(ctx context.Context, token common.Token, amount *big.Int, address ethereum.Address, timepoint uint64) (exchange.Bittwithdraw, error) {
 	result := exchange.Bittwithdraw{}
-	resp_body, err := self.GetResponse(
+	resp_body, err := self.GetResponseContext(
+		ctx,
 		addPath(self.interf.AccountEndpoint(timepoint), "withdraw"),
 		map[string]string{
 			"currency": strings.ToUpper(token.ID),
@@ -219,9 +308,10 @@ func (self *BittrexEndpoint) Withdraw(token common.Token, amount *big.Int, addre
 	}
 }
 
-func (self *BittrexEndpoint) GetInfo(timepoint uint64) (exchange.Bittinfo, error) {
+func (self *BittrexEndpoint) GetInfo(ctx context.Context, timepoint uint64) (exchange.Bittinfo, error) {
 	result := exchange.Bittinfo{}
-	resp_body, err := self.GetResponse(
+	resp_body, err := self.GetResponseContext(
+		ctx,
 		addPath(self.interf.AccountEndpoint(timepoint), "getbalances"),
 		map[string]string{},
 		true,
@@ -235,9 +325,10 @@ func (self *BittrexEndpoint) GetInfo(timepoint uint64) (exchange.Bittinfo, error
 	}
 }
 
-func (self *BittrexEndpoint) CancelOrder(uuid string, timepoint uint64) (exchange.Bittcancelorder, error) {
+func (self *BittrexEndpoint) CancelOrder(ctx context.Context, uuid string, timepoint uint64) (exchange.Bittcancelorder, error) {
 	result := exchange.Bittcancelorder{}
-	resp_body, err := self.GetResponse(
+	resp_body, err := self.GetResponseContext(
+		ctx,
 		addPath(self.interf.MarketEndpoint(timepoint), "cancel"),
 		map[string]string{
 			"uuid": uuid,
@@ -254,21 +345,21 @@ func (self *BittrexEndpoint) CancelOrder(uuid string, timepoint uint64) (exchang
 }
 
 func NewBittrexEndpoint(signer Signer, interf Interface) *BittrexEndpoint {
-	return &BittrexEndpoint{signer, interf}
+	return &BittrexEndpoint{signer, interf, common.RegisterRateLimiter("bittrex", BITTREX_RATE_LIMITS)}
 }
 
 func NewRealBittrexEndpoint(signer Signer) *BittrexEndpoint {
-	return &BittrexEndpoint{signer, NewRealInterface()}
+	return NewBittrexEndpoint(signer, NewRealInterface())
 }
 
 func NewSimulatedBittrexEndpoint(signer Signer) *BittrexEndpoint {
-	return &BittrexEndpoint{signer, NewSimulatedInterface()}
+	return NewBittrexEndpoint(signer, NewSimulatedInterface())
 }
 
 func NewDevBittrexEndpoint(signer Signer) *BittrexEndpoint {
-	return &BittrexEndpoint{signer, NewDevInterface()}
+	return NewBittrexEndpoint(signer, NewDevInterface())
 }
 
 func NewRopstenBittrexEndpoint(signer Signer) *BittrexEndpoint {
-	return &BittrexEndpoint{signer, NewRopstenInterface()}
+	return NewBittrexEndpoint(signer, NewRopstenInterface())
 }
@@ -0,0 +1,37 @@
+package bittrex
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/exchange"
+)
+
+func TestRateLimitsFitDefaultSchedule(t *testing.T) {
+	common.SupportedTokens = map[string]common.Token{}
+	for _, id := range []string{"ETH", "OMG", "DGD", "CVC", "FUN", "MCO", "GNT", "ADX", "PAY", "BAT"} {
+		common.SupportedTokens[id] = common.Token{ID: id}
+	}
+	pairs := len(exchange.NewBittrex(nil, nil).TokenPairs())
+	schedules := common.DefaultSchedules()
+	minute := uint64(time.Minute / time.Millisecond)
+	perMinute := func(kind string) int {
+		return int(minute / schedules.Schedule("bittrex", kind).Interval)
+	}
+	requests := pairs*perMinute(common.FETCH_ORDERBOOK) +
+		perMinute(common.FETCH_AUTH_DATA) +
+		pairs*perMinute(common.FETCH_TRADE_HISTORY)
+
+	limiter := common.NewRateLimiter(BITTREX_RATE_LIMITS)
+	start := time.Now()
+	for i := 0; i < requests; i++ {
+		if err := limiter.Request(context.Background(), 1); err != nil {
+			t.Fatalf("Expected %d requests a minute to fit, request %d failed: %v", requests, i+1, err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Expected a minute of the default schedule not to wait, it waited %s", elapsed)
+	}
+}
@@ -0,0 +1,38 @@
+package bittrex
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/KyberNetwork/reserve-data/exchange"
+)
+
+func init() {
+	exchange.RegisterExchange("bittrex", NewExchange)
+}
+
+// NewExchange builds bittrex adapter on the endpoint of env
+func NewExchange(env string, signer interface{}, storage interface{}) (exchange.Adapter, error) {
+	bittrexSigner, ok := signer.(Signer)
+	if !ok {
+		return nil, errors.New("Signer doesn't have bittrex keys")
+	}
+	bittrexStorage, ok := storage.(exchange.BittrexStorage)
+	if !ok {
+		return nil, errors.New("Storage doesn't support bittrex deposits")
+	}
+	var endpoint *BittrexEndpoint
+	switch env {
+	case exchange.ENV_MAINNET:
+		endpoint = NewRealBittrexEndpoint(bittrexSigner)
+	case exchange.ENV_ROPSTEN:
+		endpoint = NewRopstenBittrexEndpoint(bittrexSigner)
+	case exchange.ENV_DEV:
+		endpoint = NewDevBittrexEndpoint(bittrexSigner)
+	case exchange.ENV_SIMULATION:
+		endpoint = NewSimulatedBittrexEndpoint(bittrexSigner)
+	default:
+		return nil, errors.New(fmt.Sprintf("Environment %s is not supported", env))
+	}
+	return exchange.NewBittrex(endpoint, bittrexStorage), nil
+}
@@ -109,3 +109,41 @@ type Bittdeposithistory struct {
 		LastUpdated   string
 	} `json:"result"`
 }
+
+type Bittorderhistory struct {
+	Success bool   `json:"success"`
+	Error   string `json:"message"`
+	Result  []struct {
+		OrderUuid         string
+		Exchange          string
+		TimeStamp         string
+		OrderType         string
+		Limit             float64
+		Quantity          float64
+		QuantityRemaining float64
+		Commission        float64
+		Price             float64
+		PricePerUnit      float64
+		Closed            string
+	} `json:"result"`
+}
+
+type Bittdepositaddress struct {
+	Success bool   `json:"success"`
+	Error   string `json:"message"`
+	Result  struct {
+		Currency string `json:"Currency"`
+		Address  string `json:"Address"`
+	} `json:"result"`
+}
+
+type Bittcurrencies struct {
+	Success bool   `json:"success"`
+	Error   string `json:"message"`
+	Result  []struct {
+		Currency        string  `json:"Currency"`
+		MinConfirmation int     `json:"MinConfirmation"`
+		TxFee           float64 `json:"TxFee"`
+		IsActive        bool    `json:"IsActive"`
+	} `json:"result"`
+}
@@ -1,6 +1,7 @@
 package exchange
 
 import (
+	"context"
 	"math/big"
 
 	"github.com/KyberNetwork/reserve-data/common"
@@ -9,29 +10,37 @@ import (
 
 type BittrexInterface interface {
 	FetchOnePairData(
-		pair common.TokenPair, timepoint uint64) (Bittresp, error)
+		ctx context.Context, pair common.TokenPair, timepoint uint64) (Bittresp, error)
 
-	GetInfo(timepoint uint64) (Bittinfo, error)
+	GetInfo(ctx context.Context, timepoint uint64) (Bittinfo, error)
 
 	GetExchangeInfo() (BittExchangeInfo, error)
 
+	GetCurrencies() (Bittcurrencies, error)
+
+	GetDepositAddress(currency string) (Bittdepositaddress, error)
+
 	Withdraw(
+		ctx context.Context,
 		token common.Token,
 		amount *big.Int,
 		address ethereum.Address,
 		timepoint uint64) (Bittwithdraw, error)
 
 	Trade(
+		ctx context.Context,
 		tradeType string,
 		base, quote common.Token,
 		rate, amount float64,
 		timepoint uint64) (Bitttrade, error)
 
-	CancelOrder(uuid string, timepoint uint64) (Bittcancelorder, error)
+	CancelOrder(ctx context.Context, uuid string, timepoint uint64) (Bittcancelorder, error)
+
+	DepositHistory(ctx context.Context, currency string, timepoint uint64) (Bittdeposithistory, error)
 
-	DepositHistory(currency string, timepoint uint64) (Bittdeposithistory, error)
+	WithdrawHistory(ctx context.Context, currency string, timepoint uint64) (Bittwithdrawhistory, error)
 
-	WithdrawHistory(currency string, timepoint uint64) (Bittwithdrawhistory, error)
+	OrderStatus(ctx context.Context, uuid string, timepoint uint64) (Bitttraderesult, error)
 
-	OrderStatus(uuid string, timepoint uint64) (Bitttraderesult, error)
+	OrderHistory(ctx context.Context, pair common.TokenPair) (Bittorderhistory, error)
 }
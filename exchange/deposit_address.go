@@ -0,0 +1,72 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+const DEPOSIT_ADDRESS_UPDATE_INTERVAL time.Duration = 10 * time.Minute
+
+type DepositAddressDiscoverer interface {
+	ID() common.ExchangeID
+	DiscoverDepositAddresses() error
+}
+
+// RunDepositAddressDiscovery asks exchange for its deposit addresses
+// right away then every interval until ctx is done.
+func RunDepositAddressDiscovery(ctx context.Context, exchange DepositAddressDiscoverer, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := exchange.DiscoverDepositAddresses(); err != nil {
+			log.Printf("Discovering deposit addresses of %s failed: %s", exchange.ID(), err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// discoverDepositAddresses verifies addresses of every token configured
+// on the exchange against what the exchange reports. Mismatches are
+// logged loudly and returned together, tokens the exchange couldn't
+// report are left untouched.
+func discoverDepositAddresses(
+	id common.ExchangeID,
+	addresses *common.ExchangeAddresses,
+	getAddress func(token common.Token) (string, error)) error {
+
+	mismatches := []string{}
+	for _, tokenID := range addresses.TokenIDs() {
+		token, err := common.GetToken(tokenID)
+		if err != nil {
+			continue
+		}
+		reported, err := getAddress(token)
+		if err != nil {
+			log.Printf("Getting %s deposit address of %s failed: %s", id, tokenID, err)
+			continue
+		}
+		if !ethereum.IsHexAddress(reported) {
+			log.Printf("%s reported invalid deposit address for %s: %s", id, tokenID, reported)
+			continue
+		}
+		if err := addresses.Verify(tokenID, ethereum.HexToAddress(reported)); err != nil {
+			log.Printf("!!!!!!!!!! %s: %s. Deposits of %s are refused until the config is fixed !!!!!!!!!!", id, err, tokenID)
+			mismatches = append(mismatches, err.Error())
+		}
+	}
+	if len(mismatches) > 0 {
+		return errors.New(fmt.Sprintf("%s: %s", id, strings.Join(mismatches, "; ")))
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package exchange
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+const FEE_UPDATE_INTERVAL time.Duration = time.Hour
+
+type FeeUpdater interface {
+	ID() common.ExchangeID
+	UpdateFees() error
+}
+
+// RunFeeUpdater refreshes fees of exchange right away then every
+// interval until ctx is done. When an update fails, the exchange keeps
+// its previous fees.
+func RunFeeUpdater(ctx context.Context, exchange FeeUpdater, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := exchange.UpdateFees(); err != nil {
+			log.Printf("Updating fees of %s failed: %s", exchange.ID(), err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package exchange
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+type testFeeUpdater struct {
+	updates chan struct{}
+}
+
+func (self *testFeeUpdater) ID() common.ExchangeID {
+	return common.ExchangeID("testfees")
+}
+
+func (self *testFeeUpdater) UpdateFees() error {
+	self.updates <- struct{}{}
+	return errors.New("fees are unavailable")
+}
+
+func TestRunFeeUpdaterUpdatesUntilCanceled(t *testing.T) {
+	updater := &testFeeUpdater{make(chan struct{}, 10)}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		RunFeeUpdater(ctx, updater, time.Hour)
+		close(done)
+	}()
+	select {
+	case <-updater.updates:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected fees to be updated right away")
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected fee updater to stop when canceled")
+	}
+}
+
+// testBinanceFees serves account info and asset details for fee updates
+type testBinanceFees struct {
+	BinanceInterface
+	info       Binainfo
+	infoErr    error
+	details    string
+	detailsErr error
+}
+
+func (self *testBinanceFees) GetInfo(ctx context.Context, timepoint uint64) (Binainfo, error) {
+	return self.info, self.infoErr
+}
+
+func (self *testBinanceFees) GetAssetDetail() (Binaassetdetail, error) {
+	result := Binaassetdetail{}
+	if self.detailsErr != nil {
+		return result, self.detailsErr
+	}
+	err := json.Unmarshal([]byte(self.details), &result)
+	return result, err
+}
+
+func TestBinanceUpdateFeesFallback(t *testing.T) {
+	common.SupportedTokens = map[string]common.Token{
+		"ETH":  common.Token{"ETH", "0x0000000000000000000000000000000000000000", 18},
+		"OMG":  common.Token{"OMG", "0x1795b4560491c941c0635451f07332effe3ee7b3", 18},
+		"KNC":  common.Token{"KNC", "0xdd974d5c2e2928dea5f71b9825b8b646686bd200", 18},
+		"FUN":  common.Token{"FUN", "0x419d0d8bdd9af5e606ae2232ed285aff190e711b", 8},
+		"MCO":  common.Token{"MCO", "0xb63b606ac810a52cca15e44bb630fd42d8d1d83d", 8},
+		"EOS":  common.Token{"EOS", "0x86fa049857e0209aa7d9e616f7eb3b3b78ecfdb0", 18},
+		"LINK": common.Token{"LINK", "0x514910771af9ca656af840dff83e8264ecf986ca", 18},
+	}
+	commissions := Binainfo{MakerCommission: 5, TakerCommission: 20}
+	details := `{"success": true, "assetDetail": {
+		"OMG": {"withdrawFee": 0.5},
+		"XYZ": {"withdrawFee": 9}}}`
+	tests := []struct {
+		name        string
+		interf      *testBinanceFees
+		failed      bool
+		maker       float32
+		taker       float32
+		omgWithdraw float32
+	}{
+		{
+			name:        "account info fails",
+			interf:      &testBinanceFees{infoErr: errors.New("timeout")},
+			failed:      true,
+			maker:       0.001,
+			taker:       0.001,
+			omgWithdraw: 0.3,
+		},
+		{
+			name:        "account info is refused",
+			interf:      &testBinanceFees{info: Binainfo{Code: -2015, Msg: "Invalid API-key"}},
+			failed:      true,
+			maker:       0.001,
+			taker:       0.001,
+			omgWithdraw: 0.3,
+		},
+		{
+			name:        "asset details fail",
+			interf:      &testBinanceFees{info: commissions, detailsErr: errors.New("timeout")},
+			failed:      true,
+			maker:       0.0005,
+			taker:       0.002,
+			omgWithdraw: 0.3,
+		},
+		{
+			name:        "fees are updated",
+			interf:      &testBinanceFees{info: commissions, details: details},
+			maker:       0.0005,
+			taker:       0.002,
+			omgWithdraw: 0.5,
+		},
+	}
+	for _, test := range tests {
+		binance := NewBinance(test.interf)
+		err := binance.UpdateFees()
+		if (err != nil) != test.failed {
+			t.Errorf("%s: expected failure %t, got %v", test.name, test.failed, err)
+		}
+		fees := binance.GetFee()
+		if fees.Trading["maker"] != test.maker || fees.Trading["taker"] != test.taker {
+			t.Errorf("%s: expected trading fees %v/%v, got %v", test.name, test.maker, test.taker, fees.Trading)
+		}
+		if fees.Funding.Withdraw["OMG"] != test.omgWithdraw {
+			t.Errorf("%s: expected OMG withdraw fee %v, got %v", test.name, test.omgWithdraw, fees.Funding.Withdraw["OMG"])
+		}
+		// tokens binance doesn't list keep their hardcoded fees
+		if fees.Funding.Withdraw["KNC"] != 2.0 {
+			t.Errorf("%s: expected KNC to keep withdraw fee 2, got %v", test.name, fees.Funding.Withdraw["KNC"])
+		}
+		if _, found := fees.Funding.Withdraw["XYZ"]; found {
+			t.Errorf("%s: expected unsupported XYZ to have no withdraw fee", test.name)
+		}
+	}
+}
@@ -0,0 +1,383 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+const HUOBI_EPSILON float64 = 0.0000000001 // 10e-10
+
+type Huobi struct {
+	interf       HuobiInterface
+	pairs        []common.TokenPair
+	addresses    *common.ExchangeAddresses
+	exchangeInfo *common.ExchangeInfo
+	fees         *common.ExchangeFeeTable
+}
+
+func (self *Huobi) MarshalText() (text []byte, err error) {
+	return []byte(self.ID()), nil
+}
+
+func (self *Huobi) Address(token common.Token) (ethereum.Address, bool) {
+	return self.addresses.Get(token.ID)
+}
+
+func (self *Huobi) VerifiedAddress(token common.Token) (ethereum.Address, error) {
+	return self.addresses.Verified(token.ID)
+}
+
+func (self *Huobi) UpdateAllDepositAddresses(address string) {
+	self.addresses.UpdateAll(ethereum.HexToAddress(address))
+}
+
+func (self *Huobi) UpdateDepositAddress(token common.Token, address string) {
+	self.addresses.Update(token.ID, ethereum.HexToAddress(address))
+}
+
+// DiscoverDepositAddresses verifies configured deposit addresses against
+// the ones huobi reports for its erc20 chain
+func (self *Huobi) DiscoverDepositAddresses() error {
+	return discoverDepositAddresses(self.ID(), self.addresses, func(token common.Token) (string, error) {
+		result, err := self.interf.GetDepositAddress(token.ID)
+		if err != nil {
+			return "", err
+		}
+		for _, address := range result.Data {
+			if ethereum.IsHexAddress(address.Address) {
+				return address.Address, nil
+			}
+		}
+		return "", errors.New("no ethereum deposit address of " + token.ID)
+	})
+}
+
+func (self *Huobi) UpdatePrecisionLimit(pair common.TokenPair, symbols []HuobiSymbol) {
+	for _, symbol := range symbols {
+		if strings.ToUpper(symbol.Base) == pair.Base.ID && strings.ToUpper(symbol.Quote) == pair.Quote.ID {
+			exchangePrecisionLimit := common.ExchangePrecisionLimit{}
+			//update precision
+			exchangePrecisionLimit.Precision.Amount = symbol.AmountPrecision
+			exchangePrecisionLimit.Precision.Price = symbol.PricePrecision
+			// huobi doesn't publish amount and price limits in its symbol list
+			self.exchangeInfo.Update(pair.PairID(), exchangePrecisionLimit)
+			break
+		}
+	}
+}
+
+func (self *Huobi) UpdatePairsPrecision() {
+	exchangeInfo, err := self.interf.GetExchangeInfo()
+	if err != nil {
+		log.Printf("Get exchange info failed: %s\n", err)
+	} else {
+		symbols := exchangeInfo.Data
+		for _, pair := range self.pairs {
+			self.UpdatePrecisionLimit(pair, symbols)
+		}
+	}
+}
+
+func (self *Huobi) GetInfo() (common.ExchangeInfo, error) {
+	return self.exchangeInfo.Copy(), nil
+}
+
+func (self *Huobi) GetExchangeInfo(pair common.TokenPairID) (common.ExchangePrecisionLimit, error) {
+	data, err := self.exchangeInfo.Get(pair)
+	return data, err
+}
+
+func (self *Huobi) GetFee() common.ExchangeFees {
+	return self.fees.Get()
+}
+
+// UpdateFees is a no-op, huobi doesn't publish its fees through the API
+// so the fallback fees are used
+func (self *Huobi) UpdateFees() error {
+	return nil
+}
+
+func (self *Huobi) ID() common.ExchangeID {
+	return common.ExchangeID("huobi")
+}
+
+func (self *Huobi) TokenPairs() []common.TokenPair {
+	return self.pairs
+}
+
+func (self *Huobi) Name() string {
+	return "huobi"
+}
+
+func (self *Huobi) QueryOrder(ctx context.Context, id uint64, timepoint uint64) (done float64, remaining float64, finished bool, err error) {
+	result, err := self.interf.OrderStatus(ctx, id, timepoint)
+	if err != nil {
+		return 0, 0, false, err
+	} else {
+		done, _ := strconv.ParseFloat(result.Data.FilledAmount, 64)
+		total, _ := strconv.ParseFloat(result.Data.Amount, 64)
+		return done, total - done, total-done < HUOBI_EPSILON, nil
+	}
+}
+
+func (self *Huobi) Trade(ctx context.Context, tradeType string, base common.Token, quote common.Token, rate float64, amount float64, timepoint uint64) (id string, done float64, remaining float64, finished bool, err error) {
+	result, err := self.interf.Trade(ctx, tradeType, base, quote, rate, amount, timepoint)
+	if err != nil {
+		return "", 0, 0, false, err
+	} else {
+		orderID, err := strconv.ParseUint(result.OrderID, 10, 64)
+		if err != nil {
+			return "", 0, 0, false, errors.New("Huobi returned malformed order id: " + result.OrderID)
+		}
+		done, remaining, finished, err := self.QueryOrder(ctx, orderID, timepoint+20)
+		return result.OrderID, done, remaining, finished, err
+	}
+}
+
+func (self *Huobi) Withdraw(ctx context.Context, token common.Token, amount *big.Int, address ethereum.Address, timepoint uint64) (string, error) {
+	result, err := self.interf.Withdraw(ctx, token, amount, address, timepoint)
+	if err != nil {
+		return "", err
+	} else {
+		return strconv.FormatUint(result.ID, 10) + "|" + token.ID, nil
+	}
+}
+
+func (self *Huobi) CancelOrder(ctx context.Context, id common.ActivityID) error {
+	orderID, err := strconv.ParseUint(id.EID, 10, 64)
+	if err != nil {
+		return err
+	}
+	result, err := self.interf.CancelOrder(ctx, orderID)
+	if err != nil {
+		return err
+	}
+	if result.Status != "ok" {
+		return errors.New("Couldn't cancel order id " + id.EID + " err: " + result.Reason)
+	}
+	return nil
+}
+
+func (self *Huobi) FetchOnePairData(
+	ctx context.Context,
+	wg *sync.WaitGroup,
+	pair common.TokenPair,
+	data *sync.Map,
+	timepoint uint64) {
+
+	defer wg.Done()
+	result := common.ExchangePrice{}
+
+	timestamp := common.Timestamp(fmt.Sprintf("%d", timepoint))
+	result.Timestamp = timestamp
+	result.Valid = true
+	resp_data, err := self.interf.GetDepthOnePair(ctx, pair, timepoint)
+	returnTime := common.GetTimestamp()
+	result.ReturnTime = returnTime
+	if err != nil {
+		result.Valid = false
+		result.Error = err.Error()
+	} else {
+		if resp_data.Status != "ok" {
+			result.Valid = false
+			result.Error = fmt.Sprintf("Status: %s, Msg: %s", resp_data.Status, resp_data.Reason)
+		} else {
+			for _, buy := range resp_data.Tick.Bids {
+				result.Bids = append(
+					result.Bids,
+					common.PriceEntry{
+						Quantity: buy[1],
+						Rate:     buy[0],
+					},
+				)
+			}
+			for _, sell := range resp_data.Tick.Asks {
+				result.Asks = append(
+					result.Asks,
+					common.PriceEntry{
+						Quantity: sell[1],
+						Rate:     sell[0],
+					},
+				)
+			}
+		}
+	}
+	data.Store(pair.PairID(), result)
+}
+
+func (self *Huobi) FetchPriceData(ctx context.Context, timepoint uint64) (map[common.TokenPairID]common.ExchangePrice, error) {
+	wait := sync.WaitGroup{}
+	data := sync.Map{}
+	pairs := self.pairs
+	for _, pair := range pairs {
+		wait.Add(1)
+		go self.FetchOnePairData(ctx, &wait, pair, &data, timepoint)
+	}
+	wait.Wait()
+	result := map[common.TokenPairID]common.ExchangePrice{}
+	data.Range(func(key, value interface{}) bool {
+		result[key.(common.TokenPairID)] = value.(common.ExchangePrice)
+		return true
+	})
+	return result, nil
+}
+
+func (self *Huobi) FetchEBalanceData(ctx context.Context, timepoint uint64) (common.EBalanceEntry, error) {
+	result := common.EBalanceEntry{}
+	result.Timestamp = common.Timestamp(fmt.Sprintf("%d", timepoint))
+	result.Valid = true
+	resp_data, err := self.interf.GetInfo(ctx, timepoint)
+	result.ReturnTime = common.GetTimestamp()
+	if err != nil {
+		result.Valid = false
+		result.Error = err.Error()
+	} else {
+		result.AvailableBalance = map[string]float64{}
+		result.LockedBalance = map[string]float64{}
+		result.DepositBalance = map[string]float64{}
+		if resp_data.Status != "ok" {
+			result.Valid = false
+			result.Error = fmt.Sprintf("Status: %s, Msg: %s", resp_data.Status, resp_data.Reason)
+		} else {
+			for _, b := range resp_data.Data.List {
+				tokenID := strings.ToUpper(b.Currency)
+				_, exist := common.SupportedTokens[tokenID]
+				if exist {
+					balance, _ := strconv.ParseFloat(b.Balance, 64)
+					if b.Type == "trade" {
+						result.AvailableBalance[tokenID] += balance
+					} else {
+						result.LockedBalance[tokenID] += balance
+					}
+					result.DepositBalance[tokenID] = 0
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+func (self *Huobi) DepositStatus(ctx context.Context, id common.ActivityID, timepoint uint64) (string, error) {
+	idParts := strings.Split(id.EID, "|")
+	if len(idParts) != 3 {
+		// here, the exchange id part in id is malformed
+		// 1. because analytic didn't pass original ID
+		// 2. id is not constructed correctly in a form of uuid + "|" + token + "|" + amount
+		return "", errors.New("Invalid deposit id")
+	}
+	txID := ethereum.HexToHash(idParts[0])
+	currency := idParts[1]
+	deposits, err := self.interf.DepositHistory(ctx, currency, timepoint)
+	if err != nil {
+		return "", err
+	} else {
+		for _, deposit := range deposits.Deposits {
+			if ethereum.HexToHash(deposit.TxHash) == txID {
+				if deposit.State == "confirmed" || deposit.State == "safe" {
+					return "done", nil
+				} else if deposit.State == "orphan" {
+					return "failed", nil
+				} else {
+					return "", nil
+				}
+			}
+		}
+		// huobi only lists a deposit after it sees the tx on chain
+		return "", nil
+	}
+}
+
+func (self *Huobi) WithdrawStatus(ctx context.Context, id common.ActivityID, timepoint uint64) (string, string, error) {
+	idParts := strings.Split(id.EID, "|")
+	if len(idParts) != 2 {
+		// here, the exchange id part in id is malformed
+		// 1. because analytic didn't pass original ID
+		// 2. id is not constructed correctly in a form of id + "|" + token
+		return "", "", errors.New("Invalid withdraw id")
+	}
+	withdrawID, err := strconv.ParseUint(idParts[0], 10, 64)
+	if err != nil {
+		return "", "", errors.New("Invalid withdraw id")
+	}
+	currency := idParts[1]
+	withdraws, err := self.interf.WithdrawHistory(ctx, currency, timepoint)
+	if err != nil {
+		return "", "", err
+	} else {
+		for _, withdraw := range withdraws.Withdraws {
+			if withdraw.ID == withdrawID {
+				switch withdraw.State {
+				case "confirmed":
+					return "done", withdraw.TxHash, nil
+				case "canceled", "reject", "wallet-reject", "confirm-error", "repealed":
+					return "failed", withdraw.TxHash, nil
+				default:
+					return "", withdraw.TxHash, nil
+				}
+			}
+		}
+		return "", "", errors.New("Withdraw with id " + idParts[0] + " of currency " + currency + " is not found on huobi")
+	}
+}
+
+func (self *Huobi) OrderStatus(ctx context.Context, id common.ActivityID, timepoint uint64) (string, error) {
+	orderID, err := strconv.ParseUint(id.EID, 10, 64)
+	if err != nil {
+		// core put malformed activity ID
+		return "", errors.New("Invalid order id: " + id.EID)
+	}
+	order, err := self.interf.OrderStatus(ctx, orderID, timepoint)
+	if err != nil {
+		return "", err
+	}
+	switch order.Data.State {
+	case "pre-submitted", "submitting", "submitted", "partial-filled":
+		return "", nil
+	default:
+		return "done", nil
+	}
+}
+
+func NewHuobi(interf HuobiInterface) *Huobi {
+	return &Huobi{
+		interf,
+		[]common.TokenPair{
+			common.MustCreateTokenPair("OMG", "ETH"),
+			common.MustCreateTokenPair("EOS", "ETH"),
+			common.MustCreateTokenPair("KNC", "ETH"),
+		},
+		common.NewExchangeAddresses(),
+		common.NewExchangeInfo(),
+		common.NewExchangeFeeTable(
+			common.NewExchangeFee(
+				common.TradingFee{
+					"taker": 0.002,
+					"maker": 0.002,
+				},
+				common.NewFundingFee(
+					map[string]float32{
+						"ETH": 0.01,
+						"OMG": 0.1,
+						"EOS": 0.5,
+						"KNC": 1.0,
+					},
+					map[string]float32{
+						"ETH": 0,
+						"OMG": 0,
+						"EOS": 0,
+						"KNC": 0,
+					},
+				),
+			),
+		),
+	}
+}
@@ -0,0 +1,406 @@
+package huobi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/exchange"
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+// huobi allows 100 requests per 10 seconds per key
+var HUOBI_RATE_LIMITS = []common.RateLimit{
+	common.RateLimit{Kind: common.RATE_KIND_REQUEST, Limit: 100, Interval: 10 * time.Second},
+}
+
+type HuobiEndpoint struct {
+	signer    Signer
+	interf    Interface
+	limiter   *common.RateLimiter
+	mu        sync.Mutex
+	accountID uint64
+}
+
+func (self *HuobiEndpoint) fillRequest(req *http.Request, signNeeded bool, timepoint uint64) {
+	if req.Method == "POST" || req.Method == "PUT" || req.Method == "DELETE" {
+		req.Header.Add("Content-Type", "application/json")
+	} else {
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	}
+	req.Header.Add("Accept", "application/json")
+	if signNeeded {
+		q := req.URL.Query()
+		q.Set("AccessKeyId", self.signer.GetHuobiKey())
+		q.Set("SignatureMethod", "HmacSHA256")
+		q.Set("SignatureVersion", "2")
+		q.Set("Timestamp", common.TimepointToTime(timepoint).UTC().Format("2006-01-02T15:04:05"))
+		// huobi signs method, host, path and the sorted query string,
+		// each on its own line
+		payload := strings.Join([]string{
+			req.Method,
+			req.URL.Host,
+			req.URL.Path,
+			q.Encode(),
+		}, "\n")
+		q.Set("Signature", self.signer.HuobiSign(payload))
+		req.URL.RawQuery = q.Encode()
+	}
+}
+
+func (self *HuobiEndpoint) GetResponse(
+	method string, url string,
+	params map[string]string, signNeeded bool, timepoint uint64) ([]byte, error) {
+	return self.GetResponseContext(context.Background(), method, url, params, signNeeded, timepoint)
+}
+
+// GetResponseContext is GetResponse canceled along with ctx
+func (self *HuobiEndpoint) GetResponseContext(
+	ctx context.Context,
+	method string, url string,
+	params map[string]string, signNeeded bool, timepoint uint64) ([]byte, error) {
+
+	client := &http.Client{
+		Timeout: time.Duration(30 * time.Second),
+	}
+	var req *http.Request
+	if method == "POST" {
+		// huobi expects POST params as a json body
+		body, _ := json.Marshal(params)
+		req, _ = http.NewRequest(method, url, bytes.NewBuffer(body))
+	} else {
+		req, _ = http.NewRequest(method, url, nil)
+		q := req.URL.Query()
+		for k, v := range params {
+			q.Add(k, v)
+		}
+		req.URL.RawQuery = q.Encode()
+	}
+	req = req.WithContext(ctx)
+	self.fillRequest(req, signNeeded, timepoint)
+	var err error
+	var resp_body []byte
+	if strings.HasSuffix(req.URL.Path, "/v1/order/orders/place") {
+		err = self.limiter.Order(ctx, 1)
+	} else {
+		err = self.limiter.Request(ctx, 1)
+	}
+	if err != nil {
+		return resp_body, err
+	}
+	log.Printf("request to huobi: %s\n", req.URL)
+	resp, err := client.Do(req)
+	if err != nil {
+		return resp_body, err
+	} else {
+		defer resp.Body.Close()
+		resp_body, err = ioutil.ReadAll(resp.Body)
+		log.Printf("request to %s, got response from huobi: %s\n", req.URL, common.TruncStr(resp_body))
+		return resp_body, err
+	}
+}
+
+// getAccountID returns the id of the spot account that is used to
+// trade and hold balances. It is looked up once and cached.
+func (self *HuobiEndpoint) getAccountID() (uint64, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if self.accountID != 0 {
+		return self.accountID, nil
+	}
+	accounts, err := self.GetAccounts()
+	if err != nil {
+		return 0, err
+	}
+	for _, account := range accounts.Data {
+		if account.Type == "spot" {
+			self.accountID = account.ID
+			return self.accountID, nil
+		}
+	}
+	return 0, errors.New("Huobi spot account is not found")
+}
+
+func (self *HuobiEndpoint) GetAccounts() (exchange.HuobiAccounts, error) {
+	result := exchange.HuobiAccounts{}
+	resp_body, err := self.GetResponse(
+		"GET",
+		self.interf.AuthenticatedEndpoint()+"/v1/account/accounts",
+		map[string]string{},
+		true,
+		common.GetTimepoint(),
+	)
+	if err == nil {
+		err = json.Unmarshal(resp_body, &result)
+		if err == nil && result.Status != "ok" {
+			err = errors.New("Getting accounts from Huobi failed: " + result.Reason)
+		}
+	}
+	return result, err
+}
+
+func (self *HuobiEndpoint) GetDepthOnePair(
+	ctx context.Context,
+	pair common.TokenPair, timepoint uint64) (exchange.HuobiDepth, error) {
+
+	resp_body, err := self.GetResponseContext(
+		ctx,
+		"GET", self.interf.PublicEndpoint()+"/market/depth",
+		map[string]string{
+			"symbol": strings.ToLower(pair.Base.ID + pair.Quote.ID),
+			"type":   "step0",
+		},
+		false,
+		timepoint,
+	)
+
+	resp_data := exchange.HuobiDepth{}
+	if err != nil {
+		return resp_data, err
+	} else {
+		err = json.Unmarshal(resp_body, &resp_data)
+		return resp_data, err
+	}
+}
+
+// Relevant params:
+// account-id
+// symbol ("%s%s", base, quote) in lower case
+// type (buy-limit/sell-limit)
+// amount
+// price
+//
+// In this version, we only support limit order
+func (self *HuobiEndpoint) Trade(ctx context.Context, tradeType string, base, quote common.Token, rate, amount float64, timepoint uint64) (exchange.HuobiTrade, error) {
+	result := exchange.HuobiTrade{}
+	accountID, err := self.getAccountID()
+	if err != nil {
+		return result, err
+	}
+	params := map[string]string{
+		"account-id": strconv.FormatUint(accountID, 10),
+		"symbol":     strings.ToLower(base.ID + quote.ID),
+		"source":     "api",
+		"type":       strings.ToLower(tradeType) + "-limit",
+		"amount":     strconv.FormatFloat(amount, 'f', -1, 64),
+		"price":      strconv.FormatFloat(rate, 'f', -1, 64),
+	}
+	resp_body, err := self.GetResponseContext(
+		ctx,
+		"POST",
+		self.interf.AuthenticatedEndpoint()+"/v1/order/orders/place",
+		params,
+		true,
+		timepoint,
+	)
+	if err == nil {
+		err = json.Unmarshal(resp_body, &result)
+		if err == nil && result.Status != "ok" {
+			err = errors.New("Trade rejected by Huobi: " + result.Reason)
+		}
+	}
+	return result, err
+}
+
+func (self *HuobiEndpoint) WithdrawHistory(ctx context.Context, currency string, timepoint uint64) (exchange.HuobiWithdraws, error) {
+	result := exchange.HuobiWithdraws{}
+	resp_body, err := self.GetResponseContext(
+		ctx,
+		"GET",
+		self.interf.AuthenticatedEndpoint()+"/v1/query/deposit-withdraw",
+		map[string]string{
+			"currency": strings.ToLower(currency),
+			"type":     "withdraw",
+			"from":     "0",
+			"size":     "100",
+		},
+		true,
+		common.GetTimepoint(),
+	)
+	if err == nil {
+		err = json.Unmarshal(resp_body, &result)
+		if err == nil && result.Status != "ok" {
+			err = errors.New("Getting withdraw history from Huobi failed: " + result.Reason)
+		}
+	}
+	return result, err
+}
+
+func (self *HuobiEndpoint) DepositHistory(ctx context.Context, currency string, timepoint uint64) (exchange.HuobiDeposits, error) {
+	result := exchange.HuobiDeposits{}
+	resp_body, err := self.GetResponseContext(
+		ctx,
+		"GET",
+		self.interf.AuthenticatedEndpoint()+"/v1/query/deposit-withdraw",
+		map[string]string{
+			"currency": strings.ToLower(currency),
+			"type":     "deposit",
+			"from":     "0",
+			"size":     "100",
+		},
+		true,
+		common.GetTimepoint(),
+	)
+	if err == nil {
+		err = json.Unmarshal(resp_body, &result)
+		if err == nil && result.Status != "ok" {
+			err = errors.New("Getting deposit history from Huobi failed: " + result.Reason)
+		}
+	}
+	return result, err
+}
+
+func (self *HuobiEndpoint) CancelOrder(ctx context.Context, id uint64) (exchange.HuobiCancel, error) {
+	result := exchange.HuobiCancel{}
+	resp_body, err := self.GetResponseContext(
+		ctx,
+		"POST",
+		self.interf.AuthenticatedEndpoint()+fmt.Sprintf("/v1/order/orders/%d/submitcancel", id),
+		map[string]string{},
+		true,
+		common.GetTimepoint(),
+	)
+	if err == nil {
+		err = json.Unmarshal(resp_body, &result)
+		if err == nil && result.Status != "ok" {
+			err = errors.New("Canceling order from Huobi failed: " + result.Reason)
+		}
+	}
+	return result, err
+}
+
+func (self *HuobiEndpoint) OrderStatus(ctx context.Context, id uint64, timepoint uint64) (exchange.HuobiOrder, error) {
+	result := exchange.HuobiOrder{}
+	resp_body, err := self.GetResponseContext(
+		ctx,
+		"GET",
+		self.interf.AuthenticatedEndpoint()+fmt.Sprintf("/v1/order/orders/%d", id),
+		map[string]string{},
+		true,
+		timepoint,
+	)
+	if err == nil {
+		err = json.Unmarshal(resp_body, &result)
+		if err == nil && result.Status != "ok" {
+			err = errors.New(result.Reason)
+		}
+	}
+	return result, err
+}
+
+func (self *HuobiEndpoint) Withdraw(ctx context.Context, token common.Token, amount *big.Int, address ethereum.Address, timepoint uint64) (exchange.HuobiWithdraw, error) {
+	result := exchange.HuobiWithdraw{}
+	resp_body, err := self.GetResponseContext(
+		ctx,
+		"POST",
+		self.interf.AuthenticatedEndpoint()+"/v1/dw/withdraw/api/create",
+		map[string]string{
+			"address":  address.Hex(),
+			"amount":   strconv.FormatFloat(common.BigToFloat(amount, token.Decimal), 'f', -1, 64),
+			"currency": strings.ToLower(token.ID),
+		},
+		true,
+		timepoint,
+	)
+	if err == nil {
+		err = json.Unmarshal(resp_body, &result)
+		if err == nil && result.Status != "ok" {
+			err = errors.New(result.Reason)
+		}
+		return result, err
+	} else {
+		log.Printf("Error: %v", err)
+		return result, errors.New("withdraw rejected by Huobi")
+	}
+}
+
+func (self *HuobiEndpoint) GetInfo(ctx context.Context, timepoint uint64) (exchange.HuobiInfo, error) {
+	result := exchange.HuobiInfo{}
+	accountID, err := self.getAccountID()
+	if err != nil {
+		return result, err
+	}
+	resp_body, err := self.GetResponseContext(
+		ctx,
+		"GET",
+		self.interf.AuthenticatedEndpoint()+fmt.Sprintf("/v1/account/accounts/%d/balance", accountID),
+		map[string]string{},
+		true,
+		timepoint,
+	)
+	if err == nil {
+		err = json.Unmarshal(resp_body, &result)
+	}
+	return result, err
+}
+
+func (self *HuobiEndpoint) GetExchangeInfo() (exchange.HuobiExchangeInfo, error) {
+	result := exchange.HuobiExchangeInfo{}
+	timepoint := common.GetTimepoint()
+	resp_body, err := self.GetResponse(
+		"GET",
+		self.interf.PublicEndpoint()+"/v1/common/symbols",
+		map[string]string{},
+		false,
+		timepoint,
+	)
+	if err == nil {
+		err = json.Unmarshal(resp_body, &result)
+	}
+	return result, err
+}
+
+func (self *HuobiEndpoint) GetDepositAddress(currency string) (exchange.HuobiDepositAddress, error) {
+	result := exchange.HuobiDepositAddress{}
+	resp_body, err := self.GetResponse(
+		"GET",
+		self.interf.AuthenticatedEndpoint()+"/v2/account/deposit/address",
+		map[string]string{
+			"currency": strings.ToLower(currency),
+		},
+		true,
+		common.GetTimepoint(),
+	)
+	if err == nil {
+		err = json.Unmarshal(resp_body, &result)
+		if err == nil && result.Code != 200 {
+			err = errors.New("Getting deposit address from Huobi failed: " + result.Message)
+		}
+	}
+	return result, err
+}
+
+func NewHuobiEndpoint(signer Signer, interf Interface) *HuobiEndpoint {
+	return &HuobiEndpoint{
+		signer:  signer,
+		interf:  interf,
+		limiter: common.RegisterRateLimiter("huobi", HUOBI_RATE_LIMITS),
+	}
+}
+
+func NewRealHuobiEndpoint(signer Signer) *HuobiEndpoint {
+	return NewHuobiEndpoint(signer, NewRealInterface())
+}
+
+func NewSimulatedHuobiEndpoint(signer Signer) *HuobiEndpoint {
+	return NewHuobiEndpoint(signer, NewSimulatedInterface())
+}
+
+func NewRopstenHuobiEndpoint(signer Signer) *HuobiEndpoint {
+	return NewHuobiEndpoint(signer, NewRopstenInterface())
+}
+
+func NewDevHuobiEndpoint(signer Signer) *HuobiEndpoint {
+	return NewHuobiEndpoint(signer, NewDevInterface())
+}
@@ -0,0 +1,233 @@
+package huobi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/KyberNetwork/reserve-data/signer"
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+// 2017-05-11T15:19:30 UTC
+const testTimepoint uint64 = 1494515970000
+
+type testInterface struct {
+	url string
+}
+
+func (self *testInterface) PublicEndpoint() string {
+	return self.url
+}
+
+func (self *testInterface) AuthenticatedEndpoint() string {
+	return self.url
+}
+
+var testSigner = signer.FileSigner{
+	HuobiKey:    "testkey",
+	HuobiSecret: "testsecret",
+}
+
+// newTestEndpoint serves every request with the handler registered for
+// its path and checks the signature of authenticated requests
+func newTestEndpoint(t *testing.T, handlers map[string]http.HandlerFunc) (*HuobiEndpoint, func()) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if signature := q.Get("Signature"); signature != "" {
+			q.Del("Signature")
+			payload := strings.Join([]string{r.Method, r.Host, r.URL.Path, q.Encode()}, "\n")
+			if signature != testSigner.HuobiSign(payload) {
+				t.Errorf("Expected %s %s to be signed over %q", r.Method, r.URL.Path, payload)
+			}
+			if q.Get("AccessKeyId") != "testkey" || q.Get("SignatureMethod") != "HmacSHA256" || q.Get("SignatureVersion") != "2" {
+				t.Errorf("Expected signing params in %s, got %s", r.URL.Path, r.URL.RawQuery)
+			}
+		}
+		handler, found := handlers[r.URL.Path]
+		if !found {
+			t.Errorf("Unexpected request to %s", r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		handler(w, r)
+	}))
+	endpoint := NewHuobiEndpoint(testSigner, &testInterface{server.URL})
+	return endpoint, server.Close
+}
+
+func respond(body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, body)
+	}
+}
+
+func TestOrderStatus(t *testing.T) {
+	endpoint, stop := newTestEndpoint(t, map[string]http.HandlerFunc{
+		"/v1/order/orders/59378": func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != "GET" {
+				t.Errorf("Expected GET, got %s", r.Method)
+			}
+			if timestamp := r.URL.Query().Get("Timestamp"); timestamp != "2017-05-11T15:19:30" {
+				t.Errorf("Expected timestamp of the timepoint, got %s", timestamp)
+			}
+			fmt.Fprint(w, `{"status": "ok", "data": {
+				"id": 59378,
+				"symbol": "knceth",
+				"account-id": 100009,
+				"amount": "10.1000000000",
+				"price": "0.0021000000",
+				"created-at": 1494901162595,
+				"type": "buy-limit",
+				"field-amount": "4.5000000000",
+				"field-cash-amount": "0.0094500000",
+				"field-fees": "0.0090000000",
+				"state": "partial-filled"}}`)
+		},
+	})
+	defer stop()
+
+	order, err := endpoint.OrderStatus(context.Background(), 59378, testTimepoint)
+	if err != nil {
+		t.Fatalf("Expected order status, got %v", err)
+	}
+	if order.Data.OrderID != 59378 || order.Data.State != "partial-filled" {
+		t.Errorf("Expected partially filled order 59378, got %+v", order.Data)
+	}
+	if order.Data.Amount != "10.1000000000" || order.Data.FilledAmount != "4.5000000000" {
+		t.Errorf("Expected 4.5 of 10.1 filled, got %s of %s", order.Data.FilledAmount, order.Data.Amount)
+	}
+}
+
+func TestTrade(t *testing.T) {
+	accounts := 0
+	reject := false
+	endpoint, stop := newTestEndpoint(t, map[string]http.HandlerFunc{
+		"/v1/account/accounts": func(w http.ResponseWriter, r *http.Request) {
+			accounts++
+			fmt.Fprint(w, `{"status": "ok", "data": [
+				{"id": 100001, "type": "otc", "state": "working"},
+				{"id": 100009, "type": "spot", "state": "working"}]}`)
+		},
+		"/v1/order/orders/place": func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != "POST" {
+				t.Errorf("Expected POST, got %s", r.Method)
+			}
+			params := map[string]string{}
+			if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+				t.Errorf("Expected a json body, got %v", err)
+			}
+			expected := map[string]string{
+				"account-id": "100009",
+				"symbol":     "knceth",
+				"source":     "api",
+				"type":       "buy-limit",
+				"amount":     "10.1",
+				"price":      "0.0021",
+			}
+			for k, v := range expected {
+				if params[k] != v {
+					t.Errorf("Expected %s to be %s, got %s", k, v, params[k])
+				}
+			}
+			if reject {
+				fmt.Fprint(w, `{"status": "error", "err-code": "order-value-min-error", "err-msg": "order value is too small"}`)
+				return
+			}
+			fmt.Fprint(w, `{"status": "ok", "data": "59378"}`)
+		},
+	})
+	defer stop()
+
+	knc := common.Token{ID: "KNC", Decimal: 18}
+	eth := common.Token{ID: "ETH", Decimal: 18}
+	result, err := endpoint.Trade(context.Background(), "buy", knc, eth, 0.0021, 10.1, testTimepoint)
+	if err != nil {
+		t.Fatalf("Expected order to be placed, got %v", err)
+	}
+	if result.OrderID != "59378" {
+		t.Errorf("Expected order id 59378, got %s", result.OrderID)
+	}
+	reject = true
+	_, err = endpoint.Trade(context.Background(), "buy", knc, eth, 0.0021, 10.1, testTimepoint)
+	if err == nil || !strings.Contains(err.Error(), "order value is too small") {
+		t.Errorf("Expected rejected order to fail with huobi's reason, got %v", err)
+	}
+	if accounts != 1 {
+		t.Errorf("Expected spot account to be looked up once, it was looked up %d times", accounts)
+	}
+}
+
+func TestMalformedResponsesFail(t *testing.T) {
+	malformed := respond(`<html>502 Bad Gateway</html>`)
+	endpoint, stop := newTestEndpoint(t, map[string]http.HandlerFunc{
+		"/v1/account/accounts":                respond(`{"status": "ok", "data": [{"id": 100009, "type": "spot"}]}`),
+		"/market/depth":                       malformed,
+		"/v1/order/orders/place":              malformed,
+		"/v1/query/deposit-withdraw":          malformed,
+		"/v1/order/orders/59378/submitcancel": malformed,
+		"/v1/order/orders/59378":              malformed,
+		"/v1/dw/withdraw/api/create":          malformed,
+		"/v1/account/accounts/100009/balance": malformed,
+		"/v1/common/symbols":                  malformed,
+		"/v2/account/deposit/address":         malformed,
+	})
+	defer stop()
+
+	ctx := context.Background()
+	knc := common.Token{ID: "KNC", Decimal: 18}
+	eth := common.Token{ID: "ETH", Decimal: 18}
+	calls := map[string]func() error{
+		"GetDepthOnePair": func() error {
+			_, err := endpoint.GetDepthOnePair(ctx, common.TokenPair{Base: knc, Quote: eth}, testTimepoint)
+			return err
+		},
+		"Trade": func() error {
+			_, err := endpoint.Trade(ctx, "sell", knc, eth, 0.0021, 10, testTimepoint)
+			return err
+		},
+		"WithdrawHistory": func() error {
+			_, err := endpoint.WithdrawHistory(ctx, "KNC", testTimepoint)
+			return err
+		},
+		"DepositHistory": func() error {
+			_, err := endpoint.DepositHistory(ctx, "KNC", testTimepoint)
+			return err
+		},
+		"CancelOrder": func() error {
+			_, err := endpoint.CancelOrder(ctx, 59378)
+			return err
+		},
+		"OrderStatus": func() error {
+			_, err := endpoint.OrderStatus(ctx, 59378, testTimepoint)
+			return err
+		},
+		"Withdraw": func() error {
+			_, err := endpoint.Withdraw(ctx, knc, big.NewInt(1000), ethereum.Address{}, testTimepoint)
+			return err
+		},
+		"GetInfo": func() error {
+			_, err := endpoint.GetInfo(ctx, testTimepoint)
+			return err
+		},
+		"GetExchangeInfo": func() error {
+			_, err := endpoint.GetExchangeInfo()
+			return err
+		},
+		"GetDepositAddress": func() error {
+			_, err := endpoint.GetDepositAddress("KNC")
+			return err
+		},
+	}
+	for name, call := range calls {
+		if err := call(); err == nil {
+			t.Errorf("%s: expected malformed response to fail", name)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package huobi
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/KyberNetwork/reserve-data/exchange"
+)
+
+func init() {
+	exchange.RegisterExchange("huobi", NewExchange)
+}
+
+// NewExchange builds huobi adapter on the endpoint of env
+func NewExchange(env string, signer interface{}, storage interface{}) (exchange.Adapter, error) {
+	huobiSigner, ok := signer.(Signer)
+	if !ok {
+		return nil, errors.New("Signer doesn't have huobi keys")
+	}
+	var endpoint *HuobiEndpoint
+	switch env {
+	case exchange.ENV_MAINNET:
+		endpoint = NewRealHuobiEndpoint(huobiSigner)
+	case exchange.ENV_ROPSTEN:
+		endpoint = NewRopstenHuobiEndpoint(huobiSigner)
+	case exchange.ENV_DEV:
+		endpoint = NewDevHuobiEndpoint(huobiSigner)
+	case exchange.ENV_SIMULATION:
+		endpoint = NewSimulatedHuobiEndpoint(huobiSigner)
+	default:
+		return nil, errors.New(fmt.Sprintf("Environment %s is not supported", env))
+	}
+	return exchange.NewHuobi(endpoint), nil
+}
@@ -0,0 +1,6 @@
+package huobi
+
+type Signer interface {
+	GetHuobiKey() string
+	HuobiSign(msg string) string
+}
@@ -0,0 +1,140 @@
+package exchange
+
+type HuobiDepth struct {
+	Status string `json:"status"`
+	Tick   struct {
+		Bids [][]float64 `json:"bids"`
+		Asks [][]float64 `json:"asks"`
+	} `json:"tick"`
+	Reason string `json:"err-msg"`
+}
+
+type HuobiSymbol struct {
+	Base            string `json:"base-currency"`
+	Quote           string `json:"quote-currency"`
+	PricePrecision  int    `json:"price-precision"`
+	AmountPrecision int    `json:"amount-precision"`
+}
+
+type HuobiExchangeInfo struct {
+	Status string        `json:"status"`
+	Data   []HuobiSymbol `json:"data"`
+	Reason string        `json:"err-msg"`
+}
+
+type HuobiAccounts struct {
+	Status string `json:"status"`
+	Data   []struct {
+		ID    uint64 `json:"id"`
+		Type  string `json:"type"`
+		State string `json:"state"`
+	} `json:"data"`
+	Reason string `json:"err-msg"`
+}
+
+type HuobiInfo struct {
+	Status string `json:"status"`
+	Data   struct {
+		ID    uint64 `json:"id"`
+		Type  string `json:"type"`
+		State string `json:"state"`
+		List  []struct {
+			Currency string `json:"currency"`
+			Type     string `json:"type"`
+			Balance  string `json:"balance"`
+		} `json:"list"`
+	} `json:"data"`
+	Reason string `json:"err-msg"`
+}
+
+type HuobiTrade struct {
+	Status  string `json:"status"`
+	OrderID string `json:"data"`
+	Reason  string `json:"err-msg"`
+}
+
+type HuobiCancel struct {
+	Status  string `json:"status"`
+	OrderID string `json:"data"`
+	Reason  string `json:"err-msg"`
+}
+
+type HuobiOrder struct {
+	Status string `json:"status"`
+	Data   struct {
+		OrderID      uint64 `json:"id"`
+		Symbol       string `json:"symbol"`
+		AccountID    uint64 `json:"account-id"`
+		Amount       string `json:"amount"`
+		Price        string `json:"price"`
+		CreatedAt    uint64 `json:"created-at"`
+		Type         string `json:"type"`
+		FilledAmount string `json:"field-amount"`
+		FilledCash   string `json:"field-cash-amount"`
+		FilledFees   string `json:"field-fees"`
+		State        string `json:"state"`
+	} `json:"data"`
+	Reason string `json:"err-msg"`
+}
+
+type HuobiWithdraw struct {
+	Status string `json:"status"`
+	ID     uint64 `json:"data"`
+	Reason string `json:"err-msg"`
+}
+
+//	{
+//		"status": "ok",
+//		"data": [
+//			{
+//				"id": 1171,
+//				"type": "deposit",
+//				"currency": "eth",
+//				"tx-hash": "ed03094b84eafbe4bc16e7ef766ee959885ee5bcb265872baaa9c64e1cf86c2b",
+//				"amount": 7.457467,
+//				"address": "rae93V8d2mdoUQHwBDBdM4NHCMehRJAsbm",
+//				"address-tag": "100040",
+//				"fee": 0,
+//				"state": "safe",
+//				"created-at": 1510912472199,
+//				"updated-at": 1511145876575
+//			}
+//		]
+//	}
+type HuobiDepositWithdraw struct {
+	ID        uint64  `json:"id"`
+	Type      string  `json:"type"`
+	Currency  string  `json:"currency"`
+	TxHash    string  `json:"tx-hash"`
+	Amount    float64 `json:"amount"`
+	Address   string  `json:"address"`
+	Fee       float64 `json:"fee"`
+	State     string  `json:"state"`
+	CreatedAt uint64  `json:"created-at"`
+	UpdatedAt uint64  `json:"updated-at"`
+}
+
+type HuobiDeposits struct {
+	Status   string                 `json:"status"`
+	Deposits []HuobiDepositWithdraw `json:"data"`
+	Reason   string                 `json:"err-msg"`
+}
+
+type HuobiWithdraws struct {
+	Status    string                 `json:"status"`
+	Withdraws []HuobiDepositWithdraw `json:"data"`
+	Reason    string                 `json:"err-msg"`
+}
+
+// Response of /v2/account/deposit/address, one entry per chain of the
+// currency
+type HuobiDepositAddress struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    []struct {
+		Currency   string `json:"currency"`
+		Address    string `json:"address"`
+		AddressTag string `json:"addressTag"`
+		Chain      string `json:"chain"`
+	} `json:"data"`
+}
@@ -0,0 +1,42 @@
+package exchange
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+type HuobiInterface interface {
+	GetDepthOnePair(
+		ctx context.Context, pair common.TokenPair, timepoint uint64) (HuobiDepth, error)
+
+	GetInfo(ctx context.Context, timepoint uint64) (HuobiInfo, error)
+
+	GetExchangeInfo() (HuobiExchangeInfo, error)
+
+	Withdraw(
+		ctx context.Context,
+		token common.Token,
+		amount *big.Int,
+		address ethereum.Address,
+		timepoint uint64) (HuobiWithdraw, error)
+
+	Trade(
+		ctx context.Context,
+		tradeType string,
+		base, quote common.Token,
+		rate, amount float64,
+		timepoint uint64) (HuobiTrade, error)
+
+	CancelOrder(ctx context.Context, id uint64) (HuobiCancel, error)
+
+	DepositHistory(ctx context.Context, currency string, timepoint uint64) (HuobiDeposits, error)
+
+	WithdrawHistory(ctx context.Context, currency string, timepoint uint64) (HuobiWithdraws, error)
+
+	OrderStatus(ctx context.Context, id uint64, timepoint uint64) (HuobiOrder, error)
+
+	GetDepositAddress(currency string) (HuobiDepositAddress, error)
+}
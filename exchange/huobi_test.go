@@ -0,0 +1,147 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+// testHuobiHistory serves canned deposit, withdraw and order status
+type testHuobiHistory struct {
+	HuobiInterface
+	deposits  HuobiDeposits
+	withdraws HuobiWithdraws
+	order     HuobiOrder
+}
+
+func (self *testHuobiHistory) DepositHistory(ctx context.Context, currency string, timepoint uint64) (HuobiDeposits, error) {
+	return self.deposits, nil
+}
+
+func (self *testHuobiHistory) WithdrawHistory(ctx context.Context, currency string, timepoint uint64) (HuobiWithdraws, error) {
+	return self.withdraws, nil
+}
+
+func (self *testHuobiHistory) OrderStatus(ctx context.Context, id uint64, timepoint uint64) (HuobiOrder, error) {
+	if id != 59378 {
+		return self.order, errors.New("unexpected order id")
+	}
+	return self.order, nil
+}
+
+func huobiTestTokens() {
+	common.SupportedTokens = map[string]common.Token{
+		"ETH": common.Token{ID: "ETH", Decimal: 18},
+		"OMG": common.Token{ID: "OMG", Decimal: 18},
+		"EOS": common.Token{ID: "EOS", Decimal: 18},
+		"KNC": common.Token{ID: "KNC", Decimal: 18},
+	}
+}
+
+func TestHuobiDepositStatus(t *testing.T) {
+	huobiTestTokens()
+	tx := "0xed03094b84eafbe4bc16e7ef766ee959885ee5bcb265872baaa9c64e1cf86c2b"
+	tests := []struct {
+		name   string
+		eid    string
+		state  string
+		status string
+		failed bool
+	}{
+		{name: "confirmed", eid: tx + "|KNC|10", state: "confirmed", status: "done"},
+		{name: "safe", eid: tx + "|KNC|10", state: "safe", status: "done"},
+		{name: "orphan", eid: tx + "|KNC|10", state: "orphan", status: "failed"},
+		{name: "unknown", eid: tx + "|KNC|10", state: "unknown", status: ""},
+		{name: "not listed yet", eid: "0x1234|KNC|10", state: "safe", status: ""},
+		{name: "malformed id", eid: tx + "|KNC", state: "safe", failed: true},
+	}
+	for _, test := range tests {
+		huobi := NewHuobi(&testHuobiHistory{
+			deposits: HuobiDeposits{
+				Status: "ok",
+				Deposits: []HuobiDepositWithdraw{
+					// huobi reports tx hashes without the 0x prefix
+					HuobiDepositWithdraw{ID: 1171, Type: "deposit", Currency: "knc", TxHash: tx[2:], State: test.state},
+				},
+			},
+		})
+		status, err := huobi.DepositStatus(context.Background(), common.NewActivityID(1, test.eid), 1)
+		if (err != nil) != test.failed {
+			t.Errorf("%s: expected failure %t, got %v", test.name, test.failed, err)
+		}
+		if status != test.status {
+			t.Errorf("%s: expected status %q, got %q", test.name, test.status, status)
+		}
+	}
+}
+
+func TestHuobiWithdrawStatus(t *testing.T) {
+	huobiTestTokens()
+	tests := []struct {
+		name   string
+		eid    string
+		state  string
+		status string
+		tx     string
+		failed bool
+	}{
+		{name: "confirmed", eid: "2272|KNC", state: "confirmed", status: "done", tx: "0xabcd"},
+		{name: "canceled", eid: "2272|KNC", state: "canceled", status: "failed", tx: "0xabcd"},
+		{name: "rejected", eid: "2272|KNC", state: "reject", status: "failed", tx: "0xabcd"},
+		{name: "rejected by wallet", eid: "2272|KNC", state: "wallet-reject", status: "failed", tx: "0xabcd"},
+		{name: "confirm error", eid: "2272|KNC", state: "confirm-error", status: "failed", tx: "0xabcd"},
+		{name: "repealed", eid: "2272|KNC", state: "repealed", status: "failed", tx: "0xabcd"},
+		{name: "pending", eid: "2272|KNC", state: "pre-transfer", status: "", tx: "0xabcd"},
+		{name: "not found", eid: "2273|KNC", state: "confirmed", failed: true},
+		{name: "malformed id", eid: "abc|KNC", state: "confirmed", failed: true},
+		{name: "missing currency", eid: "2272", state: "confirmed", failed: true},
+	}
+	for _, test := range tests {
+		huobi := NewHuobi(&testHuobiHistory{
+			withdraws: HuobiWithdraws{
+				Status: "ok",
+				Withdraws: []HuobiDepositWithdraw{
+					HuobiDepositWithdraw{ID: 2272, Type: "withdraw", Currency: "knc", TxHash: "0xabcd", State: test.state},
+				},
+			},
+		})
+		status, tx, err := huobi.WithdrawStatus(context.Background(), common.NewActivityID(1, test.eid), 1)
+		if (err != nil) != test.failed {
+			t.Errorf("%s: expected failure %t, got %v", test.name, test.failed, err)
+		}
+		if status != test.status || tx != test.tx {
+			t.Errorf("%s: expected status %q with tx %q, got %q with tx %q", test.name, test.status, test.tx, status, tx)
+		}
+	}
+}
+
+func TestHuobiOrderStatus(t *testing.T) {
+	huobiTestTokens()
+	tests := []struct {
+		name   string
+		eid    string
+		state  string
+		status string
+		failed bool
+	}{
+		{name: "submitted", eid: "59378", state: "submitted", status: ""},
+		{name: "partially filled", eid: "59378", state: "partial-filled", status: ""},
+		{name: "filled", eid: "59378", state: "filled", status: "done"},
+		{name: "canceled", eid: "59378", state: "canceled", status: "done"},
+		{name: "malformed id", eid: "59378|KNC", state: "filled", failed: true},
+	}
+	for _, test := range tests {
+		order := HuobiOrder{Status: "ok"}
+		order.Data.State = test.state
+		huobi := NewHuobi(&testHuobiHistory{order: order})
+		status, err := huobi.OrderStatus(context.Background(), common.NewActivityID(1, test.eid), 1)
+		if (err != nil) != test.failed {
+			t.Errorf("%s: expected failure %t, got %v", test.name, test.failed, err)
+		}
+		if status != test.status {
+			t.Errorf("%s: expected status %q, got %q", test.name, test.status, status)
+		}
+	}
+}
@@ -1,27 +1,27 @@
 package exchange
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
+	"math"
 	"math/big"
+	"strconv"
 	"strings"
-	"time"
 
 	"github.com/KyberNetwork/reserve-data/common"
 	ethereum "github.com/ethereum/go-ethereum/common"
-	"github.com/satori/go.uuid"
 )
 
-const (
-	DEPOSIT_WAITING_TIME  uint64 = 10 * 60 * 1000
-	WITHDRAW_WAITING_TIME uint64 = 5 * 60 * 1000
-)
+const LIQUI_EPSILON float64 = 0.0000001 // 10e-7
 
 type Liqui struct {
-	interf    LiquiInterface
-	pairs     []common.TokenPair
-	addresses map[string]ethereum.Address
+	interf       LiquiInterface
+	pairs        []common.TokenPair
+	addresses    *common.ExchangeAddresses
+	exchangeInfo *common.ExchangeInfo
+	fees         *common.ExchangeFeeTable
 }
 
 func (self *Liqui) MarshalText() (text []byte, err error) {
@@ -29,18 +29,87 @@ func (self *Liqui) MarshalText() (text []byte, err error) {
 }
 
 func (self *Liqui) Address(token common.Token) (ethereum.Address, bool) {
-	addr, supported := self.addresses[token.ID]
-	return addr, supported
+	return self.addresses.Get(token.ID)
+}
+
+func (self *Liqui) VerifiedAddress(token common.Token) (ethereum.Address, error) {
+	return self.addresses.Verified(token.ID)
 }
 
 func (self *Liqui) UpdateAllDepositAddresses(address string) {
-	for k, _ := range self.addresses {
-		self.addresses[k] = ethereum.HexToAddress(address)
-	}
+	self.addresses.UpdateAll(ethereum.HexToAddress(address))
 }
 
 func (self *Liqui) UpdateDepositAddress(token common.Token, address string) {
-	self.addresses[token.ID] = ethereum.HexToAddress(address)
+	self.addresses.Update(token.ID, ethereum.HexToAddress(address))
+}
+
+// DiscoverDepositAddresses verifies configured deposit addresses against
+// the ones liqui reports
+func (self *Liqui) DiscoverDepositAddresses() error {
+	return discoverDepositAddresses(self.ID(), self.addresses, func(token common.Token) (string, error) {
+		result, err := self.interf.GetDepositAddress(token.ID)
+		return result.Return.Address, err
+	})
+}
+
+func (self *Liqui) UpdatePrecisionLimit(pair common.TokenPair, pairs map[string]Liqpairinfo) {
+	pairName := strings.ToLower(fmt.Sprintf("%s_%s", pair.Base.ID, pair.Quote.ID))
+	if info, exist := pairs[pairName]; exist {
+		exchangePrecisionLimit := common.ExchangePrecisionLimit{}
+		// liqui uses the same decimal places for both price and amount
+		exchangePrecisionLimit.Precision.Amount = info.DecimalPlaces
+		exchangePrecisionLimit.Precision.Price = info.DecimalPlaces
+		exchangePrecisionLimit.AmountLimit.Min = float32(info.MinAmount)
+		exchangePrecisionLimit.AmountLimit.Max = float32(info.MaxAmount)
+		exchangePrecisionLimit.PriceLimit.Min = float32(info.MinPrice)
+		exchangePrecisionLimit.PriceLimit.Max = float32(info.MaxPrice)
+		self.exchangeInfo.Update(pair.PairID(), exchangePrecisionLimit)
+	}
+}
+
+func (self *Liqui) UpdatePairsPrecision() {
+	exchangeInfo, err := self.interf.GetExchangeInfo()
+	if err != nil {
+		log.Printf("Get exchange info failed: %s\n", err)
+	} else {
+		for _, pair := range self.pairs {
+			self.UpdatePrecisionLimit(pair, exchangeInfo.Pairs)
+		}
+	}
+}
+
+// UpdateFees refreshes taker fee from liqui /info, liqui publishes
+// neither maker fee nor withdraw fees so they keep their fallback values
+func (self *Liqui) UpdateFees() error {
+	exchangeInfo, err := self.interf.GetExchangeInfo()
+	if err != nil {
+		return err
+	}
+	for _, pair := range self.pairs {
+		pairName := strings.ToLower(fmt.Sprintf("%s_%s", pair.Base.ID, pair.Quote.ID))
+		if info, exist := exchangeInfo.Pairs[pairName]; exist {
+			// fee is in percent and is the same for every pair
+			self.fees.UpdateTrading(common.TradingFee{
+				"taker": float32(info.Fee / 100),
+			})
+			return nil
+		}
+	}
+	return errors.New("None of liqui pairs is listed in its info")
+}
+
+func (self *Liqui) GetInfo() (common.ExchangeInfo, error) {
+	return self.exchangeInfo.Copy(), nil
+}
+
+func (self *Liqui) GetExchangeInfo(pair common.TokenPairID) (common.ExchangePrecisionLimit, error) {
+	data, err := self.exchangeInfo.Get(pair)
+	return data, err
+}
+
+func (self *Liqui) GetFee() common.ExchangeFees {
+	return self.fees.Get()
 }
 
 func (self *Liqui) ID() common.ExchangeID {
@@ -55,17 +124,53 @@ func (self *Liqui) Name() string {
 	return "liqui"
 }
 
-func (self *Liqui) Trade(tradeType string, base common.Token, quote common.Token, rate float64, amount float64, timepoint uint64) (id string, done float64, remaining float64, finished bool, err error) {
-	return self.interf.Trade(tradeType, base, quote, rate, amount, timepoint)
+func (self *Liqui) Trade(ctx context.Context, tradeType string, base common.Token, quote common.Token, rate float64, amount float64, timepoint uint64) (id string, done float64, remaining float64, finished bool, err error) {
+	return self.interf.Trade(ctx, tradeType, base, quote, rate, amount, timepoint)
 }
 
-func (self *Liqui) Withdraw(token common.Token, amount *big.Int, address ethereum.Address, timepoint uint64) (string, error) {
-	err := self.interf.Withdraw(token, amount, address, timepoint)
-	return uuid.NewV4().String(), err
+// TradeHistory returns fills of pair since a timepoint. Liqui doesn't
+// report fees of a fill so they are derived from the taker fee, charged
+// in the received currency.
+func (self *Liqui) TradeHistory(ctx context.Context, pair common.TokenPair, since uint64) ([]common.TradeFill, error) {
+	history, err := self.interf.TradeHistory(ctx, pair, since/1000)
+	if err != nil {
+		return nil, err
+	}
+	feeRate := float64(self.fees.Get().Trading["taker"])
+	result := []common.TradeFill{}
+	for id, trade := range history.Return {
+		fill := common.TradeFill{
+			ID:        id,
+			OrderID:   strconv.FormatUint(trade.OrderID, 10),
+			Pair:      pair.PairID(),
+			Type:      trade.Type,
+			Price:     trade.Rate,
+			Qty:       trade.Amount,
+			Timestamp: trade.Timestamp * 1000,
+		}
+		if trade.Type == "buy" {
+			fill.Fee = trade.Amount * feeRate
+			fill.FeeAsset = pair.Base.ID
+		} else {
+			fill.Fee = trade.Amount * trade.Rate * feeRate
+			fill.FeeAsset = pair.Quote.ID
+		}
+		result = append(result, fill)
+	}
+	return result, nil
 }
 
-func (self *Liqui) CancelOrder(id common.ActivityID) error {
-	result, err := self.interf.CancelOrder(id.EID)
+func (self *Liqui) Withdraw(ctx context.Context, token common.Token, amount *big.Int, address ethereum.Address, timepoint uint64) (string, error) {
+	result, err := self.interf.Withdraw(ctx, token, amount, address, timepoint)
+	if err != nil {
+		return "", err
+	} else {
+		return strconv.FormatUint(result.Return.TID, 10) + "|" + token.ID, nil
+	}
+}
+
+func (self *Liqui) CancelOrder(ctx context.Context, id common.ActivityID) error {
+	result, err := self.interf.CancelOrder(ctx, id.EID)
 	if err != nil {
 		return err
 	}
@@ -111,11 +216,11 @@ func (self *Liqui) FetchOrderData(timepoint uint64) (common.OrderEntry, error) {
 	return result, nil
 }
 
-func (self *Liqui) FetchEBalanceData(timepoint uint64) (common.EBalanceEntry, error) {
+func (self *Liqui) FetchEBalanceData(ctx context.Context, timepoint uint64) (common.EBalanceEntry, error) {
 	result := common.EBalanceEntry{}
 	result.Timestamp = common.Timestamp(fmt.Sprintf("%d", timepoint))
 	result.Valid = true
-	resp_data, err := self.interf.GetInfo(timepoint)
+	resp_data, err := self.interf.GetInfo(ctx, timepoint)
 	result.ReturnTime = common.GetTimestamp()
 	if err != nil {
 		result.Valid = false
@@ -140,18 +245,18 @@ func (self *Liqui) FetchEBalanceData(timepoint uint64) (common.EBalanceEntry, er
 	return result, nil
 }
 
-func (self *Liqui) FetchPriceData(timepoint uint64) (map[common.TokenPairID]common.ExchangePrice, error) {
+func (self *Liqui) FetchPriceData(ctx context.Context, timepoint uint64) (map[common.TokenPairID]common.ExchangePrice, error) {
 	result := map[common.TokenPairID]common.ExchangePrice{}
 	pairs_str := []string{}
 	for _, pair := range self.pairs {
 		pairs_str = append(pairs_str, fmt.Sprintf("%s_%s", pair.Base.ID, pair.Quote.ID))
 	}
 	timestamp := common.Timestamp(fmt.Sprintf("%d", timepoint))
-	log.Printf("depth: %s - %s\n",
+	log.Printf("depth: %s - %d\n",
 		strings.ToLower(strings.Join(pairs_str, "-")),
 		timepoint,
 	)
-	resp_data, err := self.interf.Depth(
+	resp_data, err := self.interf.Depth(ctx,
 		strings.ToLower(strings.Join(pairs_str, "-")),
 		timepoint,
 	)
@@ -200,48 +305,93 @@ func (self *Liqui) FetchPriceData(timepoint uint64) (map[common.TokenPairID]comm
 	return result, err
 }
 
-func (self *Liqui) DepositStatus(id common.ActivityID, timepoint uint64) (string, error) {
-	timestamp := id.Timepoint
-	if timepoint-timestamp/uint64(time.Millisecond) > DEPOSIT_WAITING_TIME {
-		return "done", nil
-	} else {
-		return "", nil
+func (self *Liqui) DepositStatus(ctx context.Context, id common.ActivityID, timepoint uint64) (string, error) {
+	idParts := strings.Split(id.EID, "|")
+	if len(idParts) != 3 {
+		// here, the exchange id part in id is malformed
+		// 1. because analytic didn't pass original ID
+		// 2. id is not constructed correctly in a form of uuid + "|" + token + "|" + amount
+		return "", errors.New("Invalid deposit id")
+	}
+	currency := idParts[1]
+	amount, err := strconv.ParseFloat(idParts[2], 64)
+	if err != nil {
+		return "", errors.New("Invalid deposit id")
+	}
+	// liqui doesn't expose deposit tx hash so we match deposits by
+	// currency and amount, only considering the ones that are credited
+	// after the activity was created
+	since := id.Timepoint / 1000000000
+	history, err := self.interf.TransHistory(ctx, timepoint)
+	if err != nil {
+		return "", err
+	}
+	for _, trans := range history.Return {
+		if trans.Type == 1 &&
+			strings.ToUpper(trans.Currency) == currency &&
+			math.Abs(trans.Amount-amount) < LIQUI_EPSILON &&
+			trans.Timestamp >= since {
+			switch trans.Status {
+			case 2:
+				return "done", nil
+			case 0:
+				return "failed", nil
+			default:
+				return "", nil
+			}
+		}
 	}
-	return "", errors.New("Not implemented yet")
+	return "", nil
 }
 
-// Liqui should not work properly because of lack of txid
-func (self *Liqui) WithdrawStatus(id common.ActivityID, timepoint uint64) (string, string, error) {
-	timestamp := id.Timepoint
-	if timepoint-timestamp/uint64(time.Millisecond) > WITHDRAW_WAITING_TIME {
+// Liqui doesn't return tx hash of withdrawals so tx is always empty
+func (self *Liqui) WithdrawStatus(ctx context.Context, id common.ActivityID, timepoint uint64) (string, string, error) {
+	idParts := strings.Split(id.EID, "|")
+	if len(idParts) != 2 {
+		// here, the exchange id part in id is malformed
+		// 1. because analytic didn't pass original ID
+		// 2. id is not constructed correctly in a form of tid + "|" + token
+		return "", "", errors.New("Invalid withdraw id")
+	}
+	history, err := self.interf.TransHistory(ctx, timepoint)
+	if err != nil {
+		return "", "", err
+	}
+	trans, exist := history.Return[idParts[0]]
+	if !exist {
+		return "", "", errors.New("Withdraw with id " + idParts[0] + " is not found on liqui")
+	}
+	switch trans.Status {
+	case 2:
 		return "done", "", nil
-	} else {
+	case 0:
+		return "failed", "", nil
+	default:
 		return "", "", nil
 	}
-	return "", "", errors.New("Not implemented yet")
 }
 
-func (self *Liqui) OrderStatus(id common.ActivityID, timepoint uint64) (string, error) {
-	result, err := self.interf.OrderInfo(id.EID, timepoint)
+func (self *Liqui) OrderStatus(ctx context.Context, id common.ActivityID, timepoint uint64) (string, error) {
+	result, err := self.interf.OrderInfo(ctx, id.EID, timepoint)
 	if err != nil {
 		return "", err
-	} else {
-		if result.Success != 1 {
-			for _, v := range result.Return {
-				if v.Status == 0 {
-					return "", nil
-				} else if v.Status == 1 {
-					return "done", nil
-				} else if v.Status == 2 {
-					return "failed", nil
-				} else if v.Status == 3 {
-					return "failed", nil
-				}
-			}
-			panic("Malformed response from liqui")
-		} else {
-			return "", errors.New(result.Error)
-		}
+	}
+	if result.Success != 1 {
+		return "", errors.New(result.Error)
+	}
+	order, exist := result.Return[id.EID]
+	if !exist {
+		return "", errors.New("Malformed response from liqui: order " + id.EID + " is not found")
+	}
+	switch order.Status {
+	case 0:
+		return "", nil
+	case 1:
+		return "done", nil
+	case 2, 3:
+		return "failed", nil
+	default:
+		return "", errors.New(fmt.Sprintf("Malformed response from liqui: unknown order status %d", order.Status))
 	}
 }
 
@@ -260,6 +410,43 @@ func NewLiqui(interf LiquiInterface) *Liqui {
 			common.MustCreateTokenPair("BAT", "ETH"),
 			common.MustCreateTokenPair("KNC", "ETH"),
 		},
-		map[string]ethereum.Address{},
+		common.NewExchangeAddresses(),
+		common.NewExchangeInfo(),
+		common.NewExchangeFeeTable(
+			common.NewExchangeFee(
+				common.TradingFee{
+					"taker": 0.0025,
+					"maker": 0.001,
+				},
+				common.NewFundingFee(
+					map[string]float32{
+						"ETH": 0.005,
+						"OMG": 0.1,
+						"DGD": 0.001,
+						"CVC": 1.0,
+						"MCO": 0.2,
+						"GNT": 1.0,
+						"ADX": 1.0,
+						"EOS": 0.5,
+						"PAY": 0.5,
+						"BAT": 5.0,
+						"KNC": 1.0,
+					},
+					map[string]float32{
+						"ETH": 0,
+						"OMG": 0,
+						"DGD": 0,
+						"CVC": 0,
+						"MCO": 0,
+						"GNT": 0,
+						"ADX": 0,
+						"EOS": 0,
+						"PAY": 0,
+						"BAT": 0,
+						"KNC": 0,
+					},
+				),
+			),
+		),
 	}
 }
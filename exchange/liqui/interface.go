@@ -71,6 +71,28 @@ func NewKovanInterface() *KovanInterface {
 	return &KovanInterface{}
 }
 
+type RopstenInterface struct{}
+
+func (self *RopstenInterface) baseurl() string {
+	baseurl := "127.0.0.1"
+	if len(os.Args) > 1 {
+		baseurl = os.Args[1]
+	}
+	return baseurl + ":5000"
+}
+
+func (self *RopstenInterface) PublicEndpoint(timepoint uint64) string {
+	return "https://api.liqui.io/api/3"
+}
+
+func (self *RopstenInterface) AuthenticatedEndpoint(timepoint uint64) string {
+	return self.baseurl()
+}
+
+func NewRopstenInterface() *RopstenInterface {
+	return &RopstenInterface{}
+}
+
 type DevInterface struct{}
 
 func (self *DevInterface) PublicEndpoint(timepoint uint64) string {
@@ -2,6 +2,7 @@ package liqui
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -20,9 +21,15 @@ import (
 	ethereum "github.com/ethereum/go-ethereum/common"
 )
 
+// liqui allows 60 requests per minute per IP
+var LIQUI_RATE_LIMITS = []common.RateLimit{
+	common.RateLimit{Kind: common.RATE_KIND_REQUEST, Limit: 60, Interval: time.Minute},
+}
+
 type LiquiEndpoint struct {
-	signer Signer
-	interf Interface
+	signer  Signer
+	interf  Interface
+	limiter *common.RateLimiter
 }
 
 func nonce() string {
@@ -32,7 +39,7 @@ func nonce() string {
 	return strconv.Itoa(int(timestamp))
 }
 
-func (self *LiquiEndpoint) Depth(tokens string, timepoint uint64) (exchange.Liqresp, error) {
+func (self *LiquiEndpoint) Depth(ctx context.Context, tokens string, timepoint uint64) (exchange.Liqresp, error) {
 	result := exchange.Liqresp{}
 	client := &http.Client{
 		Timeout: time.Duration(30 * time.Second)}
@@ -50,6 +57,10 @@ func (self *LiquiEndpoint) Depth(tokens string, timepoint uint64) (exchange.Liqr
 	)
 	req, _ := http.NewRequest("GET", u.String(), nil)
 	req.Header.Add("Accept", "application/json")
+	if err := self.limiter.Request(ctx, 1); err != nil {
+		return result, err
+	}
+	req = req.WithContext(ctx)
 	resp, err := client.Do(req)
 	if err == nil {
 		if resp.StatusCode == 200 {
@@ -65,7 +76,7 @@ func (self *LiquiEndpoint) Depth(tokens string, timepoint uint64) (exchange.Liqr
 	return result, err
 }
 
-func (self *LiquiEndpoint) CancelOrder(id string) (exchange.Liqcancel, error) {
+func (self *LiquiEndpoint) CancelOrder(ctx context.Context, id string) (exchange.Liqcancel, error) {
 	result := exchange.Liqcancel{}
 	client := &http.Client{
 		Timeout: time.Duration(30 * time.Second)}
@@ -84,6 +95,10 @@ func (self *LiquiEndpoint) CancelOrder(id string) (exchange.Liqcancel, error) {
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Add("Key", self.signer.GetLiquiKey())
 	req.Header.Add("Sign", self.signer.LiquiSign(params))
+	if err := self.limiter.Request(ctx, 1); err != nil {
+		return result, err
+	}
+	req = req.WithContext(ctx)
 	resp, err := client.Do(req)
 	if err == nil && resp.StatusCode == 200 {
 		defer resp.Body.Close()
@@ -98,7 +113,7 @@ func (self *LiquiEndpoint) CancelOrder(id string) (exchange.Liqcancel, error) {
 	}
 }
 
-func (self *LiquiEndpoint) Trade(tradeType string, base, quote common.Token, rate, amount float64, timepoint uint64) (id string, done float64, remaining float64, finished bool, err error) {
+func (self *LiquiEndpoint) Trade(ctx context.Context, tradeType string, base, quote common.Token, rate, amount float64, timepoint uint64) (id string, done float64, remaining float64, finished bool, err error) {
 	result := exchange.Liqtrade{}
 	client := &http.Client{
 		Timeout: time.Duration(30 * time.Second)}
@@ -120,6 +135,10 @@ func (self *LiquiEndpoint) Trade(tradeType string, base, quote common.Token, rat
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Add("Key", self.signer.GetLiquiKey())
 	req.Header.Add("Sign", self.signer.LiquiSign(params))
+	if err := self.limiter.Order(ctx, 1); err != nil {
+		return "", 0, 0, false, err
+	}
+	req = req.WithContext(ctx)
 	resp, err := client.Do(req)
 	if err == nil && resp.StatusCode == 200 {
 		defer resp.Body.Close()
@@ -141,7 +160,7 @@ func (self *LiquiEndpoint) Trade(tradeType string, base, quote common.Token, rat
 	}
 }
 
-func (self *LiquiEndpoint) Withdraw(token common.Token, amount *big.Int, address ethereum.Address, timepoint uint64) error {
+func (self *LiquiEndpoint) Withdraw(ctx context.Context, token common.Token, amount *big.Int, address ethereum.Address, timepoint uint64) (exchange.Liqwithdraw, error) {
 	// ignoring timepoint because it's only relevant in simulation
 	result := exchange.Liqwithdraw{}
 	client := &http.Client{
@@ -164,6 +183,10 @@ func (self *LiquiEndpoint) Withdraw(token common.Token, amount *big.Int, address
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Add("Key", self.signer.GetLiquiKey())
 	req.Header.Add("Sign", self.signer.LiquiSign(params))
+	if err := self.limiter.Request(ctx, 1); err != nil {
+		return result, err
+	}
+	req = req.WithContext(ctx)
 	resp, err := client.Do(req)
 	if err == nil && resp.StatusCode == 200 {
 		defer resp.Body.Close()
@@ -173,19 +196,19 @@ func (self *LiquiEndpoint) Withdraw(token common.Token, amount *big.Int, address
 			err = json.Unmarshal(resp_body, &result)
 		}
 		if err != nil {
-			return err
+			return result, err
 		}
 		if result.Error != "" {
-			return errors.New(result.Error)
+			return result, errors.New(result.Error)
 		}
-		return nil
+		return result, nil
 	} else {
 		log.Printf("Error: %v, Code: %v\n", err, resp)
-		return errors.New("withdraw rejected by Liqui")
+		return result, errors.New("withdraw rejected by Liqui")
 	}
 }
 
-func (self *LiquiEndpoint) GetInfo(timepoint uint64) (exchange.Liqinfo, error) {
+func (self *LiquiEndpoint) GetInfo(ctx context.Context, timepoint uint64) (exchange.Liqinfo, error) {
 	result := exchange.Liqinfo{}
 	client := &http.Client{
 		Timeout: time.Duration(30 * time.Second)}
@@ -204,6 +227,10 @@ func (self *LiquiEndpoint) GetInfo(timepoint uint64) (exchange.Liqinfo, error) {
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Add("Key", self.signer.GetLiquiKey())
 	req.Header.Add("Sign", self.signer.LiquiSign(params))
+	if err := self.limiter.Request(ctx, 1); err != nil {
+		return result, err
+	}
+	req = req.WithContext(ctx)
 	resp, err := client.Do(req)
 	if err == nil {
 		if resp.StatusCode == 200 {
@@ -213,7 +240,7 @@ func (self *LiquiEndpoint) GetInfo(timepoint uint64) (exchange.Liqinfo, error) {
 			if err == nil {
 				json.Unmarshal(resp_body, &result)
 			}
-			log.Printf("Liqui GetInfo data: %s\n", result)
+			log.Printf("Liqui GetInfo data: %+v\n", result)
 		} else {
 			err = errors.New("Unsuccessful response from Liqui: Status " + resp.Status)
 		}
@@ -221,7 +248,7 @@ func (self *LiquiEndpoint) GetInfo(timepoint uint64) (exchange.Liqinfo, error) {
 	return result, err
 }
 
-func (self *LiquiEndpoint) OrderInfo(orderID string, timepoint uint64) (exchange.Liqorderinfo, error) {
+func (self *LiquiEndpoint) OrderInfo(ctx context.Context, orderID string, timepoint uint64) (exchange.Liqorderinfo, error) {
 	result := exchange.Liqorderinfo{}
 	client := &http.Client{
 		Timeout: time.Duration(30 * time.Second)}
@@ -240,6 +267,10 @@ func (self *LiquiEndpoint) OrderInfo(orderID string, timepoint uint64) (exchange
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Add("Key", self.signer.GetLiquiKey())
 	req.Header.Add("Sign", self.signer.LiquiSign(params))
+	if err := self.limiter.Request(ctx, 1); err != nil {
+		return result, err
+	}
+	req = req.WithContext(ctx)
 	resp, err := client.Do(req)
 	if err == nil {
 		if resp.StatusCode == 200 {
@@ -249,7 +280,7 @@ func (self *LiquiEndpoint) OrderInfo(orderID string, timepoint uint64) (exchange
 			if err == nil {
 				json.Unmarshal(resp_body, &result)
 			}
-			log.Printf("Liqui Order info data: %s\n", result)
+			log.Printf("Liqui Order info data: %+v\n", result)
 		} else {
 			err = errors.New("Unsuccessful response from Liqui: Status " + resp.Status)
 		}
@@ -276,6 +307,9 @@ func (self *LiquiEndpoint) ActiveOrders(timepoint uint64) (exchange.Liqorders, e
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Add("Key", self.signer.GetLiquiKey())
 	req.Header.Add("Sign", self.signer.LiquiSign(params))
+	if err := self.limiter.Request(context.Background(), 1); err != nil {
+		return result, err
+	}
 	resp, err := client.Do(req)
 	if err == nil {
 		if resp.StatusCode == 200 {
@@ -285,7 +319,185 @@ func (self *LiquiEndpoint) ActiveOrders(timepoint uint64) (exchange.Liqorders, e
 			if err == nil {
 				json.Unmarshal(resp_body, &result)
 			}
-			log.Printf("Liqui ActiveOrders data: %s\n", result)
+			log.Printf("Liqui ActiveOrders data: %+v\n", result)
+		} else {
+			err = errors.New("Unsuccessful response from Liqui: Status " + resp.Status)
+		}
+	}
+	return result, err
+}
+
+func (self *LiquiEndpoint) GetExchangeInfo() (exchange.Liqexchangeinfo, error) {
+	result := exchange.Liqexchangeinfo{}
+	client := &http.Client{
+		Timeout: time.Duration(30 * time.Second)}
+	u, err := url.Parse(self.interf.PublicEndpoint(common.GetTimepoint()))
+	if err != nil {
+		return result, err
+	}
+	u.Path = path.Join(u.Path, "info")
+	req, _ := http.NewRequest("GET", u.String(), nil)
+	req.Header.Add("Accept", "application/json")
+	if err := self.limiter.Request(context.Background(), 1); err != nil {
+		return result, err
+	}
+	resp, err := client.Do(req)
+	if err == nil {
+		if resp.StatusCode == 200 {
+			defer resp.Body.Close()
+			resp_body, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				return result, err
+			}
+			err = json.Unmarshal(resp_body, &result)
+			if err != nil {
+				return result, err
+			}
+			if result.Error != "" {
+				return result, errors.New(result.Error)
+			}
+		} else {
+			err = errors.New("Unsuccessful response from Liqui: Status " + resp.Status)
+		}
+	}
+	return result, err
+}
+
+func (self *LiquiEndpoint) TransHistory(ctx context.Context, timepoint uint64) (exchange.Liqtranshistory, error) {
+	result := exchange.Liqtranshistory{}
+	client := &http.Client{
+		Timeout: time.Duration(30 * time.Second)}
+	data := url.Values{}
+	data.Set("method", "TransHistory")
+	data.Set("count", "1000")
+	data.Add("nonce", nonce())
+	params := data.Encode()
+	req, _ := http.NewRequest(
+		"POST",
+		self.interf.AuthenticatedEndpoint(timepoint),
+		bytes.NewBufferString(params),
+	)
+	req.Header.Add("Content-Length", strconv.Itoa(len(params)))
+	req.Header.Add("Accept", "application/json")
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Add("Key", self.signer.GetLiquiKey())
+	req.Header.Add("Sign", self.signer.LiquiSign(params))
+	if err := self.limiter.Request(ctx, 1); err != nil {
+		return result, err
+	}
+	req = req.WithContext(ctx)
+	resp, err := client.Do(req)
+	if err == nil {
+		if resp.StatusCode == 200 {
+			defer resp.Body.Close()
+			resp_body, err := ioutil.ReadAll(resp.Body)
+			log.Printf("Liqui TransHistory response: %s\n", string(resp_body))
+			if err != nil {
+				return result, err
+			}
+			err = json.Unmarshal(resp_body, &result)
+			if err != nil {
+				return result, err
+			}
+			if result.Success != 1 {
+				return result, errors.New("Getting transaction history from Liqui failed: " + result.Error)
+			}
+		} else {
+			err = errors.New("Unsuccessful response from Liqui: Status " + resp.Status)
+		}
+	}
+	return result, err
+}
+
+func (self *LiquiEndpoint) TradeHistory(ctx context.Context, pair common.TokenPair, since uint64) (exchange.Liqtradehistory, error) {
+	result := exchange.Liqtradehistory{}
+	timepoint := common.GetTimepoint()
+	client := &http.Client{
+		Timeout: time.Duration(30 * time.Second)}
+	data := url.Values{}
+	data.Set("method", "TradeHistory")
+	data.Set("pair", fmt.Sprintf("%s_%s", strings.ToLower(pair.Base.ID), strings.ToLower(pair.Quote.ID)))
+	data.Set("since", strconv.FormatUint(since, 10))
+	data.Set("count", "1000")
+	data.Add("nonce", nonce())
+	params := data.Encode()
+	req, _ := http.NewRequest(
+		"POST",
+		self.interf.AuthenticatedEndpoint(timepoint),
+		bytes.NewBufferString(params),
+	)
+	req.Header.Add("Content-Length", strconv.Itoa(len(params)))
+	req.Header.Add("Accept", "application/json")
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Add("Key", self.signer.GetLiquiKey())
+	req.Header.Add("Sign", self.signer.LiquiSign(params))
+	if err := self.limiter.Request(ctx, 1); err != nil {
+		return result, err
+	}
+	req = req.WithContext(ctx)
+	resp, err := client.Do(req)
+	if err == nil {
+		if resp.StatusCode == 200 {
+			defer resp.Body.Close()
+			resp_body, err := ioutil.ReadAll(resp.Body)
+			log.Printf("Liqui TradeHistory response: %s\n", string(resp_body))
+			if err != nil {
+				return result, err
+			}
+			err = json.Unmarshal(resp_body, &result)
+			if err != nil {
+				return result, err
+			}
+			// liqui reports having no trades as an error
+			if result.Success != 1 && result.Error != "no trades" {
+				return result, errors.New("Getting trade history from Liqui failed: " + result.Error)
+			}
+		} else {
+			err = errors.New("Unsuccessful response from Liqui: Status " + resp.Status)
+		}
+	}
+	return result, err
+}
+
+func (self *LiquiEndpoint) GetDepositAddress(currency string) (exchange.Liqdepositaddress, error) {
+	result := exchange.Liqdepositaddress{}
+	timepoint := common.GetTimepoint()
+	client := &http.Client{
+		Timeout: time.Duration(30 * time.Second)}
+	data := url.Values{}
+	data.Set("method", "CoinDepositAddress")
+	data.Set("coinName", strings.ToUpper(currency))
+	data.Add("nonce", nonce())
+	params := data.Encode()
+	req, _ := http.NewRequest(
+		"POST",
+		self.interf.AuthenticatedEndpoint(timepoint),
+		bytes.NewBufferString(params),
+	)
+	req.Header.Add("Content-Length", strconv.Itoa(len(params)))
+	req.Header.Add("Accept", "application/json")
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Add("Key", self.signer.GetLiquiKey())
+	req.Header.Add("Sign", self.signer.LiquiSign(params))
+	if err := self.limiter.Request(context.Background(), 1); err != nil {
+		return result, err
+	}
+	resp, err := client.Do(req)
+	if err == nil {
+		if resp.StatusCode == 200 {
+			defer resp.Body.Close()
+			resp_body, err := ioutil.ReadAll(resp.Body)
+			log.Printf("Liqui CoinDepositAddress response: %s\n", string(resp_body))
+			if err != nil {
+				return result, err
+			}
+			err = json.Unmarshal(resp_body, &result)
+			if err != nil {
+				return result, err
+			}
+			if result.Success != 1 {
+				return result, errors.New("Getting deposit address from Liqui failed: " + result.Error)
+			}
 		} else {
 			err = errors.New("Unsuccessful response from Liqui: Status " + resp.Status)
 		}
@@ -294,21 +506,25 @@ func (self *LiquiEndpoint) ActiveOrders(timepoint uint64) (exchange.Liqorders, e
 }
 
 func NewLiquiEndpoint(signer Signer, interf Interface) *LiquiEndpoint {
-	return &LiquiEndpoint{signer, interf}
+	return &LiquiEndpoint{signer, interf, common.RegisterRateLimiter("liqui", LIQUI_RATE_LIMITS)}
 }
 
 func NewRealLiquiEndpoint(signer Signer) *LiquiEndpoint {
-	return &LiquiEndpoint{signer, NewRealInterface()}
+	return NewLiquiEndpoint(signer, NewRealInterface())
 }
 
 func NewSimulatedLiquiEndpoint(signer Signer) *LiquiEndpoint {
-	return &LiquiEndpoint{signer, NewSimulatedInterface()}
+	return NewLiquiEndpoint(signer, NewSimulatedInterface())
 }
 
 func NewKovanLiquiEndpoint(signer Signer) *LiquiEndpoint {
-	return &LiquiEndpoint{signer, NewKovanInterface()}
+	return NewLiquiEndpoint(signer, NewKovanInterface())
+}
+
+func NewRopstenLiquiEndpoint(signer Signer) *LiquiEndpoint {
+	return NewLiquiEndpoint(signer, NewRopstenInterface())
 }
 
 func NewDevLiquiEndpoint(signer Signer) *LiquiEndpoint {
-	return &LiquiEndpoint{signer, NewDevInterface()}
+	return NewLiquiEndpoint(signer, NewDevInterface())
 }
@@ -0,0 +1,121 @@
+package liqui
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/KyberNetwork/reserve-data/signer"
+)
+
+type testInterface struct {
+	url string
+}
+
+func (self *testInterface) PublicEndpoint(timepoint uint64) string {
+	return self.url
+}
+
+func (self *testInterface) AuthenticatedEndpoint(timepoint uint64) string {
+	return self.url
+}
+
+var testSigner = signer.FileSigner{
+	LiquiKey:    "testkey",
+	LiquiSecret: "testsecret",
+}
+
+// newTestEndpoint answers every request with body and checks the
+// signature of authenticated requests
+func newTestEndpoint(t *testing.T, status int, body string) (*LiquiEndpoint, *url.Values, func()) {
+	params := &url.Values{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			raw, _ := ioutil.ReadAll(r.Body)
+			if r.Header.Get("Key") != "testkey" || r.Header.Get("Sign") != testSigner.LiquiSign(string(raw)) {
+				t.Errorf("Expected request to be signed, got key %q sign %q", r.Header.Get("Key"), r.Header.Get("Sign"))
+			}
+			*params, _ = url.ParseQuery(string(raw))
+		} else {
+			*params = r.URL.Query()
+			params.Set("path", r.URL.Path)
+		}
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+	endpoint := NewLiquiEndpoint(testSigner, &testInterface{server.URL})
+	return endpoint, params, server.Close
+}
+
+func TestTransHistory(t *testing.T) {
+	endpoint, params, stop := newTestEndpoint(t, http.StatusOK, `{"success": 1, "return": {
+		"1001": {"type": 1, "amount": 10.5, "currency": "KNC", "desc": "KNC deposit", "status": 2, "timestamp": 1510000100},
+		"2001": {"type": 2, "amount": 1.25, "currency": "OMG", "desc": "OMG withdrawal", "status": 0, "timestamp": 1510000200}}}`)
+	defer stop()
+
+	history, err := endpoint.TransHistory(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("Expected transaction history, got %v", err)
+	}
+	if params.Get("method") != "TransHistory" || params.Get("count") != "1000" || params.Get("nonce") == "" {
+		t.Errorf("Expected a TransHistory request with count and nonce, got %v", *params)
+	}
+	if len(history.Return) != 2 {
+		t.Fatalf("Expected 2 transactions, got %d", len(history.Return))
+	}
+	deposit := history.Return["1001"]
+	if deposit.Type != 1 || deposit.Amount != 10.5 || deposit.Currency != "KNC" || deposit.Status != 2 || deposit.Timestamp != 1510000100 {
+		t.Errorf("Expected KNC deposit of 10.5, got %+v", deposit)
+	}
+	withdrawal := history.Return["2001"]
+	if withdrawal.Type != 2 || withdrawal.Amount != 1.25 || withdrawal.Currency != "OMG" || withdrawal.Status != 0 {
+		t.Errorf("Expected canceled OMG withdrawal of 1.25, got %+v", withdrawal)
+	}
+}
+
+func TestGetExchangeInfo(t *testing.T) {
+	endpoint, params, stop := newTestEndpoint(t, http.StatusOK, `{"server_time": 1510000000, "pairs": {
+		"knc_eth": {"decimal_places": 8, "min_price": 0.00001, "max_price": 10, "min_amount": 0.1, "max_amount": 1000000, "min_total": 0.0001, "hidden": 0, "fee": 0.25}}}`)
+	defer stop()
+
+	info, err := endpoint.GetExchangeInfo()
+	if err != nil {
+		t.Fatalf("Expected exchange info, got %v", err)
+	}
+	if params.Get("path") != "/info" {
+		t.Errorf("Expected request to /info, got %s", params.Get("path"))
+	}
+	pair, found := info.Pairs["knc_eth"]
+	if !found {
+		t.Fatalf("Expected knc_eth pair, got %v", info.Pairs)
+	}
+	if pair.DecimalPlaces != 8 || pair.MinAmount != 0.1 || pair.MaxPrice != 10 || pair.MinTotal != 0.0001 {
+		t.Errorf("Expected knc_eth limits to be parsed, got %+v", pair)
+	}
+}
+
+func TestFailedResponses(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{name: "error", status: http.StatusOK, body: `{"success": 0, "error": "invalid nonce"}`},
+		{name: "malformed", status: http.StatusOK, body: `<html>502 Bad Gateway</html>`},
+		{name: "unavailable", status: http.StatusServiceUnavailable, body: `{}`},
+	}
+	for _, test := range tests {
+		endpoint, _, stop := newTestEndpoint(t, test.status, test.body)
+		if _, err := endpoint.TransHistory(context.Background(), 1); err == nil {
+			t.Errorf("%s: expected TransHistory to fail", test.name)
+		}
+		if _, err := endpoint.GetExchangeInfo(); err == nil {
+			t.Errorf("%s: expected GetExchangeInfo to fail", test.name)
+		}
+		stop()
+	}
+}
@@ -0,0 +1,34 @@
+package liqui
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/KyberNetwork/reserve-data/exchange"
+)
+
+func init() {
+	exchange.RegisterExchange("liqui", NewExchange)
+}
+
+// NewExchange builds liqui adapter on the endpoint of env
+func NewExchange(env string, signer interface{}, storage interface{}) (exchange.Adapter, error) {
+	liquiSigner, ok := signer.(Signer)
+	if !ok {
+		return nil, errors.New("Signer doesn't have liqui keys")
+	}
+	var endpoint *LiquiEndpoint
+	switch env {
+	case exchange.ENV_MAINNET:
+		endpoint = NewRealLiquiEndpoint(liquiSigner)
+	case exchange.ENV_ROPSTEN:
+		endpoint = NewRopstenLiquiEndpoint(liquiSigner)
+	case exchange.ENV_DEV:
+		endpoint = NewDevLiquiEndpoint(liquiSigner)
+	case exchange.ENV_SIMULATION:
+		endpoint = NewSimulatedLiquiEndpoint(liquiSigner)
+	default:
+		return nil, errors.New(fmt.Sprintf("Environment %s is not supported", env))
+	}
+	return exchange.NewLiqui(endpoint), nil
+}
@@ -10,9 +10,13 @@ type Liqinfo struct {
 }
 
 type Liqwithdraw struct {
-	Success int                    `json:"success"`
-	Return  map[string]interface{} `json:"return"`
-	Error   string                 `json:"error"`
+	Success int `json:"success"`
+	Return  struct {
+		TID        uint64             `json:"tId"`
+		AmountSent float64            `json:"amountSent"`
+		Funds      map[string]float64 `json:"funds"`
+	} `json:"return"`
+	Error string `json:"error"`
 }
 
 type Liqtrade struct {
@@ -59,3 +63,62 @@ type Liqcancel struct {
 	} `json:"return"`
 	Error string `json:"error"`
 }
+
+type Liqpairinfo struct {
+	DecimalPlaces int     `json:"decimal_places"`
+	MinPrice      float64 `json:"min_price"`
+	MaxPrice      float64 `json:"max_price"`
+	MinAmount     float64 `json:"min_amount"`
+	MaxAmount     float64 `json:"max_amount"`
+	MinTotal      float64 `json:"min_total"`
+	Hidden        int     `json:"hidden"`
+	Fee           float64 `json:"fee"`
+}
+
+// Response of public /info endpoint, pairs are keyed by lower case
+// base_quote, eg. omg_eth
+type Liqexchangeinfo struct {
+	ServerTime uint64                 `json:"server_time"`
+	Pairs      map[string]Liqpairinfo `json:"pairs"`
+	Success    int                    `json:"success"`
+	Error      string                 `json:"error"`
+}
+
+// Type: 1 - deposit, 2 - withdrawal
+// Trades of our orders keyed by trade id, type is buy or sell
+type Liqtradehistory struct {
+	Success int `json:"success"`
+	Return  map[string]struct {
+		Pair        string  `json:"pair"`
+		Type        string  `json:"type"`
+		Amount      float64 `json:"amount"`
+		Rate        float64 `json:"rate"`
+		OrderID     uint64  `json:"order_id"`
+		IsYourOrder int     `json:"is_your_order"`
+		Timestamp   uint64  `json:"timestamp"`
+	} `json:"return"`
+	Error string `json:"error"`
+}
+
+type Liqdepositaddress struct {
+	Success int `json:"success"`
+	Return  struct {
+		Address string `json:"address"`
+	} `json:"return"`
+	Error string `json:"error"`
+}
+
+// Status: 0 - canceled/failed, 1 - waiting for acceptance,
+// 2 - successful, 3 - not confirmed
+type Liqtranshistory struct {
+	Success int `json:"success"`
+	Return  map[string]struct {
+		Type      int     `json:"type"`
+		Amount    float64 `json:"amount"`
+		Currency  string  `json:"currency"`
+		Desc      string  `json:"desc"`
+		Status    int     `json:"status"`
+		Timestamp uint64  `json:"timestamp"`
+	} `json:"return"`
+	Error string `json:"error"`
+}
@@ -1,6 +1,7 @@
 package exchange
 
 import (
+	"context"
 	"math/big"
 
 	"github.com/KyberNetwork/reserve-data/common"
@@ -8,25 +9,36 @@ import (
 )
 
 type LiquiInterface interface {
-	Depth(tokens string, timepoint uint64) (Liqresp, error)
+	Depth(ctx context.Context, tokens string, timepoint uint64) (Liqresp, error)
 
-	GetInfo(timepoint uint64) (Liqinfo, error)
+	GetInfo(ctx context.Context, timepoint uint64) (Liqinfo, error)
+
+	GetExchangeInfo() (Liqexchangeinfo, error)
+
+	TransHistory(ctx context.Context, timepoint uint64) (Liqtranshistory, error)
+
+	// since is in seconds as liqui expects
+	TradeHistory(ctx context.Context, pair common.TokenPair, since uint64) (Liqtradehistory, error)
+
+	GetDepositAddress(currency string) (Liqdepositaddress, error)
 
 	ActiveOrders(timepoint uint64) (Liqorders, error)
 
-	OrderInfo(orderID string, timepoint uint64) (Liqorderinfo, error)
+	OrderInfo(ctx context.Context, orderID string, timepoint uint64) (Liqorderinfo, error)
 
 	Withdraw(
+		ctx context.Context,
 		token common.Token,
 		amount *big.Int,
 		address ethereum.Address,
-		timepoint uint64) error
+		timepoint uint64) (Liqwithdraw, error)
 
 	Trade(
+		ctx context.Context,
 		tradeType string,
 		base, quote common.Token,
 		rate, amount float64,
 		timepoint uint64) (id string, done float64, remaining float64, finished bool, err error)
 
-	CancelOrder(id string) (Liqcancel, error)
+	CancelOrder(ctx context.Context, id string) (Liqcancel, error)
 }
@@ -0,0 +1,102 @@
+package exchange
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+// testLiquiHistory serves a canned transaction history
+type testLiquiHistory struct {
+	LiquiInterface
+	history string
+}
+
+func (self *testLiquiHistory) TransHistory(ctx context.Context, timepoint uint64) (Liqtranshistory, error) {
+	result := Liqtranshistory{}
+	err := json.Unmarshal([]byte(self.history), &result)
+	return result, err
+}
+
+const liquiTransHistory = `{"success": 1, "return": {
+	"1001": {"type": 1, "amount": 10.5, "currency": "KNC", "desc": "KNC deposit", "status": 2, "timestamp": 1510000100},
+	"1002": {"type": 1, "amount": 3, "currency": "OMG", "desc": "OMG deposit", "status": 0, "timestamp": 1510000100},
+	"1003": {"type": 1, "amount": 4, "currency": "OMG", "desc": "OMG deposit", "status": 1, "timestamp": 1510000100},
+	"1004": {"type": 1, "amount": 5, "currency": "OMG", "desc": "OMG deposit", "status": 3, "timestamp": 1510000100},
+	"1005": {"type": 1, "amount": 7, "currency": "EOS", "desc": "EOS deposit", "status": 2, "timestamp": 1509999000},
+	"2001": {"type": 2, "amount": 1, "currency": "KNC", "desc": "KNC withdrawal", "status": 2, "timestamp": 1510000100},
+	"2002": {"type": 2, "amount": 1, "currency": "KNC", "desc": "KNC withdrawal", "status": 0, "timestamp": 1510000100},
+	"2003": {"type": 2, "amount": 1, "currency": "KNC", "desc": "KNC withdrawal", "status": 1, "timestamp": 1510000100},
+	"2004": {"type": 2, "amount": 1, "currency": "KNC", "desc": "KNC withdrawal", "status": 3, "timestamp": 1510000100}}}`
+
+// activities are created at 1510000000 seconds, ids are in nanoseconds
+const liquiActivityTimepoint uint64 = 1510000000 * 1000000000
+
+func liquiTestTokens() {
+	common.SupportedTokens = map[string]common.Token{}
+	for _, id := range []string{"ETH", "OMG", "DGD", "CVC", "MCO", "GNT", "ADX", "EOS", "PAY", "BAT", "KNC"} {
+		common.SupportedTokens[id] = common.Token{ID: id, Decimal: 18}
+	}
+}
+
+func TestLiquiDepositStatus(t *testing.T) {
+	liquiTestTokens()
+	liqui := NewLiqui(&testLiquiHistory{history: liquiTransHistory})
+	tests := []struct {
+		name   string
+		eid    string
+		status string
+		failed bool
+	}{
+		{name: "matching credit", eid: "0x1234|KNC|10.5", status: "done"},
+		{name: "credit before since", eid: "0x1234|EOS|7", status: ""},
+		{name: "wrong currency", eid: "0x1234|OMG|10.5", status: ""},
+		{name: "wrong amount", eid: "0x1234|KNC|10.4", status: ""},
+		{name: "withdrawal is not a credit", eid: "0x1234|KNC|1", status: ""},
+		{name: "canceled", eid: "0x1234|OMG|3", status: "failed"},
+		{name: "waiting for acceptance", eid: "0x1234|OMG|4", status: ""},
+		{name: "not confirmed", eid: "0x1234|OMG|5", status: ""},
+		{name: "missing amount", eid: "0x1234|KNC", failed: true},
+		{name: "malformed amount", eid: "0x1234|KNC|abc", failed: true},
+	}
+	for _, test := range tests {
+		id := common.NewActivityID(liquiActivityTimepoint, test.eid)
+		status, err := liqui.DepositStatus(context.Background(), id, 1)
+		if (err != nil) != test.failed {
+			t.Errorf("%s: expected failure %t, got %v", test.name, test.failed, err)
+		}
+		if status != test.status {
+			t.Errorf("%s: expected status %q, got %q", test.name, test.status, status)
+		}
+	}
+}
+
+func TestLiquiWithdrawStatus(t *testing.T) {
+	liquiTestTokens()
+	liqui := NewLiqui(&testLiquiHistory{history: liquiTransHistory})
+	tests := []struct {
+		name   string
+		eid    string
+		status string
+		failed bool
+	}{
+		{name: "successful", eid: "2001|KNC", status: "done"},
+		{name: "canceled", eid: "2002|KNC", status: "failed"},
+		{name: "waiting for acceptance", eid: "2003|KNC", status: ""},
+		{name: "not confirmed", eid: "2004|KNC", status: ""},
+		{name: "not found", eid: "2005|KNC", failed: true},
+		{name: "missing currency", eid: "2001", failed: true},
+	}
+	for _, test := range tests {
+		id := common.NewActivityID(liquiActivityTimepoint, test.eid)
+		status, tx, err := liqui.WithdrawStatus(context.Background(), id, 1)
+		if (err != nil) != test.failed {
+			t.Errorf("%s: expected failure %t, got %v", test.name, test.failed, err)
+		}
+		if status != test.status || tx != "" {
+			t.Errorf("%s: expected status %q without tx, got %q with tx %q", test.name, test.status, status, tx)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package exchange
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+// OrderBook is a local copy of one pair's order book, kept in sync by
+// an exchange stream. It is written by the stream goroutine and read
+// concurrently by the fetcher.
+type OrderBook struct {
+	mu       sync.RWMutex
+	valid    bool
+	err      string
+	updateID int64
+	bids     map[float64]float64
+	asks     map[float64]float64
+}
+
+func NewOrderBook() *OrderBook {
+	return &OrderBook{
+		mu:    sync.RWMutex{},
+		valid: false,
+		err:   "order book is not synced yet",
+		bids:  map[float64]float64{},
+		asks:  map[float64]float64{},
+	}
+}
+
+// Reset replaces the whole book with a snapshot and marks it valid.
+func (self *OrderBook) Reset(updateID int64, bids, asks []common.PriceEntry) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.bids = map[float64]float64{}
+	self.asks = map[float64]float64{}
+	for _, bid := range bids {
+		self.bids[bid.Rate] = bid.Quantity
+	}
+	for _, ask := range asks {
+		self.asks[ask.Rate] = ask.Quantity
+	}
+	self.updateID = updateID
+	self.valid = true
+	self.err = ""
+}
+
+// Apply updates price levels of the book, a level with zero quantity
+// is removed.
+func (self *OrderBook) Apply(updateID int64, bids, asks []common.PriceEntry) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	applyLevels(self.bids, bids)
+	applyLevels(self.asks, asks)
+	self.updateID = updateID
+}
+
+func applyLevels(levels map[float64]float64, changes []common.PriceEntry) {
+	for _, change := range changes {
+		if change.Quantity == 0 {
+			delete(levels, change.Rate)
+		} else {
+			levels[change.Rate] = change.Quantity
+		}
+	}
+}
+
+// Invalidate marks the book as out of sync until the next Reset.
+func (self *OrderBook) Invalidate(reason string) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.valid = false
+	self.err = reason
+}
+
+func (self *OrderBook) UpdateID() int64 {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	return self.updateID
+}
+
+// Snapshot returns at most depth best levels of each side, bids are
+// sorted descending and asks ascending by rate.
+func (self *OrderBook) Snapshot(depth int, timepoint uint64) common.ExchangePrice {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	result := common.ExchangePrice{}
+	result.Timestamp = common.Timestamp(fmt.Sprintf("%d", timepoint))
+	result.ReturnTime = common.GetTimestamp()
+	result.Valid = self.valid
+	result.Error = self.err
+	if !self.valid {
+		return result
+	}
+	result.Bids = sortedLevels(self.bids, depth, true)
+	result.Asks = sortedLevels(self.asks, depth, false)
+	return result
+}
+
+func sortedLevels(levels map[float64]float64, depth int, descending bool) []common.PriceEntry {
+	result := []common.PriceEntry{}
+	for rate, quantity := range levels {
+		result = append(result, common.PriceEntry{quantity, rate})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if descending {
+			return result[i].Rate > result[j].Rate
+		}
+		return result[i].Rate < result[j].Rate
+	})
+	if len(result) > depth {
+		result = result[:depth]
+	}
+	return result
+}
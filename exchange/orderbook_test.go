@@ -0,0 +1,49 @@
+package exchange
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+func TestOrderBookAppliesLevels(t *testing.T) {
+	book := NewOrderBook()
+	if book.Snapshot(10, 1000).Valid {
+		t.Fatalf("Expected book not to be valid before the first snapshot")
+	}
+	book.Reset(10,
+		[]common.PriceEntry{{5, 0.010}, {2, 0.009}},
+		[]common.PriceEntry{{3, 0.020}},
+	)
+	// a zero quantity removes its level
+	book.Apply(11,
+		[]common.PriceEntry{{0, 0.009}, {1, 0.011}},
+		[]common.PriceEntry{{4, 0.019}},
+	)
+	snapshot := book.Snapshot(10, 1000)
+	if !snapshot.Valid || snapshot.Timestamp != "1000" {
+		t.Fatalf("Expected valid book at 1000, got %+v", snapshot)
+	}
+	if fmt.Sprint(snapshot.Bids) != "[{1 0.011} {5 0.01}]" || fmt.Sprint(snapshot.Asks) != "[{4 0.019} {3 0.02}]" {
+		t.Fatalf("Expected bids descending and asks ascending, got %v %v", snapshot.Bids, snapshot.Asks)
+	}
+	if fmt.Sprint(book.Snapshot(1, 1000).Bids) != "[{1 0.011}]" {
+		t.Fatalf("Expected snapshot to keep the best levels only")
+	}
+}
+
+func TestOrderBookIsInvalidUntilReset(t *testing.T) {
+	book := NewOrderBook()
+	book.Reset(10, []common.PriceEntry{{5, 0.010}}, nil)
+	book.Invalidate("Gap in depth updates")
+	snapshot := book.Snapshot(10, 1000)
+	if snapshot.Valid || snapshot.Error != "Gap in depth updates" || len(snapshot.Bids) != 0 {
+		t.Fatalf("Expected invalidated book to be reported invalid without levels, got %+v", snapshot)
+	}
+	book.Reset(20, []common.PriceEntry{{1, 0.012}}, nil)
+	snapshot = book.Snapshot(10, 1000)
+	if !snapshot.Valid || fmt.Sprint(snapshot.Bids) != "[{1 0.012}]" || book.UpdateID() != 20 {
+		t.Fatalf("Expected reset book to hold the new snapshot only, got %+v", snapshot)
+	}
+}
@@ -0,0 +1,99 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+// Environments an exchange factory is asked to build for
+const (
+	ENV_MAINNET    string = "mainnet"
+	ENV_ROPSTEN    string = "ropsten"
+	ENV_DEV        string = "dev"
+	ENV_SIMULATION string = "simulation"
+)
+
+// Adapter is what every registered exchange implements on top of
+// common.Exchange so they are all set up the same way
+type Adapter interface {
+	common.Exchange
+	UpdateDepositAddress(token common.Token, address string)
+	UpdatePairsPrecision()
+	UpdateFees() error
+	DiscoverDepositAddresses() error
+}
+
+// ExchangeFactory builds an exchange talking to the endpoints of env.
+// signer and storage are passed as is, the factory checks they provide
+// what its exchange needs.
+type ExchangeFactory func(env string, signer interface{}, storage interface{}) (Adapter, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = map[string]ExchangeFactory{}
+)
+
+// RegisterExchange makes an exchange available under name. It is meant
+// to be called from init of the exchange's endpoint package and panics
+// if name is already taken.
+func RegisterExchange(name string, factory ExchangeFactory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	if _, found := factories[name]; found {
+		panic(fmt.Sprintf("Exchange %s is registered twice", name))
+	}
+	factories[name] = factory
+}
+
+// RegisteredExchanges returns names of registered exchanges, sorted
+func RegisteredExchanges() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+	result := []string{}
+	for name := range factories {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// NewExchange builds the exchange registered under name, sets its
+// configured deposit addresses, loads its pairs precision and starts
+// refreshing its fees and deposit addresses in background until ctx is
+// done.
+func NewExchange(
+	ctx context.Context,
+	name, env string,
+	signer interface{}, storage interface{},
+	addresses map[string]string) (Adapter, error) {
+
+	factoriesMu.RLock()
+	factory, found := factories[name]
+	factoriesMu.RUnlock()
+	if !found {
+		return nil, errors.New(fmt.Sprintf(
+			"Exchange %s is not supported, supported exchanges are: %s",
+			name, strings.Join(RegisteredExchanges(), ", ")))
+	}
+	ex, err := factory(env, signer, storage)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Creating exchange %s failed: %s", name, err))
+	}
+	for tokenID, addr := range addresses {
+		token, err := common.GetToken(tokenID)
+		if err != nil {
+			return nil, err
+		}
+		ex.UpdateDepositAddress(token, addr)
+	}
+	ex.UpdatePairsPrecision()
+	go RunFeeUpdater(ctx, ex, FEE_UPDATE_INTERVAL)
+	go RunDepositAddressDiscovery(ctx, ex, DEPOSIT_ADDRESS_UPDATE_INTERVAL)
+	return ex, nil
+}
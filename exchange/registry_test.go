@@ -0,0 +1,88 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/KyberNetwork/reserve-data/common"
+)
+
+type testAdapter struct {
+	common.TestExchange
+	addresses map[string]string
+	precision bool
+}
+
+func (self *testAdapter) UpdateDepositAddress(token common.Token, address string) {
+	self.addresses[token.ID] = address
+}
+
+func (self *testAdapter) UpdatePairsPrecision() {
+	self.precision = true
+}
+
+func (self *testAdapter) UpdateFees() error {
+	return nil
+}
+
+func (self *testAdapter) DiscoverDepositAddresses() error {
+	return nil
+}
+
+func TestNewExchangeFromRegistry(t *testing.T) {
+	common.SupportedTokens = map[string]common.Token{
+		"OMG": common.Token{"OMG", "0x1795b4560491c941c0635451f07332effe3ee7b3", 18},
+	}
+	var gotEnv string
+	var gotSigner, gotStorage interface{}
+	RegisterExchange("testregistry", func(env string, signer interface{}, storage interface{}) (Adapter, error) {
+		gotEnv, gotSigner, gotStorage = env, signer, storage
+		return &testAdapter{addresses: map[string]string{}}, nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ex, err := NewExchange(
+		ctx, "testregistry", ENV_SIMULATION, "signer", "storage",
+		map[string]string{"OMG": "0x9db6e8d2d133448dbcf755f19d540253da4ba043"})
+	if err != nil {
+		t.Fatalf("Expected exchange to be created, got %v", err)
+	}
+	if gotEnv != ENV_SIMULATION || gotSigner != "signer" || gotStorage != "storage" {
+		t.Fatalf("Expected factory to get env, signer and storage, got %v %v %v", gotEnv, gotSigner, gotStorage)
+	}
+	adapter := ex.(*testAdapter)
+	if adapter.addresses["OMG"] != "0x9db6e8d2d133448dbcf755f19d540253da4ba043" || !adapter.precision {
+		t.Fatalf("Expected exchange to be set up, got %v", adapter)
+	}
+}
+
+func TestNewExchangeUnknownName(t *testing.T) {
+	_, err := NewExchange(context.Background(), "unknownexchange", ENV_DEV, nil, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "unknownexchange") {
+		t.Fatalf("Expected unknown exchange to be an error, got %v", err)
+	}
+}
+
+func TestNewExchangeFactoryError(t *testing.T) {
+	RegisterExchange("testfailing", func(env string, signer interface{}, storage interface{}) (Adapter, error) {
+		return nil, errors.New("no keys")
+	})
+	if _, err := NewExchange(context.Background(), "testfailing", ENV_DEV, nil, nil, nil); err == nil {
+		t.Fatalf("Expected factory error to be returned")
+	}
+}
+
+func TestRegisterExchangeTwicePanics(t *testing.T) {
+	factory := func(env string, signer interface{}, storage interface{}) (Adapter, error) {
+		return nil, nil
+	}
+	RegisterExchange("testtwice", factory)
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("Expected registering a name twice to panic")
+		}
+	}()
+	RegisterExchange("testtwice", factory)
+}
@@ -1,6 +1,10 @@
 package http
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"math/big"
@@ -27,6 +31,7 @@ type HTTPServer struct {
 	authEnabled bool
 	auth        Authentication
 	r           *gin.Engine
+	server      *http.Server
 }
 
 const MAX_TIMESPOT uint64 = 18446744073709551615
@@ -45,10 +50,10 @@ func getTimePoint(c *gin.Context, useDefault bool) uint64 {
 	} else {
 		timepoint, err := strconv.ParseUint(timestamp, 10, 64)
 		if err != nil {
-			log.Printf("Interpreted timestamp(%s) to default - %s\n", timestamp, MAX_TIMESPOT)
+			log.Printf("Interpreted timestamp(%s) to default - %d\n", timestamp, MAX_TIMESPOT)
 			return MAX_TIMESPOT
 		} else {
-			log.Printf("Interpreted timestamp(%s) to %s\n", timestamp, timepoint)
+			log.Printf("Interpreted timestamp(%s) to %d\n", timestamp, timepoint)
 			return timepoint
 		}
 	}
@@ -369,7 +374,7 @@ func (self *HTTPServer) Trade(c *gin.Context) {
 		return
 	}
 	id, done, remaining, finished, err := self.core.Trade(
-		exchange, typeParam, base, quote, rate, amount, getTimePoint(c, false))
+		c.Request.Context(), exchange, typeParam, base, quote, rate, amount, getTimePoint(c, false))
 	if err != nil {
 		c.JSON(
 			http.StatusOK,
@@ -415,7 +420,7 @@ func (self *HTTPServer) CancelOrder(c *gin.Context) {
 		)
 		return
 	}
-	err = self.core.CancelOrder(activityID, exchange)
+	err = self.core.CancelOrder(c.Request.Context(), activityID, exchange)
 	if err != nil {
 		c.JSON(
 			http.StatusOK,
@@ -466,7 +471,7 @@ func (self *HTTPServer) Withdraw(c *gin.Context) {
 		return
 	}
 	log.Printf("Withdraw %s %s from %s\n", amount.Text(10), token.ID, exchange.ID())
-	id, err := self.core.Withdraw(exchange, token, amount, getTimePoint(c, false))
+	id, err := self.core.Withdraw(c.Request.Context(), exchange, token, amount, getTimePoint(c, false))
 	if err != nil {
 		c.JSON(
 			http.StatusOK,
@@ -535,14 +540,104 @@ func (self *HTTPServer) Deposit(c *gin.Context) {
 	)
 }
 
+// GetActivities returns a page of activities, newest first, selected by
+// the filters given and where the next page starts
 func (self *HTTPServer) GetActivities(c *gin.Context) {
-	log.Printf("Getting all activity records \n")
-	_, ok := self.Authenticated(c, []string{})
+	log.Printf("Getting activity records \n")
+	params, ok := self.Authenticated(c, []string{})
 	if !ok {
 		return
 	}
+	filter := common.ActivityFilter{
+		Destination:    params.Get("destination"),
+		Token:          params.Get("token"),
+		Action:         params.Get("action"),
+		ExchangeStatus: params.Get("exchange_status"),
+		MiningStatus:   params.Get("mining_status"),
+	}
+	var err error
+	if params.Get("fromTime") != "" {
+		filter.FromTime, err = strconv.ParseUint(params.Get("fromTime"), 10, 64)
+		if err != nil {
+			c.JSON(
+				http.StatusOK,
+				gin.H{"success": false, "reason": err.Error()},
+			)
+			return
+		}
+	}
+	if params.Get("toTime") != "" {
+		filter.ToTime, err = strconv.ParseUint(params.Get("toTime"), 10, 64)
+		if err != nil {
+			c.JSON(
+				http.StatusOK,
+				gin.H{"success": false, "reason": err.Error()},
+			)
+			return
+		}
+	}
+	limit := common.DEFAULT_ACTIVITY_LIMIT
+	if params.Get("limit") != "" {
+		limit, err = strconv.Atoi(params.Get("limit"))
+		if err == nil && (limit <= 0 || limit > common.MAX_ACTIVITY_LIMIT) {
+			err = errors.New(fmt.Sprintf("limit must be from 1 to %d", common.MAX_ACTIVITY_LIMIT))
+		}
+		if err != nil {
+			c.JSON(
+				http.StatusOK,
+				gin.H{"success": false, "reason": err.Error()},
+			)
+			return
+		}
+	}
+	data, next, err := self.app.GetActivities(filter, params.Get("cursor"), limit)
+	if err != nil {
+		c.JSON(
+			http.StatusOK,
+			gin.H{"success": false, "reason": err.Error()},
+		)
+	} else {
+		c.JSON(
+			http.StatusOK,
+			gin.H{
+				"success": true,
+				"data":    data,
+				"next":    next,
+			},
+		)
+	}
+}
 
-	data, err := self.app.GetRecords()
+func (self *HTTPServer) GetTradeHistory(c *gin.Context) {
+	log.Printf("Getting trade history \n")
+	params, ok := self.Authenticated(c, []string{})
+	if !ok {
+		return
+	}
+	fromTime := uint64(0)
+	toTime := MAX_TIMESPOT
+	var err error
+	if params.Get("fromTime") != "" {
+		fromTime, err = strconv.ParseUint(params.Get("fromTime"), 10, 64)
+		if err != nil {
+			c.JSON(
+				http.StatusOK,
+				gin.H{"success": false, "reason": err.Error()},
+			)
+			return
+		}
+	}
+	if params.Get("toTime") != "" {
+		toTime, err = strconv.ParseUint(params.Get("toTime"), 10, 64)
+		if err != nil {
+			c.JSON(
+				http.StatusOK,
+				gin.H{"success": false, "reason": err.Error()},
+			)
+			return
+		}
+	}
+	data, err := self.app.GetTradeHistory(fromTime, toTime)
 	if err != nil {
 		c.JSON(
 			http.StatusOK,
@@ -559,6 +654,232 @@ func (self *HTTPServer) GetActivities(c *gin.Context) {
 	}
 }
 
+// ReserveTrades returns events logged by the reserve contracts between
+// fromBlock and toBlock, only the ones of kind if it is given
+func (self *HTTPServer) ReserveTrades(c *gin.Context) {
+	log.Printf("Getting reserve events \n")
+	params, ok := self.Authenticated(c, []string{})
+	if !ok {
+		return
+	}
+	fromBlock := uint64(0)
+	toBlock := MAX_TIMESPOT
+	var err error
+	if params.Get("fromBlock") != "" {
+		fromBlock, err = strconv.ParseUint(params.Get("fromBlock"), 10, 64)
+		if err != nil {
+			c.JSON(
+				http.StatusOK,
+				gin.H{"success": false, "reason": err.Error()},
+			)
+			return
+		}
+	}
+	if params.Get("toBlock") != "" {
+		toBlock, err = strconv.ParseUint(params.Get("toBlock"), 10, 64)
+		if err != nil {
+			c.JSON(
+				http.StatusOK,
+				gin.H{"success": false, "reason": err.Error()},
+			)
+			return
+		}
+	}
+	limit := common.DEFAULT_HISTORY_LIMIT
+	if params.Get("limit") != "" {
+		limit, err = strconv.Atoi(params.Get("limit"))
+		if err == nil && (limit <= 0 || limit > common.MAX_HISTORY_LIMIT) {
+			err = errors.New(fmt.Sprintf("limit must be from 1 to %d", common.MAX_HISTORY_LIMIT))
+		}
+		if err != nil {
+			c.JSON(
+				http.StatusOK,
+				gin.H{"success": false, "reason": err.Error()},
+			)
+			return
+		}
+	}
+	events, err := self.app.GetReserveEvents(fromBlock, toBlock)
+	if err != nil {
+		c.JSON(
+			http.StatusOK,
+			gin.H{"success": false, "reason": err.Error()},
+		)
+		return
+	}
+	data, next := common.PageReserveEvents(events, params.Get("kind"), limit)
+	c.JSON(
+		http.StatusOK,
+		gin.H{
+			"success": true,
+			"data":    data,
+			"next":    next,
+		},
+	)
+}
+
+// historyQuery reads the range of a history request, from and to are
+// timestamps in milliseconds, interval is in seconds and limit is the
+// most entries returned
+func historyQuery(params url.Values) (common.HistoryQuery, error) {
+	query := common.HistoryQuery{ToTime: MAX_TIMESPOT, Limit: common.DEFAULT_HISTORY_LIMIT}
+	var err error
+	if params.Get("from") != "" {
+		if query.FromTime, err = strconv.ParseUint(params.Get("from"), 10, 64); err != nil {
+			return query, err
+		}
+	}
+	if params.Get("to") != "" {
+		if query.ToTime, err = strconv.ParseUint(params.Get("to"), 10, 64); err != nil {
+			return query, err
+		}
+	}
+	if params.Get("interval") != "" {
+		interval, err := strconv.ParseUint(params.Get("interval"), 10, 64)
+		if err != nil {
+			return query, err
+		}
+		query.Interval = interval * 1000
+	}
+	if params.Get("limit") != "" {
+		if query.Limit, err = strconv.Atoi(params.Get("limit")); err != nil {
+			return query, err
+		}
+		if query.Limit <= 0 || query.Limit > common.MAX_HISTORY_LIMIT {
+			return query, errors.New(fmt.Sprintf("limit must be from 1 to %d", common.MAX_HISTORY_LIMIT))
+		}
+	}
+	return query, nil
+}
+
+// PriceHistory returns prices stored from "from" to "to", of every pair
+// or only of pair (e.g. KNC-ETH), and where the next page starts. It is
+// routed through /prices/:base as gin can't have /prices/history next to
+// /prices/:base/:quote.
+func (self *HTTPServer) PriceHistory(c *gin.Context) {
+	if c.Param("base") != "history" {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	log.Printf("Getting price history \n")
+	params, ok := self.Authenticated(c, []string{})
+	if !ok {
+		return
+	}
+	query, err := historyQuery(params)
+	if err != nil {
+		c.JSON(
+			http.StatusOK,
+			gin.H{"success": false, "reason": err.Error()},
+		)
+		return
+	}
+	var pairID common.TokenPairID
+	if params.Get("pair") != "" {
+		tokens := strings.Split(params.Get("pair"), "-")
+		if len(tokens) != 2 {
+			c.JSON(
+				http.StatusOK,
+				gin.H{"success": false, "reason": "Token pair is not supported"},
+			)
+			return
+		}
+		pair, err := common.NewTokenPair(tokens[0], tokens[1])
+		if err != nil {
+			c.JSON(
+				http.StatusOK,
+				gin.H{"success": false, "reason": "Token pair is not supported"},
+			)
+			return
+		}
+		pairID = pair.PairID()
+	}
+	data, next, err := self.app.GetPriceHistory(pairID, query)
+	if err != nil {
+		c.JSON(
+			http.StatusOK,
+			gin.H{"success": false, "reason": err.Error()},
+		)
+	} else {
+		c.JSON(
+			http.StatusOK,
+			gin.H{
+				"success": true,
+				"data":    data,
+				"next":    next,
+			},
+		)
+	}
+}
+
+// RateHistory returns rates stored from "from" to "to" and where the
+// next page starts
+func (self *HTTPServer) RateHistory(c *gin.Context) {
+	log.Printf("Getting rate history \n")
+	params, ok := self.Authenticated(c, []string{})
+	if !ok {
+		return
+	}
+	query, err := historyQuery(params)
+	if err != nil {
+		c.JSON(
+			http.StatusOK,
+			gin.H{"success": false, "reason": err.Error()},
+		)
+		return
+	}
+	data, next, err := self.app.GetRateHistory(query)
+	if err != nil {
+		c.JSON(
+			http.StatusOK,
+			gin.H{"success": false, "reason": err.Error()},
+		)
+	} else {
+		c.JSON(
+			http.StatusOK,
+			gin.H{
+				"success": true,
+				"data":    data,
+				"next":    next,
+			},
+		)
+	}
+}
+
+// AuthDataHistory returns auth data stored from "from" to "to" and where
+// the next page starts
+func (self *HTTPServer) AuthDataHistory(c *gin.Context) {
+	log.Printf("Getting auth data history \n")
+	params, ok := self.Authenticated(c, []string{})
+	if !ok {
+		return
+	}
+	query, err := historyQuery(params)
+	if err != nil {
+		c.JSON(
+			http.StatusOK,
+			gin.H{"success": false, "reason": err.Error()},
+		)
+		return
+	}
+	data, next, err := self.app.GetAuthDataHistory(query)
+	if err != nil {
+		c.JSON(
+			http.StatusOK,
+			gin.H{"success": false, "reason": err.Error()},
+		)
+	} else {
+		c.JSON(
+			http.StatusOK,
+			gin.H{
+				"success": true,
+				"data":    data,
+				"next":    next,
+			},
+		)
+	}
+}
+
 func (self *HTTPServer) StopFetcher(c *gin.Context) {
 	err := self.app.Stop()
 	if err != nil {
@@ -830,14 +1151,81 @@ func (self *HTTPServer) GetFee(c *gin.Context) {
 	return
 }
 
-func (self *HTTPServer) Run() {
+func (self *HTTPServer) GetRateLimitUsage(c *gin.Context) {
+	c.JSON(
+		http.StatusOK,
+		gin.H{"success": true, "data": common.GetRateLimitUsages()},
+	)
+}
+
+func (self *HTTPServer) GetBreakers(c *gin.Context) {
+	c.JSON(
+		http.StatusOK,
+		gin.H{"success": true, "data": self.app.GetBreakers()},
+	)
+}
+
+// FetchMetrics serves metrics of fetches in prometheus text format
+func (self *HTTPServer) FetchMetrics(c *gin.Context) {
+	buffer := bytes.Buffer{}
+	if err := self.app.WriteFetchMetrics(&buffer); err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.Data(http.StatusOK, "text/plain; version=0.0.4", buffer.Bytes())
+}
+
+func (self *HTTPServer) GetSchedules(c *gin.Context) {
+	data, err := self.app.GetSchedules()
+	if err != nil {
+		c.JSON(
+			http.StatusOK,
+			gin.H{"success": false, "reason": err.Error()},
+		)
+	} else {
+		c.JSON(
+			http.StatusOK,
+			gin.H{"success": true, "data": data},
+		)
+	}
+}
+
+func (self *HTTPServer) SetSchedules(c *gin.Context) {
+	postForm, ok := self.Authenticated(c, []string{"schedules"})
+	if !ok {
+		return
+	}
+	schedules := common.NewSchedules(nil)
+	err := json.Unmarshal([]byte(postForm.Get("schedules")), schedules)
+	if err == nil {
+		err = self.app.SetSchedules(schedules)
+	}
+	if err != nil {
+		c.JSON(
+			http.StatusOK,
+			gin.H{"success": false, "reason": err.Error()},
+		)
+	} else {
+		c.JSON(
+			http.StatusOK,
+			gin.H{"success": true, "data": schedules},
+		)
+	}
+}
+
+func (self *HTTPServer) Run() error {
 	self.r.GET("/prices", self.AllPrices)
+	self.r.GET("/prices/:base", self.PriceHistory)
 	self.r.GET("/prices/:base/:quote", self.Price)
 	self.r.GET("/getrates", self.GetRate)
+	self.r.GET("/getrates/history", self.RateHistory)
 
 	self.r.GET("/authdata", self.AuthData)
+	self.r.GET("/authdata/history", self.AuthDataHistory)
 	self.r.GET("/activities", self.GetActivities)
 	self.r.GET("/immediate-pending-activities", self.ImmediatePendingActivities)
+	self.r.GET("/tradehistory", self.GetTradeHistory)
+	self.r.GET("/reserve-trades", self.ReserveTrades)
 
 	self.r.GET("/metrics", self.Metrics)
 	self.r.POST("/metrics", self.StoreMetrics)
@@ -851,8 +1239,24 @@ func (self *HTTPServer) Run() {
 	self.r.GET("/exchangeinfo/:exchangeid/:base/:quote", self.GetPairInfo)
 	self.r.GET("/exchangefees", self.GetFee)
 	self.r.GET("/exchangefees/:exchangeid", self.GetExchangeFee)
+	self.r.GET("/ratelimits", self.GetRateLimitUsage)
+	self.r.GET("/breakers", self.GetBreakers)
+	self.r.GET("/schedules", self.GetSchedules)
+	self.r.POST("/schedules", self.SetSchedules)
+	self.r.GET("/debug/metrics", self.FetchMetrics)
+
+	log.Printf("Listening and serving HTTP on %s\n", self.host)
+	err := self.server.ListenAndServe()
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
 
-	self.r.Run(self.host)
+// Shutdown stops accepting requests and waits for the ones in flight
+// until ctx is done
+func (self *HTTPServer) Shutdown(ctx context.Context) error {
+	return self.server.Shutdown(ctx)
 }
 
 func NewHTTPServer(
@@ -870,5 +1274,6 @@ func NewHTTPServer(
 
 	return &HTTPServer{
 		app, core, metric, host, enableAuth, authEngine, r,
+		&http.Server{Addr: host, Handler: r},
 	}
 }
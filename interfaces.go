@@ -1,7 +1,9 @@
 package reserve
 
 import (
+	"context"
 	"github.com/KyberNetwork/reserve-data/common"
+	"io"
 	"math/big"
 )
 
@@ -10,16 +12,29 @@ type ReserveData interface {
 	CurrentPriceVersion(timestamp uint64) (common.Version, error)
 	GetAllPrices(timestamp uint64) (common.AllPriceResponse, error)
 	GetOnePrice(id common.TokenPairID, timestamp uint64) (common.OnePriceResponse, error)
+	GetPriceHistory(pair common.TokenPairID, query common.HistoryQuery) ([]common.AllPriceResponse, uint64, error)
 
 	CurrentAuthDataVersion(timestamp uint64) (common.Version, error)
 	GetAuthData(timestamp uint64) (common.AuthDataResponse, error)
+	GetAuthDataHistory(query common.HistoryQuery) ([]common.AuthDataResponse, uint64, error)
 
 	CurrentRateVersion(timestamp uint64) (common.Version, error)
 	GetAllRates(timestamp uint64) (common.AllRateResponse, error)
+	GetRateHistory(query common.HistoryQuery) ([]common.AllRateResponse, uint64, error)
 
 	GetRecords() ([]common.ActivityRecord, error)
+	GetActivities(filter common.ActivityFilter, cursor string, limit int) ([]common.ActivityRecord, string, error)
 	GetPendingActivities() ([]common.ActivityRecord, error)
 
+	GetTradeHistory(fromTime, toTime uint64) ([]common.TradeFillRecord, error)
+	GetReserveEvents(fromBlock, toBlock uint64) ([]common.ReserveEvent, error)
+
+	GetBreakers() map[common.ExchangeID]common.BreakerStatus
+
+	GetSchedules() (*common.Schedules, error)
+	SetSchedules(schedules *common.Schedules) error
+	WriteFetchMetrics(w io.Writer) error
+
 	Run() error
 	Stop() error
 }
@@ -27,6 +42,7 @@ type ReserveData interface {
 type ReserveCore interface {
 	// place order
 	Trade(
+		ctx context.Context,
 		exchange common.Exchange,
 		tradeType string,
 		base common.Token,
@@ -42,12 +58,13 @@ type ReserveCore interface {
 		timestamp uint64) (common.ActivityID, error)
 
 	Withdraw(
+		ctx context.Context,
 		exchange common.Exchange,
 		token common.Token,
 		amount *big.Int,
 		timestamp uint64) (common.ActivityID, error)
 
-	CancelOrder(id common.ActivityID, exchange common.Exchange) error
+	CancelOrder(ctx context.Context, id common.ActivityID, exchange common.Exchange) error
 
 	// blockchain related action
 	SetRates(tokens []common.Token, buys, sells []*big.Int, block *big.Int) (common.ActivityID, error)
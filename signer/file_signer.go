@@ -4,6 +4,7 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"crypto/sha512"
+	"encoding/base64"
 	"encoding/json"
 	"io/ioutil"
 	"math/big"
@@ -23,6 +24,8 @@ type FileSigner struct {
 	BittrexSecret  string `json:"bittrex_secret"`
 	BitfinexKey    string `json:"bitfinex_key"`
 	BitfinexSecret string `json:"bitfinex_secret"`
+	HuobiKey       string `json:"huobi_key"`
+	HuobiSecret    string `json:"huobi_secret"`
 	Keystore       string `json:"keystore_path"`
 	Passphrase     string `json:"passphrase"`
 	KNSecret       string `json:"kn_secret"`
@@ -57,6 +60,10 @@ func (self FileSigner) GetBinanceKey() string {
 	return self.BinanceKey
 }
 
+func (self FileSigner) GetHuobiKey() string {
+	return self.HuobiKey
+}
+
 func (self FileSigner) KNSign(msg string) string {
 	mac := hmac.New(sha512.New, []byte(self.KNSecret))
 	mac.Write([]byte(msg))
@@ -88,6 +95,12 @@ func (self FileSigner) BinanceSign(msg string) string {
 	return result
 }
 
+func (self FileSigner) HuobiSign(msg string) string {
+	mac := hmac.New(sha256.New, []byte(self.HuobiSecret))
+	mac.Write([]byte(msg))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
 func NewFileSigner(file string) *FileSigner {
 	raw, err := ioutil.ReadFile(file)
 	if err != nil {
@@ -0,0 +1,20 @@
+package signer
+
+import (
+	"testing"
+)
+
+func TestHuobiSign(t *testing.T) {
+	signer := FileSigner{
+		HuobiKey:    "e2xxxxxx-99xxxxxx-84xxxxxx-7xxxx",
+		HuobiSecret: "b0xxxxxx-c6xxxxxx-94xxxxxx-dxxxx",
+	}
+	payload := "GET\n" +
+		"api.huobi.pro\n" +
+		"/v1/order/orders\n" +
+		"AccessKeyId=e2xxxxxx-99xxxxxx-84xxxxxx-7xxxx&SignatureMethod=HmacSHA256&SignatureVersion=2&Timestamp=2017-05-11T15%3A19%3A30&order-id=1234567890"
+	expected := "Nmd8AU8uAe0mkFpxNbiava0aeZzBEtYjCdie1ZYZjoM="
+	if signature := signer.HuobiSign(payload); signature != expected {
+		t.Errorf("Expected signature %s, got %s", expected, signature)
+	}
+}
@@ -0,0 +1,391 @@
+package simulator
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// binance error codes used by the simulator
+const (
+	BINANCE_UNKNOWN_ORDER int = -2013
+	BINANCE_NEW_ORDER     int = -2010
+	BINANCE_CANCEL_REJECT int = -2011
+	BINANCE_BAD_PARAM     int = -1102
+	BINANCE_BAD_SYMBOL    int = -1121
+)
+
+// BinanceServer serves the subset of binance rest api the core uses and
+// diff depth streams. Signatures and api keys are not checked.
+type BinanceServer struct {
+	ex *Exchange
+	r  *gin.Engine
+}
+
+func NewBinanceServer(ex *Exchange) *BinanceServer {
+	self := &BinanceServer{ex, gin.New()}
+	self.r.Use(gin.Recovery())
+	self.r.GET("/api/v1/depth", self.Depth)
+	self.r.GET("/api/v1/exchangeInfo", self.ExchangeInfo)
+	self.r.POST("/api/v3/order", self.NewOrder)
+	self.r.GET("/api/v3/order", self.QueryOrder)
+	self.r.DELETE("/api/v3/order", self.CancelOrder)
+	self.r.GET("/api/v3/openOrders", self.OpenOrders)
+	self.r.GET("/api/v3/account", self.Account)
+	self.r.GET("/api/v3/myTrades", self.MyTrades)
+	self.r.POST("/wapi/v3/withdraw.html", self.Withdraw)
+	self.r.GET("/wapi/v3/depositHistory.html", self.DepositHistory)
+	self.r.GET("/wapi/v3/withdrawHistory.html", self.WithdrawHistory)
+	self.r.GET("/wapi/v3/depositAddress.html", self.DepositAddress)
+	self.r.GET("/wapi/v3/assetDetail.html", self.AssetDetail)
+	self.r.GET("/ws/:stream", self.DepthStream)
+	return self
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', 8, 64)
+}
+
+func binanceError(c *gin.Context, code int, msg string) {
+	c.JSON(http.StatusBadRequest, gin.H{"code": code, "msg": msg})
+}
+
+// wapi endpoints report errors in success and msg fields
+func binanceWapiError(c *gin.Context, msg string) {
+	c.JSON(http.StatusOK, gin.H{"success": false, "msg": msg})
+}
+
+func (self *BinanceServer) pair(c *gin.Context) (Pair, bool) {
+	pair, found := self.ex.FindPair(c.Query("symbol"))
+	if !found {
+		binanceError(c, BINANCE_BAD_SYMBOL, "Invalid symbol.")
+	}
+	return pair, found
+}
+
+func (self *BinanceServer) Depth(c *gin.Context) {
+	pair, ok := self.pair(c)
+	if !ok {
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil {
+		binanceError(c, BINANCE_BAD_PARAM, "Illegal characters found in parameter 'limit'.")
+		return
+	}
+	version, bids, asks := self.ex.VersionedDepth(pair, limit)
+	c.JSON(http.StatusOK, gin.H{
+		"lastUpdateId": version,
+		"bids":         binanceLevels(bids),
+		"asks":         binanceLevels(asks),
+	})
+}
+
+func binanceLevels(levels []PriceLevel) [][]string {
+	result := [][]string{}
+	for _, level := range levels {
+		result = append(result, []string{formatFloat(level.Price), formatFloat(level.Qty)})
+	}
+	return result
+}
+
+func (self *BinanceServer) ExchangeInfo(c *gin.Context) {
+	symbols := []gin.H{}
+	for _, pair := range self.ex.Pairs() {
+		symbols = append(symbols, gin.H{
+			"symbol":             pair.Base + pair.Quote,
+			"status":             "TRADING",
+			"baseAsset":          pair.Base,
+			"baseAssetPrecision": 8,
+			"quoteAsset":         pair.Quote,
+			"quotePrecision":     8,
+			"filters": []gin.H{
+				gin.H{
+					"filterType": "PRICE_FILTER",
+					"minPrice":   "0.00000001",
+					"maxPrice":   "100000.00000000",
+					"tickSize":   "0.00000001",
+				},
+				gin.H{
+					"filterType": "LOT_SIZE",
+					"minQty":     "1.00000000",
+					"maxQty":     "90000000.00000000",
+					"stepSize":   "1.00000000",
+				},
+			},
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"timezone":   "UTC",
+		"serverTime": self.ex.Block(),
+		"symbols":    symbols,
+	})
+}
+
+func binanceStatus(order Order) string {
+	switch {
+	case order.Canceled:
+		return "CANCELED"
+	case !order.IsOpen():
+		return "FILLED"
+	case order.Filled > EPSILON:
+		return "PARTIALLY_FILLED"
+	default:
+		return "NEW"
+	}
+}
+
+func binanceOrder(order Order) gin.H {
+	return gin.H{
+		"symbol":        order.Pair.Base + order.Pair.Quote,
+		"orderId":       order.ID,
+		"clientOrderId": strconv.FormatUint(order.ID, 10),
+		"price":         formatFloat(order.Price),
+		"origQty":       formatFloat(order.Qty),
+		"executedQty":   formatFloat(order.Filled),
+		"status":        binanceStatus(order),
+		"timeInForce":   "GTC",
+		"type":          "LIMIT",
+		"side":          strings.ToUpper(order.Side),
+		"stopPrice":     formatFloat(0),
+		"icebergQty":    formatFloat(0),
+		"time":          order.Time,
+	}
+}
+
+func (self *BinanceServer) NewOrder(c *gin.Context) {
+	pair, ok := self.pair(c)
+	if !ok {
+		return
+	}
+	if c.Query("type") != "LIMIT" {
+		binanceError(c, BINANCE_NEW_ORDER, "Only LIMIT orders are simulated.")
+		return
+	}
+	price, perr := strconv.ParseFloat(c.Query("price"), 64)
+	qty, qerr := strconv.ParseFloat(c.Query("quantity"), 64)
+	if perr != nil || qerr != nil {
+		binanceError(c, BINANCE_BAD_PARAM, "Mandatory parameter price or quantity was not sent, was empty/null, or malformed.")
+		return
+	}
+	order, err := self.ex.PlaceOrder(pair, strings.ToLower(c.Query("side")), price, qty)
+	if err != nil {
+		binanceError(c, BINANCE_NEW_ORDER, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"symbol":        pair.Base + pair.Quote,
+		"orderId":       order.ID,
+		"clientOrderId": strconv.FormatUint(order.ID, 10),
+		"transactTime":  order.Time,
+	})
+}
+
+func (self *BinanceServer) order(c *gin.Context) (Order, bool) {
+	id, err := strconv.ParseUint(c.Query("orderId"), 10, 64)
+	if err != nil {
+		binanceError(c, BINANCE_BAD_PARAM, "Mandatory parameter 'orderId' was not sent, was empty/null, or malformed.")
+		return Order{}, false
+	}
+	order, found := self.ex.GetOrder(id)
+	if !found || order.Pair.Base+order.Pair.Quote != strings.ToUpper(c.Query("symbol")) {
+		binanceError(c, BINANCE_UNKNOWN_ORDER, "Order does not exist.")
+		return Order{}, false
+	}
+	return order, true
+}
+
+func (self *BinanceServer) QueryOrder(c *gin.Context) {
+	order, ok := self.order(c)
+	if ok {
+		c.JSON(http.StatusOK, binanceOrder(order))
+	}
+}
+
+func (self *BinanceServer) CancelOrder(c *gin.Context) {
+	order, ok := self.order(c)
+	if !ok {
+		return
+	}
+	order, err := self.ex.CancelOrder(order.ID)
+	if err != nil {
+		binanceError(c, BINANCE_CANCEL_REJECT, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"symbol":            order.Pair.Base + order.Pair.Quote,
+		"origClientOrderId": strconv.FormatUint(order.ID, 10),
+		"orderId":           order.ID,
+		"clientOrderId":     strconv.FormatUint(order.ID, 10),
+	})
+}
+
+func (self *BinanceServer) OpenOrders(c *gin.Context) {
+	pair, ok := self.pair(c)
+	if !ok {
+		return
+	}
+	result := []gin.H{}
+	for _, order := range self.ex.Orders(pair) {
+		if order.IsOpen() {
+			result = append(result, binanceOrder(order))
+		}
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func (self *BinanceServer) Account(c *gin.Context) {
+	balances := []gin.H{}
+	for asset, balance := range self.ex.Balances() {
+		balances = append(balances, gin.H{
+			"asset":  asset,
+			"free":   formatFloat(balance.Free),
+			"locked": formatFloat(balance.Locked),
+		})
+	}
+	// commissions are in basis points
+	commission := int64(self.ex.Fee() * 10000)
+	c.JSON(http.StatusOK, gin.H{
+		"makerCommission":  commission,
+		"takerCommission":  commission,
+		"buyerCommission":  0,
+		"sellerCommission": 0,
+		"canTrade":         true,
+		"canWithdraw":      true,
+		"canDeposit":       true,
+		"balances":         balances,
+	})
+}
+
+func (self *BinanceServer) MyTrades(c *gin.Context) {
+	pair, ok := self.pair(c)
+	if !ok {
+		return
+	}
+	since, _ := strconv.ParseUint(c.DefaultQuery("startTime", "0"), 10, 64)
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "500"))
+	result := []gin.H{}
+	for _, fill := range self.ex.Fills(pair, since) {
+		if len(result) == limit {
+			break
+		}
+		result = append(result, gin.H{
+			"id":              fill.ID,
+			"orderId":         fill.OrderID,
+			"price":           formatFloat(fill.Price),
+			"qty":             formatFloat(fill.Qty),
+			"commission":      formatFloat(fill.Fee),
+			"commissionAsset": fill.FeeAsset,
+			"time":            fill.Time,
+			"isBuyer":         fill.Side == SIDE_BUY,
+			"isMaker":         false,
+			"isBestMatch":     true,
+		})
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func (self *BinanceServer) Withdraw(c *gin.Context) {
+	amount, err := strconv.ParseFloat(c.Query("amount"), 64)
+	if err != nil {
+		binanceWapiError(c, "Invalid amount")
+		return
+	}
+	withdrawal, err := self.ex.Withdraw(strings.ToUpper(c.Query("asset")), amount, c.Query("address"))
+	if err != nil {
+		binanceWapiError(c, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"msg":     "success",
+		"id":      strconv.FormatUint(withdrawal.ID, 10),
+	})
+}
+
+func timeRange(c *gin.Context) (uint64, uint64) {
+	startTime, _ := strconv.ParseUint(c.DefaultQuery("startTime", "0"), 10, 64)
+	endTime, err := strconv.ParseUint(c.Query("endTime"), 10, 64)
+	if err != nil {
+		endTime = ^uint64(0)
+	}
+	return startTime, endTime
+}
+
+func (self *BinanceServer) DepositHistory(c *gin.Context) {
+	startTime, endTime := timeRange(c)
+	deposits := []gin.H{}
+	for _, deposit := range self.ex.Deposits(strings.ToUpper(c.Query("asset"))) {
+		if deposit.Time < startTime || deposit.Time > endTime {
+			continue
+		}
+		status := 0
+		if deposit.ConfirmedTime != 0 {
+			status = 1
+		}
+		deposits = append(deposits, gin.H{
+			"insertTime": deposit.Time,
+			"amount":     deposit.Amount,
+			"asset":      deposit.Asset,
+			"address":    deposit.Address,
+			"txId":       deposit.TxID,
+			"status":     status,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "depositList": deposits})
+}
+
+func (self *BinanceServer) WithdrawHistory(c *gin.Context) {
+	startTime, endTime := timeRange(c)
+	withdrawals := []gin.H{}
+	for _, withdrawal := range self.ex.Withdrawals(strings.ToUpper(c.Query("asset"))) {
+		if withdrawal.Time < startTime || withdrawal.Time > endTime {
+			continue
+		}
+		// 4 is processing, 6 is completed
+		status := 4
+		if withdrawal.Completed {
+			status = 6
+		}
+		withdrawals = append(withdrawals, gin.H{
+			"id":        strconv.FormatUint(withdrawal.ID, 10),
+			"amount":    withdrawal.Amount,
+			"address":   withdrawal.Address,
+			"asset":     withdrawal.Asset,
+			"txId":      withdrawal.TxID,
+			"applyTime": withdrawal.Time,
+			"status":    status,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "withdrawList": withdrawals})
+}
+
+func (self *BinanceServer) DepositAddress(c *gin.Context) {
+	asset := strings.ToUpper(c.Query("asset"))
+	address, found := self.ex.Address(asset)
+	if !found {
+		binanceWapiError(c, "No deposit address for "+asset)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"address":    address,
+		"addressTag": "",
+		"asset":      asset,
+	})
+}
+
+func (self *BinanceServer) AssetDetail(c *gin.Context) {
+	details := gin.H{}
+	for asset := range self.ex.Balances() {
+		details[asset] = gin.H{
+			"minWithdrawAmount": formatFloat(0),
+			"depositStatus":     true,
+			"withdrawFee":       self.ex.WithdrawFee(asset),
+			"withdrawStatus":    true,
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "assetDetail": details})
+}
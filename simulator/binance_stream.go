@@ -0,0 +1,108 @@
+package simulator
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	BINANCE_STREAM_INTERVAL time.Duration = 100 * time.Millisecond
+	// as deep as the snapshots the core asks for
+	BINANCE_STREAM_LEVELS int = 1000
+)
+
+// DepthStream serves /ws/<symbol>@depth. Every interval the books have
+// changed, it sends the levels that changed since the last update, gone
+// levels with a quantity of 0. Updates are numbered by book versions,
+// as lastUpdateId of /api/v1/depth, so the core syncs its books the way
+// it does against binance.
+func (self *BinanceServer) DepthStream(c *gin.Context) {
+	stream := c.Param("stream")
+	if !strings.HasSuffix(stream, "@depth") {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	pair, found := self.ex.FindPair(strings.TrimSuffix(stream, "@depth"))
+	if !found {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	// books are read before the subscription is answered, so a snapshot
+	// taken after subscribing is never older than the stream
+	version, bids, asks := self.ex.VersionedDepth(pair, BINANCE_STREAM_LEVELS)
+	websocket.Handler(func(ws *websocket.Conn) {
+		self.streamDepth(ws, pair, version, bids, asks)
+	}).ServeHTTP(c.Writer, c.Request)
+}
+
+func (self *BinanceServer) streamDepth(
+	ws *websocket.Conn,
+	pair Pair,
+	version uint64,
+	bids, asks []PriceLevel) {
+	// nothing is read from clients, reading only tells when they leave
+	closed := make(chan struct{})
+	go func() {
+		buffer := make([]byte, 512)
+		for {
+			if _, err := ws.Read(buffer); err != nil {
+				close(closed)
+				return
+			}
+		}
+	}()
+	ticker := time.NewTicker(BINANCE_STREAM_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-closed:
+			return
+		case <-ticker.C:
+		}
+		newVersion, newBids, newAsks := self.ex.VersionedDepth(pair, BINANCE_STREAM_LEVELS)
+		if newVersion == version {
+			continue
+		}
+		update := gin.H{
+			"e": "depthUpdate",
+			"E": common.GetTimepoint(),
+			"s": pair.Base + pair.Quote,
+			"U": version + 1,
+			"u": newVersion,
+			"b": changedLevels(bids, newBids),
+			"a": changedLevels(asks, newAsks),
+		}
+		if err := websocket.JSON.Send(ws, update); err != nil {
+			log.Printf("Simulated binance %s depth stream stopped: %s", pair, err)
+			return
+		}
+		version, bids, asks = newVersion, newBids, newAsks
+	}
+}
+
+// changedLevels returns levels of current that are new or have another
+// quantity than in last, and levels of last that are gone with a
+// quantity of 0
+func changedLevels(last, current []PriceLevel) [][]string {
+	lastQty := map[float64]float64{}
+	for _, level := range last {
+		lastQty[level.Price] = level.Qty
+	}
+	changed := []PriceLevel{}
+	for _, level := range current {
+		if qty, found := lastQty[level.Price]; !found || qty != level.Qty {
+			changed = append(changed, level)
+		}
+		delete(lastQty, level.Price)
+	}
+	for price := range lastQty {
+		changed = append(changed, PriceLevel{Price: price, Qty: 0})
+	}
+	return binanceLevels(changed)
+}
@@ -0,0 +1,62 @@
+package simulator
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KyberNetwork/reserve-data/exchange"
+	"golang.org/x/net/websocket"
+)
+
+func TestChangedLevels(t *testing.T) {
+	last := []PriceLevel{{0.011, 10}, {0.012, 10}, {0.013, 5}}
+	current := []PriceLevel{{0.0105, 2}, {0.011, 10}, {0.012, 4}}
+	changed := changedLevels(last, current)
+	expected := "[[0.01050000 2.00000000] [0.01200000 4.00000000] [0.01300000 0.00000000]]"
+	if fmt.Sprint(changed) != expected {
+		t.Fatalf("Expected %s, got %v", expected, changed)
+	}
+}
+
+func TestDepthStreamFollowsSnapshot(t *testing.T) {
+	ex := newTestExchange()
+	server := httptest.NewServer(NewBinanceServer(ex).r)
+	defer server.Close()
+	ws, err := websocket.Dial(
+		strings.Replace(server.URL, "http", "ws", 1)+"/ws/omgeth@depth", "", server.URL)
+	if err != nil {
+		t.Fatalf("Expected to subscribe to depth stream, got %v", err)
+	}
+	defer ws.Close()
+	snapshot, _, _ := ex.VersionedDepth(omgeth, BINANCE_STREAM_LEVELS)
+	ex.AddLiquidity(omgeth, SIDE_BUY, 0.0095, 3)
+	ex.AddLiquidity(omgeth, SIDE_SELL, 0.012, 5)
+	ws.SetReadDeadline(time.Now().Add(time.Second))
+	levels := map[string]string{}
+	lastID := int64(snapshot)
+	for lastID < int64(snapshot)+2 {
+		update := exchange.Binadepthupdate{}
+		if err := websocket.JSON.Receive(ws, &update); err != nil {
+			t.Fatalf("Expected depth updates, got %v", err)
+		}
+		// binance's rule for updates to apply on a snapshot
+		if update.FirstUpdateID != lastID+1 || update.FinalUpdateID <= lastID {
+			t.Fatalf("Expected update to follow %d, got %d to %d",
+				lastID, update.FirstUpdateID, update.FinalUpdateID)
+		}
+		lastID = update.FinalUpdateID
+		for _, level := range update.Bids {
+			levels[fmt.Sprintf("bid %s", level[0])] = level[1].(string)
+		}
+		for _, level := range update.Asks {
+			levels[fmt.Sprintf("ask %s", level[0])] = level[1].(string)
+		}
+	}
+	expected := "map[ask 0.01200000:15.00000000 bid 0.00950000:3.00000000]"
+	if fmt.Sprint(levels) != expected {
+		t.Fatalf("Expected the new bid and the bigger ask, got %v", levels)
+	}
+}
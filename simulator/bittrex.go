@@ -0,0 +1,325 @@
+package simulator
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const BITTREX_TIME_FORMAT string = "2006-01-02T15:04:05.000"
+
+// BittrexServer serves the subset of bittrex v1.1 api the core uses.
+// Signatures and api keys are not checked.
+type BittrexServer struct {
+	ex *Exchange
+	r  *gin.Engine
+}
+
+func NewBittrexServer(ex *Exchange) *BittrexServer {
+	self := &BittrexServer{ex, gin.New()}
+	self.r.Use(gin.Recovery())
+	public := self.r.Group("/api/v1.1/public")
+	public.GET("/getorderbook", self.OrderBook)
+	public.GET("/getmarkets", self.Markets)
+	public.GET("/getcurrencies", self.Currencies)
+	market := self.r.Group("/api/v1.1/market")
+	market.GET("/buylimit", self.BuyLimit)
+	market.GET("/selllimit", self.SellLimit)
+	market.GET("/cancel", self.Cancel)
+	account := self.r.Group("/api/v1.1/account")
+	account.GET("/getbalances", self.Balances)
+	account.GET("/getorder", self.Order)
+	account.GET("/getorderhistory", self.OrderHistory)
+	account.GET("/getdeposithistory", self.DepositHistory)
+	account.GET("/getwithdrawalhistory", self.WithdrawalHistory)
+	account.GET("/withdraw", self.Withdraw)
+	account.GET("/getdepositaddress", self.DepositAddress)
+	return self
+}
+
+func bittrexError(c *gin.Context, msg string) {
+	c.JSON(http.StatusOK, gin.H{"success": false, "message": msg, "result": nil})
+}
+
+func bittrexResult(c *gin.Context, result interface{}) {
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "", "result": result})
+}
+
+func bittrexTime(timepoint uint64) string {
+	if timepoint == 0 {
+		return ""
+	}
+	return time.Unix(0, int64(timepoint)*int64(time.Millisecond)).UTC().Format(BITTREX_TIME_FORMAT)
+}
+
+// bittrex uuids are derived from our numeric ids
+func bittrexUUID(id uint64) string {
+	return fmt.Sprintf("00000000-0000-0000-0000-%012d", id)
+}
+
+func bittrexID(uuid string) (uint64, error) {
+	parts := strings.Split(uuid, "-")
+	return strconv.ParseUint(parts[len(parts)-1], 10, 64)
+}
+
+// markets are named QUOTE-BASE on bittrex
+func (self *BittrexServer) market(c *gin.Context) (Pair, bool) {
+	parts := strings.Split(strings.ToUpper(c.Query("market")), "-")
+	if len(parts) == 2 {
+		pair := Pair{parts[1], parts[0]}
+		for _, p := range self.ex.Pairs() {
+			if p == pair {
+				return pair, true
+			}
+		}
+	}
+	bittrexError(c, "INVALID_MARKET")
+	return Pair{}, false
+}
+
+func (self *BittrexServer) OrderBook(c *gin.Context) {
+	pair, ok := self.market(c)
+	if !ok {
+		return
+	}
+	bids, asks := self.ex.Depth(pair, 50)
+	bittrexResult(c, gin.H{
+		"buy":  bittrexLevels(bids),
+		"sell": bittrexLevels(asks),
+	})
+}
+
+func bittrexLevels(levels []PriceLevel) []gin.H {
+	result := []gin.H{}
+	for _, level := range levels {
+		result = append(result, gin.H{"Quantity": level.Qty, "Rate": level.Price})
+	}
+	return result
+}
+
+func (self *BittrexServer) Markets(c *gin.Context) {
+	markets := []gin.H{}
+	for _, pair := range self.ex.Pairs() {
+		markets = append(markets, gin.H{
+			"MarketCurrency": pair.Base,
+			"BaseCurrency":   pair.Quote,
+			"MarketName":     pair.Quote + "-" + pair.Base,
+			"MinTradeSize":   0.00000001,
+			"IsActive":       true,
+		})
+	}
+	bittrexResult(c, markets)
+}
+
+func (self *BittrexServer) Currencies(c *gin.Context) {
+	currencies := []gin.H{}
+	for asset := range self.ex.Balances() {
+		currencies = append(currencies, gin.H{
+			"Currency":        asset,
+			"MinConfirmation": self.ex.Confirmations(),
+			"TxFee":           self.ex.WithdrawFee(asset),
+			"IsActive":        true,
+		})
+	}
+	bittrexResult(c, currencies)
+}
+
+func (self *BittrexServer) limit(c *gin.Context, side string) {
+	pair, ok := self.market(c)
+	if !ok {
+		return
+	}
+	qty, qerr := strconv.ParseFloat(c.Query("quantity"), 64)
+	rate, rerr := strconv.ParseFloat(c.Query("rate"), 64)
+	if qerr != nil || rerr != nil {
+		bittrexError(c, "QUANTITY_OR_RATE_INVALID")
+		return
+	}
+	order, err := self.ex.PlaceOrder(pair, side, rate, qty)
+	if err != nil {
+		bittrexError(c, err.Error())
+		return
+	}
+	bittrexResult(c, gin.H{"uuid": bittrexUUID(order.ID)})
+}
+
+func (self *BittrexServer) BuyLimit(c *gin.Context) {
+	self.limit(c, SIDE_BUY)
+}
+
+func (self *BittrexServer) SellLimit(c *gin.Context) {
+	self.limit(c, SIDE_SELL)
+}
+
+func (self *BittrexServer) Cancel(c *gin.Context) {
+	id, err := bittrexID(c.Query("uuid"))
+	if err != nil {
+		bittrexError(c, "UUID_INVALID")
+		return
+	}
+	if _, err := self.ex.CancelOrder(id); err != nil {
+		bittrexError(c, err.Error())
+		return
+	}
+	bittrexResult(c, nil)
+}
+
+func (self *BittrexServer) Balances(c *gin.Context) {
+	pending := map[string]float64{}
+	for _, deposit := range self.ex.Deposits("") {
+		if deposit.ConfirmedTime == 0 {
+			pending[deposit.Asset] += deposit.Amount
+		}
+	}
+	result := []gin.H{}
+	for asset, balance := range self.ex.Balances() {
+		address, _ := self.ex.Address(asset)
+		result = append(result, gin.H{
+			"Currency":      asset,
+			"Balance":       balance.Free + balance.Locked,
+			"Available":     balance.Free,
+			"Pending":       pending[asset],
+			"CryptoAddress": address,
+		})
+	}
+	bittrexResult(c, result)
+}
+
+func bittrexOrderType(order Order) string {
+	if order.Side == SIDE_BUY {
+		return "LIMIT_BUY"
+	}
+	return "LIMIT_SELL"
+}
+
+func (self *BittrexServer) Order(c *gin.Context) {
+	id, err := bittrexID(c.Query("uuid"))
+	if err != nil {
+		bittrexError(c, "UUID_INVALID")
+		return
+	}
+	order, found := self.ex.GetOrder(id)
+	if !found {
+		bittrexError(c, "INVALID_ORDER")
+		return
+	}
+	reserved := order.Price * order.Qty
+	bittrexResult(c, gin.H{
+		"AccountId":                  nil,
+		"OrderUuid":                  bittrexUUID(order.ID),
+		"Exchange":                   order.Pair.Quote + "-" + order.Pair.Base,
+		"Type":                       bittrexOrderType(order),
+		"Quantity":                   order.Qty,
+		"QuantityRemaining":          order.Remaining(),
+		"Limit":                      order.Price,
+		"Reserved":                   reserved,
+		"ReserveRemaining":           order.Price * order.Remaining(),
+		"CommissionReserved":         reserved * self.ex.Fee(),
+		"CommissionReserveRemaining": order.Price * order.Remaining() * self.ex.Fee(),
+		"CommissionPaid":             order.Fee,
+		"Price":                      order.FilledQuote,
+		"PricePerUnit":               order.AvgPrice(),
+		"Opened":                     bittrexTime(order.Time),
+		"Closed":                     bittrexTime(order.ClosedTime),
+		"IsOpen":                     order.IsOpen(),
+		"CancelInitiated":            order.Canceled,
+		"ImmediateOrCancel":          false,
+		"IsConditional":              false,
+		"Condition":                  "NONE",
+	})
+}
+
+func (self *BittrexServer) OrderHistory(c *gin.Context) {
+	pair, ok := self.market(c)
+	if !ok {
+		return
+	}
+	result := []gin.H{}
+	for _, order := range self.ex.Orders(pair) {
+		if order.IsOpen() {
+			continue
+		}
+		result = append(result, gin.H{
+			"OrderUuid":         bittrexUUID(order.ID),
+			"Exchange":          order.Pair.Quote + "-" + order.Pair.Base,
+			"TimeStamp":         bittrexTime(order.Time),
+			"OrderType":         bittrexOrderType(order),
+			"Limit":             order.Price,
+			"Quantity":          order.Qty,
+			"QuantityRemaining": order.Remaining(),
+			"Commission":        order.Fee,
+			"Price":             order.FilledQuote,
+			"PricePerUnit":      order.AvgPrice(),
+			"Closed":            bittrexTime(order.ClosedTime),
+		})
+	}
+	bittrexResult(c, result)
+}
+
+// only deposits with enough confirmations show up, as on bittrex
+func (self *BittrexServer) DepositHistory(c *gin.Context) {
+	result := []gin.H{}
+	for _, deposit := range self.ex.Deposits(strings.ToUpper(c.Query("currency"))) {
+		if deposit.ConfirmedTime == 0 {
+			continue
+		}
+		result = append(result, gin.H{
+			"Id":            deposit.ID,
+			"Currency":      deposit.Asset,
+			"Amount":        deposit.Amount,
+			"CryptoAddress": deposit.Address,
+			"TxId":          deposit.TxID,
+			"Confirmations": deposit.Confirmations,
+			"LastUpdated":   bittrexTime(deposit.ConfirmedTime),
+		})
+	}
+	bittrexResult(c, result)
+}
+
+func (self *BittrexServer) WithdrawalHistory(c *gin.Context) {
+	result := []gin.H{}
+	for _, withdrawal := range self.ex.Withdrawals(strings.ToUpper(c.Query("currency"))) {
+		result = append(result, gin.H{
+			"PaymentUuid":    bittrexUUID(withdrawal.ID),
+			"Currency":       withdrawal.Asset,
+			"Amount":         withdrawal.Amount,
+			"Address":        withdrawal.Address,
+			"Opened":         bittrexTime(withdrawal.Time),
+			"Authorized":     true,
+			"PendingPayment": !withdrawal.Completed,
+			"TxCost":         withdrawal.Fee,
+			"TxId":           withdrawal.TxID,
+			"Canceled":       false,
+			"InvalidAddress": false,
+		})
+	}
+	bittrexResult(c, result)
+}
+
+func (self *BittrexServer) Withdraw(c *gin.Context) {
+	qty, err := strconv.ParseFloat(c.Query("quantity"), 64)
+	if err != nil {
+		bittrexError(c, "QUANTITY_INVALID")
+		return
+	}
+	withdrawal, err := self.ex.Withdraw(strings.ToUpper(c.Query("currency")), qty, c.Query("address"))
+	if err != nil {
+		bittrexError(c, err.Error())
+		return
+	}
+	bittrexResult(c, gin.H{"uuid": bittrexUUID(withdrawal.ID)})
+}
+
+func (self *BittrexServer) DepositAddress(c *gin.Context) {
+	currency := strings.ToUpper(c.Query("currency"))
+	address, found := self.ex.Address(currency)
+	if !found {
+		bittrexError(c, "ADDRESS_GENERATING")
+		return
+	}
+	bittrexResult(c, gin.H{"Currency": currency, "Address": address})
+}
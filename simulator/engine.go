@@ -0,0 +1,181 @@
+package simulator
+
+import (
+	"sort"
+)
+
+const (
+	SIDE_BUY  string = "buy"
+	SIDE_SELL string = "sell"
+
+	// quantities below this are considered filled
+	EPSILON float64 = 0.0000000001
+)
+
+// Order is either ours, placed through the exchange api, or resting
+// liquidity loaded from config. Only our orders move balances.
+type Order struct {
+	ID       uint64
+	Pair     Pair
+	Side     string
+	Price    float64
+	Qty      float64
+	Filled   float64
+	Own      bool
+	Canceled bool
+	// total quote amount of the fills, to compute average price
+	FilledQuote float64
+	Fee         float64
+	FeeAsset    string
+	Time        uint64
+	ClosedTime  uint64
+}
+
+func (self *Order) Remaining() float64 {
+	return self.Qty - self.Filled
+}
+
+func (self *Order) IsOpen() bool {
+	return !self.Canceled && self.Remaining() > EPSILON
+}
+
+func (self *Order) AvgPrice() float64 {
+	if self.Filled < EPSILON {
+		return 0
+	}
+	return self.FilledQuote / self.Filled
+}
+
+// Fill is one match between an incoming order and a resting one
+type Fill struct {
+	ID       uint64
+	OrderID  uint64
+	Pair     Pair
+	Side     string
+	Price    float64
+	Qty      float64
+	Fee      float64
+	FeeAsset string
+	Time     uint64
+}
+
+type Pair struct {
+	Base  string
+	Quote string
+}
+
+func (self Pair) String() string {
+	return self.Base + "-" + self.Quote
+}
+
+// OrderBook keeps bids by price descending and asks by price ascending,
+// orders at the same price keep their arrival order.
+type OrderBook struct {
+	Bids []*Order
+	Asks []*Order
+}
+
+func NewOrderBook() *OrderBook {
+	return &OrderBook{[]*Order{}, []*Order{}}
+}
+
+func (self *OrderBook) Add(order *Order) {
+	if order.Side == SIDE_BUY {
+		i := sort.Search(len(self.Bids), func(i int) bool {
+			return self.Bids[i].Price < order.Price
+		})
+		self.Bids = insertOrder(self.Bids, i, order)
+	} else {
+		i := sort.Search(len(self.Asks), func(i int) bool {
+			return self.Asks[i].Price > order.Price
+		})
+		self.Asks = insertOrder(self.Asks, i, order)
+	}
+}
+
+func insertOrder(orders []*Order, i int, order *Order) []*Order {
+	orders = append(orders, nil)
+	copy(orders[i+1:], orders[i:])
+	orders[i] = order
+	return orders
+}
+
+func (self *OrderBook) Remove(id uint64) {
+	self.Bids = removeOrder(self.Bids, id)
+	self.Asks = removeOrder(self.Asks, id)
+}
+
+func removeOrder(orders []*Order, id uint64) []*Order {
+	for i, order := range orders {
+		if order.ID == id {
+			return append(orders[:i], orders[i+1:]...)
+		}
+	}
+	return orders
+}
+
+type match struct {
+	maker *Order
+	price float64
+	qty   float64
+}
+
+// Match crosses order with the opposite side at the makers' prices and
+// returns the matches, fully filled makers are removed from the book.
+// It doesn't touch filled amounts, that is up to the caller.
+func (self *OrderBook) Match(order *Order) []match {
+	result := []match{}
+	remaining := order.Remaining()
+	var side *[]*Order
+	var crosses func(price float64) bool
+	if order.Side == SIDE_BUY {
+		side = &self.Asks
+		crosses = func(price float64) bool { return price <= order.Price }
+	} else {
+		side = &self.Bids
+		crosses = func(price float64) bool { return price >= order.Price }
+	}
+	consumed := 0
+	for _, maker := range *side {
+		if remaining < EPSILON || !crosses(maker.Price) {
+			break
+		}
+		qty := maker.Remaining()
+		if qty > remaining {
+			qty = remaining
+		}
+		result = append(result, match{maker, maker.Price, qty})
+		remaining -= qty
+		if maker.Remaining()-qty < EPSILON {
+			consumed++
+		}
+	}
+	*side = (*side)[consumed:]
+	return result
+}
+
+type PriceLevel struct {
+	Price float64
+	Qty   float64
+}
+
+// Depth aggregates open quantity by price, at most limit levels a side
+func (self *OrderBook) Depth(limit int) ([]PriceLevel, []PriceLevel) {
+	return aggregate(self.Bids, limit), aggregate(self.Asks, limit)
+}
+
+func aggregate(orders []*Order, limit int) []PriceLevel {
+	result := []PriceLevel{}
+	for _, order := range orders {
+		n := len(result)
+		if n > 0 && result[n-1].Price == order.Price {
+			result[n-1].Qty += order.Remaining()
+			continue
+		}
+		if n == limit {
+			break
+		}
+		result = append(result, PriceLevel{order.Price, order.Remaining()})
+	}
+	return result
+}
@@ -0,0 +1,177 @@
+package simulator
+
+import (
+	"math"
+	"testing"
+)
+
+var omgeth = Pair{"OMG", "ETH"}
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 0.0000001
+}
+
+func newTestExchange() *Exchange {
+	return NewExchange("test", ExchangeConfig{
+		Fee:          0.001,
+		WithdrawFees: map[string]float64{"OMG": 1},
+		Addresses:    map[string]string{"OMG": "0x9db6e8d2d133448dbcf755f19d540253da4ba043"},
+		Balances:     map[string]float64{"ETH": 10, "OMG": 100},
+		Books: map[string]BookConfig{
+			"OMG-ETH": BookConfig{
+				Bids: [][2]float64{{0.009, 10}, {0.008, 10}},
+				Asks: [][2]float64{{0.011, 10}, {0.012, 10}},
+			},
+		},
+	}, 2)
+}
+
+func TestOrderBookPriceTimePriority(t *testing.T) {
+	book := NewOrderBook()
+	book.Add(&Order{ID: 1, Side: SIDE_SELL, Price: 0.02, Qty: 5})
+	book.Add(&Order{ID: 2, Side: SIDE_SELL, Price: 0.01, Qty: 5})
+	book.Add(&Order{ID: 3, Side: SIDE_SELL, Price: 0.01, Qty: 5})
+	matches := book.Match(&Order{ID: 4, Side: SIDE_BUY, Price: 0.02, Qty: 7})
+	if len(matches) != 2 {
+		t.Fatalf("Expected 2 matches, got %d", len(matches))
+	}
+	if matches[0].maker.ID != 2 || matches[0].qty != 5 {
+		t.Fatalf("Expected order 2 to be filled first with 5, got order %d with %f", matches[0].maker.ID, matches[0].qty)
+	}
+	if matches[1].maker.ID != 3 || matches[1].qty != 2 || matches[1].price != 0.01 {
+		t.Fatalf("Expected order 3 to be filled 2 at 0.01, got order %d with %f at %f", matches[1].maker.ID, matches[1].qty, matches[1].price)
+	}
+	// order 2 is fully consumed, order 3 is still resting
+	if len(book.Asks) != 2 || book.Asks[0].ID != 3 {
+		t.Fatalf("Expected order 3 on top of asks, got %v", book.Asks)
+	}
+}
+
+func TestOrderBookDepthAggregatesLevels(t *testing.T) {
+	book := NewOrderBook()
+	book.Add(&Order{ID: 1, Side: SIDE_BUY, Price: 0.01, Qty: 5})
+	book.Add(&Order{ID: 2, Side: SIDE_BUY, Price: 0.01, Qty: 3, Filled: 1})
+	book.Add(&Order{ID: 3, Side: SIDE_BUY, Price: 0.009, Qty: 1})
+	book.Add(&Order{ID: 4, Side: SIDE_BUY, Price: 0.008, Qty: 1})
+	bids, asks := book.Depth(2)
+	if len(asks) != 0 {
+		t.Fatalf("Expected no asks, got %v", asks)
+	}
+	if len(bids) != 2 || bids[0].Price != 0.01 || bids[0].Qty != 7 || bids[1].Price != 0.009 {
+		t.Fatalf("Expected 2 levels with 7 at 0.01 on top, got %v", bids)
+	}
+}
+
+func TestBuyFillsAtMakerPriceAndTakesFee(t *testing.T) {
+	ex := newTestExchange()
+	order, err := ex.PlaceOrder(omgeth, SIDE_BUY, 0.0115, 15)
+	if err != nil {
+		t.Fatalf("Expected order to be placed, got %v", err)
+	}
+	if !almostEqual(order.Filled, 10) || !order.IsOpen() {
+		t.Fatalf("Expected 10 filled and the order to rest, got %f", order.Filled)
+	}
+	balances := ex.Balances()
+	// 10 filled at 0.011, 5 locked at 0.0115
+	if !almostEqual(balances["ETH"].Free, 10-0.11-0.0575) || !almostEqual(balances["ETH"].Locked, 0.0575) {
+		t.Fatalf("Unexpected ETH balance %v", balances["ETH"])
+	}
+	if !almostEqual(balances["OMG"].Free, 100+10*0.999) {
+		t.Fatalf("Unexpected OMG balance %v", balances["OMG"])
+	}
+	bids, _ := ex.Depth(omgeth, 1)
+	if bids[0].Price != 0.0115 || !almostEqual(bids[0].Qty, 5) {
+		t.Fatalf("Expected the rest of the order on top of bids, got %v", bids)
+	}
+	// someone sells into our resting bid
+	ex.AddLiquidity(omgeth, SIDE_SELL, 0.0115, 5)
+	order, _ = ex.GetOrder(order.ID)
+	if order.IsOpen() || order.ClosedTime == 0 {
+		t.Fatalf("Expected the order to be filled, got %v", order)
+	}
+	balances = ex.Balances()
+	if !almostEqual(balances["ETH"].Locked, 0) || !almostEqual(balances["OMG"].Free, 100+15*0.999) {
+		t.Fatalf("Unexpected balances %v", balances)
+	}
+	if fills := ex.Fills(omgeth, 0); len(fills) != 2 {
+		t.Fatalf("Expected 2 fills, got %v", fills)
+	}
+}
+
+func TestCancelReleasesLockedBalance(t *testing.T) {
+	ex := newTestExchange()
+	order, err := ex.PlaceOrder(omgeth, SIDE_SELL, 0.02, 50)
+	if err != nil {
+		t.Fatalf("Expected order to be placed, got %v", err)
+	}
+	if balances := ex.Balances(); balances["OMG"].Locked != 50 {
+		t.Fatalf("Expected 50 OMG locked, got %v", balances["OMG"])
+	}
+	if _, err := ex.CancelOrder(order.ID); err != nil {
+		t.Fatalf("Expected order to be canceled, got %v", err)
+	}
+	if balances := ex.Balances(); balances["OMG"].Locked != 0 || balances["OMG"].Free != 100 {
+		t.Fatalf("Expected OMG to be unlocked, got %v", balances["OMG"])
+	}
+	if _, err := ex.CancelOrder(order.ID); err == nil {
+		t.Fatalf("Expected canceling a closed order to fail")
+	}
+	_, asks := ex.Depth(omgeth, 10)
+	if len(asks) != 2 {
+		t.Fatalf("Expected canceled order to leave the book, got %v", asks)
+	}
+}
+
+func TestOrderNeedsBalance(t *testing.T) {
+	ex := newTestExchange()
+	if _, err := ex.PlaceOrder(omgeth, SIDE_BUY, 0.011, 1000); err == nil {
+		t.Fatalf("Expected order over ETH balance to be rejected")
+	}
+	if _, err := ex.PlaceOrder(Pair{"KNC", "ETH"}, SIDE_BUY, 0.011, 1); err == nil {
+		t.Fatalf("Expected order on unknown market to be rejected")
+	}
+}
+
+func TestDepositCreditedAfterConfirmations(t *testing.T) {
+	ex := newTestExchange()
+	deposit, err := ex.Deposit("OMG", 5, "")
+	if err != nil {
+		t.Fatalf("Expected deposit to be recorded, got %v", err)
+	}
+	if deposit.TxID == "" {
+		t.Fatalf("Expected a tx hash to be generated")
+	}
+	ex.NewBlock()
+	if ex.Balances()["OMG"].Free != 100 || ex.Deposits("OMG")[0].ConfirmedTime != 0 {
+		t.Fatalf("Expected deposit to wait for 2 confirmations")
+	}
+	ex.NewBlock()
+	if ex.Balances()["OMG"].Free != 105 || ex.Deposits("OMG")[0].ConfirmedTime == 0 {
+		t.Fatalf("Expected deposit to be credited, got %v", ex.Balances()["OMG"])
+	}
+	if _, err := ex.Deposit("KNC", 5, ""); err == nil {
+		t.Fatalf("Expected deposit without address to be rejected")
+	}
+}
+
+func TestWithdrawTakesFeeAndCompletes(t *testing.T) {
+	ex := newTestExchange()
+	if _, err := ex.Withdraw("OMG", 100, "0x0"); err == nil {
+		t.Fatalf("Expected withdraw not covering the fee to be rejected")
+	}
+	withdrawal, err := ex.Withdraw("OMG", 10, "0x0")
+	if err != nil {
+		t.Fatalf("Expected withdraw to be accepted, got %v", err)
+	}
+	if ex.Balances()["OMG"].Free != 89 {
+		t.Fatalf("Expected 11 OMG to be taken, got %v", ex.Balances()["OMG"])
+	}
+	if withdrawal.Completed {
+		t.Fatalf("Expected withdraw to be pending")
+	}
+	ex.NewBlock()
+	ex.NewBlock()
+	if !ex.Withdrawals("OMG")[0].Completed {
+		t.Fatalf("Expected withdraw to be completed")
+	}
+}
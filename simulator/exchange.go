@@ -0,0 +1,498 @@
+package simulator
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/KyberNetwork/reserve-data/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+type Balance struct {
+	Free   float64
+	Locked float64
+}
+
+type Deposit struct {
+	ID            uint64
+	Asset         string
+	Amount        float64
+	Address       string
+	TxID          string
+	Block         uint64
+	Confirmations uint64
+	Time          uint64
+	// time the deposit reached enough confirmations, 0 while pending
+	ConfirmedTime uint64
+}
+
+type Withdrawal struct {
+	ID        uint64
+	Asset     string
+	Amount    float64
+	Fee       float64
+	Address   string
+	TxID      string
+	Block     uint64
+	Time      uint64
+	Completed bool
+}
+
+// Exchange holds the state of one simulated exchange. It doesn't know
+// about any api dialect, those are served on top of it.
+type Exchange struct {
+	mu            sync.Mutex
+	name          string
+	fee           float64
+	withdrawFees  map[string]float64
+	confirmations uint64
+	addresses     map[string]string
+	balances      map[string]*Balance
+	books         map[Pair]*OrderBook
+	orders        map[uint64]*Order
+	fills         []Fill
+	deposits      []*Deposit
+	withdrawals   []*Withdrawal
+	block         uint64
+	nextID        uint64
+	// number of changes of the books, streamed depth updates are
+	// numbered by it
+	bookVersion uint64
+}
+
+func NewExchange(name string, config ExchangeConfig, confirmations uint64) *Exchange {
+	self := &Exchange{
+		name:          name,
+		fee:           config.Fee,
+		withdrawFees:  map[string]float64{},
+		confirmations: confirmations,
+		addresses:     map[string]string{},
+		balances:      map[string]*Balance{},
+		books:         map[Pair]*OrderBook{},
+		orders:        map[uint64]*Order{},
+		fills:         []Fill{},
+		deposits:      []*Deposit{},
+		withdrawals:   []*Withdrawal{},
+		nextID:        1,
+	}
+	for asset, fee := range config.WithdrawFees {
+		self.withdrawFees[strings.ToUpper(asset)] = fee
+	}
+	for asset, address := range config.Addresses {
+		self.addresses[strings.ToUpper(asset)] = address
+	}
+	for asset, amount := range config.Balances {
+		self.balances[strings.ToUpper(asset)] = &Balance{amount, 0}
+	}
+	for name, book := range config.Books {
+		pair, err := ParsePair(name)
+		if err != nil {
+			panic(err)
+		}
+		for _, level := range book.Bids {
+			self.AddLiquidity(pair, SIDE_BUY, level[0], level[1])
+		}
+		for _, level := range book.Asks {
+			self.AddLiquidity(pair, SIDE_SELL, level[0], level[1])
+		}
+	}
+	return self
+}
+
+// ParsePair parses pairs in BASE-QUOTE form
+func ParsePair(name string) (Pair, error) {
+	parts := strings.Split(strings.ToUpper(name), "-")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return Pair{}, errors.New(fmt.Sprintf("Pair %s is not in BASE-QUOTE form", name))
+	}
+	return Pair{parts[0], parts[1]}, nil
+}
+
+func (self *Exchange) Name() string {
+	return self.name
+}
+
+func (self *Exchange) Fee() float64 {
+	return self.fee
+}
+
+func (self *Exchange) Confirmations() uint64 {
+	return self.confirmations
+}
+
+func (self *Exchange) WithdrawFee(asset string) float64 {
+	return self.withdrawFees[asset]
+}
+
+func (self *Exchange) newID() uint64 {
+	id := self.nextID
+	self.nextID++
+	return id
+}
+
+func (self *Exchange) balance(asset string) *Balance {
+	b, found := self.balances[asset]
+	if !found {
+		b = &Balance{}
+		self.balances[asset] = b
+	}
+	return b
+}
+
+func (self *Exchange) book(pair Pair) *OrderBook {
+	b, found := self.books[pair]
+	if !found {
+		b = NewOrderBook()
+		self.books[pair] = b
+	}
+	return b
+}
+
+// Pairs returns pairs having a book, sorted by name
+func (self *Exchange) Pairs() []Pair {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	result := []Pair{}
+	for pair := range self.books {
+		result = append(result, pair)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].String() < result[j].String()
+	})
+	return result
+}
+
+// FindPair resolves a concatenated symbol such as OMGETH
+func (self *Exchange) FindPair(symbol string) (Pair, bool) {
+	symbol = strings.ToUpper(symbol)
+	for _, pair := range self.Pairs() {
+		if pair.Base+pair.Quote == symbol {
+			return pair, true
+		}
+	}
+	return Pair{}, false
+}
+
+func (self *Exchange) Balances() map[string]Balance {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	result := map[string]Balance{}
+	for asset, b := range self.balances {
+		result[asset] = *b
+	}
+	return result
+}
+
+func (self *Exchange) Depth(pair Pair, limit int) ([]PriceLevel, []PriceLevel) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.book(pair).Depth(limit)
+}
+
+// VersionedDepth returns the depth of pair along with the version of the
+// books it was read at
+func (self *Exchange) VersionedDepth(pair Pair, limit int) (uint64, []PriceLevel, []PriceLevel) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	bids, asks := self.book(pair).Depth(limit)
+	return self.bookVersion, bids, asks
+}
+
+// AddLiquidity rests an order which isn't ours in the book, it matches
+// our resting orders if it crosses them
+func (self *Exchange) AddLiquidity(pair Pair, side string, price, qty float64) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	order := &Order{
+		ID:    self.newID(),
+		Pair:  pair,
+		Side:  side,
+		Price: price,
+		Qty:   qty,
+		Time:  common.GetTimepoint(),
+	}
+	self.place(order)
+}
+
+// PlaceOrder places a limit order of ours, the amount it needs is locked
+// until the order is filled or canceled
+func (self *Exchange) PlaceOrder(pair Pair, side string, price, qty float64) (Order, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if _, found := self.books[pair]; !found {
+		return Order{}, errors.New(fmt.Sprintf("Invalid market %s", pair))
+	}
+	if price <= 0 || qty <= 0 {
+		return Order{}, errors.New("Price and quantity must be positive")
+	}
+	asset, amount := pair.Quote, price*qty
+	if side == SIDE_SELL {
+		asset, amount = pair.Base, qty
+	} else if side != SIDE_BUY {
+		return Order{}, errors.New(fmt.Sprintf("Invalid side %s", side))
+	}
+	b := self.balance(asset)
+	if b.Free+EPSILON < amount {
+		return Order{}, errors.New(fmt.Sprintf("Insufficient %s balance: %f < %f", asset, b.Free, amount))
+	}
+	b.Free -= amount
+	b.Locked += amount
+	order := &Order{
+		ID:    self.newID(),
+		Pair:  pair,
+		Side:  side,
+		Price: price,
+		Qty:   qty,
+		Own:   true,
+		Time:  common.GetTimepoint(),
+	}
+	self.orders[order.ID] = order
+	self.place(order)
+	return *order, nil
+}
+
+func (self *Exchange) place(order *Order) {
+	self.bookVersion++
+	book := self.book(order.Pair)
+	for _, m := range book.Match(order) {
+		self.settle(order, m.price, m.qty)
+		self.settle(m.maker, m.price, m.qty)
+	}
+	if order.IsOpen() {
+		book.Add(order)
+	}
+}
+
+// settle fills qty of order at price and moves balances if the order is
+// ours. Fees are taken from the received asset.
+func (self *Exchange) settle(order *Order, price, qty float64) {
+	timepoint := common.GetTimepoint()
+	order.Filled += qty
+	order.FilledQuote += price * qty
+	if !order.IsOpen() {
+		order.ClosedTime = timepoint
+	}
+	if !order.Own {
+		return
+	}
+	var fee float64
+	var feeAsset string
+	if order.Side == SIDE_BUY {
+		quote := self.balance(order.Pair.Quote)
+		// locked at the limit price, the difference is given back
+		quote.Locked -= order.Price * qty
+		quote.Free += (order.Price - price) * qty
+		fee, feeAsset = qty*self.fee, order.Pair.Base
+		self.balance(order.Pair.Base).Free += qty - fee
+	} else {
+		self.balance(order.Pair.Base).Locked -= qty
+		fee, feeAsset = price*qty*self.fee, order.Pair.Quote
+		self.balance(order.Pair.Quote).Free += price*qty - fee
+	}
+	order.Fee += fee
+	order.FeeAsset = feeAsset
+	self.fills = append(self.fills, Fill{
+		ID:       self.newID(),
+		OrderID:  order.ID,
+		Pair:     order.Pair,
+		Side:     order.Side,
+		Price:    price,
+		Qty:      qty,
+		Fee:      fee,
+		FeeAsset: feeAsset,
+		Time:     timepoint,
+	})
+}
+
+func (self *Exchange) CancelOrder(id uint64) (Order, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	order, found := self.orders[id]
+	if !found {
+		return Order{}, errors.New("Unknown order sent")
+	}
+	if !order.IsOpen() {
+		return Order{}, errors.New("Order is not open")
+	}
+	self.book(order.Pair).Remove(id)
+	self.bookVersion++
+	order.Canceled = true
+	order.ClosedTime = common.GetTimepoint()
+	if order.Side == SIDE_BUY {
+		b := self.balance(order.Pair.Quote)
+		b.Locked -= order.Price * order.Remaining()
+		b.Free += order.Price * order.Remaining()
+	} else {
+		b := self.balance(order.Pair.Base)
+		b.Locked -= order.Remaining()
+		b.Free += order.Remaining()
+	}
+	return *order, nil
+}
+
+func (self *Exchange) GetOrder(id uint64) (Order, bool) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	order, found := self.orders[id]
+	if !found {
+		return Order{}, false
+	}
+	return *order, true
+}
+
+// Orders returns our orders of pair ordered by id
+func (self *Exchange) Orders(pair Pair) []Order {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	result := []Order{}
+	for _, order := range self.orders {
+		if order.Pair == pair {
+			result = append(result, *order)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+func (self *Exchange) Fills(pair Pair, since uint64) []Fill {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	result := []Fill{}
+	for _, fill := range self.fills {
+		if fill.Pair == pair && fill.Time >= since {
+			result = append(result, fill)
+		}
+	}
+	return result
+}
+
+func (self *Exchange) Address(asset string) (string, bool) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	address, found := self.addresses[asset]
+	return address, found
+}
+
+// Deposit records an incoming transfer, it is credited once the chain
+// is confirmations blocks past it
+func (self *Exchange) Deposit(asset string, amount float64, tx string) (Deposit, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	address, found := self.addresses[asset]
+	if !found {
+		return Deposit{}, errors.New(fmt.Sprintf("No deposit address for %s", asset))
+	}
+	if amount <= 0 {
+		return Deposit{}, errors.New("Amount must be positive")
+	}
+	id := self.newID()
+	if tx == "" {
+		tx = txHash(self.name, "deposit", id)
+	}
+	deposit := &Deposit{
+		ID:      id,
+		Asset:   asset,
+		Amount:  amount,
+		Address: address,
+		TxID:    tx,
+		Block:   self.block,
+		Time:    common.GetTimepoint(),
+	}
+	self.deposits = append(self.deposits, deposit)
+	self.confirm()
+	return *deposit, nil
+}
+
+func (self *Exchange) Deposits(asset string) []Deposit {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	result := []Deposit{}
+	for _, deposit := range self.deposits {
+		if asset == "" || deposit.Asset == asset {
+			result = append(result, *deposit)
+		}
+	}
+	return result
+}
+
+// Withdraw takes amount plus the withdraw fee off the free balance, the
+// transfer is mined confirmations blocks later
+func (self *Exchange) Withdraw(asset string, amount float64, address string) (Withdrawal, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if amount <= 0 {
+		return Withdrawal{}, errors.New("Amount must be positive")
+	}
+	fee := self.withdrawFees[asset]
+	b := self.balance(asset)
+	if b.Free+EPSILON < amount+fee {
+		return Withdrawal{}, errors.New(fmt.Sprintf("Insufficient %s balance: %f < %f", asset, b.Free, amount+fee))
+	}
+	b.Free -= amount + fee
+	id := self.newID()
+	withdrawal := &Withdrawal{
+		ID:      id,
+		Asset:   asset,
+		Amount:  amount,
+		Fee:     fee,
+		Address: address,
+		TxID:    txHash(self.name, "withdraw", id),
+		Block:   self.block,
+		Time:    common.GetTimepoint(),
+	}
+	self.withdrawals = append(self.withdrawals, withdrawal)
+	self.confirm()
+	return *withdrawal, nil
+}
+
+func (self *Exchange) Withdrawals(asset string) []Withdrawal {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	result := []Withdrawal{}
+	for _, withdrawal := range self.withdrawals {
+		if asset == "" || withdrawal.Asset == asset {
+			result = append(result, *withdrawal)
+		}
+	}
+	return result
+}
+
+func (self *Exchange) Block() uint64 {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.block
+}
+
+// NewBlock moves the simulated chain one block forward
+func (self *Exchange) NewBlock() uint64 {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.block++
+	self.confirm()
+	return self.block
+}
+
+func (self *Exchange) confirm() {
+	timepoint := common.GetTimepoint()
+	for _, deposit := range self.deposits {
+		deposit.Confirmations = self.block - deposit.Block
+		if deposit.ConfirmedTime == 0 && deposit.Confirmations >= self.confirmations {
+			deposit.ConfirmedTime = timepoint
+			self.balance(deposit.Asset).Free += deposit.Amount
+		}
+	}
+	for _, withdrawal := range self.withdrawals {
+		if !withdrawal.Completed && self.block-withdrawal.Block >= self.confirmations {
+			withdrawal.Completed = true
+		}
+	}
+}
+
+func txHash(name, kind string, id uint64) string {
+	return crypto.Keccak256Hash([]byte(fmt.Sprintf("%s|%s|%d", name, kind, id))).Hex()
+}
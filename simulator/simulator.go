@@ -0,0 +1,200 @@
+// Package simulator serves simulated exchanges speaking the binance and
+// bittrex rest dialects so the core can run in simulation mode without
+// any connection to real exchanges.
+package simulator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	BINANCE_PORT int = 5100
+	BITTREX_PORT int = 5300
+)
+
+// Book levels are [price, quantity]
+type BookConfig struct {
+	Bids [][2]float64 `json:"bids"`
+	Asks [][2]float64 `json:"asks"`
+}
+
+type ExchangeConfig struct {
+	// trading fee as a fraction, 0.001 is 0.1%
+	Fee          float64               `json:"fee"`
+	WithdrawFees map[string]float64    `json:"withdraw_fees"`
+	Addresses    map[string]string     `json:"addresses"`
+	Balances     map[string]float64    `json:"balances"`
+	Books        map[string]BookConfig `json:"books"`
+}
+
+type Config struct {
+	// how often a new block is mined, 0 means blocks only move
+	// through the /sim/block admin endpoint
+	BlockTime     time.Duration             `json:"block_time"`
+	Confirmations uint64                    `json:"confirmations"`
+	Exchanges     map[string]ExchangeConfig `json:"exchanges"`
+}
+
+func GetConfigFromFile(path string) (Config, error) {
+	result := Config{}
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return result, err
+	}
+	err = json.Unmarshal(raw, &result)
+	return result, err
+}
+
+// DefaultConfig gives binance and bittrex a book of 10 levels a side
+// around 0.01 for every token against ETH and some balance of each
+func DefaultConfig(tokens []string) Config {
+	result := Config{
+		BlockTime:     15 * time.Second,
+		Confirmations: 12,
+		Exchanges:     map[string]ExchangeConfig{},
+	}
+	for name, fee := range map[string]float64{"binance": 0.001, "bittrex": 0.0025} {
+		config := ExchangeConfig{
+			Fee:          fee,
+			WithdrawFees: map[string]float64{},
+			Addresses:    map[string]string{},
+			Balances:     map[string]float64{},
+			Books:        map[string]BookConfig{},
+		}
+		for _, token := range tokens {
+			config.WithdrawFees[token] = 0.01
+			if token == "ETH" {
+				config.Balances[token] = 100
+				continue
+			}
+			config.Balances[token] = 10000
+			config.Books[token+"-ETH"] = defaultBook(0.01, 10)
+		}
+		result.Exchanges[name] = config
+	}
+	return result
+}
+
+func defaultBook(mid float64, levels int) BookConfig {
+	result := BookConfig{[][2]float64{}, [][2]float64{}}
+	for i := 1; i <= levels; i++ {
+		spread := mid * 0.002 * float64(i)
+		qty := 100 * float64(i)
+		result.Bids = append(result.Bids, [2]float64{round(mid-spread, 8), qty})
+		result.Asks = append(result.Asks, [2]float64{round(mid+spread, 8), qty})
+	}
+	return result
+}
+
+func round(value float64, precision int) float64 {
+	p := math.Pow(10, float64(precision))
+	return math.Floor(value*p+0.5) / p
+}
+
+type Simulator struct {
+	config    Config
+	exchanges map[string]*Exchange
+}
+
+func NewSimulator(config Config) *Simulator {
+	exchanges := map[string]*Exchange{}
+	for name, exconfig := range config.Exchanges {
+		exchanges[name] = NewExchange(name, exconfig, config.Confirmations)
+	}
+	return &Simulator{config, exchanges}
+}
+
+func (self *Simulator) Exchange(name string) (*Exchange, bool) {
+	ex, found := self.exchanges[name]
+	return ex, found
+}
+
+// Run serves each configured exchange in its own dialect and returns
+// once they are listening
+func (self *Simulator) Run() error {
+	for name, ex := range self.exchanges {
+		var r *gin.Engine
+		var port int
+		switch name {
+		case "binance":
+			r, port = NewBinanceServer(ex).r, BINANCE_PORT
+		case "bittrex":
+			r, port = NewBittrexServer(ex).r, BITTREX_PORT
+		default:
+			return errors.New(fmt.Sprintf("Simulator doesn't support %s", name))
+		}
+		addAdminRoutes(r, ex)
+		listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+		if err != nil {
+			return err
+		}
+		log.Printf("Simulated %s listening on %s", name, listener.Addr())
+		go func(name string, r *gin.Engine) {
+			if err := http.Serve(listener, r); err != nil {
+				log.Printf("Simulated %s stopped: %s", name, err)
+			}
+		}(name, r)
+		if self.config.BlockTime > 0 {
+			go runBlockClock(ex, self.config.BlockTime)
+		}
+	}
+	return nil
+}
+
+func runBlockClock(ex *Exchange, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	for range ticker.C {
+		ex.NewBlock()
+	}
+}
+
+// addAdminRoutes lets tests drive the exchange: deposits coming in,
+// liquidity being added and blocks being mined
+func addAdminRoutes(r *gin.Engine, ex *Exchange) {
+	r.POST("/sim/deposit", func(c *gin.Context) {
+		amount, err := strconv.ParseFloat(c.Query("amount"), 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "reason": "amount is required"})
+			return
+		}
+		deposit, err := ex.Deposit(c.Query("asset"), amount, c.Query("tx"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "reason": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": deposit})
+	})
+	r.POST("/sim/orderbook", func(c *gin.Context) {
+		pair, err := ParsePair(c.Query("pair"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "reason": err.Error()})
+			return
+		}
+		price, perr := strconv.ParseFloat(c.Query("price"), 64)
+		qty, qerr := strconv.ParseFloat(c.Query("qty"), 64)
+		side := c.Query("side")
+		if perr != nil || qerr != nil || (side != SIDE_BUY && side != SIDE_SELL) {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "reason": "side, price and qty are required"})
+			return
+		}
+		ex.AddLiquidity(pair, side, price, qty)
+		c.JSON(http.StatusOK, gin.H{"success": true})
+	})
+	r.POST("/sim/block", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": ex.NewBlock()})
+	})
+	r.GET("/sim/balances", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": ex.Balances()})
+	})
+}